@@ -0,0 +1,349 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Item analysis of the recorded answers. For every question the report
+// gives the difficulty index (share of correct responses), the corrected
+// point-biserial discrimination and how often each option was chosen,
+// overall and by the top and bottom scorers.
+
+const (
+	itemGroupFraction = 0.27 // size of the high and low scoring groups
+	itemMinResponses  = 5    // responses needed before a question is flagged
+)
+
+// OptionStats describes how often one option of a question was chosen
+type OptionStats struct {
+	Index    int     `json:"index"`
+	Text     string  `json:"text"`
+	IsKey    bool    `json:"is_key"`
+	Count    int     `json:"count"`
+	Rate     float64 `json:"rate"`
+	HighRate float64 `json:"high_rate"`
+	LowRate  float64 `json:"low_rate"`
+}
+
+// ItemStats is the item analysis for a single question
+type ItemStats struct {
+	QuestionID     string        `json:"question_id"`
+	Category       string        `json:"category"`
+	Module         string        `json:"module"`
+	Question       string        `json:"question"`
+	Answer         int           `json:"answer"`
+	Responses      int           `json:"responses"`
+	Difficulty     float64       `json:"difficulty"`     // p-value
+	Discrimination float64       `json:"discrimination"` // point-biserial
+	Options        []OptionStats `json:"options"`
+	Flags          []string      `json:"flags,omitempty"`
+}
+
+type itemResponse struct {
+	chosen  int
+	correct bool
+	rest    float64 // share of the attempt's other questions answered correctly
+}
+
+// analyseItems computes item statistics for every question from the
+// attempts of all users
+func analyseItems(questions []Question, users []User) []ItemStats {
+	responses := make(map[string][]itemResponse)
+
+	for _, u := range users {
+		for _, a := range u.Attempts {
+			if a.tampered {
+				continue
+			}
+			correct := a.Correct()
+			others := len(a.Answers) - 1
+
+			for _, ans := range a.Answers {
+				r := itemResponse{chosen: ans.Chosen, correct: ans.Correct}
+				if others > 0 {
+					rest := correct
+					if ans.Correct {
+						rest--
+					}
+					r.rest = float64(rest) / float64(others)
+				}
+				responses[ans.QuestionID] = append(responses[ans.QuestionID], r)
+			}
+		}
+	}
+
+	var stats []ItemStats
+	for _, q := range questions {
+		stats = append(stats, analyseItem(q, responses[q.ID]))
+	}
+
+	sort.SliceStable(stats, func(i, j int) bool {
+		if stats[i].Category != stats[j].Category {
+			return stats[i].Category < stats[j].Category
+		}
+		return stats[i].Module < stats[j].Module
+	})
+
+	return stats
+}
+
+func analyseItem(q Question, responses []itemResponse) ItemStats {
+	s := ItemStats{
+		QuestionID: q.ID,
+		Category:   q.Category,
+		Module:     q.Module,
+		Question:   q.Question,
+		Answer:     q.Answer,
+		Responses:  len(responses),
+	}
+	for i, opt := range q.Options {
+		s.Options = append(s.Options, OptionStats{Index: i, Text: opt, IsKey: i == q.Answer})
+	}
+
+	if len(responses) == 0 {
+		return s
+	}
+	n := float64(len(responses))
+
+	// Difficulty and option frequencies
+	var sumCorrect, sumWrong, sumAll float64
+	var nCorrect, nWrong int
+	for _, r := range responses {
+		if r.chosen >= 0 && r.chosen < len(s.Options) {
+			s.Options[r.chosen].Count++
+		}
+		if r.correct {
+			nCorrect++
+			sumCorrect += r.rest
+		} else {
+			nWrong++
+			sumWrong += r.rest
+		}
+		sumAll += r.rest
+	}
+	for i := range s.Options {
+		s.Options[i].Rate = float64(s.Options[i].Count) / n
+	}
+	p := float64(nCorrect) / n
+	s.Difficulty = p
+
+	// Point-biserial correlation between the item and the rest score
+	mean := sumAll / n
+	var variance float64
+	for _, r := range responses {
+		variance += (r.rest - mean) * (r.rest - mean)
+	}
+	sd := math.Sqrt(variance / n)
+	if sd > 0 && nCorrect > 0 && nWrong > 0 {
+		meanCorrect := sumCorrect / float64(nCorrect)
+		meanWrong := sumWrong / float64(nWrong)
+		s.Discrimination = (meanCorrect - meanWrong) / sd * math.Sqrt(p*(1-p))
+	}
+
+	// Option choices of the high and low scoring groups
+	sorted := append([]itemResponse(nil), responses...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].rest > sorted[j].rest })
+
+	group := int(math.Round(n * itemGroupFraction))
+	if group < 1 {
+		group = 1
+	}
+	if group > len(sorted)/2 {
+		group = len(sorted) / 2
+	}
+	if group > 0 {
+		high, low := sorted[:group], sorted[len(sorted)-group:]
+		for i := range s.Options {
+			s.Options[i].HighRate = chosenRate(high, i)
+			s.Options[i].LowRate = chosenRate(low, i)
+		}
+	}
+
+	if s.Responses >= itemMinResponses {
+		s.Flags = itemFlags(s)
+	}
+
+	return s
+}
+
+func chosenRate(responses []itemResponse, option int) float64 {
+	count := 0
+	for _, r := range responses {
+		if r.chosen == option {
+			count++
+		}
+	}
+	return float64(count) / float64(len(responses))
+}
+
+func itemFlags(s ItemStats) []string {
+	var flags []string
+
+	for _, opt := range s.Options {
+		if !opt.IsKey && opt.HighRate > opt.LowRate {
+			flags = append(flags, trf(
+				"high scorers chose option %d more than low scorers (%.0f%% vs %.0f%%) - check the answer key",
+				opt.Index+1, opt.HighRate*100, opt.LowRate*100))
+		}
+	}
+	if s.Discrimination < 0 {
+		flags = append(flags, tr("negative discrimination"))
+	}
+	if s.Difficulty < 0.2 {
+		flags = append(flags, tr("very hard (p < 0.20)"))
+	} else if s.Difficulty > 0.95 {
+		flags = append(flags, tr("very easy (p > 0.95)"))
+	}
+
+	return flags
+}
+
+// itemReportLines formats the analysis for the terminal
+func itemReportLines(stats []ItemStats) []pageLine {
+	flagged := 0
+	for _, s := range stats {
+		if len(s.Flags) > 0 {
+			flagged++
+		}
+	}
+
+	lines := []pageLine{
+		{ColorCyan, trf("%d questions analysed, %d flagged for review", len(stats), flagged)},
+		{ColorWhite, tr("p = difficulty index (share correct), r = point-biserial discrimination")},
+		{ColorWhite, tr("H/L = share of the top/bottom 27% of scorers choosing the option")},
+	}
+
+	lastModule := ""
+	for _, s := range stats {
+		if module := s.Category + " - " + s.Module; module != lastModule {
+			lines = append(lines, pageLine{}, pageLine{ColorCyan + ColorBold, module})
+			lastModule = module
+		}
+
+		lines = append(lines, pageLine{ColorWhite + ColorBold, fmt.Sprintf("  [%s] %s", s.QuestionID, s.Question)})
+		if s.Responses == 0 {
+			lines = append(lines, pageLine{ColorYellow, "      " + tr("no responses yet")})
+			continue
+		}
+
+		summary := fmt.Sprintf("      n=%d  p=%.2f  r=%.2f", s.Responses, s.Difficulty, s.Discrimination)
+		if s.Responses < itemMinResponses {
+			summary += "  " + trf("(fewer than %d responses, not flagged)", itemMinResponses)
+		}
+		lines = append(lines, pageLine{ColorYellow, summary})
+
+		for _, opt := range s.Options {
+			mark, color := " ", ColorWhite
+			if opt.IsKey {
+				mark, color = "✓", ColorGreen
+			}
+			lines = append(lines, pageLine{color, fmt.Sprintf("    %s %d. %-30s %5.1f%%  H %5.1f%%  L %5.1f%%",
+				mark, opt.Index+1, fit(opt.Text, 30), opt.Rate*100, opt.HighRate*100, opt.LowRate*100)})
+		}
+		for _, flag := range s.Flags {
+			lines = append(lines, pageLine{ColorRed, "      ⚠ " + flag})
+		}
+	}
+
+	return lines
+}
+
+// exportItemAnalysis writes the analysis to the reports directory as CSV
+// or JSON and returns the file path
+func exportItemAnalysis(stats []ItemStats, format string) (string, error) {
+	dir := filepath.Join(cacheDir, "reports")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("item-analysis-%s.%s", time.Now().Format("20060102-150405"), format))
+
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(stats, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return path, os.WriteFile(path, data, 0644)
+	case "csv":
+		f, err := os.Create(path)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		return path, writeItemCSV(f, stats)
+	}
+
+	return "", fmt.Errorf("unknown export format %q", format)
+}
+
+func writeItemCSV(f *os.File, stats []ItemStats) error {
+	maxOptions := 0
+	for _, s := range stats {
+		if len(s.Options) > maxOptions {
+			maxOptions = len(s.Options)
+		}
+	}
+
+	w := csv.NewWriter(f)
+	header := []string{"category", "module", "question_id", "question", "answer", "responses", "difficulty", "discrimination", "flags"}
+	for i := 1; i <= maxOptions; i++ {
+		header = append(header, fmt.Sprintf("option_%d_rate", i), fmt.Sprintf("option_%d_high", i), fmt.Sprintf("option_%d_low", i))
+	}
+	w.Write(header)
+
+	for _, s := range stats {
+		row := []string{
+			s.Category, s.Module, s.QuestionID, s.Question,
+			fmt.Sprintf("%d", s.Answer+1),
+			fmt.Sprintf("%d", s.Responses),
+			fmt.Sprintf("%.3f", s.Difficulty),
+			fmt.Sprintf("%.3f", s.Discrimination),
+			strings.Join(s.Flags, "; "),
+		}
+		for i := 0; i < maxOptions; i++ {
+			if i < len(s.Options) {
+				opt := s.Options[i]
+				row = append(row, fmt.Sprintf("%.3f", opt.Rate), fmt.Sprintf("%.3f", opt.HighRate), fmt.Sprintf("%.3f", opt.LowRate))
+			} else {
+				row = append(row, "", "", "")
+			}
+		}
+		w.Write(row)
+	}
+
+	w.Flush()
+	return w.Error()
+}
+
+// questionAnalytics shows the item analysis report in the admin panel
+func questionAnalytics() {
+	stats := analyseItems(quizData.Questions, loadAllUsers())
+	showPager(tr("Question Analytics"), ColorBlue, itemReportLines(stats))
+
+	clearScreen()
+	printBoxHeader(tr("Export Question Analytics"), ColorBlue)
+	fmt.Println()
+	printColor(ColorYellow, tr("Export report as csv or json (Enter to skip): "))
+
+	format := strings.ToLower(readInput())
+	if format == "" {
+		return
+	}
+
+	path, err := exportItemAnalysis(stats, format)
+	if err != nil {
+		printColor(ColorRed, "\n"+trf("✗ Export failed: %v", err)+"\n")
+	} else {
+		printColor(ColorGreen+ColorBold, "\n"+trf("✓ Report saved to %s", path)+"\n")
+	}
+	printColor(ColorYellow, tr("Press Enter to continue..."))
+	readInput()
+}
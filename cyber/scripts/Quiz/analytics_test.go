@@ -0,0 +1,65 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestAnalyseItems(t *testing.T) {
+	useLanguage(t, sourceLanguage)
+	questions := []Question{
+		{ID: "q1", Question: "Miskeyed", Options: []string{"a", "b", "c"}, Answer: 0, Category: "Networking", Module: "Ports"},
+		{ID: "q2", Question: "Other", Options: []string{"a", "b"}, Answer: 0, Category: "Networking", Module: "Ports"},
+		{ID: "q3", Question: "Unanswered", Options: []string{"a", "b"}, Category: "Linux", Module: "Shell"},
+	}
+	// The learners who get q2 right choose option b of q1, which is wrong
+	var users []User
+	for i := 0; i < 6; i++ {
+		strong := i < 3
+		chosen, other := 0, 1
+		if strong {
+			chosen, other = 1, 0
+		}
+		users = append(users, User{Attempts: []Attempt{{Answers: []AnswerRecord{
+			{QuestionID: "q1", Chosen: chosen, Correct: !strong},
+			{QuestionID: "q2", Chosen: other, Correct: strong},
+		}}}})
+	}
+	tampered := Attempt{Answers: []AnswerRecord{{QuestionID: "q3", Chosen: 0, Correct: true}}, tampered: true}
+	users[0].Attempts = append(users[0].Attempts, tampered)
+
+	stats := analyseItems(questions, users)
+	if got := []string{stats[0].QuestionID, stats[1].QuestionID, stats[2].QuestionID}; !reflect.DeepEqual(got, []string{"q3", "q1", "q2"}) {
+		t.Fatalf("stats in order %q", got)
+	}
+	if stats[0].Responses != 0 {
+		t.Errorf("a tampered attempt was counted: %+v", stats[0])
+	}
+
+	q1 := stats[1]
+	if q1.Responses != 6 || q1.Difficulty != 0.5 || math.Abs(q1.Discrimination+1) > 1e-9 {
+		t.Errorf("q1: n=%d p=%v r=%v", q1.Responses, q1.Difficulty, q1.Discrimination)
+	}
+	if q1.Options[1].HighRate != 1 || q1.Options[1].LowRate != 0 || q1.Options[0].Rate != 0.5 {
+		t.Errorf("q1 options %+v", q1.Options)
+	}
+	want := []string{
+		"high scorers chose option 2 more than low scorers (100% vs 0%) - check the answer key",
+		"negative discrimination",
+	}
+	if !reflect.DeepEqual(q1.Flags, want) {
+		t.Errorf("q1 flags %q, want %q", q1.Flags, want)
+	}
+
+	// Fewer responses than itemMinResponses are not flagged
+	if stats := analyseItems(questions, users[:4]); stats[1].Flags != nil {
+		t.Errorf("flagged on 4 responses: %q", stats[1].Flags)
+	}
+
+	// Flags follow the interface language
+	currentLanguage = "es"
+	if flags := analyseItems(questions, users)[1].Flags; flags[1] != "discriminación negativa" {
+		t.Errorf("Spanish flags %q", flags)
+	}
+}
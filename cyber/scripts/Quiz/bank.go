@@ -0,0 +1,311 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// Sealed question banks. A sealed bank keeps the question text and
+// options readable but replaces everything that gives an answer away -
+// the answer, explanations, command steps and lab checks - with an AES-GCM
+// encrypted value bound to the question ID; in full mode the whole bank is
+// encrypted instead. The key is derived from a passphrase the admin types
+// when sealing or, for banks handed out to classrooms, from a distribution
+// key given in $CYBER_QUIZ_BANK_KEY. Neither is stored: the bank only
+// holds an encrypted check value that detects a wrong key, and the quiz
+// asks for the passphrase on startup unless $CYBER_QUIZ_BANK_KEY is set.
+// Sealing keeps the answers out of the file; whoever can run the quiz
+// with the key can still grade, and so read, them.
+// The shipped questions.json can be replaced by one sealed with "seal-bank
+// -all" before building, so the binary holds no answers either.
+// Plaintext banks keep working for development.
+
+const (
+	sealedBankFormat = "cyber-quiz-sealed-bank/v1"
+	bankKeyEnv       = "CYBER_QUIZ_BANK_KEY"
+	bankCheckText    = "cyber-quiz"
+)
+
+// sealedBank is the on-disk form of a sealed questions.json
+type sealedBank struct {
+	Format    string            `json:"format"`
+	KeySource string            `json:"key_source"` // "passphrase" or "distribution"; "admin" in older banks
+	Full      bool              `json:"full,omitempty"`
+	Salt      string            `json:"salt"`
+	Check     string            `json:"check"` // known value, detects a wrong key
+	Questions []json.RawMessage `json:"questions,omitempty"`
+	Removed   []string          `json:"removed,omitempty"`
+	Base      map[string]string `json:"base,omitempty"`
+	Bank      string            `json:"bank,omitempty"`
+}
+
+// bankSealing remembers how the loaded bank was sealed, so that saving
+// after an admin edit seals it again the same way
+type bankSealing struct {
+	source string
+	full   bool
+	salt   []byte
+	key    []byte
+}
+
+// sealedSecrets are the fields of a question that give its answer away
+type sealedSecrets struct {
+	Answer       int               `json:"answer"`
+	Explanation  string            `json:"explanation,omitempty"`
+	Steps        []SimStep         `json:"steps,omitempty"`
+	Lab          *Lab              `json:"lab,omitempty"`
+	Explanations map[string]string `json:"explanations,omitempty"` // translated explanations by language
+}
+
+var (
+	currentSealing *bankSealing
+
+	// typedPassphrase is the last bank passphrase typed, tried first on
+	// the next sealed bank so the quiz asks only once
+	typedPassphrase string
+)
+
+// bankPassphrase returns the key of a sealed bank: the distribution key
+// from the environment or, for banks sealed with a passphrase (or the
+// admin password in older versions), one typed at the terminal
+func bankPassphrase(source string) (string, error) {
+	if key := os.Getenv(bankKeyEnv); key != "" {
+		return key, nil
+	}
+	if source == "distribution" {
+		return "", fmt.Errorf("the bank is sealed with a distribution key; set %s", bankKeyEnv)
+	}
+	passphrase := readSecret("Question bank passphrase: ")
+	if passphrase == "" {
+		return "", fmt.Errorf("the bank is sealed with a passphrase; type it or set %s", bankKeyEnv)
+	}
+	typedPassphrase = passphrase
+	return passphrase, nil
+}
+
+// unlockBank derives the key of a sealed bank and checks it
+func unlockBank(bank sealedBank) (*bankSealing, error) {
+	salt, err := base64.StdEncoding.DecodeString(bank.Salt)
+	if err != nil {
+		return nil, err
+	}
+	s := &bankSealing{source: bank.KeySource, full: bank.Full, salt: salt}
+	opens := func(passphrase string) bool {
+		s.key = deriveKey(passphrase, salt)
+		check, err := openWithKey(s.key, bank.Check, []byte("check"))
+		return err == nil && string(check) == bankCheckText
+	}
+
+	if typedPassphrase != "" && os.Getenv(bankKeyEnv) == "" && opens(typedPassphrase) {
+		return s, nil
+	}
+	passphrase, err := bankPassphrase(bank.KeySource)
+	if err != nil {
+		return nil, err
+	}
+	if !opens(passphrase) {
+		return nil, errors.New("wrong key for the sealed question bank")
+	}
+	return s, nil
+}
+
+// newBankSealing asks for a new passphrase, twice, unless the key comes
+// from the environment
+func newBankSealing(source string, full bool) (*bankSealing, error) {
+	passphrase := os.Getenv(bankKeyEnv)
+	if source == "distribution" && passphrase == "" {
+		return nil, fmt.Errorf("set the distribution key in %s", bankKeyEnv)
+	}
+	if passphrase == "" {
+		passphrase = readSecret("New question bank passphrase: ")
+		if len(passphrase) < 8 {
+			return nil, errors.New("the passphrase must be at least 8 characters")
+		}
+		if readSecret("Type it again: ") != passphrase {
+			return nil, errors.New("the passphrases don't match")
+		}
+	}
+	salt := randomBytes(16)
+	return &bankSealing{source: source, full: full, salt: salt, key: deriveKey(passphrase, salt)}, nil
+}
+
+func answerAD(id string) []byte {
+	return []byte("answer:" + id)
+}
+
+// sealBank encodes the questions in the sealed format
+func sealBank(data QuizData, s *bankSealing) ([]byte, error) {
+	bank := sealedBank{
+		Format:    sealedBankFormat,
+		KeySource: s.source,
+		Full:      s.full,
+		Salt:      base64.StdEncoding.EncodeToString(s.salt),
+	}
+
+	var err error
+	if bank.Check, err = sealWithKey(s.key, []byte(bankCheckText), []byte("check")); err != nil {
+		return nil, err
+	}
+
+	if s.full {
+		plain, err := json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+		if bank.Bank, err = sealWithKey(s.key, plain, []byte("bank")); err != nil {
+			return nil, err
+		}
+		return json.MarshalIndent(bank, "", "  ")
+	}
+
+	bank.Removed, bank.Base = data.Removed, data.Base
+	for _, q := range data.Questions {
+		raw, err := json.Marshal(q)
+		if err != nil {
+			return nil, err
+		}
+		var fields map[string]json.RawMessage
+		json.Unmarshal(raw, &fields)
+		for _, name := range []string{"answer", "explanation", "steps", "lab"} {
+			delete(fields, name)
+		}
+
+		secrets := sealedSecrets{Answer: q.Answer, Explanation: q.Explanation, Steps: q.Steps, Lab: q.Lab}
+		if len(q.Translations) > 0 {
+			translations := make(map[string]QuestionText)
+			for lang, t := range q.Translations {
+				if t.Explanation != "" {
+					if secrets.Explanations == nil {
+						secrets.Explanations = make(map[string]string)
+					}
+					secrets.Explanations[lang] = t.Explanation
+					t.Explanation = ""
+				}
+				translations[lang] = t
+			}
+			fields["translations"], _ = json.Marshal(translations)
+		}
+		plain, err := json.Marshal(secrets)
+		if err != nil {
+			return nil, err
+		}
+		sealed, err := sealWithKey(s.key, plain, answerAD(q.ID))
+		if err != nil {
+			return nil, err
+		}
+		fields["sealed"], _ = json.Marshal(sealed)
+
+		raw, err = json.Marshal(fields)
+		if err != nil {
+			return nil, err
+		}
+		bank.Questions = append(bank.Questions, raw)
+	}
+
+	return json.MarshalIndent(bank, "", "  ")
+}
+
+// openBank decodes a plaintext or sealed bank. The returned sealing is
+// nil for plaintext banks.
+func openBank(raw []byte) (QuizData, *bankSealing, error) {
+	var data QuizData
+
+	var bank sealedBank
+	if err := json.Unmarshal(raw, &bank); err != nil {
+		return data, nil, err
+	}
+	if bank.Format == "" {
+		err := json.Unmarshal(raw, &data)
+		return data, nil, err
+	}
+	if bank.Format != sealedBankFormat {
+		return data, nil, fmt.Errorf("unsupported bank format %q", bank.Format)
+	}
+
+	s, err := unlockBank(bank)
+	if err != nil {
+		return data, nil, err
+	}
+
+	if bank.Full {
+		plain, err := openWithKey(s.key, bank.Bank, []byte("bank"))
+		if err != nil {
+			return data, nil, err
+		}
+		err = json.Unmarshal(plain, &data)
+		return data, s, err
+	}
+
+	for _, rawQuestion := range bank.Questions {
+		var q Question
+		var sealed struct {
+			Sealed       string `json:"sealed"`
+			SealedAnswer string `json:"sealed_answer"` // older banks sealed only the answer
+		}
+		if err := json.Unmarshal(rawQuestion, &q); err != nil {
+			return data, nil, err
+		}
+		json.Unmarshal(rawQuestion, &sealed)
+		if err := openSecrets(&q, s.key, sealed.Sealed, sealed.SealedAnswer); err != nil {
+			return data, nil, fmt.Errorf("question %s: %w", q.ID, err)
+		}
+		data.Questions = append(data.Questions, q)
+	}
+	data.Removed, data.Base = bank.Removed, bank.Base
+
+	return data, s, nil
+}
+
+// openSecrets decrypts the sealed fields of a question back into it
+func openSecrets(q *Question, key []byte, sealed, sealedAnswer string) error {
+	if sealed == "" {
+		answer, err := openWithKey(key, sealedAnswer, answerAD(q.ID))
+		if err != nil {
+			return fmt.Errorf("answer does not decrypt: %w", err)
+		}
+		q.Answer, err = strconv.Atoi(string(answer))
+		return err
+	}
+
+	plain, err := openWithKey(key, sealed, answerAD(q.ID))
+	if err != nil {
+		return fmt.Errorf("answer does not decrypt: %w", err)
+	}
+	var secrets sealedSecrets
+	if err := json.Unmarshal(plain, &secrets); err != nil {
+		return err
+	}
+	q.Answer, q.Explanation, q.Steps, q.Lab = secrets.Answer, secrets.Explanation, secrets.Steps, secrets.Lab
+	for lang, explanation := range secrets.Explanations {
+		t := q.Translations[lang]
+		t.Explanation = explanation
+		q.Translations[lang] = t
+	}
+	return nil
+}
+
+// encodeQuestions returns the user layer's questions.json contents, sealed
+// the same way as the loaded bank
+func encodeQuestions() ([]byte, error) {
+	data := userLayerData()
+	if currentSealing != nil {
+		return sealBank(data, currentSealing)
+	}
+	return json.MarshalIndent(data, "", "  ")
+}
+
+// bankDescription describes how the installed bank is stored
+func bankDescription() string {
+	if currentSealing == nil {
+		return "plaintext"
+	}
+	what := "answers sealed"
+	if currentSealing.full {
+		what = "fully sealed"
+	}
+	return fmt.Sprintf("%s with the %s key", what, currentSealing.source)
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// secretBank has an answer-revealing value in every field that can hold
+// one, each containing "SECRET"
+func secretBank() QuizData {
+	return QuizData{
+		Questions: []Question{
+			{
+				ID: "q1", Question: "Which port does SSH use?", Options: []string{"21", "22"}, Answer: 1,
+				Category: "Networking", Module: "Ports", Explanation: "SECRET: SSH listens on 22",
+				Translations: map[string]QuestionText{
+					"es": {Question: "¿Qué puerto usa SSH?", Explanation: "SECRET: SSH escucha en el 22"},
+					"fr": {Question: "Quel port utilise SSH ?"},
+				},
+			},
+			{
+				ID: "q2", Question: "List the files", Category: "Linux", Module: "Shell",
+				Steps: []SimStep{{Task: "List them", Accept: []string{"ls SECRET"}, Output: "SECRET.txt"}},
+			},
+			{
+				ID: "q3", Question: "Set the hostname", Category: "Cisco", Module: "Labs",
+				Lab: &Lab{Device: "router", Checks: []LabCheck{{Task: "Hostname", Line: "hostname SECRET"}}, Solution: []string{"hostname SECRET"}},
+			},
+		},
+		Removed: []string{"old"},
+		Base:    map[string]string{"q1": "hash"},
+	}
+}
+
+func TestSealBankRoundTrip(t *testing.T) {
+	t.Setenv(bankKeyEnv, "distribution-key")
+	for _, full := range []bool{false, true} {
+		s, err := newBankSealing("distribution", full)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := secretBank()
+		raw, err := sealBank(data, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(raw), "SECRET") {
+			t.Errorf("full=%v: the sealed bank shows an answer-revealing value:\n%s", full, raw)
+		}
+		for _, field := range []string{`"answer"`, `"explanation"`, `"steps"`, `"lab"`} {
+			if strings.Contains(string(raw), field) {
+				t.Errorf("full=%v: the sealed bank has a %s field", full, field)
+			}
+		}
+
+		opened, sealing, err := openBank(raw)
+		if err != nil {
+			t.Fatalf("full=%v: %v", full, err)
+		}
+		if sealing == nil || sealing.full != full || sealing.source != "distribution" {
+			t.Errorf("full=%v: sealing %+v", full, sealing)
+		}
+		if !reflect.DeepEqual(opened, data) {
+			t.Errorf("full=%v: round trip changed the bank:\n got %+v\nwant %+v", full, opened, data)
+		}
+	}
+}
+
+func TestOpenBankKeys(t *testing.T) {
+	t.Setenv(bankKeyEnv, "right-key")
+	s, err := newBankSealing("distribution", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := sealBank(secretBank(), s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(bankKeyEnv, "wrong-key")
+	if _, _, err := openBank(raw); err == nil || !strings.Contains(err.Error(), "wrong key") {
+		t.Errorf("wrong key: %v", err)
+	}
+	t.Setenv(bankKeyEnv, "")
+	if _, _, err := openBank(raw); err == nil || !strings.Contains(err.Error(), bankKeyEnv) {
+		t.Errorf("missing distribution key: %v", err)
+	}
+	if _, err := newBankSealing("distribution", false); err == nil {
+		t.Error("sealed with a distribution key without one")
+	}
+
+	// A sealed value moved onto another question does not open
+	var bank sealedBank
+	json.Unmarshal(raw, &bank)
+	var first, second map[string]json.RawMessage
+	json.Unmarshal(bank.Questions[0], &first)
+	json.Unmarshal(bank.Questions[1], &second)
+	first["sealed"] = second["sealed"]
+	bank.Questions[0], _ = json.Marshal(first)
+	moved, _ := json.Marshal(bank)
+	t.Setenv(bankKeyEnv, "right-key")
+	if _, _, err := openBank(moved); err == nil || !strings.Contains(err.Error(), "question q1") {
+		t.Errorf("moved answer: %v", err)
+	}
+}
+
+func TestOpenBankFormats(t *testing.T) {
+	t.Setenv(bankKeyEnv, "key")
+	plain, _ := json.Marshal(QuizData{Questions: []Question{{ID: "p1", Answer: 2}}})
+	data, sealing, err := openBank(plain)
+	if err != nil || sealing != nil || data.Questions[0].Answer != 2 {
+		t.Errorf("plaintext bank: %+v, %+v, %v", data, sealing, err)
+	}
+
+	if _, _, err := openBank([]byte(`{"format": "cyber-quiz-sealed-bank/v9"}`)); err == nil {
+		t.Error("an unknown format was opened")
+	}
+
+	// Banks sealed by older versions hold only the answer, as a number
+	s, _ := newBankSealing("distribution", false)
+	check, _ := sealWithKey(s.key, []byte(bankCheckText), []byte("check"))
+	answer, _ := sealWithKey(s.key, []byte("3"), answerAD("old1"))
+	question, _ := json.Marshal(map[string]any{"id": "old1", "question": "Q?", "sealed_answer": answer})
+	old, _ := json.Marshal(sealedBank{
+		Format: sealedBankFormat, KeySource: "admin", Salt: base64.StdEncoding.EncodeToString(s.salt), Check: check,
+		Questions: []json.RawMessage{question},
+	})
+	data, _, err = openBank(old)
+	if err != nil || data.Questions[0].Answer != 3 {
+		t.Errorf("older sealed bank: %+v, %v", data, err)
+	}
+}
+
+func TestAdminCommand(t *testing.T) {
+	tests := []struct {
+		args  string
+		admin bool
+	}{
+		{"seal-bank", true},
+		{"unseal-bank -out x", true},
+		{"merge a b", true},
+		{"bankdir diff", true},
+		{"bankdir compile", true},
+		{"bankdir pull", true},
+		{"pack build dir", true},
+		{"pack install p", true},
+		{"pack uninstall p", true},
+		{"pack list", false},
+		{"banks", false},
+		{"banks keep q1", true},
+		{"banks reset q1", true},
+		{"sync status", false},
+		{"sync import f", true},
+		{"sync dir d", true},
+		{"questions html", false},
+		{"questions merge f", true},
+		{"questions from-scan f", true},
+		{"questions duplicates", false},
+		{"verify CQ-AAAA-BBBB", false},
+		{"check-integrity", false},
+	}
+	for _, tt := range tests {
+		if got := adminCommand(strings.Fields(tt.args)); got != tt.admin {
+			t.Errorf("adminCommand(%q) = %v, want %v", tt.args, got, tt.admin)
+		}
+	}
+}
+
+func TestRequireAdmin(t *testing.T) {
+	useDataDir(t)
+	adminConfig.PasswordHash = newPasswordHash("admin-password")
+
+	t.Setenv(adminPasswordEnv, "wrong-password")
+	if requireAdmin() || adminPassword != "" {
+		t.Error("a wrong password was accepted")
+	}
+	t.Setenv(adminPasswordEnv, "admin-password")
+	if !requireAdmin() || adminPassword != "admin-password" {
+		t.Error("the right password was refused")
+	}
+
+	t.Setenv(adminPasswordEnv, "wrong-password")
+	for _, args := range [][]string{{"unseal-bank"}, {"questions", "html", "-answers"}} {
+		if code := runCommand(args); code != 1 {
+			t.Errorf("%v with a wrong password exited %d", args, code)
+		}
+	}
+	if _, err := os.Stat(questionsFile); !os.IsNotExist(err) {
+		t.Error("unseal-bank wrote the bank without the admin password")
+	}
+	t.Setenv(adminPasswordEnv, "admin-password")
+	if code := runCommand([]string{"unseal-bank"}); code != 0 {
+		t.Errorf("unseal-bank with the admin password exited %d", code)
+	}
+}
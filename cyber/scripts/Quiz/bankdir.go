@@ -0,0 +1,399 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Question bank directories for authoring in git. Each question is a
+// JSON or YAML file at <dir>/<category>/<module>/<id>.json (or .yaml), so
+// changes can be reviewed one question at a time. The directory is
+// compiled into the runtime bank, and admin panel edits are written back
+// to it, keeping the format of existing files and using the directory's
+// format, chosen at init, for new ones.
+//
+// Write-back only touches questions changed during the session, compared
+// with a baseline taken at load, so files pulled into the directory but
+// not compiled yet are left alone.
+
+// bankDirBaseline maps question IDs to their hash when the bank was loaded
+// or last written back
+var bankDirBaseline map[string]string
+
+// dirComponent makes a category or module name safe as a directory name
+func dirComponent(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`<>:"/\|?*`, r) || r < 32 {
+			return '-'
+		}
+		return r
+	}, name)
+	name = strings.Trim(name, " .")
+	if name == "" {
+		return "_"
+	}
+	return name
+}
+
+// bankDirExt is the file extension new question files are written with
+func bankDirExt() string {
+	if adminConfig.BankDirFormat == "yaml" {
+		return ".yaml"
+	}
+	return ".json"
+}
+
+func isQuestionFile(p string) bool {
+	switch filepath.Ext(p) {
+	case ".json", ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+func questionFilePath(root string, q Question, ext string) string {
+	return filepath.Join(root, dirComponent(q.Category), dirComponent(q.Module), dirComponent(q.ID)+ext)
+}
+
+// encodeQuestionFile encodes a question as JSON, or as YAML for a .yaml or
+// .yml file
+func encodeQuestionFile(q Question, ext string) ([]byte, error) {
+	q.Pack = ""
+	if ext != ".json" {
+		return marshalYAML(q)
+	}
+	data, err := json.MarshalIndent(q, "", "  ")
+	return append(data, '\n'), err
+}
+
+func decodeQuestionFile(data []byte, ext string) (Question, error) {
+	var q Question
+	if ext != ".json" {
+		return q, unmarshalYAML(data, &q)
+	}
+	return q, json.Unmarshal(data, &q)
+}
+
+func writeQuestionFile(target string, q Question) error {
+	data, err := encodeQuestionFile(q, filepath.Ext(target))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(target, data, 0644)
+}
+
+// readBankDir loads every question file under root. Problems that do not
+// stop compilation, like files in the wrong directory, are returned as
+// warnings.
+func readBankDir(root string) (questions []Question, paths map[string]string, warnings []string, err error) {
+	paths = make(map[string]string)
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != root && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !isQuestionFile(p) {
+			return nil
+		}
+
+		rel, _ := filepath.Rel(root, p)
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		q, err := decodeQuestionFile(data, filepath.Ext(p))
+		if err != nil {
+			return fmt.Errorf("%s: %w", rel, err)
+		}
+		if other, dup := paths[q.ID]; dup {
+			return fmt.Errorf("%s: question ID %s is also used by %s", rel, q.ID, other)
+		}
+		if want := questionFilePath(root, q, filepath.Ext(p)); want != p {
+			wantRel, _ := filepath.Rel(root, want)
+			warnings = append(warnings, fmt.Sprintf("%s: expected at %s", rel, wantRel))
+		}
+
+		paths[q.ID] = rel
+		questions = append(questions, q)
+		return nil
+	})
+	return questions, paths, warnings, err
+}
+
+// setBankDirBaseline records the current questions as the state the
+// directory is in sync with
+func setBankDirBaseline() {
+	bankDirBaseline = make(map[string]string)
+	for _, q := range quizData.Questions {
+		if q.Pack == "" {
+			bankDirBaseline[q.ID] = questionHash(q)
+		}
+	}
+}
+
+// writeBackBankDir writes questions added, edited or removed since the
+// baseline to the bank directory
+func writeBackBankDir() error {
+	root := adminConfig.BankDir
+	if root == "" {
+		return nil
+	}
+	if bankDirBaseline == nil {
+		setBankDirBaseline()
+		return nil
+	}
+
+	_, paths, _, err := readBankDir(root)
+	if err != nil {
+		return err
+	}
+
+	present := make(map[string]bool)
+	for _, q := range quizData.Questions {
+		if q.Pack != "" {
+			continue
+		}
+		present[q.ID] = true
+		h := questionHash(q)
+		if bankDirBaseline[q.ID] == h {
+			continue
+		}
+
+		ext := bankDirExt()
+		old, exists := paths[q.ID]
+		if exists {
+			ext = filepath.Ext(old)
+		}
+		target := questionFilePath(root, q, ext)
+		if exists && filepath.Join(root, old) != target {
+			removeQuestionFile(root, old)
+		}
+		if err := writeQuestionFile(target, q); err != nil {
+			return err
+		}
+		bankDirBaseline[q.ID] = h
+	}
+
+	for id := range bankDirBaseline {
+		if present[id] {
+			continue
+		}
+		if old, ok := paths[id]; ok {
+			removeQuestionFile(root, old)
+		}
+		delete(bankDirBaseline, id)
+	}
+	return nil
+}
+
+// removeQuestionFile deletes a question file and its directories once
+// they are empty
+func removeQuestionFile(root, rel string) {
+	p := filepath.Join(root, rel)
+	os.Remove(p)
+	for dir := filepath.Dir(p); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+}
+
+// initBankDir writes the current bank, without pack questions, to root as
+// files with the extension ext
+func initBankDir(root string, force bool, ext string) (int, error) {
+	if existing, _, _, err := readBankDir(root); err == nil && len(existing) > 0 && !force {
+		return 0, fmt.Errorf("%s already contains %d questions; use -force to overwrite", root, len(existing))
+	}
+
+	written := 0
+	for _, q := range quizData.Questions {
+		if q.Pack != "" {
+			continue
+		}
+		if err := writeQuestionFile(questionFilePath(root, q, ext), q); err != nil {
+			return written, err
+		}
+		written++
+	}
+	return written, nil
+}
+
+// compileBankDir checks the directory and returns its questions, together
+// with the pack questions of the loaded bank, which the directory does not
+// hold
+func compileBankDir(root string) ([]Question, []string, error) {
+	questions, _, warnings, err := readBankDir(root)
+	if err != nil {
+		return nil, warnings, err
+	}
+	problems := validateQuestions(questions, nil)
+	for _, q := range questions {
+		if len(q.Images) > 0 {
+			problems = append(problems, imageProblems(q, filepath.Join(root, "media"))...)
+		}
+	}
+	if len(problems) > 0 {
+		return nil, warnings, fmt.Errorf("the bank has errors:\n  %s", strings.Join(problems, "\n  "))
+	}
+
+	sort.SliceStable(questions, func(i, j int) bool {
+		if questions[i].Category != questions[j].Category {
+			return questions[i].Category < questions[j].Category
+		}
+		return questions[i].Module < questions[j].Module
+	})
+
+	for _, q := range quizData.Questions {
+		if q.Pack != "" {
+			questions = append(questions, q)
+		}
+	}
+	return questions, warnings, nil
+}
+
+// installCompiledBank makes compiled questions the runtime bank. Questions
+// read from the directory take the layer of the loaded question they
+// match, or of the lower layer they leave unchanged, and are user edits
+// otherwise.
+func installCompiledBank(questions []Question) {
+	loaded := make(map[string]Question)
+	for _, q := range quizData.Questions {
+		loaded[q.ID] = q
+	}
+	for i := range questions {
+		q := &questions[i]
+		if q.Pack != "" {
+			continue
+		}
+		h := questionHash(*q)
+		if prev, ok := loaded[q.ID]; ok && questionHash(prev) == h {
+			q.layer = prev.layer
+		} else if lower, ok := lowerQuestions[q.ID]; ok && questionHash(lower) == h {
+			q.layer = lower.layer
+		} else {
+			q.layer = layerUser
+		}
+	}
+	quizData.Questions = questions
+	writeQuestionsFile()
+	setBankDirBaseline()
+}
+
+// diffQuestionLines describes how the questions in next differ from those
+// in current
+func diffQuestionLines(current, next []Question) (lines []pageLine, changes int) {
+	before := make(map[string]Question)
+	for _, q := range current {
+		if q.Pack == "" {
+			before[q.ID] = q
+		}
+	}
+	after := make(map[string]Question)
+	var ids []string
+	for _, q := range next {
+		if q.Pack != "" {
+			continue
+		}
+		after[q.ID] = q
+		ids = append(ids, q.ID)
+	}
+	for id := range before {
+		if _, ok := after[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		old, hadOld := before[id]
+		q, hasNew := after[id]
+		switch {
+		case !hadOld:
+			lines = append(lines, pageLine{ColorGreen, fmt.Sprintf("+ %s [%s - %s] %s", id, q.Category, q.Module, stemSummary(q.Question))})
+		case !hasNew:
+			lines = append(lines, pageLine{ColorRed, fmt.Sprintf("- %s [%s - %s] %s", id, old.Category, old.Module, old.Question)})
+		case questionHash(old) != questionHash(q):
+			lines = append(lines, pageLine{ColorYellow, fmt.Sprintf("~ %s [%s - %s] %s", id, q.Category, q.Module, stemSummary(q.Question))})
+			lines = append(lines, questionFieldChanges(old, q)...)
+		default:
+			continue
+		}
+		changes++
+	}
+
+	if changes == 0 {
+		lines = append(lines, pageLine{ColorGreen, "No changes."})
+	}
+	return lines, changes
+}
+
+func questionFieldChanges(old, q Question) []pageLine {
+	var lines []pageLine
+	change := func(field, before, after string) {
+		if before != after {
+			lines = append(lines, pageLine{ColorWhite, fmt.Sprintf("    %s: %q → %q", field, before, after)})
+		}
+	}
+
+	change("category", old.Category, q.Category)
+	change("module", old.Module, q.Module)
+	change("question", old.Question, q.Question)
+	for i := 0; i < len(old.Options) || i < len(q.Options); i++ {
+		var a, b string
+		if i < len(old.Options) {
+			a = old.Options[i]
+		}
+		if i < len(q.Options) {
+			b = q.Options[i]
+		}
+		change(fmt.Sprintf("option %d", i+1), a, b)
+	}
+	change("answer", fmt.Sprint(old.Answer+1), fmt.Sprint(q.Answer+1))
+	change("objectives", strings.Join(old.Objectives, ","), strings.Join(q.Objectives, ","))
+
+	if len(lines) == 0 && !reflect.DeepEqual(old, q) {
+		lines = append(lines, pageLine{ColorWhite, "    other fields changed"})
+	}
+	return lines
+}
+
+// pullBankDir fast-forwards the bank directory's git checkout from a
+// remote, such as a path to the team's repository. The remote and branch
+// may not look like options, so they cannot pass flags such as
+// --upload-pack to git.
+func pullBankDir(root, remote, branch string) error {
+	for _, arg := range []string{remote, branch} {
+		if strings.HasPrefix(arg, "-") {
+			return fmt.Errorf("%q is not a remote or branch name", arg)
+		}
+	}
+	args := []string{"-C", root, "pull", "--ff-only", "--"}
+	if remote != "" {
+		args = append(args, remote)
+		if branch != "" {
+			args = append(args, branch)
+		}
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("git pull failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// useBank installs questions as the loaded bank, with lower as the
+// built-in layer, for the duration of a test
+func useBank(t *testing.T, lower, loaded []Question) {
+	t.Helper()
+	savedData, savedLower, savedConfig, savedFile := quizData, lowerQuestions, adminConfig, questionsFile
+	savedBaseline, savedSealing := bankDirBaseline, currentSealing
+	t.Cleanup(func() {
+		quizData, lowerQuestions, adminConfig, questionsFile = savedData, savedLower, savedConfig, savedFile
+		bankDirBaseline, currentSealing = savedBaseline, savedSealing
+	})
+
+	lowerQuestions = make(map[string]Question)
+	for _, q := range lower {
+		q.layer = layerBuiltin
+		lowerQuestions[q.ID] = q
+	}
+	quizData = QuizData{Questions: loaded}
+	adminConfig = AdminConfig{}
+	questionsFile = filepath.Join(t.TempDir(), "questions.json")
+	currentSealing = nil
+	bankDirBaseline = nil
+}
+
+func testQuestion(id, module string) Question {
+	return Question{
+		ID:       id,
+		Question: "Question " + id + "?",
+		Options:  []string{"Yes", "No"},
+		Category: "Testing",
+		Module:   module,
+	}
+}
+
+func TestBankDirFormats(t *testing.T) {
+	a, b := testQuestion("a1", "One"), testQuestion("b2", "Two")
+	useBank(t, nil, []Question{a, b})
+	root := t.TempDir()
+
+	if _, err := initBankDir(root, false, ".yaml"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := initBankDir(root, false, ".yaml"); err == nil {
+		t.Error("init wrote into a directory that already holds questions")
+	}
+	// Mixed formats in one directory
+	os.Remove(questionFilePath(root, b, ".yaml"))
+	data, _ := encodeQuestionFile(b, ".json")
+	os.WriteFile(questionFilePath(root, b, ".json"), data, 0644)
+
+	questions, paths, warnings, err := readBankDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) > 0 {
+		t.Errorf("unexpected warnings %v", warnings)
+	}
+	want := map[string]string{
+		"a1": filepath.Join("Testing", "One", "a1.yaml"),
+		"b2": filepath.Join("Testing", "Two", "b2.json"),
+	}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("paths %v, want %v", paths, want)
+	}
+	if !reflect.DeepEqual(questions, []Question{a, b}) {
+		t.Errorf("read %+v", questions)
+	}
+
+	// Write-back keeps each file's format and uses the directory's for
+	// new questions
+	adminConfig.BankDir, adminConfig.BankDirFormat = root, "yaml"
+	setBankDirBaseline()
+	a.Question, b.Question = "Edited A?", "Edited B?"
+	c := testQuestion("c3", "One")
+	quizData.Questions = []Question{a, b, c}
+	if err := writeBackBankDir(); err != nil {
+		t.Fatal(err)
+	}
+	_, paths, _, err = readBankDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want["c3"] = filepath.Join("Testing", "One", "c3.yaml")
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("after write-back paths %v, want %v", paths, want)
+	}
+
+	// Moving a question to another module moves its file
+	a.Module = "Two"
+	quizData.Questions = []Question{a, b}
+	if err := writeBackBankDir(); err != nil {
+		t.Fatal(err)
+	}
+	_, paths, _, _ = readBankDir(root)
+	want = map[string]string{
+		"a1": filepath.Join("Testing", "Two", "a1.yaml"),
+		"b2": filepath.Join("Testing", "Two", "b2.json"),
+	}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("after move paths %v, want %v", paths, want)
+	}
+	if _, err := os.Stat(filepath.Join(root, "Testing", "One")); !os.IsNotExist(err) {
+		t.Error("empty module directory was left behind")
+	}
+}
+
+func TestReadBankDirErrors(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "Testing", "One")
+	os.MkdirAll(dir, 0755)
+	os.WriteFile(filepath.Join(dir, "a1.yaml"), []byte("id: a1\ncategory: Testing\nmodule: One\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "moved.yml"), []byte("id: b2\ncategory: Testing\nmodule: Two\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("ignored"), 0644)
+
+	_, _, warnings, err := readBankDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 1 {
+		t.Errorf("warnings %v, want one about moved.yml", warnings)
+	}
+
+	os.WriteFile(filepath.Join(dir, "copy.json"), []byte(`{"id": "a1"}`), 0644)
+	if _, _, _, err := readBankDir(root); err == nil {
+		t.Error("duplicate IDs were accepted")
+	}
+	os.Remove(filepath.Join(dir, "copy.json"))
+
+	os.WriteFile(filepath.Join(dir, "broken.yaml"), []byte("id: [\n"), 0644)
+	if _, _, _, err := readBankDir(root); err == nil {
+		t.Error("a broken YAML file was accepted")
+	}
+}
+
+func TestInstallCompiledBankLayers(t *testing.T) {
+	builtin := testQuestion("b1", "One")
+	edited := testQuestion("b2", "One")
+	useBank(t, []Question{builtin, edited}, nil)
+	for _, q := range []Question{builtin, edited} {
+		q.layer = layerBuiltin
+		quizData.Questions = append(quizData.Questions, q)
+	}
+
+	edited.Question = "Changed?"
+	installCompiledBank([]Question{builtin, edited, testQuestion("n1", "Two")})
+
+	want := map[string]string{"b1": layerBuiltin, "b2": layerUser, "n1": layerUser}
+	for _, q := range quizData.Questions {
+		if q.layer != want[q.ID] {
+			t.Errorf("%s is in the %q layer, want %q", q.ID, q.layer, want[q.ID])
+		}
+	}
+	if data := userLayerData(); len(data.Questions) != 2 {
+		t.Errorf("user layer holds %d questions, want 2", len(data.Questions))
+	}
+}
+
+func TestPullBankDirOptions(t *testing.T) {
+	root := t.TempDir()
+	for _, args := range [][2]string{
+		{"--upload-pack=touch " + filepath.Join(root, "pwned"), ""},
+		{"origin", "-q"},
+	} {
+		if err := pullBankDir(root, args[0], args[1]); err == nil || !strings.Contains(err.Error(), "is not a remote or branch name") {
+			t.Errorf("pull %q: %v", args, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "pwned")); err == nil {
+		t.Error("an option in the remote name was passed to git")
+	}
+}
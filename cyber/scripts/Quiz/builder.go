@@ -0,0 +1,381 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Custom quizzes draw questions from several modules, filtered by tags,
+// difficulty, objective, question type and the learner's history. Each
+// module's share of the questions follows its weight. Learners can save a
+// quiz as a preset, which then appears in the main menu.
+
+// customCategory is the category custom quiz attempts are recorded under
+const customCategory = "Custom"
+
+// Difficulty levels a question can have
+var difficulties = []string{"easy", "medium", "hard"}
+
+// QuizPreset is a saved custom quiz
+type QuizPreset struct {
+	Name       string         `json:"name"`
+	Modules    []PresetModule `json:"modules"`
+	Tags       []string       `json:"tags,omitempty"`       // any of these
+	Difficulty []string       `json:"difficulty,omitempty"` // any of these
+	Objectives []string       `json:"objectives,omitempty"` // any of these
+	Types      []string       `json:"types,omitempty"`      // any of these
+	History    string         `json:"history,omitempty"`    // "", "unseen" or "wrong"
+	Count      int            `json:"count"`                // 0 for every matching question
+}
+
+// PresetModule is a module in a custom quiz with its relative weight
+type PresetModule struct {
+	Category string `json:"category"`
+	Module   string `json:"module"`
+	Weight   int    `json:"weight"`
+}
+
+// questionType describes how a question is answered
+func questionType(q Question) string {
+	if q.Lab != nil {
+		return "lab"
+	}
+	if len(q.Steps) > 0 {
+		return "command-line"
+	}
+	if len(q.Options) == 2 && strings.EqualFold(q.Options[0], "true") && strings.EqualFold(q.Options[1], "false") {
+		return "true-false"
+	}
+	return "multiple-choice"
+}
+
+// anyOf reports whether values and wanted share an element, ignoring
+// case; an empty wanted list matches everything
+func anyOf(values, wanted []string) bool {
+	if len(wanted) == 0 {
+		return true
+	}
+	for _, v := range values {
+		for _, w := range wanted {
+			if strings.EqualFold(v, w) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// answerHistory returns the questions the user has answered, and those
+// they have answered wrongly at least once
+func answerHistory(u *User) (seen, wrong map[string]bool) {
+	seen, wrong = make(map[string]bool), make(map[string]bool)
+	for _, a := range u.Attempts {
+		if a.tampered {
+			continue
+		}
+		for _, ans := range a.Answers {
+			seen[ans.QuestionID] = true
+			if !ans.Correct {
+				wrong[ans.QuestionID] = true
+			}
+		}
+	}
+	return seen, wrong
+}
+
+// presetPools returns, per preset module, the questions passing the filters
+func presetPools(p QuizPreset) [][]Question {
+	seen, wrong := answerHistory(currentUser)
+	pools := make([][]Question, len(p.Modules))
+	for i, m := range p.Modules {
+		for _, q := range getQuestionsByModule(m.Category, m.Module) {
+			switch {
+			case !anyOf(q.Tags, p.Tags),
+				!anyOf([]string{q.Difficulty}, p.Difficulty),
+				!anyOf(q.Objectives, p.Objectives),
+				!anyOf([]string{questionType(q)}, p.Types),
+				p.History == "unseen" && seen[q.ID],
+				p.History == "wrong" && !wrong[q.ID]:
+				continue
+			}
+			pools[i] = append(pools[i], q)
+		}
+	}
+	return pools
+}
+
+// buildPresetQuiz picks the questions for a custom quiz. Modules get a
+// share of the questions in proportion to their weight; a module with too
+// few matching questions gives the rest of its share to the others.
+func buildPresetQuiz(p QuizPreset) []Question {
+	pools := presetPools(p)
+	available := 0
+	for _, pool := range pools {
+		available += len(pool)
+	}
+	count := p.Count
+	if count <= 0 || count > available {
+		count = available
+	}
+
+	quota := make([]int, len(pools))
+	for remaining := count; remaining > 0; {
+		// Share what is left among the modules that still have questions
+		totalWeight := 0
+		for i, m := range p.Modules {
+			if quota[i] < len(pools[i]) {
+				totalWeight += max(m.Weight, 1)
+			}
+		}
+		if totalWeight == 0 {
+			break
+		}
+		given := 0
+		for i, m := range p.Modules {
+			if quota[i] >= len(pools[i]) {
+				continue
+			}
+			share := remaining * max(m.Weight, 1) / totalWeight
+			if share == 0 && given < remaining {
+				share = 1
+			}
+			share = min(share, len(pools[i])-quota[i], remaining-given)
+			quota[i] += share
+			given += share
+		}
+		remaining -= given
+	}
+
+	var questions []Question
+	for i, pool := range pools {
+		pool = append([]Question(nil), pool...)
+		rand.Shuffle(len(pool), func(a, b int) { pool[a], pool[b] = pool[b], pool[a] })
+		questions = append(questions, pool[:quota[i]]...)
+	}
+	rand.Shuffle(len(questions), func(a, b int) { questions[a], questions[b] = questions[b], questions[a] })
+	return questions
+}
+
+// runPreset takes a custom quiz
+func runPreset(p QuizPreset) {
+	runQuiz(customCategory, p.Name, buildPresetQuiz(p), nil)
+}
+
+// presetSummary describes a preset's modules and filters in one line
+func presetSummary(p QuizPreset) string {
+	var parts []string
+	for _, m := range p.Modules {
+		part := m.Category + " - " + m.Module
+		if len(p.Modules) > 1 && m.Weight > 1 {
+			part += fmt.Sprintf(" ×%d", m.Weight)
+		}
+		parts = append(parts, part)
+	}
+	summary := strings.Join(parts, ", ")
+	for _, f := range []struct {
+		name   string
+		values []string
+	}{{"tags", p.Tags}, {"difficulty", p.Difficulty}, {"objectives", p.Objectives}, {"types", p.Types}} {
+		if len(f.values) > 0 {
+			summary += fmt.Sprintf("; %s %s", f.name, strings.Join(f.values, "/"))
+		}
+	}
+	switch p.History {
+	case "unseen":
+		summary += "; " + tr("never seen")
+	case "wrong":
+		summary += "; " + tr("previously wrong")
+	}
+	if p.Count > 0 {
+		summary += "; " + trf("%d questions", p.Count)
+	}
+	return summary
+}
+
+// splitList splits a comma separated answer into trimmed values
+func splitList(s string) []string {
+	var values []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// questionFacets lists the tags, objectives and types found in the bank
+func questionFacets() (tags, objectives, types []string) {
+	seen := make(map[string]bool)
+	add := func(list *[]string, kind, v string) {
+		if v != "" && !seen[kind+v] {
+			seen[kind+v] = true
+			*list = append(*list, v)
+		}
+	}
+	for _, q := range quizData.Questions {
+		for _, t := range q.Tags {
+			add(&tags, "tag", t)
+		}
+		for _, o := range q.Objectives {
+			add(&objectives, "objective", o)
+		}
+		add(&types, "type", questionType(q))
+	}
+	sort.Strings(tags)
+	sort.Strings(objectives)
+	sort.Strings(types)
+	return tags, objectives, types
+}
+
+// customQuiz lets the learner run or delete a preset, or build a new quiz
+func customQuiz() {
+	if len(currentUser.Presets) > 0 {
+		items := []listItem{{label: tr("Build a new quiz")}}
+		for _, p := range currentUser.Presets {
+			items = append(items, listItem{label: p.Name, detail: presetSummary(p), group: tr("Saved presets")})
+		}
+		idx := pickItem(tr("Custom Quiz"), ColorMagenta, items)
+		if idx < 0 {
+			return
+		}
+		if idx > 0 {
+			p := currentUser.Presets[idx-1]
+			printColor(ColorYellow, "\n"+trf("Press Enter to take '%s', or type 'd' to delete it: ", p.Name))
+			if strings.ToLower(readInput()) == "d" {
+				currentUser.Presets = append(currentUser.Presets[:idx-1], currentUser.Presets[idx:]...)
+				saveUser()
+				printColor(ColorGreen, trf("✓ Deleted preset '%s'", p.Name)+"\n")
+				pause(1 * time.Second)
+				return
+			}
+			runPreset(p)
+			return
+		}
+	}
+	buildCustomQuiz()
+}
+
+// buildCustomQuiz asks for the modules, weights and filters of a quiz,
+// offers to save it as a preset and runs it
+func buildCustomQuiz() {
+	clearScreen()
+	printBoxHeader(tr("Custom Quiz"), ColorMagenta)
+	fmt.Println()
+
+	var modules []PresetModule
+	available := getAvailableModules()
+	var categories []string
+	for category := range available {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	for _, category := range categories {
+		mods := available[category]
+		sort.Strings(mods)
+		for _, mod := range mods {
+			modules = append(modules, PresetModule{Category: category, Module: mod, Weight: 1})
+			printColor(ColorCyan, fmt.Sprintf("  %d. ", len(modules)))
+			printColor(ColorWhite, category+" - "+mod+" ")
+			printColor(ColorYellow, trf("(%d questions)", countQuestions(category, mod))+"\n")
+		}
+	}
+	if len(modules) == 0 {
+		printColor(ColorRed, tr("No quiz modules available.")+"\n")
+		printColor(ColorYellow, tr("Press Enter to continue..."))
+		readInput()
+		return
+	}
+
+	var p QuizPreset
+	printColor(ColorYellow, "\n"+tr("Modules, e.g. 1,3 (Enter for all): "))
+	if picks := splitList(readInput()); len(picks) == 0 {
+		p.Modules = modules
+	} else {
+		for _, pick := range picks {
+			n, err := strconv.Atoi(pick)
+			if err != nil || n < 1 || n > len(modules) {
+				printColor(ColorRed, trf("Invalid module number: %s", pick)+"\n")
+				printColor(ColorYellow, tr("Press Enter to continue..."))
+				readInput()
+				return
+			}
+			p.Modules = append(p.Modules, modules[n-1])
+		}
+	}
+
+	if len(p.Modules) > 1 {
+		printColor(ColorCyan, "\n"+tr("Weights decide each module's share of the questions.")+"\n")
+		for i, m := range p.Modules {
+			printColor(ColorYellow, trf("Weight for %s - %s [1]: ", m.Category, m.Module))
+			if n, err := strconv.Atoi(readInput()); err == nil && n > 0 {
+				p.Modules[i].Weight = n
+			}
+		}
+	}
+
+	tags, objectives, types := questionFacets()
+	filter := func(label string, choices []string) []string {
+		if len(choices) == 0 {
+			return nil
+		}
+		printColor(ColorCyan, "\n"+label+": "+strings.Join(choices, ", ")+"\n")
+		printColor(ColorYellow, tr("Comma separated, Enter for any: "))
+		return splitList(readInput())
+	}
+	p.Tags = filter(tr("Tags"), tags)
+	p.Difficulty = filter(tr("Difficulty"), difficulties)
+	p.Objectives = filter(tr("Objectives"), objectives)
+	if len(types) > 1 {
+		p.Types = filter(tr("Question types"), types)
+	}
+
+	printColor(ColorCyan, "\n1. "+tr("All questions")+"  2. "+tr("Never seen")+"  3. "+tr("Previously wrong")+"\n")
+	printColor(ColorYellow, tr("Questions to include [1]: "))
+	switch readInput() {
+	case "2":
+		p.History = "unseen"
+	case "3":
+		p.History = "wrong"
+	}
+
+	p.Count = 10
+	printColor(ColorYellow, "\n"+tr("Number of questions, 0 for all [10]: "))
+	if n, err := strconv.Atoi(readInput()); err == nil && n >= 0 {
+		p.Count = n
+	}
+
+	matching := 0
+	for _, pool := range presetPools(p) {
+		matching += len(pool)
+	}
+	printColor(ColorGreen, "\n"+trf("%d questions match.", matching)+"\n")
+	if matching == 0 {
+		printColor(ColorYellow, tr("Press Enter to continue..."))
+		readInput()
+		return
+	}
+
+	printColor(ColorYellow, tr("Save as a preset? Name (Enter to skip): "))
+	if name := readInput(); name != "" {
+		p.Name = name
+		replaced := false
+		for i, existing := range currentUser.Presets {
+			if existing.Name == name {
+				currentUser.Presets[i], replaced = p, true
+			}
+		}
+		if !replaced {
+			currentUser.Presets = append(currentUser.Presets, p)
+		}
+		saveUser()
+		printColor(ColorGreen, trf("✓ Saved preset '%s'; it is now in the main menu", name)+"\n")
+	} else {
+		p.Name = tr("Custom quiz")
+	}
+
+	runPreset(p)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// moduleQuestions returns n questions in a module, with IDs prefix1..n
+func moduleQuestions(prefix, module string, n int) []Question {
+	var questions []Question
+	for i := 1; i <= n; i++ {
+		questions = append(questions, testQuestion(fmt.Sprintf("%s%d", prefix, i), module))
+	}
+	return questions
+}
+
+func TestQuestionType(t *testing.T) {
+	tests := []struct {
+		q    Question
+		want string
+	}{
+		{Question{Options: []string{"a", "b", "c"}}, "multiple-choice"},
+		{Question{Options: []string{"True", "False"}}, "true-false"},
+		{Question{Options: []string{"False", "True"}}, "multiple-choice"},
+		{Question{Steps: []SimStep{{Task: "ls"}}}, "command-line"},
+		{Question{Lab: &Lab{Device: "router"}, Steps: []SimStep{{Task: "ls"}}}, "lab"},
+	}
+	for _, tt := range tests {
+		if got := questionType(tt.q); got != tt.want {
+			t.Errorf("questionType(%+v) = %q, want %q", tt.q, got, tt.want)
+		}
+	}
+}
+
+func TestPresetPools(t *testing.T) {
+	useDataDir(t)
+	nmap := testQuestion("nmap", "Recon")
+	nmap.Tags, nmap.Difficulty, nmap.Objectives = []string{"nmap", "scanning"}, "hard", []string{"2.1"}
+	whois := testQuestion("whois", "Recon")
+	whois.Tags, whois.Difficulty, whois.Objectives = []string{"OSINT"}, "easy", []string{"2.2"}
+	truth := testQuestion("truth", "Recon")
+	truth.Options, truth.Difficulty = []string{"True", "False"}, "easy"
+	shell := testQuestion("shell", "Shell")
+	shell.Steps = []SimStep{{Task: "List the files", Accept: []string{"ls"}}}
+	quizData.Questions = []Question{nmap, whois, truth, shell}
+
+	// nmap answered wrongly, whois rightly; the tampered attempt does not count
+	currentUser = &User{Attempts: []Attempt{
+		{Answers: []AnswerRecord{{QuestionID: "nmap"}, {QuestionID: "whois", Correct: true}}},
+		{Answers: []AnswerRecord{{QuestionID: "truth"}}, tampered: true},
+	}}
+
+	both := []PresetModule{{Category: "Testing", Module: "Recon"}, {Category: "Testing", Module: "Shell"}}
+	tests := []struct {
+		name   string
+		preset QuizPreset
+		want   string
+	}{
+		{"no filters", QuizPreset{}, "nmap whois truth | shell"},
+		{"tag, ignoring case", QuizPreset{Tags: []string{"osint", "Scanning"}}, "nmap whois |"},
+		{"difficulty", QuizPreset{Difficulty: []string{"easy"}}, "whois truth |"},
+		{"objective", QuizPreset{Objectives: []string{"2.1"}}, "nmap |"},
+		{"type", QuizPreset{Types: []string{"true-false", "command-line"}}, "truth | shell"},
+		{"never seen", QuizPreset{History: "unseen"}, "truth | shell"},
+		{"previously wrong", QuizPreset{History: "wrong"}, "nmap |"},
+		{"filters combine", QuizPreset{Difficulty: []string{"easy"}, History: "unseen"}, "truth |"},
+	}
+	for _, tt := range tests {
+		tt.preset.Modules = both
+		var got string
+		for i, pool := range presetPools(tt.preset) {
+			if i > 0 {
+				got += " |"
+			}
+			for _, q := range pool {
+				if got != "" {
+					got += " "
+				}
+				got += q.ID
+			}
+		}
+		if got != tt.want {
+			t.Errorf("%s: %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBuildPresetQuiz(t *testing.T) {
+	useDataDir(t)
+	currentUser = &User{}
+	quizData.Questions = append(append(moduleQuestions("a", "A", 10), moduleQuestions("b", "B", 10)...), moduleQuestions("c", "C", 1)...)
+	a, b, c := PresetModule{"Testing", "A", 3}, PresetModule{"Testing", "B", 1}, PresetModule{"Testing", "C", 5}
+
+	tests := []struct {
+		name    string
+		modules []PresetModule
+		count   int
+		want    map[string]int
+	}{
+		{"by weight", []PresetModule{a, b}, 8, map[string]int{"A": 6, "B": 2}},
+		{"weight 0 counts as 1", []PresetModule{{"Testing", "A", 0}, b}, 4, map[string]int{"A": 2, "B": 2}},
+		{"short module gives its share away", []PresetModule{c, b}, 6, map[string]int{"C": 1, "B": 5}},
+		{"every question", []PresetModule{a, c}, 0, map[string]int{"A": 10, "C": 1}},
+		{"more than there are", []PresetModule{b, c}, 50, map[string]int{"B": 10, "C": 1}},
+		{"small modules still get one", []PresetModule{{"Testing", "A", 20}, b}, 3, map[string]int{"A": 2, "B": 1}},
+	}
+	for _, tt := range tests {
+		questions := buildPresetQuiz(QuizPreset{Modules: tt.modules, Count: tt.count})
+		got := make(map[string]int)
+		var ids []string
+		for _, q := range questions {
+			got[q.Module]++
+			ids = append(ids, q.ID)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: %v, want %v", tt.name, got, tt.want)
+		}
+		sort.Strings(ids)
+		for i := 1; i < len(ids); i++ {
+			if ids[i] == ids[i-1] {
+				t.Errorf("%s: %s asked twice", tt.name, ids[i])
+			}
+		}
+	}
+}
+
+func TestPresetSummary(t *testing.T) {
+	useLanguage(t, sourceLanguage)
+	p := QuizPreset{
+		Modules: []PresetModule{{"Networking", "Ports", 2}, {"Linux", "Shell", 1}},
+		Tags:    []string{"nmap", "dns"}, Difficulty: []string{"hard"}, History: "wrong", Count: 10,
+	}
+	want := "Networking - Ports ×2, Linux - Shell; tags nmap/dns; difficulty hard; previously wrong; 10 questions"
+	if got := presetSummary(p); got != want {
+		t.Errorf("summary %q, want %q", got, want)
+	}
+	if got := splitList(" nmap, ,dns ,"); !reflect.DeepEqual(got, []string{"nmap", "dns"}) {
+		t.Errorf("splitList %q", got)
+	}
+}
@@ -0,0 +1,635 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Completion certificates. A learner who scores at or above the
+// certificate threshold gets a pending certificate. The admin checks each
+// one against the signed attempt that earned it and issues it by signing
+// it with an Ed25519 key sealed under the admin password. Every
+// issued certificate is kept in certificates.json, and certificate files
+// embed the signed record so "cyber-quiz verify" can check a file or its
+// short code against that record.
+
+const defaultCertificateThreshold = 80
+
+// Certificate is a completion certificate. It is pending until signed.
+type Certificate struct {
+	Code      string    `json:"code,omitempty"`
+	UserID    string    `json:"user_id"`
+	Name      string    `json:"name"`
+	Category  string    `json:"category"`
+	Module    string    `json:"module"`
+	Correct   int       `json:"correct"`
+	Total     int       `json:"total"`
+	EarnedAt  time.Time `json:"earned_at"`
+	IssuedAt  time.Time `json:"issued_at,omitempty"`
+	Signature string    `json:"signature,omitempty"`
+	Revoked   bool      `json:"revoked,omitempty"`
+}
+
+// Percent returns the certified score as a percentage
+func (c Certificate) Percent() float64 {
+	if c.Total == 0 {
+		return 0
+	}
+	return float64(c.Correct) / float64(c.Total) * 100
+}
+
+// payload is the exact byte string covered by the signature
+func (c Certificate) payload() []byte {
+	return []byte(strings.Join([]string{
+		"cyber-quiz-certificate/v1",
+		c.UserID,
+		c.Name,
+		c.Category,
+		c.Module,
+		fmt.Sprintf("%d/%d", c.Correct, c.Total),
+		c.EarnedAt.UTC().Format(time.RFC3339Nano),
+		c.IssuedAt.UTC().Format(time.RFC3339Nano),
+	}, "\n"))
+}
+
+// certificateCode derives the short verification code from a signature
+func certificateCode(signature []byte) string {
+	sum := sha256.Sum256(signature)
+	code := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(sum[:])
+	return "CQ-" + code[:4] + "-" + code[4:8]
+}
+
+var certificateMarker = regexp.MustCompile(`CQCERT:([A-Za-z0-9+/=]+)`)
+
+func certificateThreshold() int {
+	if adminConfig.CertificateThreshold > 0 {
+		return adminConfig.CertificateThreshold
+	}
+	return defaultCertificateThreshold
+}
+
+func loadCertificates() []Certificate {
+	var certs []Certificate
+	if _, err := os.Stat(certificatesFile); err == nil {
+		data, _ := os.ReadFile(certificatesFile)
+		json.Unmarshal(data, &certs)
+	}
+	return certs
+}
+
+func saveCertificates(certs []Certificate) {
+	data, _ := json.MarshalIndent(certs, "", "  ")
+	os.WriteFile(certificatesFile, data, 0644)
+}
+
+// requestCertificate queues a pending certificate when an attempt reaches
+// the threshold and beats any certificate the user already has for the
+// module. It returns the queued certificate, or nil.
+func requestCertificate(attempt Attempt) *Certificate {
+	total := len(attempt.Answers)
+	if total == 0 || attempt.AssignmentID != "" || attempt.Category == customCategory {
+		return nil
+	}
+	correct := attempt.Correct()
+	if float64(correct)/float64(total)*100 < float64(certificateThreshold()) {
+		return nil
+	}
+
+	certs := loadCertificates()
+	for i, c := range certs {
+		if c.UserID != currentUser.ID || c.Category != attempt.Category || c.Module != attempt.Module || c.Revoked {
+			continue
+		}
+		if c.Signature == "" {
+			// Replace a pending request with the better score
+			if float64(correct)/float64(total) > c.Percent()/100 {
+				certs[i].Correct, certs[i].Total, certs[i].EarnedAt = correct, total, attempt.FinishedAt
+				saveCertificates(certs)
+			}
+			return &certs[i]
+		}
+		if float64(correct)/float64(total) <= c.Percent()/100 {
+			return nil
+		}
+	}
+
+	cert := Certificate{
+		UserID:   currentUser.ID,
+		Name:     currentUser.Name,
+		Category: attempt.Category,
+		Module:   attempt.Module,
+		Correct:  correct,
+		Total:    total,
+		EarnedAt: attempt.FinishedAt,
+	}
+	certs = append(certs, cert)
+	saveCertificates(certs)
+	return &cert
+}
+
+// signingKey unseals the admin's Ed25519 key with the password typed this
+// session, creating the key on first use. Only a verifier of the password
+// is stored, so the sealed key is safe in admin.json; its public half is
+// not, and anyone who can edit admin.json can swap it, so verifiers should
+// pin the fingerprint printed on every certificate issued.
+func signingKey() (ed25519.PrivateKey, error) {
+	if adminPassword == "" {
+		return nil, errors.New("the admin password is needed to unlock the signing key")
+	}
+	if adminConfig.SigningKey == nil {
+		pub, priv, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			return nil, err
+		}
+		sealed, err := seal(adminPassword, priv.Seed())
+		if err != nil {
+			return nil, err
+		}
+		adminConfig.SigningKey = sealed
+		adminConfig.SigningPublicKey = base64.StdEncoding.EncodeToString(pub)
+		saveAdminConfig()
+		return priv, nil
+	}
+
+	seed, err := unseal(adminPassword, adminConfig.SigningKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, errors.New("signing key has the wrong size")
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+func signingPublicKey() (ed25519.PublicKey, error) {
+	pub, err := base64.StdEncoding.DecodeString(adminConfig.SigningPublicKey)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return nil, errors.New("no certificate signing key has been created yet")
+	}
+	return pub, nil
+}
+
+// keyFingerprint is a short hex digest of a public key for comparing by eye
+func keyFingerprint(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return fmt.Sprintf("%x", sum[:8])
+}
+
+// issueCertificate signs a pending certificate and writes its files
+func issueCertificate(cert *Certificate, key ed25519.PrivateKey) ([]string, error) {
+	cert.IssuedAt = time.Now()
+	sig := ed25519.Sign(key, cert.payload())
+	cert.Signature = base64.StdEncoding.EncodeToString(sig)
+	cert.Code = certificateCode(sig)
+	return writeCertificateFiles(*cert)
+}
+
+// verifyCertificate checks a certificate's signature and compares it with
+// the stored issuance record
+func verifyCertificate(cert Certificate) error {
+	pub, err := signingPublicKey()
+	if err != nil {
+		return err
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(cert.Signature)
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return errors.New("certificate is not signed")
+	}
+	if !ed25519.Verify(pub, cert.payload(), sig) {
+		return errors.New("signature does not match - the certificate has been altered or was not issued with this key")
+	}
+	if certificateCode(sig) != cert.Code {
+		return errors.New("verification code does not match the signature")
+	}
+
+	for _, stored := range loadCertificates() {
+		if stored.Code != cert.Code {
+			continue
+		}
+		if stored.Signature != cert.Signature || string(stored.payload()) != string(cert.payload()) {
+			return errors.New("certificate differs from the issuance record")
+		}
+		if stored.Revoked {
+			return errors.New("certificate has been revoked")
+		}
+		return nil
+	}
+	return errors.New("no issuance record found for this certificate")
+}
+
+// readCertificateFile extracts the signed record embedded in a
+// certificate's HTML or PDF file
+func readCertificateFile(path string) (Certificate, error) {
+	var cert Certificate
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cert, err
+	}
+
+	m := certificateMarker.FindSubmatch(data)
+	if m == nil {
+		return cert, errors.New("no embedded certificate record found")
+	}
+	raw, err := base64.StdEncoding.DecodeString(string(m[1]))
+	if err != nil {
+		return cert, fmt.Errorf("embedded certificate record is corrupt: %v", err)
+	}
+	if err := json.Unmarshal(raw, &cert); err != nil {
+		return cert, fmt.Errorf("embedded certificate record is corrupt: %v", err)
+	}
+	return cert, nil
+}
+
+// findCertificate looks up an issued certificate by its short code
+func findCertificate(code string) (Certificate, bool) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	for _, c := range loadCertificates() {
+		if c.Code != "" && c.Code == code {
+			return c, true
+		}
+	}
+	return Certificate{}, false
+}
+
+func certificateEmbed(cert Certificate) string {
+	cert.Revoked = false
+	data, _ := json.Marshal(cert)
+	return "CQCERT:" + base64.StdEncoding.EncodeToString(data)
+}
+
+func writeCertificateFiles(cert Certificate) ([]string, error) {
+	dir := filepath.Join(cacheDir, "certificates")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	base := filepath.Join(dir, fmt.Sprintf("%s-%s-%s", cert.Code, slugify(cert.Name), slugify(cert.Module)))
+	htmlPath, pdfPath := base+".html", base+".pdf"
+
+	if err := os.WriteFile(htmlPath, []byte(certificateHTML(cert)), 0644); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(pdfPath, certificatePDF(cert), 0644); err != nil {
+		return nil, err
+	}
+	return []string{htmlPath, pdfPath}, nil
+}
+
+func certificateHTML(cert Certificate) string {
+	esc := html.EscapeString
+	var b strings.Builder
+
+	b.WriteString("<!DOCTYPE html>\n<html lang=\"en\">\n<head>\n<meta charset=\"utf-8\">\n")
+	fmt.Fprintf(&b, "<title>Certificate of Completion - %s</title>\n", esc(cert.Name))
+	b.WriteString(`<style>
+body { font-family: Georgia, serif; background: #f4f4f4; }
+.cert { max-width: 800px; margin: 3em auto; padding: 3em; background: #fff; border: 12px double #0a6d8f; text-align: center; }
+h1 { color: #0a6d8f; font-size: 2.4em; margin-bottom: 0.2em; }
+.name { font-size: 2em; font-weight: bold; margin: 0.6em 0; }
+.module { font-size: 1.4em; }
+.meta { color: #555; margin-top: 2em; font-family: monospace; }
+</style>
+</head>
+<body>
+<div class="cert">
+<h1>Certificate of Completion</h1>
+<p>This certifies that</p>
+`)
+	fmt.Fprintf(&b, "<p class=\"name\">%s</p>\n", esc(cert.Name))
+	b.WriteString("<p>has successfully completed</p>\n")
+	fmt.Fprintf(&b, "<p class=\"module\">%s - %s</p>\n", esc(cert.Category), esc(cert.Module))
+	fmt.Fprintf(&b, "<p>with a score of %d/%d (%.0f%%) on %s</p>\n", cert.Correct, cert.Total, cert.Percent(), cert.EarnedAt.Format("2 January 2006"))
+	fmt.Fprintf(&b, "<p class=\"meta\">Verification code: %s<br>Issued %s</p>\n", esc(cert.Code), cert.IssuedAt.Format(dateTimeLayout))
+	b.WriteString("</div>\n")
+	fmt.Fprintf(&b, "<!-- %s -->\n", certificateEmbed(cert))
+	b.WriteString("</body>\n</html>\n")
+
+	return b.String()
+}
+
+func certificatePDF(cert Certificate) []byte {
+	d := newPDF()
+	d.setInfo("Title", "Certificate of Completion - "+cert.Name)
+	d.setInfo("Keywords", certificateEmbed(cert))
+
+	center := func(y, size float64, bold bool, s string) {
+		d.text((pdfPageWidth-pdfTextWidth(s, size))/2, y, size, bold, s)
+	}
+
+	d.setColor(10, 109, 143)
+	d.rect(30, 30, pdfPageWidth-60, pdfPageHeight-60, false)
+	d.rect(38, 38, pdfPageWidth-76, pdfPageHeight-76, false)
+
+	center(200, 30, true, "Certificate of Completion")
+	d.setColor(0, 0, 0)
+	center(270, 14, false, "This certifies that")
+	center(320, 26, true, cert.Name)
+	center(370, 14, false, "has successfully completed")
+	center(415, 20, true, cert.Category+" - "+cert.Module)
+	center(460, 14, false, fmt.Sprintf("with a score of %d/%d (%.0f%%) on %s",
+		cert.Correct, cert.Total, cert.Percent(), cert.EarnedAt.Format("2 January 2006")))
+
+	d.setColor(90, 90, 90)
+	center(700, 11, false, "Verification code: "+cert.Code)
+	center(718, 9, false, "Issued "+cert.IssuedAt.Format(dateTimeLayout)+" - verify with: cyber-quiz verify "+cert.Code)
+
+	return d.bytes()
+}
+
+// manageCertificates is the admin menu for certificates
+func manageCertificates() {
+	for {
+		certs := loadCertificates()
+		pending := 0
+		for _, c := range certs {
+			if c.Signature == "" && !c.Revoked {
+				pending++
+			}
+		}
+
+		choice := runMenu(menu{
+			title:    "Certificates",
+			color:    ColorMagenta,
+			subtitle: fmt.Sprintf("Certificates are earned at %d%% or above", certificateThreshold()),
+			items: []string{
+				fmt.Sprintf("Issue Pending Certificates (%d)", pending),
+				"Issued Certificates",
+				"Revoke Certificate",
+				"Verify Certificate",
+				"Set Certificate Threshold",
+				"Back",
+			},
+			back: 6,
+		})
+
+		switch choice {
+		case "1":
+			issuePendingCertificates()
+		case "2":
+			listIssuedCertificates(false)
+		case "3":
+			listIssuedCertificates(true)
+		case "4":
+			clearScreen()
+			printBoxHeader("Verify Certificate", ColorMagenta)
+			fmt.Println()
+			printColor(ColorYellow, "Enter a certificate file path or verification code: ")
+			if target := readInput(); target != "" {
+				fmt.Println()
+				printVerifyResult(target, "")
+			}
+			printColor(ColorYellow, "\n"+tr("Press Enter to continue..."))
+			readInput()
+		case "5":
+			clearScreen()
+			printBoxHeader("Set Certificate Threshold", ColorMagenta)
+			fmt.Println()
+			printColor(ColorYellow, fmt.Sprintf("Minimum percentage for a certificate [%d]: ", certificateThreshold()))
+			var threshold int
+			fmt.Sscanf(readInput(), "%d", &threshold)
+			if threshold > 0 && threshold <= 100 {
+				adminConfig.CertificateThreshold = threshold
+				saveAdminConfig()
+			}
+		case "6":
+			return
+		default:
+			printColor(ColorRed, "Invalid choice. Press Enter to continue...")
+			readInput()
+		}
+	}
+}
+
+// checkPendingCertificate rebuilds a pending certificate from the signed
+// attempt that earned it. certificates.json is not signed, so the user,
+// module, score and date are only trusted when an untampered attempt with
+// the same values is on record.
+func checkPendingCertificate(c Certificate, users []User) (Certificate, error) {
+	var user *User
+	for i := range users {
+		if users[i].ID == c.UserID {
+			user = &users[i]
+			break
+		}
+	}
+	if user == nil {
+		return c, errors.New("no user has this ID")
+	}
+
+	for _, a := range user.Attempts {
+		if a.Category != c.Category || a.Module != c.Module || !a.FinishedAt.Equal(c.EarnedAt) ||
+			a.Correct() != c.Correct || len(a.Answers) != c.Total || a.AssignmentID != "" {
+			continue
+		}
+		switch {
+		case a.tampered:
+			return c, errors.New("the attempt that earned it failed the integrity check")
+		case a.MAC == "":
+			return c, errors.New("the attempt that earned it is unsigned")
+		case c.Percent() < float64(certificateThreshold()):
+			return c, fmt.Errorf("the score is below the %d%% threshold", certificateThreshold())
+		}
+		return Certificate{
+			UserID:   user.ID,
+			Name:     user.Name,
+			Category: a.Category,
+			Module:   a.Module,
+			Correct:  a.Correct(),
+			Total:    len(a.Answers),
+			EarnedAt: a.FinishedAt,
+		}, nil
+	}
+	return c, errors.New("no recorded attempt matches its module, score and date")
+}
+
+// issuePendingCertificates checks each pending certificate against the
+// attempts on record and asks the admin to confirm it before signing
+func issuePendingCertificates() {
+	clearScreen()
+	printBoxHeader("Issue Certificates", ColorGreen)
+	fmt.Println()
+
+	certs := loadCertificates()
+	var pending []int
+	for i, c := range certs {
+		if c.Signature == "" && !c.Revoked {
+			pending = append(pending, i)
+		}
+	}
+
+	if len(pending) == 0 {
+		printColor(ColorYellow, "No certificates are waiting to be issued.\n")
+		printColor(ColorYellow, tr("Press Enter to continue..."))
+		readInput()
+		return
+	}
+
+	users := loadAllUsers()
+	var key ed25519.PrivateKey
+	issued := 0
+	for n, i := range pending {
+		c, err := checkPendingCertificate(certs[i], users)
+		printColor(ColorWhite+ColorBold, fmt.Sprintf("\n%d of %d: %s (%s)\n", n+1, len(pending), c.Name, c.UserID))
+		printColor(ColorWhite, fmt.Sprintf("  %s - %s: %d/%d (%.0f%%), earned %s\n",
+			c.Category, c.Module, c.Correct, c.Total, c.Percent(), formatDate(c.EarnedAt)))
+		if err != nil {
+			printColor(ColorRed, fmt.Sprintf("  ✗ Not issued: %v\n", err))
+			continue
+		}
+
+		printColor(ColorYellow, "  Sign and issue this certificate? (y/n/q): ")
+		answer := strings.ToLower(readInput())
+		if answer == "q" {
+			break
+		}
+		if answer != "y" {
+			continue
+		}
+
+		if key == nil {
+			if key, err = signingKey(); err != nil {
+				printColor(ColorRed, fmt.Sprintf("\n✗ Could not unlock the signing key: %v\n", err))
+				break
+			}
+		}
+		certs[i] = c
+		files, err := issueCertificate(&certs[i], key)
+		if err != nil {
+			printColor(ColorRed, fmt.Sprintf("  ✗ %s: %v\n", c.Name, err))
+			continue
+		}
+		issued++
+		printColor(ColorGreen, fmt.Sprintf("  ✓ %s - %s: %s\n", c.Name, c.Module, certs[i].Code))
+		for _, f := range files {
+			printColor(ColorCyan, "    "+f+"\n")
+		}
+	}
+
+	if issued > 0 {
+		saveCertificates(certs)
+		printColor(ColorCyan, fmt.Sprintf("\nSigning key fingerprint: %s\n", keyFingerprint(key.Public().(ed25519.PublicKey))))
+	}
+	printColor(ColorYellow, "\n"+tr("Press Enter to continue..."))
+	readInput()
+}
+
+// listIssuedCertificates lists issued certificates. Selecting one rewrites
+// its files, or revokes it when revoke is set.
+func listIssuedCertificates(revoke bool) {
+	certs := loadCertificates()
+	var issued []int
+	var items []listItem
+	for i, c := range certs {
+		if c.Signature == "" {
+			continue
+		}
+		detail := fmt.Sprintf("%s - %s, %.0f%%, %s", c.Category, c.Module, c.Percent(), c.IssuedAt.Format("2006-01-02"))
+		if c.Revoked {
+			detail += " [REVOKED]"
+		}
+		issued = append(issued, i)
+		items = append(items, listItem{label: c.Code + "  " + c.Name, detail: detail})
+	}
+
+	title := "Issued Certificates"
+	if revoke {
+		title = "Revoke Certificate"
+	}
+	if len(items) == 0 {
+		clearScreen()
+		printBoxHeader(title, ColorMagenta)
+		fmt.Println()
+		printColor(ColorYellow, "No certificates have been issued yet.\n")
+		printColor(ColorYellow, tr("Press Enter to continue..."))
+		readInput()
+		return
+	}
+
+	idx := pickItem(title, ColorMagenta, items)
+	if idx < 0 {
+		return
+	}
+	cert := &certs[issued[idx]]
+
+	clearScreen()
+	printBoxHeader(title, ColorMagenta)
+	fmt.Println()
+
+	if revoke {
+		printColor(ColorRed+ColorBold, fmt.Sprintf("⚠ Revoke %s for %s? (yes/no): ", cert.Code, cert.Name))
+		if strings.ToLower(readInput()) == "yes" {
+			cert.Revoked = true
+			saveCertificates(certs)
+			printColor(ColorGreen+ColorBold, "\n✓ Certificate revoked.\n")
+		}
+	} else {
+		files, err := writeCertificateFiles(*cert)
+		if err != nil {
+			printColor(ColorRed, fmt.Sprintf("✗ Could not write certificate files: %v\n", err))
+		} else {
+			printColor(ColorGreen, "✓ Certificate files written:\n")
+			for _, f := range files {
+				printColor(ColorCyan, "    "+f+"\n")
+			}
+		}
+	}
+
+	printColor(ColorYellow, tr("Press Enter to continue..."))
+	readInput()
+}
+
+// printVerifyResult verifies a certificate file or code and reports the
+// outcome, returning true if it is valid. The signing key is read from
+// admin.json, so a fingerprint pinned from the issuer is checked against
+// it when given.
+func printVerifyResult(target, pinned string) bool {
+	var cert Certificate
+	if _, err := os.Stat(target); err == nil {
+		cert, err = readCertificateFile(target)
+		if err != nil {
+			printColor(ColorRed+ColorBold, fmt.Sprintf("✗ %v\n", err))
+			return false
+		}
+	} else {
+		var found bool
+		if cert, found = findCertificate(target); !found {
+			printColor(ColorRed+ColorBold, fmt.Sprintf("✗ No certificate found with code or file %q\n", target))
+			return false
+		}
+	}
+
+	pub, _ := signingPublicKey()
+	if pinned != "" && (pub == nil || !strings.EqualFold(strings.TrimSpace(pinned), keyFingerprint(pub))) {
+		printColor(ColorRed+ColorBold, fmt.Sprintf("✗ INVALID certificate %s: the signing key here (fingerprint %s) is not the pinned key %s\n",
+			cert.Code, keyFingerprint(pub), pinned))
+		return false
+	}
+	if err := verifyCertificate(cert); err != nil {
+		printColor(ColorRed+ColorBold, fmt.Sprintf("✗ INVALID certificate %s: %v\n", cert.Code, err))
+		if pub != nil {
+			printColor(ColorCyan, fmt.Sprintf("  Signing key fingerprint: %s\n", keyFingerprint(pub)))
+		}
+		return false
+	}
+
+	printColor(ColorGreen+ColorBold, fmt.Sprintf("✓ Valid certificate %s\n", cert.Code))
+	printColor(ColorWhite, fmt.Sprintf("  Name:    %s (%s)\n", cert.Name, cert.UserID))
+	printColor(ColorWhite, fmt.Sprintf("  Module:  %s - %s\n", cert.Category, cert.Module))
+	printColor(ColorWhite, fmt.Sprintf("  Score:   %d/%d (%.0f%%)\n", cert.Correct, cert.Total, cert.Percent()))
+	printColor(ColorWhite, fmt.Sprintf("  Earned:  %s\n", cert.EarnedAt.Format(dateTimeLayout)))
+	printColor(ColorWhite, fmt.Sprintf("  Issued:  %s\n", cert.IssuedAt.Format(dateTimeLayout)))
+	printColor(ColorCyan, fmt.Sprintf("  Signing key fingerprint: %s\n", keyFingerprint(pub)))
+	return true
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/base64"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// issueTestCertificate signs and records a certificate with the admin key
+func issueTestCertificate(t *testing.T) Certificate {
+	t.Helper()
+	key, err := signingKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := Certificate{
+		UserID:   "01HZZZZZZZZZZZZZZZZZZZZZZZ",
+		Name:     "Ada",
+		Category: "Networking",
+		Module:   "Subnetting",
+		Correct:  9,
+		Total:    10,
+		EarnedAt: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+	}
+	if _, err := issueCertificate(&cert, key); err != nil {
+		t.Fatal(err)
+	}
+	saveCertificates(append(loadCertificates(), cert))
+	return cert
+}
+
+func TestSigningKey(t *testing.T) {
+	useDataDir(t)
+	if _, err := signingKey(); err == nil {
+		t.Fatal("the signing key was unlocked without the admin password")
+	}
+
+	adminPassword = "first-password"
+	key, err := signingKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := os.ReadFile(adminFile)
+	if strings.Contains(string(stored), base64.StdEncoding.EncodeToString(key.Seed())) || strings.Contains(string(stored), "first-password") {
+		t.Error("admin.json holds the seed or the password in the clear")
+	}
+	again, err := signingKey()
+	if err != nil || !again.Equal(key) {
+		t.Errorf("unlocking again gave a different key (%v)", err)
+	}
+
+	adminPassword = "wrong-password"
+	if _, err := signingKey(); err == nil {
+		t.Error("the signing key was unlocked with the wrong password")
+	}
+}
+
+func TestVerifyCertificate(t *testing.T) {
+	useDataDir(t)
+	adminPassword = "admin-password"
+	cert := issueTestCertificate(t)
+	if err := verifyCertificate(cert); err != nil {
+		t.Fatalf("issued certificate does not verify: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		change func(c *Certificate)
+		want   string
+	}{
+		{"score raised", func(c *Certificate) { c.Correct = 10 }, "signature does not match"},
+		{"name changed", func(c *Certificate) { c.Name = "Mallory" }, "signature does not match"},
+		{"signature removed", func(c *Certificate) { c.Signature = "" }, "not signed"},
+		{"code changed", func(c *Certificate) { c.Code = "CQ-AAAA-BBBB" }, "verification code"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forged := cert
+			tt.change(&forged)
+			err := verifyCertificate(forged)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	// A record missing from certificates.json, or revoked there
+	saveCertificates(nil)
+	if err := verifyCertificate(cert); err == nil || !strings.Contains(err.Error(), "no issuance record") {
+		t.Errorf("unrecorded certificate: %v", err)
+	}
+	revoked := cert
+	revoked.Revoked = true
+	saveCertificates([]Certificate{revoked})
+	if err := verifyCertificate(cert); err == nil || !strings.Contains(err.Error(), "revoked") {
+		t.Errorf("revoked certificate: %v", err)
+	}
+}
+
+func TestVerifyPinnedKey(t *testing.T) {
+	useDataDir(t)
+	adminPassword = "admin-password"
+	cert := issueTestCertificate(t)
+	pub, _ := signingPublicKey()
+	pinned := keyFingerprint(pub)
+
+	if !printVerifyResult(cert.Code, pinned) || !printVerifyResult(cert.Code, strings.ToUpper(pinned)) {
+		t.Fatal("certificate does not verify against its own key's fingerprint")
+	}
+
+	// Replacing the key in admin.json and re-signing the record there is
+	// only caught by the pinned fingerprint
+	adminConfig.SigningKey, adminConfig.SigningPublicKey = nil, ""
+	saveCertificates(nil)
+	forged := issueTestCertificate(t)
+	if !printVerifyResult(forged.Code, "") {
+		t.Fatal("re-signed certificate does not verify without a pin")
+	}
+	if printVerifyResult(forged.Code, pinned) {
+		t.Error("certificate signed with a swapped key passes the pinned fingerprint")
+	}
+}
+
+func TestCheckPendingCertificate(t *testing.T) {
+	useDataDir(t)
+	useIntegrityKey(t)
+
+	// Ada scored 9/10 at 10:00 and 6/10 at 11:00
+	ada := User{ID: "u1", Name: "Ada"}
+	for i, correct := range []int{9, 6} {
+		finished := time.Date(2024, 3, 1, 10+i, 0, 0, 0, time.UTC)
+		a := Attempt{Category: "Networking", Module: "Subnetting", FinishedAt: finished}
+		for q := 0; q < 10; q++ {
+			a.Answers = append(a.Answers, AnswerRecord{QuestionID: "q", Correct: q < correct})
+		}
+		signAttempt(&ada, &a)
+		ada.Attempts = append(ada.Attempts, a)
+	}
+	sealChainHead(&ada)
+	unsigned := User{ID: "u2", Name: "Bob", Attempts: []Attempt{ada.Attempts[0]}}
+	unsigned.Attempts[0].MAC, unsigned.Attempts[0].Prev = "", ""
+	saveAllUsers([]User{ada, unsigned})
+
+	earned := Certificate{UserID: "u1", Name: "Ada", Category: "Networking", Module: "Subnetting",
+		Correct: 9, Total: 10, EarnedAt: ada.Attempts[0].FinishedAt}
+	tests := []struct {
+		name   string
+		change func(c *Certificate)
+		err    string
+	}{
+		{"as earned", func(c *Certificate) {}, ""},
+		{"name changed", func(c *Certificate) { c.Name = "Eve" }, ""},
+		{"score raised", func(c *Certificate) { c.Correct, c.EarnedAt = 10, c.EarnedAt.Add(time.Hour) }, "no recorded attempt matches"},
+		{"date moved", func(c *Certificate) { c.EarnedAt = c.EarnedAt.Add(time.Minute) }, "no recorded attempt matches"},
+		{"module changed", func(c *Certificate) { c.Module = "Routing" }, "no recorded attempt matches"},
+		{"low score", func(c *Certificate) { c.Correct, c.EarnedAt = 6, c.EarnedAt.Add(time.Hour) }, "below the 80% threshold"},
+		{"unknown user", func(c *Certificate) { c.UserID = "u9" }, "no user has this ID"},
+		{"unsigned attempt", func(c *Certificate) { c.UserID = "u2" }, "is unsigned"},
+	}
+	for _, tt := range tests {
+		c := earned
+		tt.change(&c)
+		got, err := checkPendingCertificate(c, loadAllUsers())
+		if tt.err == "" {
+			if err != nil || got != earned {
+				t.Errorf("%s: %+v, %v; want %+v", tt.name, got, err, earned)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: got %v, want %q", tt.name, err, tt.err)
+		}
+	}
+
+	// Editing the attempt to match a forged certificate breaks its MAC
+	users := loadAllUsers()
+	users[0].Attempts[1].Answers[9].Correct = true
+	users[0].Attempts[1].Answers[8].Correct = true
+	saveAllUsers(users)
+	forged := earned
+	forged.Correct, forged.EarnedAt = 8, ada.Attempts[1].FinishedAt
+	if _, err := checkPendingCertificate(forged, loadAllUsers()); err == nil || !strings.Contains(err.Error(), "failed the integrity check") {
+		t.Errorf("certificate for an edited attempt: %v", err)
+	}
+}
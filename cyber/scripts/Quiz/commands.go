@@ -0,0 +1,865 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Non-interactive subcommands, run as "cyber-quiz <command> [args]"
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [flags] [command]\n\n", os.Args[0])
+	fmt.Fprintln(out, "Without a command the interactive quiz starts.")
+	fmt.Fprintln(out, "\nCommands:")
+	fmt.Fprintln(out, "  verify [-key fingerprint] <file|code>")
+	fmt.Fprintln(out, "                       check a certificate file or verification code, optionally")
+	fmt.Fprintln(out, "                       against the signing key fingerprint printed when it was issued")
+	fmt.Fprintln(out, "  check-integrity      verify the score records of every user")
+	fmt.Fprintln(out, "  seal-bank [flags]    encrypt the answers (or the whole bank) of a question bank; with -all,")
+	fmt.Fprintln(out, "                       of every layer, to build into the binary in place of questions.json")
+	fmt.Fprintln(out, "  unseal-bank [flags]  write a sealed question bank back as plain JSON")
+	fmt.Fprintln(out, "  pack build <dir> [-out file]")
+	fmt.Fprintln(out, "                       sign a question pack from a source directory")
+	fmt.Fprintln(out, "  pack verify <file>   check a pack's signature and contents")
+	fmt.Fprintln(out, "  pack install [-trust] [-force] <file>")
+	fmt.Fprintln(out, "                       install or upgrade a pack")
+	fmt.Fprintln(out, "  pack uninstall <name>")
+	fmt.Fprintln(out, "  pack list            show installed packs")
+	fmt.Fprintln(out, "  merge [-both] <dir>  merge learner progress from another data directory, e.g. a portable drive")
+	fmt.Fprintln(out, "  sync export [-out file]")
+	fmt.Fprintln(out, "                       write this device's progress to a signed sync bundle")
+	fmt.Fprintln(out, "  sync import [-trust] <file>...")
+	fmt.Fprintln(out, "                       merge progress from other devices' bundles")
+	fmt.Fprintln(out, "  sync dir [<dir>|-off]")
+	fmt.Fprintln(out, "                       set the shared sync folder and sync with it now")
+	fmt.Fprintln(out, "  sync status          show this device and its sync peers")
+	fmt.Fprintln(out, "  banks                show the question bank layers and where each module comes from")
+	fmt.Fprintln(out, "  banks keep <id>      keep the local edit of a conflicting question")
+	fmt.Fprintln(out, "  banks reset <id>     drop the local edit and use the built-in or system question")
+	fmt.Fprintln(out, "  bankdir init [-force] [-format json|yaml] <dir>")
+	fmt.Fprintln(out, "                       write the bank as one JSON or YAML file per question, for authoring in git")
+	fmt.Fprintln(out, "  bankdir compile [-out file] [<dir>]")
+	fmt.Fprintln(out, "                       check a question directory and install it as the bank")
+	fmt.Fprintln(out, "  bankdir diff [<dir>] show how a question directory differs from the installed bank")
+	fmt.Fprintln(out, "  bankdir pull [<remote> [<branch>]]")
+	fmt.Fprintln(out, "                       fast-forward the question directory with git, then compile it")
+	fmt.Fprintln(out, "  questions search <query>")
+	fmt.Fprintln(out, "                       find questions by words, allowing typos, or by tag:, difficulty:,")
+	fmt.Fprintln(out, "                       category:, module:, author:, source: or id:")
+	fmt.Fprintln(out, "  questions duplicates list clusters of similar questions")
+	fmt.Fprintln(out, "  questions merge <keep> <id>...")
+	fmt.Fprintln(out, "                       merge duplicates into one question, moving their attempt history")
+	fmt.Fprintln(out, "  questions html [-out file] [-answers] [<Category - Module>]")
+	fmt.Fprintln(out, "                       write questions as a web page with their code, exhibits and images")
+	fmt.Fprintln(out, "  questions from-scan [-engagement name] [-category name] [-dry-run] <file>...")
+	fmt.Fprintln(out, "                       make scenario questions from nmap, RustScan, dirb, gobuster or")
+	fmt.Fprintln(out, "                       DirBuster output, in a module named after the engagement")
+	fmt.Fprintln(out, "  translations         list questions missing a translation for each installed language")
+	fmt.Fprintln(out, "  config show          print the effective settings and where each comes from")
+	fmt.Fprintln(out, "  config keys          list the settings with their environment variables")
+	fmt.Fprintln(out, "\nCommands that change the question bank or learner progress, sign with the")
+	fmt.Fprintln(out, "admin key or show answers ask for the admin password, or read it from")
+	fmt.Fprintln(out, "$"+adminPasswordEnv+".")
+	fmt.Fprintln(out, "\nQuiz results are signed with a key sealed under the admin password, so the")
+	fmt.Fprintln(out, "quiz asks for it at startup; a classroom launcher can set $"+adminPasswordEnv)
+	fmt.Fprintln(out, "instead. Without it the session is practice only and results are not saved.")
+	fmt.Fprintln(out, "A project bank in .cyber-quiz/questions.json is only read in sessions that")
+	fmt.Fprintln(out, "have the admin password.")
+	fmt.Fprintln(out, "\nFlags:")
+	flag.PrintDefaults()
+}
+
+// adminCommand reports whether a subcommand changes the bank or learner
+// progress, signs with the admin's key, or reveals answers
+func adminCommand(args []string) bool {
+	sub := ""
+	if len(args) > 1 {
+		sub = args[1]
+	}
+	switch args[0] {
+	case "seal-bank", "unseal-bank", "merge", "bankdir":
+		return true
+	case "pack":
+		return sub == "build" || sub == "install" || sub == "uninstall"
+	case "banks":
+		return sub == "keep" || sub == "reset"
+	case "sync":
+		return sub == "export" || sub == "import" || sub == "dir"
+	case "questions":
+		return sub == "merge" || sub == "from-scan"
+	}
+	return false
+}
+
+// requireAdmin asks for the admin password, or takes it from
+// $CYBER_QUIZ_ADMIN_PASSWORD in scripts
+func requireAdmin() bool {
+	password := os.Getenv(adminPasswordEnv)
+	if password == "" {
+		password = readSecret("Admin password: ")
+	}
+	if !checkAdminPassword(password) {
+		fmt.Fprintln(os.Stderr, "✗ Incorrect admin password")
+		return false
+	}
+	return true
+}
+
+// runCommand runs a subcommand and returns the process exit status
+func runCommand(args []string) int {
+	if adminCommand(args) {
+		if !requireAdmin() {
+			return 1
+		}
+		loadProjectBank()
+	}
+
+	switch args[0] {
+	case "verify":
+		return cmdVerify(args[1:])
+	case "check-integrity":
+		return cmdCheckIntegrity()
+	case "seal-bank":
+		return cmdSealBank(args[1:])
+	case "unseal-bank":
+		return cmdUnsealBank(args[1:])
+	case "pack":
+		return cmdPack(args[1:])
+	case "banks":
+		return cmdBanks(args[1:])
+	case "merge":
+		return cmdMerge(args[1:])
+	case "sync":
+		return cmdSync(args[1:])
+	case "bankdir":
+		return cmdBankDir(args[1:])
+	case "config":
+		return cmdConfig(args[1:])
+	case "questions":
+		return cmdQuestions(args[1:])
+	case "translations":
+		lines := translationLines()
+		for _, line := range lines {
+			printColor(line.color, line.text+"\n")
+		}
+		return 0
+	case "help":
+		usage()
+		return 0
+	}
+
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", args[0])
+	usage()
+	return 2
+}
+
+func cmdVerify(args []string) int {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	pinned := fs.String("key", "", "fingerprint the signing key must have, as printed when certificates are issued")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: cyber-quiz verify [-key fingerprint] <certificate file|verification code>")
+		return 2
+	}
+	if printVerifyResult(fs.Arg(0), *pinned) {
+		return 0
+	}
+	return 1
+}
+
+func cmdCheckIntegrity() int {
+	lines, issues, _ := integrityReportLines(loadAllUsers())
+	for _, line := range lines {
+		printColor(line.color, line.text+"\n")
+	}
+	if issues > 0 {
+		return 1
+	}
+	return 0
+}
+
+// readBankArg returns the bank named by -in, or the user layer of the
+// installed bank
+func readBankArg(in string) (QuizData, error) {
+	if in == "" {
+		return userLayerData(), nil
+	}
+	raw, err := os.ReadFile(in)
+	if err != nil {
+		return QuizData{}, err
+	}
+	data, _, err := openBank(raw)
+	return data, err
+}
+
+func cmdSealBank(args []string) int {
+	fs := flag.NewFlagSet("seal-bank", flag.ContinueOnError)
+	full := fs.Bool("full", false, "encrypt the whole bank, not only the answers")
+	dist := fs.Bool("distribution", false, "seal with the distribution key in $"+bankKeyEnv+" instead of a passphrase")
+	all := fs.Bool("all", false, "seal the merged bank of every layer, e.g. as the questions.json to build into the binary")
+	in := fs.String("in", "", "bank to read (default: the installed questions.json)")
+	out := fs.String("out", "", "file to write (default: overwrite the input)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if *all && *in != "" {
+		fmt.Fprintln(os.Stderr, "✗ -all seals the installed layers and cannot be used with -in")
+		return 2
+	}
+	data, err := readBankArg(*in)
+	if *all {
+		data = allLayersData()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "✗ %v\n", err)
+		return 1
+	}
+
+	source := "passphrase"
+	if *dist {
+		source = "distribution"
+	}
+	sealing, err := newBankSealing(source, *full)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "✗ %v\n", err)
+		return 1
+	}
+	encoded, err := sealBank(data, sealing)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "✗ %v\n", err)
+		return 1
+	}
+
+	target := firstNonEmpty(*out, *in, questionsFile)
+	if err := os.WriteFile(target, encoded, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "✗ %v\n", err)
+		return 1
+	}
+	printColor(ColorGreen, fmt.Sprintf("✓ Sealed %d questions with the %s key to %s\n", len(data.Questions), source, target))
+	return 0
+}
+
+func cmdUnsealBank(args []string) int {
+	fs := flag.NewFlagSet("unseal-bank", flag.ContinueOnError)
+	in := fs.String("in", "", "bank to read (default: the installed questions.json)")
+	out := fs.String("out", "", "file to write (default: overwrite the input)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	data, err := readBankArg(*in)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "✗ %v\n", err)
+		return 1
+	}
+	encoded, _ := json.MarshalIndent(data, "", "  ")
+
+	target := firstNonEmpty(*out, *in, questionsFile)
+	if err := os.WriteFile(target, encoded, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "✗ %v\n", err)
+		return 1
+	}
+	printColor(ColorGreen, fmt.Sprintf("✓ Wrote %d questions as plain JSON to %s\n", len(data.Questions), target))
+	return 0
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func cmdPack(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: cyber-quiz pack build|verify|install|uninstall|list ...")
+		return 2
+	}
+
+	fs := flag.NewFlagSet("pack "+args[0], flag.ContinueOnError)
+	out := fs.String("out", "", "archive to write (pack build)")
+	trust := fs.Bool("trust", false, "accept the pack's signing key (pack install)")
+	force := fs.Bool("force", false, "allow installing an older version (pack install)")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	switch args[0] {
+	case "build":
+		if fs.NArg() != 1 {
+			fmt.Fprintln(os.Stderr, "usage: cyber-quiz pack build [-out file] <source dir>")
+			return 2
+		}
+		file, m, err := buildPack(fs.Arg(0), *out)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "✗ %v\n", err)
+			return 1
+		}
+		pub, _ := signingPublicKey()
+		printColor(ColorGreen+ColorBold, fmt.Sprintf("✓ Built %s %s: %s\n", m.Name, m.Version, file))
+		printColor(ColorCyan, fmt.Sprintf("  Signing key fingerprint: %s\n", keyFingerprint(pub)))
+		return 0
+
+	case "verify", "install":
+		if fs.NArg() != 1 {
+			fmt.Fprintf(os.Stderr, "usage: cyber-quiz pack %s <file>\n", args[0])
+			return 2
+		}
+		p, err := openPack(fs.Arg(0))
+		if err != nil {
+			printColor(ColorRed+ColorBold, fmt.Sprintf("✗ INVALID pack %s: %v\n", fs.Arg(0), err))
+			return 1
+		}
+		if args[0] == "verify" {
+			printColor(ColorGreen+ColorBold, "✓ Valid pack\n")
+			printPackManifest(p)
+			return 0
+		}
+
+		res, err := installPack(p, *trust, *force)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "✗ %v\n", err)
+			return 1
+		}
+		action := "Installed"
+		switch c := compareVersions(p.manifest.Version, res.previous); {
+		case res.previous == "":
+		case c == 0:
+			action = "Reinstalled"
+		case c > 0:
+			action = fmt.Sprintf("Upgraded from %s to", res.previous)
+		default:
+			action = fmt.Sprintf("Downgraded from %s to", res.previous)
+		}
+		printColor(ColorGreen+ColorBold, fmt.Sprintf("✓ %s %s %s\n", action, p.manifest.Name, p.manifest.Version))
+		printColor(ColorWhite, fmt.Sprintf("  %d questions added, %d updated, %d removed\n", res.added, res.updated, res.removed))
+		printWarnings(res.duplicates)
+		return 0
+
+	case "uninstall":
+		if fs.NArg() != 1 {
+			fmt.Fprintln(os.Stderr, "usage: cyber-quiz pack uninstall <name>")
+			return 2
+		}
+		removed, err := uninstallPack(fs.Arg(0))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "✗ %v\n", err)
+			return 1
+		}
+		printColor(ColorGreen, fmt.Sprintf("✓ Removed %s and its %d questions; learner history is kept\n", fs.Arg(0), removed))
+		return 0
+
+	case "list":
+		if len(installedPacks) == 0 {
+			printColor(ColorYellow, "No packs installed.\n")
+		}
+		for _, p := range installedPacks {
+			printColor(ColorWhite, fmt.Sprintf("%-20s %-10s %3d questions  %s, %s  key %s\n",
+				p.Name, p.Version, p.Questions, p.Author, p.Licence, p.KeyFingerprint))
+		}
+		return 0
+	}
+
+	fmt.Fprintf(os.Stderr, "unknown pack command %q\n", args[0])
+	return 2
+}
+
+func cmdBanks(args []string) int {
+	if len(args) == 0 {
+		for _, line := range bankSourceLines() {
+			printColor(line.color, line.text+"\n")
+		}
+		if len(bankConflicts) > 0 {
+			return 1
+		}
+		return 0
+	}
+
+	if len(args) != 2 || (args[0] != "keep" && args[0] != "reset") {
+		fmt.Fprintln(os.Stderr, "usage: cyber-quiz banks [keep|reset <question id>]")
+		return 2
+	}
+
+	id := args[1]
+	if args[0] == "reset" {
+		if err := resetQuestion(id); err != nil {
+			fmt.Fprintf(os.Stderr, "✗ %v\n", err)
+			return 1
+		}
+		printColor(ColorGreen, fmt.Sprintf("✓ %s now follows the lower bank again\n", id))
+		return 0
+	}
+
+	if _, edited := userBase[id]; !edited {
+		fmt.Fprintf(os.Stderr, "✗ %s has no local edit\n", id)
+		return 1
+	}
+	acceptLocalEdit(id)
+	saveQuestions()
+	printColor(ColorGreen, fmt.Sprintf("✓ Kept the local version of %s\n", id))
+	return 0
+}
+
+func cmdMerge(args []string) int {
+	fs := flag.NewFlagSet("merge", flag.ContinueOnError)
+	both := fs.Bool("both", false, "also write the merged progress back to the other directory")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: cyber-quiz merge [-both] <data directory>")
+		return 2
+	}
+
+	report, err := mergeDataDir(fs.Arg(0), *both)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "✗ %v\n", err)
+		return 1
+	}
+
+	printColor(ColorGreen+ColorBold, fmt.Sprintf("✓ Merged %s into %s\n", fs.Arg(0), cacheDir))
+	printColor(ColorWhite, fmt.Sprintf("  %d users merged, %d added, %d attempts added, %d scores updated\n",
+		report.usersMerged, report.usersAdded, report.attemptsAdded, report.scoresUpdated))
+	if report.skipped > 0 {
+		printColor(ColorYellow, fmt.Sprintf("  %d unsigned or tampered records from %s were left out\n", report.skipped, fs.Arg(0)))
+	}
+	if len(report.usersSkipped) > 0 {
+		printColor(ColorRed, fmt.Sprintf("  Not merged, local records failed the integrity check: %s\n", strings.Join(report.usersSkipped, ", ")))
+		return 1
+	}
+	return 0
+}
+
+func cmdSync(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: cyber-quiz sync export|import|dir|status ...")
+		return 2
+	}
+
+	fs := flag.NewFlagSet("sync "+args[0], flag.ContinueOnError)
+	out := fs.String("out", "", "bundle to write (sync export)")
+	trust := fs.Bool("trust", false, "accept bundles from devices not seen before (sync import)")
+	off := fs.Bool("off", false, "stop syncing with a folder (sync dir)")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	switch args[0] {
+	case "export":
+		path := *out
+		if path == "" {
+			path = adminConfig.DeviceID + syncExtension
+		}
+		unsigned, err := exportSyncBundle(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "✗ %v\n", err)
+			return 1
+		}
+		printColor(ColorGreen+ColorBold, fmt.Sprintf("✓ Exported progress of %s to %s\n",
+			deviceLabel(adminConfig.DeviceID, adminConfig.DeviceName), path))
+		if unsigned > 0 {
+			printColor(ColorYellow, fmt.Sprintf("  %d unsigned legacy records were not exported\n", unsigned))
+		}
+		return 0
+
+	case "import":
+		if fs.NArg() == 0 {
+			fmt.Fprintln(os.Stderr, "usage: cyber-quiz sync import [-trust] <bundle>...")
+			return 2
+		}
+		status := 0
+		for _, file := range fs.Args() {
+			payload, report, err := importSyncBundle(file, *trust)
+			if err != nil {
+				printColor(ColorRed, fmt.Sprintf("✗ %s: %v\n", file, err))
+				status = 1
+				continue
+			}
+			printColor(ColorGreen, fmt.Sprintf("✓ %s from %s: %d users merged, %d added, %d attempts added, %d scores updated\n",
+				file, deviceLabel(payload.Device, payload.DeviceName),
+				report.usersMerged, report.usersAdded, report.attemptsAdded, report.scoresUpdated))
+			if len(report.usersSkipped) > 0 {
+				printColor(ColorRed, fmt.Sprintf("  Not merged, local records failed the integrity check: %s\n",
+					strings.Join(report.usersSkipped, ", ")))
+				status = 1
+			}
+			if len(report.usersRejected) > 0 {
+				printColor(ColorRed, fmt.Sprintf("  Not merged, records from the bundle failed the check: %s\n",
+					strings.Join(report.usersRejected, ", ")))
+				status = 1
+			}
+		}
+		return status
+
+	case "dir":
+		switch {
+		case *off:
+			adminConfig.SyncDir = ""
+			saveAdminConfig()
+			printColor(ColorGreen, "✓ Folder sync turned off\n")
+			return 0
+		case fs.NArg() == 1:
+			dir, err := filepath.Abs(fs.Arg(0))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "✗ %v\n", err)
+				return 1
+			}
+			adminConfig.SyncDir = dir
+			saveAdminConfig()
+		case adminConfig.SyncDir == "":
+			printColor(ColorYellow, "No sync folder set. Use: cyber-quiz sync dir <folder>\n")
+			return 0
+		}
+		printColor(ColorCyan, fmt.Sprintf("🔄 Syncing with %s\n", adminConfig.SyncDir))
+		for _, line := range syncWithDir(adminConfig.SyncDir) {
+			printColor(line.color, "   "+line.text+"\n")
+		}
+		return 0
+
+	case "status":
+		printColor(ColorWhite, fmt.Sprintf("Device:       %s\n", deviceLabel(adminConfig.DeviceID, adminConfig.DeviceName)))
+		if pub := devicePublicKey(); pub != nil {
+			printColor(ColorWhite, fmt.Sprintf("Key:          %s\n", keyFingerprint(pub)))
+		} else {
+			printColor(ColorWhite, "Key:          (created with the admin password on the first export)\n")
+		}
+		folder := adminConfig.SyncDir
+		if folder == "" {
+			folder = "(none)"
+		}
+		printColor(ColorWhite, fmt.Sprintf("Sync folder:  %s\n", folder))
+		printColor(ColorWhite, fmt.Sprintf("Peers:        %d\n", len(adminConfig.SyncPeers)))
+		for _, p := range adminConfig.SyncPeers {
+			key, _ := base64.StdEncoding.DecodeString(p.PublicKey)
+			printColor(ColorWhite, fmt.Sprintf("  %s  key %s\n", deviceLabel(p.Device, p.Name), keyFingerprint(key)))
+		}
+		return 0
+	}
+
+	fmt.Fprintf(os.Stderr, "unknown sync command %q\n", args[0])
+	return 2
+}
+
+func cmdBankDir(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: cyber-quiz bankdir init|compile|diff|pull ...")
+		return 2
+	}
+
+	fs := flag.NewFlagSet("bankdir "+args[0], flag.ContinueOnError)
+	force := fs.Bool("force", false, "write into a directory that already holds questions (bankdir init)")
+	format := fs.String("format", "json", "file format of the questions, json or yaml (bankdir init)")
+	out := fs.String("out", "", "write the compiled bank to a file instead of installing it (bankdir compile)")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	dir := adminConfig.BankDir
+	if fs.NArg() > 0 && args[0] != "pull" {
+		abs, err := filepath.Abs(fs.Arg(0))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "✗ %v\n", err)
+			return 1
+		}
+		dir = abs
+	}
+	if dir == "" {
+		fmt.Fprintf(os.Stderr, "usage: cyber-quiz bankdir %s <dir>\n", args[0])
+		return 2
+	}
+
+	switch args[0] {
+	case "init":
+		if *format != "json" && *format != "yaml" {
+			fmt.Fprintf(os.Stderr, "✗ unknown format %q, use json or yaml\n", *format)
+			return 2
+		}
+		n, err := initBankDir(dir, *force, "."+*format)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "✗ %v\n", err)
+			return 1
+		}
+		adminConfig.BankDir, adminConfig.BankDirFormat = dir, *format
+		saveAdminConfig()
+		printColor(ColorGreen+ColorBold, fmt.Sprintf("✓ Wrote %d questions to %s\n", n, dir))
+		printColor(ColorWhite, "  Admin edits are now written back there; commit the directory to git to track them.\n")
+		return 0
+
+	case "diff":
+		questions, warnings, err := compileBankDir(dir)
+		printWarnings(warnings)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "✗ %v\n", err)
+			return 1
+		}
+		lines, changes := diffQuestionLines(quizData.Questions, questions)
+		for _, line := range lines {
+			printColor(line.color, line.text+"\n")
+		}
+		if changes > 0 {
+			return 1
+		}
+		return 0
+
+	case "compile":
+		return compileAndInstall(dir, *out)
+
+	case "pull":
+		if err := pullBankDir(dir, fs.Arg(0), fs.Arg(1)); err != nil {
+			fmt.Fprintf(os.Stderr, "✗ %v\n", err)
+			return 1
+		}
+		return compileAndInstall(dir, "")
+	}
+
+	fmt.Fprintf(os.Stderr, "unknown bankdir command %q\n", args[0])
+	return 2
+}
+
+// compileAndInstall compiles a question directory and installs it, or
+// writes it to out
+func compileAndInstall(dir, out string) int {
+	questions, warnings, err := compileBankDir(dir)
+	printWarnings(warnings)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "✗ %v\n", err)
+		return 1
+	}
+
+	if out != "" {
+		var plain []Question
+		for _, q := range questions {
+			if q.Pack == "" {
+				plain = append(plain, q)
+			}
+		}
+		data, _ := json.MarshalIndent(QuizData{Questions: plain}, "", "  ")
+		if err := os.WriteFile(out, data, 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "✗ %v\n", err)
+			return 1
+		}
+		printColor(ColorGreen+ColorBold, fmt.Sprintf("✓ Compiled %d questions to %s\n", len(plain), out))
+		return 0
+	}
+
+	lines, changes := diffQuestionLines(quizData.Questions, questions)
+	for _, line := range lines {
+		printColor(line.color, line.text+"\n")
+	}
+	installed := make(map[string]bool)
+	for _, q := range quizData.Questions {
+		installed[q.ID] = true
+	}
+	var added []Question
+	for _, q := range questions {
+		if !installed[q.ID] {
+			added = append(added, q)
+		}
+	}
+	printWarnings(duplicateWarnings(added, questions))
+	if changes > 0 {
+		installCompiledBank(questions)
+		printColor(ColorGreen+ColorBold, fmt.Sprintf("✓ Installed %d changes from %s\n", changes, dir))
+	}
+	if adminConfig.BankDir != dir {
+		adminConfig.BankDir = dir
+		saveAdminConfig()
+	}
+	return 0
+}
+
+func printWarnings(warnings []string) {
+	for _, w := range warnings {
+		printColor(ColorYellow, "⚠ "+w+"\n")
+	}
+}
+
+func cmdQuestions(args []string) int {
+	if len(args) == 1 && args[0] == "duplicates" {
+		for _, line := range duplicateReportLines(duplicateClusters(quizData.Questions), loadAllUsers()) {
+			printColor(line.color, line.text+"\n")
+		}
+		return 0
+	}
+	if len(args) >= 3 && args[0] == "merge" {
+		report, err := mergeQuestions(args[1], args[2:])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "✗ %v\n", err)
+			return 1
+		}
+		for _, line := range report.lines() {
+			printColor(line.color, line.text+"\n")
+		}
+		return 0
+	}
+	if len(args) >= 1 && args[0] == "html" {
+		return cmdQuestionsHTML(args[1:])
+	}
+	if len(args) >= 1 && args[0] == "from-scan" {
+		return cmdQuestionsFromScan(args[1:])
+	}
+	if len(args) < 2 || args[0] != "search" {
+		fmt.Fprintln(os.Stderr, "usage: cyber-quiz questions search <query> | duplicates | merge <keep> <id>... | html | from-scan")
+		return 2
+	}
+
+	query := strings.Join(args[1:], " ")
+	results := newSearchIndex(quizData.Questions).search(query)
+	if len(results) == 0 {
+		printColor(ColorYellow, fmt.Sprintf("No questions match %q.\n", query))
+		return 1
+	}
+	for _, r := range results {
+		q := quizData.Questions[r.index]
+		printColor(ColorCyan, fmt.Sprintf("%-10s ", q.ID))
+		printColor(ColorYellow, fmt.Sprintf("[%s - %s] ", q.Category, q.Module))
+		printColor(ColorWhite, stemSummary(q.Question)+"\n")
+		var details []string
+		if meta := questionMeta(q); meta != "" {
+			details = append(details, meta)
+		}
+		if len(r.matched) > 0 {
+			details = append(details, "matched "+strings.Join(r.matched, ", "))
+		}
+		if len(details) > 0 {
+			printColor(ColorGreen, fmt.Sprintf("%-10s %s\n", "", strings.Join(details, " · ")))
+		}
+	}
+	printColor(ColorGreen, fmt.Sprintf("\nFound %d of %d questions\n", len(results), len(quizData.Questions)))
+	return 0
+}
+
+func cmdConfig(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: cyber-quiz config show|keys")
+		return 2
+	}
+
+	switch args[0] {
+	case "show":
+		for _, line := range configLines() {
+			printColor(line.color, line.text+"\n")
+		}
+		return 0
+	case "keys":
+		for _, s := range configSettings() {
+			printColor(ColorWhite, fmt.Sprintf("%-22s %-34s %s\n", s.key, envName(s.key), s.help))
+		}
+		printColor(ColorWhite, fmt.Sprintf("%-22s %-34s %s\n", `grades."<Category - Module>".pass`, "", "pass percentage for one module"))
+		printColor(ColorWhite, fmt.Sprintf("%-22s %-34s %s\n", `grades."<Category - Module>".merit`, "", "merit percentage for one module"))
+		return 0
+	}
+
+	fmt.Fprintf(os.Stderr, "unknown config command %q\n", args[0])
+	return 2
+}
+
+// cmdQuestionsHTML writes questions, optionally of one module, as a web
+// page with images embedded
+func cmdQuestionsHTML(args []string) int {
+	fs := flag.NewFlagSet("questions html", flag.ContinueOnError)
+	out := fs.String("out", "", "write the page to a file instead of standard output")
+	answers := fs.Bool("answers", false, "mark the correct answers and show explanations")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *answers && !requireAdmin() {
+		return 1
+	}
+
+	title := "Cyber Quiz"
+	questions := quizData.Questions
+	if fs.NArg() > 0 {
+		title = strings.Join(fs.Args(), " ")
+		category, module, ok := strings.Cut(title, " - ")
+		if !ok {
+			fmt.Fprintln(os.Stderr, "usage: cyber-quiz questions html [-out file] [-answers] [<Category - Module>]")
+			return 2
+		}
+		questions = getQuestionsByModule(category, module)
+		if len(questions) == 0 {
+			fmt.Fprintf(os.Stderr, "✗ no questions in %s\n", title)
+			return 1
+		}
+	}
+
+	page := questionsPageHTML(title, questions, *answers)
+	if *out == "" {
+		fmt.Print(page)
+		return 0
+	}
+	if err := os.WriteFile(*out, []byte(page), 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "✗ %v\n", err)
+		return 1
+	}
+	printColor(ColorGreen, fmt.Sprintf("✓ Wrote %d questions to %s\n", len(questions), *out))
+	return 0
+}
+
+// cmdQuestionsFromScan adds scenario questions made from scan reports
+func cmdQuestionsFromScan(args []string) int {
+	fs := flag.NewFlagSet("questions from-scan", flag.ContinueOnError)
+	engagement := fs.String("engagement", "", "module name (default: the folder of the first report)")
+	category := fs.String("category", "Engagements", "category of the module")
+	dryRun := fs.Bool("dry-run", false, "list the questions without adding them")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: cyber-quiz questions from-scan [-engagement name] [-category name] [-dry-run] <file>...")
+		return 2
+	}
+
+	f, empty, err := readScans(fs.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "✗ %v\n", err)
+		return 1
+	}
+	for _, name := range empty {
+		printColor(ColorYellow, fmt.Sprintf("⚠ Nothing found in %s.\n", name))
+	}
+	if f.empty() {
+		fmt.Fprintln(os.Stderr, "✗ no hosts, ports or web paths found")
+		return 1
+	}
+	printColor(ColorGreen, "Found "+f.summary()+".\n")
+
+	if *engagement == "" {
+		*engagement = engagementName(fs.Args())
+	}
+	var names []string
+	for _, p := range fs.Args() {
+		names = append(names, filepath.Base(p))
+	}
+	questions, skipped := newScanQuestions(scenarioQuestions(f, *category, *engagement, "scan: "+strings.Join(names, ", ")))
+	if problems := validateQuestions(questions, nil); len(problems) > 0 {
+		for _, p := range problems {
+			fmt.Fprintf(os.Stderr, "✗ %s\n", p)
+		}
+		return 1
+	}
+	for _, q := range questions {
+		printColor(ColorWhite, fmt.Sprintf("  • %s → %s\n", q.Question, q.Options[q.Answer]))
+	}
+	if skipped > 0 {
+		printColor(ColorCyan, fmt.Sprintf("%d questions are already in the module.\n", skipped))
+	}
+	if *dryRun || len(questions) == 0 {
+		return 0
+	}
+	quizData.Questions = append(quizData.Questions, questions...)
+	saveQuestions()
+	printColor(ColorGreen+ColorBold, fmt.Sprintf("✓ Added %d questions to %s - %s\n", len(questions), *category, *engagement))
+	return 0
+}
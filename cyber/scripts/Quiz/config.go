@@ -0,0 +1,475 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Configuration. Settings come from, in increasing precedence, built-in
+// defaults, config.toml in the data directory, CYBER_QUIZ_* environment
+// variables and command-line flags. The file is a small subset of TOML:
+//
+//	theme = "mono"
+//	animations = false
+//
+//	[quiz]
+//	length = 10
+//	shuffle = true
+//
+//	[grades]
+//	pass = 60
+//	merit = 80
+//
+//	[grades."Cisco - CCNA"]
+//	pass = 70
+//
+// Every fixed setting can be given in the environment as CYBER_QUIZ_ and
+// its key in upper case with dots replaced by underscores, for example
+// CYBER_QUIZ_QUIZ_LENGTH=10.
+
+const (
+	configFileName  = "config.toml"
+	configEnvPrefix = "CYBER_QUIZ_"
+)
+
+// gradeThresholds are the percentages for a pass and a merit
+type gradeThresholds struct {
+	Pass  int
+	Merit int
+}
+
+// Config holds the effective settings
+type Config struct {
+	DataDir        string
+	Theme          string
+	Language       string
+	Animations     bool
+	ASCII          bool
+	ScreenReader   bool
+	Images         string // how question images are shown, see imageModes
+	QuizLength     int    // questions per quiz, 0 for the whole module
+	Shuffle        bool
+	ShuffleOptions bool
+	OptionCount    int // options asked for when adding a question
+	MissedStreak   int // correct answers in a row that take a question out of the missed deck
+	DuplicateLevel int // similarity percentage at which questions count as duplicates
+	CommandTries   int // commands a learner may type for each step of a command-line question
+	Grades         gradeThresholds
+	ModuleGrades   map[string]map[string]int // "Category - Module" -> "pass"/"merit" -> percentage
+}
+
+var (
+	config = Config{
+		Theme:          "auto",
+		Language:       "auto",
+		Animations:     true,
+		Images:         "auto",
+		OptionCount:    4,
+		MissedStreak:   2,
+		DuplicateLevel: 70,
+		CommandTries:   2,
+		Grades:         gradeThresholds{Pass: 60, Merit: 80},
+	}
+
+	// configSources records where each setting's value came from
+	configSources = make(map[string]string)
+
+	// configFile is the config file that was read, if any
+	configFile string
+)
+
+// configSetting is a fixed setting with its key
+type configSetting struct {
+	key  string
+	help string
+	get  func() string
+	set  func(value string) error
+}
+
+func configSettings() []configSetting {
+	return []configSetting{
+		{"data_dir", "directory for users, questions and settings",
+			func() string { return config.DataDir },
+			func(v string) error { config.DataDir = v; return nil }},
+		{"theme", "colour theme: " + strings.Join(themeNames(), ", "),
+			func() string { return config.Theme },
+			func(v string) error {
+				if _, ok := themes[v]; !ok {
+					return fmt.Errorf("unknown theme %q (choose from %s)", v, strings.Join(themeNames(), ", "))
+				}
+				config.Theme = v
+				return nil
+			}},
+		{"language", "interface and question language code, or auto for the system locale",
+			func() string { return config.Language },
+			func(v string) error { config.Language = v; return nil }},
+		{"animations", "pause after messages and on startup",
+			func() string { return strconv.FormatBool(config.Animations) },
+			boolSetter(&config.Animations)},
+		{"ascii", "plain ASCII output without emoji or box drawing",
+			func() string { return strconv.FormatBool(config.ASCII) },
+			boolSetter(&config.ASCII)},
+		{"screen_reader", "linear output for screen readers, implies ascii",
+			func() string { return strconv.FormatBool(config.ScreenReader) },
+			boolSetter(&config.ScreenReader)},
+		{"images", "how question images are shown: " + strings.Join(imageModes, ", "),
+			func() string { return config.Images },
+			func(v string) error {
+				v = strings.ToLower(v)
+				if !anyOf(imageModes, []string{v}) {
+					return fmt.Errorf("unknown image mode %q (choose from %s)", v, strings.Join(imageModes, ", "))
+				}
+				config.Images = v
+				return nil
+			}},
+		{"quiz.length", "questions per quiz, 0 for the whole module",
+			func() string { return strconv.Itoa(config.QuizLength) },
+			intSetter(&config.QuizLength, 0, 1000)},
+		{"quiz.shuffle", "ask questions in random order",
+			func() string { return strconv.FormatBool(config.Shuffle) },
+			boolSetter(&config.Shuffle)},
+		{"quiz.shuffle_options", "show answer options in random order",
+			func() string { return strconv.FormatBool(config.ShuffleOptions) },
+			boolSetter(&config.ShuffleOptions)},
+		{"quiz.options", "number of options when adding a question",
+			func() string { return strconv.Itoa(config.OptionCount) },
+			intSetter(&config.OptionCount, 2, 10)},
+		{"quiz.missed_streak", "correct answers in a row that clear a question from the missed deck",
+			func() string { return strconv.Itoa(config.MissedStreak) },
+			intSetter(&config.MissedStreak, 1, 20)},
+		{"quiz.duplicate_threshold", "similarity percentage at which questions are reported as duplicates",
+			func() string { return strconv.Itoa(config.DuplicateLevel) },
+			intSetter(&config.DuplicateLevel, 1, 100)},
+		{"quiz.command_tries", "commands a learner may type for each step of a command-line question",
+			func() string { return strconv.Itoa(config.CommandTries) },
+			intSetter(&config.CommandTries, 1, 10)},
+		{"grades.pass", "percentage for a pass",
+			func() string { return strconv.Itoa(config.Grades.Pass) },
+			intSetter(&config.Grades.Pass, 0, 100)},
+		{"grades.merit", "percentage for a merit",
+			func() string { return strconv.Itoa(config.Grades.Merit) },
+			intSetter(&config.Grades.Merit, 0, 100)},
+	}
+}
+
+func boolSetter(p *bool) func(string) error {
+	return func(v string) error {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", v)
+		}
+		*p = b
+		return nil
+	}
+}
+
+func intSetter(p *int, min, max int) func(string) error {
+	return func(v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < min || n > max {
+			return fmt.Errorf("%q is not a number from %d to %d", v, min, max)
+		}
+		*p = n
+		return nil
+	}
+}
+
+func envName(key string) string {
+	return configEnvPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// setConfigValue sets a fixed setting or a module's grade threshold,
+// written grades."Category - Module".pass or .merit
+func setConfigValue(key, value, source string) error {
+	for _, s := range configSettings() {
+		if s.key == key {
+			if err := s.set(value); err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			configSources[key] = source
+			return nil
+		}
+	}
+
+	if rest, ok := strings.CutPrefix(key, "grades."); ok {
+		dot := strings.LastIndex(rest, ".")
+		if dot > 0 {
+			module, field := strings.Trim(rest[:dot], `"`), rest[dot+1:]
+			if field == "pass" || field == "merit" {
+				var n int
+				if err := intSetter(&n, 0, 100)(value); err != nil {
+					return fmt.Errorf("%s: %w", key, err)
+				}
+				if config.ModuleGrades == nil {
+					config.ModuleGrades = make(map[string]map[string]int)
+				}
+				if config.ModuleGrades[module] == nil {
+					config.ModuleGrades[module] = make(map[string]int)
+				}
+				config.ModuleGrades[module][field] = n
+				configSources[moduleGradeKey(module, field)] = source
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("unknown setting %q", key)
+}
+
+func moduleGradeKey(module, field string) string {
+	return fmt.Sprintf("grades.%q.%s", module, field)
+}
+
+// configOverride is a setting given on the command line
+type configOverride struct {
+	key, value, source string
+}
+
+// configFlags collects -set key=value flags
+type configFlags []configOverride
+
+func (c *configFlags) String() string { return "" }
+
+func (c *configFlags) Set(v string) error {
+	key, value, ok := strings.Cut(v, "=")
+	if !ok {
+		return fmt.Errorf("expected key=value, got %q", v)
+	}
+	*c = append(*c, configOverride{strings.TrimSpace(key), strings.TrimSpace(value), "flag -set"})
+	return nil
+}
+
+// loadConfig merges the config file, environment and flags over the
+// defaults. The config file is read from the data directory given by a
+// flag or the environment, or else the default one.
+func loadConfig(defaultDir string, overrides []configOverride) error {
+	for _, s := range configSettings() {
+		configSources[s.key] = "default"
+	}
+
+	dir := defaultDir
+	if v := os.Getenv(envName("data_dir")); v != "" {
+		dir = v
+	}
+	for _, o := range overrides {
+		if o.key == "data_dir" {
+			dir = o.value
+		}
+	}
+
+	path := filepath.Join(dir, configFileName)
+	if _, err := os.Stat(path); err == nil {
+		configFile = path
+		if err := readConfigFile(path); err != nil {
+			return err
+		}
+	}
+
+	for _, s := range configSettings() {
+		if v, ok := os.LookupEnv(envName(s.key)); ok {
+			if err := setConfigValue(s.key, v, "env "+envName(s.key)); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, o := range overrides {
+		if err := setConfigValue(o.key, o.value, o.source); err != nil {
+			return err
+		}
+	}
+
+	if config.DataDir != "" {
+		abs, err := filepath.Abs(config.DataDir)
+		if err != nil {
+			return err
+		}
+		config.DataDir = abs
+	}
+	applyOutputSettings()
+	return nil
+}
+
+// readConfigFile applies the settings in a TOML config file
+func readConfigFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(stripTOMLComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+		where := fmt.Sprintf("%s:%d", configFileName, n)
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return fmt.Errorf("%s: unterminated section header", where)
+			}
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("%s: expected key = value", where)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if section != "" {
+			key = section + "." + key
+		}
+		if strings.HasPrefix(value, `"`) {
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return fmt.Errorf("%s: bad string %s", where, value)
+			}
+			value = unquoted
+		}
+		if err := setConfigValue(key, value, where); err != nil {
+			return fmt.Errorf("%s: %w", where, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// stripTOMLComment removes a # comment that is not inside a string
+func stripTOMLComment(line string) string {
+	inString, escaped := false, false
+	for i, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\' && inString:
+			escaped = true
+		case r == '"':
+			inString = !inString
+		case r == '#' && !inString:
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// configLines lists every setting with its value and source
+func configLines() []pageLine {
+	file := configFile
+	if file == "" {
+		file = filepath.Join(cacheDir, configFileName) + " (not present)"
+	}
+	lines := []pageLine{{ColorCyan + ColorBold, "Config file: " + file}, {}}
+
+	for _, s := range configSettings() {
+		value := s.get()
+		if s.key == "data_dir" && value == "" {
+			value = cacheDir
+		}
+		if s.key == "theme" && themeReason != "" {
+			value += " (" + activeTheme + ": " + themeReason + ")"
+		}
+		lines = append(lines, configLine(s.key, value, configSources[s.key]))
+	}
+
+	var modules []string
+	for m := range config.ModuleGrades {
+		modules = append(modules, m)
+	}
+	sort.Strings(modules)
+	for _, m := range modules {
+		for _, field := range []string{"pass", "merit"} {
+			if value, ok := config.ModuleGrades[m][field]; ok {
+				key := moduleGradeKey(m, field)
+				lines = append(lines, configLine(key, strconv.Itoa(value), configSources[key]))
+			}
+		}
+	}
+	return lines
+}
+
+func configLine(key, value, source string) pageLine {
+	color := ColorWhite
+	if source != "default" {
+		color = ColorGreen
+	}
+	return pageLine{color, fmt.Sprintf("%-32s = %-20s %s", key, strconv.Quote(value), source)}
+}
+
+// gradesFor returns the thresholds for a module
+func gradesFor(category, module string) gradeThresholds {
+	g := config.Grades
+	if fields, ok := config.ModuleGrades[category+" - "+module]; ok {
+		if n, ok := fields["pass"]; ok {
+			g.Pass = n
+		}
+		if n, ok := fields["merit"]; ok {
+			g.Merit = n
+		}
+	}
+	return g
+}
+
+// gradeColor picks the colour for a percentage: green for a merit, yellow
+// for a pass and red below
+func gradeColor(percentage float64, g gradeThresholds) string {
+	switch {
+	case percentage >= float64(g.Merit):
+		return ColorGreen
+	case percentage >= float64(g.Pass):
+		return ColorYellow
+	}
+	return ColorRed
+}
+
+// pause waits so a message can be read, unless animations are off
+func pause(d time.Duration) {
+	if config.Animations {
+		time.Sleep(d)
+	}
+}
+
+// prepareQuestions applies the shuffling and length settings to a
+// module's questions
+func prepareQuestions(questions []Question) []Question {
+	out := append([]Question(nil), questions...)
+	if config.Shuffle {
+		rand.Shuffle(len(out), func(i, j int) { out[i], out[j] = out[j], out[i] })
+	}
+	if config.QuizLength > 0 && len(out) > config.QuizLength {
+		out = out[:config.QuizLength]
+	}
+	return out
+}
+
+// shuffledOptions returns the question as shown, with its options in
+// random order if enabled, and for each shown option its original index
+func shuffledOptions(q Question) (Question, []int) {
+	order := make([]int, len(q.Options))
+	for i := range order {
+		order[i] = i
+	}
+	if !config.ShuffleOptions {
+		return q, order
+	}
+	rand.Shuffle(len(order), func(i, j int) { order[i], order[j] = order[j], order[i] })
+
+	shown := q
+	shown.Options = make([]string, len(order))
+	for i, orig := range order {
+		shown.Options[i] = q.Options[orig]
+		if orig == q.Answer {
+			shown.Answer = i
+		}
+	}
+	return shown, order
+}
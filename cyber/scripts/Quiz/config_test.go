@@ -0,0 +1,165 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// useConfig restores the settings and output state after a test
+func useConfig(t *testing.T) {
+	t.Helper()
+	saved, savedSources, savedFile := config, configSources, configFile
+	config.ModuleGrades = nil
+	configSources = make(map[string]string)
+	t.Cleanup(func() {
+		config, configSources, configFile = saved, savedSources, savedFile
+		applyOutputSettings()
+	})
+}
+
+func writeConfig(t *testing.T, contents string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, configFileName), []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestReadConfigFile(t *testing.T) {
+	useConfig(t)
+	dir := writeConfig(t, `# Classroom settings
+theme = "mono"   # no colours on the projector
+animations = false
+language = "es"
+data_dir = "C:\\quiz # data"
+
+[quiz]
+length = 10
+shuffle = true
+
+[grades]
+pass = 50 # lower for beginners
+
+[grades."Cisco - CCNA 1.0"]
+pass = 70
+merit = 90
+`)
+	if err := readConfigFile(filepath.Join(dir, configFileName)); err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Theme != "mono" || config.Animations || config.Language != "es" || config.DataDir != `C:\quiz # data` {
+		t.Errorf("top-level settings: %+v", config)
+	}
+	if config.QuizLength != 10 || !config.Shuffle {
+		t.Errorf("quiz settings: length %d, shuffle %v", config.QuizLength, config.Shuffle)
+	}
+	if got := gradesFor("Cisco", "CCNA 1.0"); got != (gradeThresholds{Pass: 70, Merit: 90}) {
+		t.Errorf("module grades %+v", got)
+	}
+	if got := gradesFor("Cisco", "Other"); got != (gradeThresholds{Pass: 50, Merit: 80}) {
+		t.Errorf("default grades %+v", got)
+	}
+	if src := configSources["quiz.length"]; src != configFileName+":8" {
+		t.Errorf("quiz.length comes from %q", src)
+	}
+	if src := configSources[moduleGradeKey("Cisco - CCNA 1.0", "merit")]; src != configFileName+":16" {
+		t.Errorf("module merit comes from %q", src)
+	}
+}
+
+func TestReadConfigFileErrors(t *testing.T) {
+	tests := []struct {
+		contents string
+		want     string
+	}{
+		{"theme = \"mono\"\n[quiz\n", "config.toml:2: unterminated section header"},
+		{"just words\n", "config.toml:1: expected key = value"},
+		{"theme = \"mono\n", "config.toml:1: bad string"},
+		{"colour = \"red\"\n", `config.toml:1: unknown setting "colour"`},
+		{"[quiz]\nlength = lots\n", `config.toml:2: quiz.length: "lots" is not a number`},
+		{"[quiz]\nlength = 5000\n", "is not a number from 0 to 1000"},
+		{"animations = maybe\n", "is not true or false"},
+		{"theme = \"plaid\"\n", `unknown theme "plaid"`},
+		{"[grades.\"X - Y\"]\ndistinction = 95\n", "unknown setting"},
+		{"[grades.\"X - Y\"]\npass = 101\n", "is not a number from 0 to 100"},
+	}
+	for _, tt := range tests {
+		useConfig(t)
+		dir := writeConfig(t, tt.contents)
+		err := readConfigFile(filepath.Join(dir, configFileName))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%q: got %v, want %q", tt.contents, err, tt.want)
+		}
+	}
+}
+
+func TestStripTOMLComment(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{`a = 1 # note`, `a = 1 `},
+		{`# whole line`, ``},
+		{`a = "# not a comment"`, `a = "# not a comment"`},
+		{`a = "say \"hi\" # still text" # note`, `a = "say \"hi\" # still text" `},
+		{`a = "C:\\" # note`, `a = "C:\\" `},
+		{`a = "C:\\\" # text"`, `a = "C:\\\" # text"`},
+	}
+	for _, tt := range tests {
+		if got := stripTOMLComment(tt.in); got != tt.want {
+			t.Errorf("stripTOMLComment(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	useConfig(t)
+	dir := writeConfig(t, "theme = \"mono\"\n[quiz]\nlength = 5\nshuffle = true\noptions = 3\n")
+	t.Setenv(envName("quiz.length"), "7")
+	t.Setenv(envName("quiz.options"), "6")
+
+	var flags configFlags
+	for _, f := range []string{"quiz.options=8", "animations = false"} {
+		if err := flags.Set(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := flags.Set("novalue"); err == nil {
+		t.Error("-set accepted a value without =")
+	}
+	if err := loadConfig(dir, flags); err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]string{}
+	for _, key := range []string{"theme", "quiz.length", "quiz.shuffle", "quiz.options", "animations", "ascii"} {
+		got[key] = configSources[key]
+	}
+	want := map[string]string{
+		"theme":        configFileName + ":1",
+		"quiz.length":  "env CYBER_QUIZ_QUIZ_LENGTH",
+		"quiz.shuffle": configFileName + ":4",
+		"quiz.options": "flag -set",
+		"animations":   "flag -set",
+		"ascii":        "default",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("sources %v, want %v", got, want)
+	}
+	if config.Theme != "mono" || config.QuizLength != 7 || !config.Shuffle || config.OptionCount != 8 || config.Animations {
+		t.Errorf("settings %+v", config)
+	}
+
+	// The config file is read from a data directory given on the command
+	// line rather than the default one
+	other := writeConfig(t, "theme = \"none\"\n")
+	useConfig(t)
+	if err := loadConfig(dir, []configOverride{{"data_dir", other, "flag -data-dir"}}); err != nil {
+		t.Fatal(err)
+	}
+	if config.Theme != "none" || config.DataDir != other {
+		t.Errorf("theme %q from data dir %q", config.Theme, config.DataDir)
+	}
+}
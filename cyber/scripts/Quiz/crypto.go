@@ -0,0 +1,185 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Password-based encryption for secrets kept in the data directory.
+// Keys are derived with PBKDF2-HMAC-SHA256 and data is sealed with
+// AES-256-GCM.
+
+const pbkdf2Iterations = 200000
+
+// sealedData is an encrypted blob together with its KDF salt and nonce
+type sealedData struct {
+	Salt       string `json:"salt"`
+	Nonce      string `json:"nonce"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+var errWrongPassword = errors.New("wrong password or corrupted data")
+
+// pbkdf2Key derives a key of keyLen bytes from a password (RFC 8018)
+func pbkdf2Key(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	hashLen := prf.Size()
+	blocks := (keyLen + hashLen - 1) / hashLen
+
+	var key []byte
+	buf := make([]byte, 4)
+	for block := 1; block <= blocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(buf, uint32(block))
+		prf.Write(buf)
+		u := prf.Sum(nil)
+
+		t := make([]byte, len(u))
+		copy(t, u)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
+
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	return b
+}
+
+// seal encrypts plaintext with a key derived from the password
+func seal(password string, plaintext []byte) (*sealedData, error) {
+	salt := randomBytes(16)
+	gcm, err := newGCM(pbkdf2Key([]byte(password), salt, pbkdf2Iterations, 32))
+	if err != nil {
+		return nil, err
+	}
+	nonce := randomBytes(gcm.NonceSize())
+
+	return &sealedData{
+		Salt:       base64.StdEncoding.EncodeToString(salt),
+		Nonce:      base64.StdEncoding.EncodeToString(nonce),
+		Ciphertext: base64.StdEncoding.EncodeToString(gcm.Seal(nil, nonce, plaintext, nil)),
+	}, nil
+}
+
+// unseal decrypts data sealed with the same password
+func unseal(password string, data *sealedData) ([]byte, error) {
+	salt, err1 := base64.StdEncoding.DecodeString(data.Salt)
+	nonce, err2 := base64.StdEncoding.DecodeString(data.Nonce)
+	ciphertext, err3 := base64.StdEncoding.DecodeString(data.Ciphertext)
+	if err := errors.Join(err1, err2, err3); err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(pbkdf2Key([]byte(password), salt, pbkdf2Iterations, 32))
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, errWrongPassword
+	}
+
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, errWrongPassword
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// passwordHash verifies a password without storing it
+type passwordHash struct {
+	Salt       string `json:"salt"`
+	Hash       string `json:"hash"`
+	Iterations int    `json:"iterations"`
+}
+
+func newPasswordHash(password string) *passwordHash {
+	salt := randomBytes(16)
+	return &passwordHash{
+		Salt:       base64.StdEncoding.EncodeToString(salt),
+		Hash:       base64.StdEncoding.EncodeToString(pbkdf2Key([]byte("verify:"+password), salt, pbkdf2Iterations, 32)),
+		Iterations: pbkdf2Iterations,
+	}
+}
+
+// matches reports whether password is the one hashed. The key sealing the
+// signing key is derived from the password too, so the verifier hashes it
+// with a prefix to keep the two apart.
+func (h *passwordHash) matches(password string) bool {
+	if h == nil || h.Iterations <= 0 {
+		return false
+	}
+	salt, err1 := base64.StdEncoding.DecodeString(h.Salt)
+	want, err2 := base64.StdEncoding.DecodeString(h.Hash)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	got := pbkdf2Key([]byte("verify:"+password), salt, h.Iterations, len(want))
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
+
+// deriveKey derives an AES-256 key from a passphrase and salt
+func deriveKey(passphrase string, salt []byte) []byte {
+	return pbkdf2Key([]byte(passphrase), salt, pbkdf2Iterations, 32)
+}
+
+// sealWithKey encrypts plaintext under an already derived key. The nonce
+// is prepended to the ciphertext and additional data is authenticated but
+// not stored.
+func sealWithKey(key, plaintext, additional []byte) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := randomBytes(gcm.NonceSize())
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, plaintext, additional)), nil
+}
+
+// openWithKey decrypts a value produced by sealWithKey
+func openWithKey(key []byte, sealed string, additional []byte) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errWrongPassword
+	}
+
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], additional)
+	if err != nil {
+		return nil, errWrongPassword
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+)
+
+func TestPBKDF2Key(t *testing.T) {
+	// PBKDF2-HMAC-SHA256 test vectors
+	tests := []struct {
+		password, salt string
+		iterations     int
+		keyLen         int
+		want           string
+	}{
+		{"password", "salt", 1, 32, "120fb6cffcf8b32c43e7225256c4f837a86548c92ccc35480805987cb70be17b"},
+		{"password", "salt", 2, 32, "ae4d0c95af6b46d32d0adff928f06dd02a303f8ef3c251dfd6e2d85a95474c43"},
+		{"password", "salt", 4096, 32, "c5e478d59288c841aa530db6845c4c8d962893a001ce4e11a4963873aa98134a"},
+		{"passwordPASSWORDpassword", "saltSALTsaltSALTsaltSALTsaltSALTsalt", 4096, 40,
+			"348c89dbcbd32b2f32d814b8116e84cf2b17347ebc1800181c4e2a1fb8dd53e1c635518c7dac47e9"},
+	}
+	for _, tt := range tests {
+		got := hex.EncodeToString(pbkdf2Key([]byte(tt.password), []byte(tt.salt), tt.iterations, tt.keyLen))
+		if got != tt.want {
+			t.Errorf("pbkdf2Key(%q, %q, %d) = %s, want %s", tt.password, tt.salt, tt.iterations, got, tt.want)
+		}
+	}
+}
+
+func TestSealUnseal(t *testing.T) {
+	sealed, err := seal("correct horse", []byte("secret seed"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := unseal("correct horse", sealed)
+	if err != nil || string(plain) != "secret seed" {
+		t.Fatalf("unseal = %q, %v", plain, err)
+	}
+	if _, err := unseal("wrong horse", sealed); err != errWrongPassword {
+		t.Errorf("wrong password gave %v", err)
+	}
+
+	tampered := *sealed
+	tampered.Ciphertext = "A" + tampered.Ciphertext[1:]
+	if _, err := unseal("correct horse", &tampered); err == nil {
+		t.Error("tampered ciphertext was opened")
+	}
+}
+
+func TestSealWithKey(t *testing.T) {
+	key := deriveKey("passphrase", []byte("0123456789abcdef"))
+	other := deriveKey("passphrase", []byte("fedcba9876543210"))
+
+	sealed, err := sealWithKey(key, []byte("answer"), []byte("answer:q1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain, err := openWithKey(key, sealed, []byte("answer:q1")); err != nil || string(plain) != "answer" {
+		t.Errorf("openWithKey = %q, %v", plain, err)
+	}
+	if _, err := openWithKey(other, sealed, []byte("answer:q1")); err == nil {
+		t.Error("opened with a key from another salt")
+	}
+	// The additional data binds the value to its question
+	if _, err := openWithKey(key, sealed, []byte("answer:q2")); err == nil {
+		t.Error("opened a value moved to another question")
+	}
+	if _, err := openWithKey(key, "c2hvcnQ=", nil); err == nil {
+		t.Error("opened a value shorter than a nonce")
+	}
+}
+
+func TestPasswordHash(t *testing.T) {
+	h := newPasswordHash("s3cret-password")
+	if !h.matches("s3cret-password") {
+		t.Error("the password does not match its own hash")
+	}
+	for _, wrong := range []string{"", "s3cret-passwor", "S3cret-password", "s3cret-password "} {
+		if h.matches(wrong) {
+			t.Errorf("%q matches", wrong)
+		}
+	}
+	var missing *passwordHash
+	if missing.matches("") {
+		t.Error("a missing hash matches the empty password")
+	}
+	if (&passwordHash{Salt: h.Salt, Hash: h.Hash}).matches("s3cret-password") {
+		t.Error("a hash without iterations matches")
+	}
+
+	if newPasswordHash("x").Hash == newPasswordHash("x").Hash {
+		t.Error("hashes of the same password share a salt")
+	}
+
+	// The stored verifier must not be the key that seals the signing key
+	salt, _ := base64.StdEncoding.DecodeString(h.Salt)
+	if base64.StdEncoding.EncodeToString(pbkdf2Key([]byte("s3cret-password"), salt, h.Iterations, 32)) == h.Hash {
+		t.Error("the verifier is the sealing key")
+	}
+}
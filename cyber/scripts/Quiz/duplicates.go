@@ -0,0 +1,502 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+)
+
+// Duplicate detection. Question stems are normalised and broken into
+// character trigrams, and a MinHash signature estimates how many trigrams
+// two stems share. The overlap of the option sets and whether the correct
+// answers agree are weighed in, so the same question in other words, with
+// the same options, is caught too. Questions at or above
+// quiz.duplicate_threshold percent are reported.
+//
+// Merging duplicates keeps one question and moves the attempt history,
+// missed decks and assignments of the others onto it.
+
+const minHashSize = 64
+
+// stopWords carry no meaning for telling questions apart, including the
+// phrasing of questions about acronyms
+var stopWords = map[string]bool{
+	"a": true, "an": true, "the": true, "of": true, "is": true, "are": true, "what": true,
+	"which": true, "does": true, "do": true, "for": true, "to": true, "in": true, "on": true,
+	"by": true, "with": true, "following": true, "used": true, "be": true, "it": true,
+	"these": true, "this": true, "most": true, "stand": true, "short": true, "acronym": true,
+	"mean": true, "called": true, "known": true, "as": true,
+}
+
+// minHashSeeds gives each of the signature's hash functions its own seed
+var minHashSeeds = func() []uint64 {
+	seeds := make([]uint64, minHashSize)
+	x := uint64(0x9e3779b97f4a7c15)
+	for i := range seeds {
+		x = mix64(x + uint64(i))
+		seeds[i] = x
+	}
+	return seeds
+}()
+
+// mix64 is the splitmix64 finaliser
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	return x ^ x>>31
+}
+
+// questionSketch is what similarity is computed from
+type questionSketch struct {
+	stem      string
+	signature []uint64
+	options   map[string]bool
+	answer    string
+}
+
+// duplicateMatch is an existing question similar to another one
+type duplicateMatch struct {
+	index      int
+	similarity int // percentage
+}
+
+// normalizeStem lower-cases text, drops punctuation and stop words and
+// trims plural endings
+func normalizeStem(text string) string {
+	var words []string
+	for _, w := range searchWords(text) {
+		if stopWords[w] {
+			continue
+		}
+		if len(w) > 3 && strings.HasSuffix(w, "s") && !strings.HasSuffix(w, "ss") {
+			w = w[:len(w)-1]
+		}
+		if !stopWords[w] {
+			words = append(words, w)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// sameOption reports whether two normalised options match, allowing for
+// spelling variants such as organised and organized
+func sameOption(a, b string) bool {
+	return a == b || editDistance(a, b) <= max(1, len(a)/10)
+}
+
+func sketchQuestion(q Question) questionSketch {
+	s := questionSketch{stem: normalizeStem(q.Question), options: make(map[string]bool)}
+	for _, o := range q.Options {
+		s.options[strings.Join(searchWords(o), " ")] = true
+	}
+	if q.Answer >= 0 && q.Answer < len(q.Options) {
+		s.answer = strings.Join(searchWords(q.Options[q.Answer]), " ")
+	}
+
+	s.signature = make([]uint64, minHashSize)
+	for i := range s.signature {
+		s.signature[i] = ^uint64(0)
+	}
+	padded := []rune(" " + s.stem + " ")
+	for i := 0; i+3 <= len(padded); i++ {
+		h := fnv.New64a()
+		h.Write([]byte(string(padded[i : i+3])))
+		base := h.Sum64()
+		for j, seed := range minHashSeeds {
+			if v := mix64(base ^ seed); v < s.signature[j] {
+				s.signature[j] = v
+			}
+		}
+	}
+	return s
+}
+
+// similarity estimates how alike two questions are, as a percentage
+func similarity(a, b questionSketch) int {
+	stem := 1.0
+	if a.stem == "" || a.stem != b.stem {
+		same := 0
+		for i := range a.signature {
+			if a.signature[i] == b.signature[i] {
+				same++
+			}
+		}
+		stem = float64(same) / minHashSize
+	}
+
+	shared := 0
+	for o := range a.options {
+		for p := range b.options {
+			if sameOption(o, p) {
+				shared++
+				break
+			}
+		}
+	}
+	options := 0.0
+	if union := len(a.options) + len(b.options) - shared; union > 0 {
+		options = float64(shared) / float64(union)
+	}
+
+	// Options like true and false are shared by unrelated questions
+	weight := 0.35
+	if min(len(a.options), len(b.options)) < 3 {
+		weight = 0.1
+	}
+	score := (1-weight)*stem + weight*options
+	if !sameOption(a.answer, b.answer) {
+		score *= 0.8
+	}
+	return int(score*100 + 0.5)
+}
+
+// closestQuestions returns the questions in bank most similar to q, at or
+// above the duplicate threshold, best first. A question is not compared
+// with itself.
+func closestQuestions(q Question, bank []Question, limit int) []duplicateMatch {
+	sketch := sketchQuestion(q)
+	var matches []duplicateMatch
+	for i, other := range bank {
+		if other.ID == q.ID {
+			continue
+		}
+		if s := similarity(sketch, sketchQuestion(other)); s >= config.DuplicateLevel {
+			matches = append(matches, duplicateMatch{i, s})
+		}
+	}
+	sort.SliceStable(matches, func(a, b int) bool { return matches[a].similarity > matches[b].similarity })
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// duplicateWarnings describes incoming questions that look like a question
+// already in bank
+func duplicateWarnings(incoming, bank []Question) []string {
+	var warnings []string
+	for _, q := range incoming {
+		for _, m := range closestQuestions(q, bank, 3) {
+			other := bank[m.index]
+			warnings = append(warnings, fmt.Sprintf("%s looks like %s [%s - %s] (%d%% similar): %s",
+				q.ID, other.ID, other.Category, other.Module, m.similarity, other.Question))
+		}
+	}
+	return warnings
+}
+
+// duplicateCluster is a group of questions that are all linked by
+// similarity, with the highest similarity between any two of them
+type duplicateCluster struct {
+	indexes []int
+	highest int
+}
+
+// duplicateClusters groups the bank's questions into clusters of
+// duplicates
+func duplicateClusters(questions []Question) []duplicateCluster {
+	sketches := make([]questionSketch, len(questions))
+	for i, q := range questions {
+		sketches[i] = sketchQuestion(q)
+	}
+
+	parent := make([]int, len(questions))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	highest := make(map[int]int)
+	for i := range questions {
+		for j := i + 1; j < len(questions); j++ {
+			s := similarity(sketches[i], sketches[j])
+			if s < config.DuplicateLevel {
+				continue
+			}
+			ri, rj := find(i), find(j)
+			parent[rj] = ri
+			highest[ri] = max(highest[ri], highest[rj], s)
+		}
+	}
+
+	groups := make(map[int][]int)
+	for i := range questions {
+		groups[find(i)] = append(groups[find(i)], i)
+	}
+	var clusters []duplicateCluster
+	for root, indexes := range groups {
+		if len(indexes) > 1 {
+			clusters = append(clusters, duplicateCluster{indexes, highest[root]})
+		}
+	}
+	sort.Slice(clusters, func(a, b int) bool {
+		if clusters[a].highest != clusters[b].highest {
+			return clusters[a].highest > clusters[b].highest
+		}
+		return clusters[a].indexes[0] < clusters[b].indexes[0]
+	})
+	return clusters
+}
+
+// answerCounts counts the recorded answers to each question
+func answerCounts(users []User) map[string]int {
+	counts := make(map[string]int)
+	for _, u := range users {
+		for _, a := range u.Attempts {
+			for _, ans := range a.Answers {
+				counts[ans.QuestionID]++
+			}
+		}
+	}
+	return counts
+}
+
+// duplicateReportLines describes every cluster of duplicates
+func duplicateReportLines(clusters []duplicateCluster, users []User) []pageLine {
+	if len(clusters) == 0 {
+		return []pageLine{{ColorGreen, fmt.Sprintf("No duplicate questions at the %d%% threshold.", config.DuplicateLevel)}}
+	}
+
+	counts := answerCounts(users)
+	var lines []pageLine
+	for n, c := range clusters {
+		lines = append(lines, pageLine{ColorYellow + ColorBold,
+			fmt.Sprintf("Cluster %d: %d questions, up to %d%% similar", n+1, len(c.indexes), c.highest)})
+		for _, i := range c.indexes {
+			q := quizData.Questions[i]
+			lines = append(lines, pageLine{ColorWhite, fmt.Sprintf("  %-12s [%s - %s] %s (%d answers)",
+				q.ID, q.Category, q.Module, stemSummary(q.Question), counts[q.ID])})
+		}
+		lines = append(lines, pageLine{})
+	}
+	return lines
+}
+
+// questionMergeReport says what a merge changed
+type questionMergeReport struct {
+	removed, users, answers, assignments int
+	skipped                              []string // users whose records failed verification
+}
+
+// mergeQuestions removes the merged questions from the bank and points
+// their history at the survivor. Signed attempts that change are signed
+// again; users whose records fail verification are left alone so the
+// evidence is kept.
+func mergeQuestions(survivor string, merged []string) (questionMergeReport, error) {
+	var report questionMergeReport
+	ids := make(map[string]bool)
+	for _, id := range merged {
+		if id != survivor {
+			ids[id] = true
+		}
+	}
+
+	inBank := make(map[string]bool)
+	for _, q := range quizData.Questions {
+		inBank[q.ID] = true
+		if ids[q.ID] && q.Pack != "" {
+			return report, fmt.Errorf("%s belongs to the %s pack; change the pack instead", q.ID, q.Pack)
+		}
+	}
+	for _, id := range append([]string{survivor}, merged...) {
+		if !inBank[id] {
+			return report, fmt.Errorf("question %s is not in the bank", id)
+		}
+	}
+	if len(ids) == 0 {
+		return report, fmt.Errorf("no other questions to merge into %s", survivor)
+	}
+
+	ensureIntegrityKey()
+	users := loadAllUsers()
+	for i := range users {
+		u := &users[i]
+		changed := false
+
+		for id, streak := range u.Missed {
+			if ids[id] {
+				if current, ok := u.Missed[survivor]; !ok || streak < current {
+					u.Missed[survivor] = streak
+				}
+				delete(u.Missed, id)
+				changed = true
+			}
+		}
+
+		refers := false
+		for _, a := range u.Attempts {
+			for _, ans := range a.Answers {
+				refers = refers || ids[ans.QuestionID]
+			}
+		}
+		if refers && len(u.integrityIssues) > 0 {
+			report.skipped = append(report.skipped, u.ID)
+			refers = false
+		}
+		if refers {
+			for j := range u.Attempts {
+				a := &u.Attempts[j]
+				for k := range a.Answers {
+					if !ids[a.Answers[k].QuestionID] {
+						continue
+					}
+					if a.ID == "" {
+						// Legacy IDs derive from the answers, so fix them first
+						a.ID = attemptID(*a)
+					}
+					a.Answers[k].QuestionID = survivor
+					report.answers++
+				}
+			}
+			resignRecords(u)
+			changed = true
+		}
+		if changed {
+			report.users++
+		}
+	}
+
+	for i := range classData.Assignments {
+		a := &classData.Assignments[i]
+		var kept []string
+		seen := make(map[string]bool)
+		changed := false
+		for _, id := range a.QuestionIDs {
+			if ids[id] {
+				id, changed = survivor, true
+			}
+			if !seen[id] {
+				seen[id] = true
+				kept = append(kept, id)
+			}
+		}
+		if changed {
+			a.QuestionIDs = kept
+			report.assignments++
+		}
+	}
+
+	var kept []Question
+	for _, q := range quizData.Questions {
+		if ids[q.ID] {
+			report.removed++
+			continue
+		}
+		kept = append(kept, q)
+	}
+	quizData.Questions = kept
+
+	saveQuestions()
+	saveClassData()
+	saveAllUsers(users)
+	return report, nil
+}
+
+func (r questionMergeReport) lines() []pageLine {
+	lines := []pageLine{{ColorGreen + ColorBold, fmt.Sprintf("✓ Merged %d questions; moved %d answers for %d users and updated %d assignments.",
+		r.removed, r.answers, r.users, r.assignments)}}
+	if len(r.skipped) > 0 {
+		lines = append(lines, pageLine{ColorRed, "⚠ History not moved for users whose records failed the integrity check: " +
+			strings.Join(r.skipped, ", ")})
+	}
+	return lines
+}
+
+// warnDuplicates shows questions similar to a new one and asks whether to
+// add it anyway
+func warnDuplicates(q Question) bool {
+	matches := closestQuestions(q, quizData.Questions, 3)
+	if len(matches) == 0 {
+		return true
+	}
+	printColor(ColorRed+ColorBold, "\n⚠ This looks like a question already in the bank:\n")
+	for _, m := range matches {
+		other := quizData.Questions[m.index]
+		printColor(ColorYellow, fmt.Sprintf("  %d%% ", m.similarity))
+		printColor(ColorWhite, fmt.Sprintf("%s [%s - %s] %s\n", other.ID, other.Category, other.Module, other.Question))
+	}
+	printColor(ColorYellow, "\nAdd it anyway? (y/n): ")
+	return strings.ToLower(readInput()) == "y"
+}
+
+// duplicateQuestions shows the clusters of duplicates and merges one on
+// request
+func duplicateQuestions() {
+	for {
+		users := loadAllUsers()
+		clusters := duplicateClusters(quizData.Questions)
+		if len(clusters) == 0 {
+			clearScreen()
+			printBoxHeader("Duplicate Questions", ColorMagenta)
+			fmt.Println()
+			printColor(ColorGreen, fmt.Sprintf("No duplicate questions at the %d%% threshold.\n", config.DuplicateLevel))
+			printColor(ColorYellow, "\n"+tr("Press Enter to continue..."))
+			readInput()
+			return
+		}
+
+		var items []listItem
+		for _, c := range clusters {
+			items = append(items, listItem{
+				label:  quizData.Questions[c.indexes[0]].Question,
+				detail: fmt.Sprintf("%d questions, up to %d%% similar", len(c.indexes), c.highest),
+			})
+		}
+		choice := pickItem("Duplicate Questions", ColorMagenta, items)
+		if choice < 0 {
+			return
+		}
+		mergeCluster(clusters[choice], answerCounts(users))
+	}
+}
+
+// mergeCluster asks which question of a cluster to keep and merges the
+// rest into it
+func mergeCluster(c duplicateCluster, counts map[string]int) {
+	var items []listItem
+	for _, i := range c.indexes {
+		q := quizData.Questions[i]
+		items = append(items, listItem{
+			label:  stemSummary(q.Question),
+			detail: fmt.Sprintf("%s [%s - %s] %d answers", q.ID, q.Category, q.Module, counts[q.ID]),
+		})
+	}
+	keep := pickItem("Keep which question?", ColorMagenta, items)
+	if keep < 0 {
+		return
+	}
+
+	survivor := quizData.Questions[c.indexes[keep]].ID
+	var merged []string
+	for _, i := range c.indexes {
+		if id := quizData.Questions[i].ID; id != survivor {
+			merged = append(merged, id)
+		}
+	}
+	printColor(ColorYellow, fmt.Sprintf("\nMerge %s into %s? Their attempt history moves to %s. (yes/no): ",
+		strings.Join(merged, ", "), survivor, survivor))
+	if strings.ToLower(readInput()) != "yes" {
+		return
+	}
+
+	report, err := mergeQuestions(survivor, merged)
+	if err != nil {
+		printColor(ColorRed, fmt.Sprintf("\n✗ %v\n", err))
+	} else {
+		fmt.Println()
+		for _, line := range report.lines() {
+			printColor(line.color, line.text+"\n")
+		}
+	}
+	printColor(ColorYellow, "\n"+tr("Press Enter to continue..."))
+	readInput()
+}
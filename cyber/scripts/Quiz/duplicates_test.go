@@ -0,0 +1,151 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeStem(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"What does OSINT stand for?", "osint"},
+		{"What is the acronym OSINT short for?", "osint"},
+		{"Which of the following ports are used by DNS servers?", "port dns server"},
+		{"What is the purpose of an access list?", "purpose access list"},
+	}
+	for _, tt := range tests {
+		if got := normalizeStem(tt.in); got != tt.want {
+			t.Errorf("normalizeStem(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	osint := Question{Question: "What does OSINT stand for?", Answer: 0,
+		Options: []string{"Open Source Intelligence", "Operational Security Intel", "Open System Interconnect", "Online Surveillance"}}
+
+	tests := []struct {
+		name     string
+		other    Question
+		min, max int
+	}{
+		{"same question", osint, 100, 100},
+		{"other wording", Question{Question: "What is OSINT short for?", Answer: 0, Options: osint.Options}, 100, 100},
+		{"options reordered and respelt", Question{Question: "OSINT stands for what?", Answer: 2,
+			Options: []string{"Operational Security Intel", "Open System Interconnect", "Open-Source Intelligence", "Online surveillance"}}, 90, 100},
+		{"different answer", Question{Question: "What does OSINT stand for?", Answer: 1, Options: osint.Options}, 70, 85},
+		{"different question", Question{Question: "Which port does SSH listen on?", Answer: 1,
+			Options: []string{"21", "22", "23", "25"}}, 0, 30},
+		{"shared true/false options", Question{Question: "Is nmap a port scanner?", Options: []string{"True", "False"}}, 0, 30},
+	}
+	a := sketchQuestion(osint)
+	for _, tt := range tests {
+		b := sketchQuestion(tt.other)
+		got := similarity(a, b)
+		if got < tt.min || got > tt.max {
+			t.Errorf("%s: %d%% similar, want %d-%d", tt.name, got, tt.min, tt.max)
+		}
+		if back := similarity(b, a); back != got {
+			t.Errorf("%s: similarity is not symmetric, %d and %d", tt.name, got, back)
+		}
+	}
+}
+
+// duplicateBank has three wordings of the OSINT question and two
+// unrelated questions
+func duplicateBank() []Question {
+	options := []string{"Open Source Intelligence", "Operational Security Intel", "Open System Interconnect", "Online Surveillance"}
+	return []Question{
+		{ID: "osint1", Question: "What does OSINT stand for?", Options: options, Category: "PenTest+", Module: "Recon"},
+		{ID: "ssh", Question: "Which port does SSH listen on?", Options: []string{"21", "22", "23", "25"}, Answer: 1, Category: "Networking", Module: "Ports"},
+		{ID: "osint2", Question: "What is the acronym OSINT short for?", Options: options, Category: "Security+", Module: "Threats"},
+		{ID: "dns", Question: "Which port does DNS use?", Options: []string{"53", "80", "443", "25"}, Category: "Networking", Module: "Ports"},
+		{ID: "osint3", Question: "OSINT is an acronym for what?", Options: options, Category: "Security+", Module: "Threats"},
+	}
+}
+
+func TestDuplicateClusters(t *testing.T) {
+	useConfig(t)
+	config.DuplicateLevel = 70
+	bank := duplicateBank()
+
+	clusters := duplicateClusters(bank)
+	if len(clusters) != 1 || !reflect.DeepEqual(clusters[0].indexes, []int{0, 2, 4}) || clusters[0].highest != 100 {
+		t.Fatalf("clusters %+v", clusters)
+	}
+
+	// Adding a new wording warns with the closest matches, best first
+	incoming := Question{ID: "new", Question: "What does the acronym OSINT mean?", Options: bank[0].Options}
+	matches := closestQuestions(incoming, bank, 2)
+	if len(matches) != 2 || bank[matches[0].index].ID != "osint1" || matches[0].similarity < matches[1].similarity {
+		t.Errorf("closest matches %+v", matches)
+	}
+	warnings := duplicateWarnings([]Question{incoming, bank[1]}, bank)
+	if len(warnings) != 3 || !strings.HasPrefix(warnings[0], "new looks like osint1 [PenTest+ - Recon] (100% similar)") {
+		t.Errorf("warnings %q", warnings)
+	}
+}
+
+func TestMergeQuestions(t *testing.T) {
+	useDataDir(t)
+	useIntegrityKey(t)
+	saved := classData
+	t.Cleanup(func() { classData = saved })
+	quizData.Questions = duplicateBank()
+	classData = ClassData{Assignments: []Assignment{
+		{ID: "a1", QuestionIDs: []string{"osint2", "ssh", "osint1"}},
+		{ID: "a2", QuestionIDs: []string{"dns"}},
+	}}
+
+	ada := User{ID: "01HADA0000000000000000000", Name: "Ada", Missed: map[string]int{"osint2": 1, "osint3": 0}}
+	a := Attempt{ID: "att1", Answers: []AnswerRecord{{QuestionID: "osint2", Correct: true}, {QuestionID: "ssh"}, {QuestionID: "osint3"}}}
+	signAttempt(&ada, &a)
+	ada.Attempts = []Attempt{a}
+	sealChainHead(&ada)
+	bob := User{ID: "01HBOB0000000000000000000", Name: "Bob", Attempts: []Attempt{{ID: "att2", Answers: []AnswerRecord{{QuestionID: "osint3"}}, MAC: "forged"}}}
+	saveTracked(ada, bob)
+
+	if _, err := mergeQuestions("osint1", []string{"missing"}); err == nil {
+		t.Error("merged a question that is not in the bank")
+	}
+	report, err := mergeQuestions("osint1", []string{"osint2", "osint3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := questionMergeReport{removed: 2, users: 1, answers: 2, assignments: 1, skipped: []string{bob.ID}}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("report %+v, want %+v", report, want)
+	}
+
+	var ids []string
+	for _, q := range quizData.Questions {
+		ids = append(ids, q.ID)
+	}
+	if !reflect.DeepEqual(ids, []string{"osint1", "ssh", "dns"}) {
+		t.Errorf("bank after merging %v", ids)
+	}
+	if got := classData.Assignments[0].QuestionIDs; !reflect.DeepEqual(got, []string{"osint1", "ssh"}) {
+		t.Errorf("assignment questions %v", got)
+	}
+
+	// Ada's history moves onto the survivor and still verifies; Bob's
+	// tampered record is left as evidence
+	users := loadAllUsers()
+	got := users[0]
+	if len(got.integrityIssues) > 0 {
+		t.Errorf("merged history does not verify: %v", got.integrityIssues)
+	}
+	var answered []string
+	for _, ans := range got.Attempts[0].Answers {
+		answered = append(answered, ans.QuestionID)
+	}
+	if !reflect.DeepEqual(answered, []string{"osint1", "ssh", "osint1"}) || got.Attempts[0].ID != "att1" {
+		t.Errorf("Ada's answers %v", answered)
+	}
+	if !reflect.DeepEqual(got.Missed, map[string]int{"osint1": 0}) {
+		t.Errorf("Ada's missed deck %v, want the lowest streak kept", got.Missed)
+	}
+	if users[1].Attempts[0].Answers[0].QuestionID != "osint3" {
+		t.Error("a tampered user's history was rewritten")
+	}
+}
@@ -0,0 +1,661 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Group is a class or cohort of users, e.g. the Tuesday PenTest+ cohort
+type Group struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Assignment sets a group a module, or a custom set of questions, to
+// complete between two dates
+type Assignment struct {
+	ID          string    `json:"id"`
+	GroupID     string    `json:"group_id"`
+	Title       string    `json:"title"`
+	Category    string    `json:"category,omitempty"`
+	Module      string    `json:"module,omitempty"`
+	QuestionIDs []string  `json:"question_ids,omitempty"` // custom set, used instead of the module when present
+	OpensAt     time.Time `json:"opens_at"`
+	ClosesAt    time.Time `json:"closes_at"`
+	MaxAttempts int       `json:"max_attempts"` // 0 means unlimited
+	PassMark    int       `json:"pass_mark"`    // percentage required to pass
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ClassData holds all groups and assignments
+type ClassData struct {
+	Groups      []Group      `json:"groups"`
+	Assignments []Assignment `json:"assignments"`
+}
+
+const dateTimeLayout = "2006-01-02 15:04"
+
+var classData ClassData
+
+func loadClassData() {
+	if _, err := os.Stat(groupsFile); err == nil {
+		data, _ := os.ReadFile(groupsFile)
+		json.Unmarshal(data, &classData)
+	}
+}
+
+func saveClassData() {
+	data, _ := json.MarshalIndent(classData, "", "  ")
+	os.WriteFile(groupsFile, data, 0644)
+}
+
+func findGroup(id string) *Group {
+	for i := range classData.Groups {
+		if classData.Groups[i].ID == id {
+			return &classData.Groups[i]
+		}
+	}
+	return nil
+}
+
+func inGroup(u User, groupID string) bool {
+	for _, g := range u.Groups {
+		if g == groupID {
+			return true
+		}
+	}
+	return false
+}
+
+func groupMembers(users []User, groupID string) []User {
+	var members []User
+	for _, u := range users {
+		if inGroup(u, groupID) {
+			members = append(members, u)
+		}
+	}
+	return members
+}
+
+func groupAssignments(groupID string) []Assignment {
+	var assignments []Assignment
+	for _, a := range classData.Assignments {
+		if a.GroupID == groupID {
+			assignments = append(assignments, a)
+		}
+	}
+	return assignments
+}
+
+// assignmentQuestions returns the questions an assignment covers
+func assignmentQuestions(a Assignment) []Question {
+	if len(a.QuestionIDs) == 0 {
+		return getQuestionsByModule(a.Category, a.Module)
+	}
+
+	var questions []Question
+	for _, id := range a.QuestionIDs {
+		for _, q := range quizData.Questions {
+			if q.ID == id {
+				questions = append(questions, q)
+				break
+			}
+		}
+	}
+	return questions
+}
+
+func assignmentTarget(a Assignment) string {
+	if len(a.QuestionIDs) > 0 {
+		return fmt.Sprintf("custom set of %d questions", len(a.QuestionIDs))
+	}
+	return a.Category + " - " + a.Module
+}
+
+// assignmentProgress summarises a user's attempts at an assignment
+func assignmentProgress(a Assignment, u User) (attempts int, best float64, passed bool) {
+	best = -1
+	for _, attempt := range u.Attempts {
+		if attempt.AssignmentID != a.ID || len(attempt.Answers) == 0 || attempt.tampered {
+			continue
+		}
+		attempts++
+		pct := attempt.Percent()
+		if pct > best {
+			best = pct
+		}
+	}
+	return attempts, best, best >= float64(a.PassMark)
+}
+
+// assignmentState describes where a user stands on an assignment and
+// whether they may start an attempt now
+func assignmentState(a Assignment, u User, now time.Time) (state, color string, canStart bool) {
+	attempts, _, passed := assignmentProgress(a, u)
+	outOfAttempts := a.MaxAttempts > 0 && attempts >= a.MaxAttempts
+
+	switch {
+	case passed:
+		return "Passed", ColorGreen, !outOfAttempts && (a.ClosesAt.IsZero() || now.Before(a.ClosesAt))
+	case now.Before(a.OpensAt):
+		return "Opens " + a.OpensAt.Format(dateTimeLayout), ColorCyan, false
+	case !a.ClosesAt.IsZero() && now.After(a.ClosesAt):
+		return "Overdue", ColorRed, false
+	case outOfAttempts:
+		return "No attempts left", ColorRed, false
+	case !a.ClosesAt.IsZero() && a.ClosesAt.Sub(now) < 48*time.Hour:
+		return "Due " + a.ClosesAt.Format(dateTimeLayout), ColorYellow, true
+	}
+
+	if a.ClosesAt.IsZero() {
+		return "Open", ColorGreen, true
+	}
+	return "Open until " + a.ClosesAt.Format(dateTimeLayout), ColorGreen, true
+}
+
+func attemptsLabel(a Assignment, attempts int) string {
+	if a.MaxAttempts == 0 {
+		return fmt.Sprintf("%d attempts", attempts)
+	}
+	return fmt.Sprintf("%d/%d attempts", attempts, a.MaxAttempts)
+}
+
+// myAssignments lists the assignments set for the current user's groups
+func myAssignments() {
+	var assignments []Assignment
+	for _, a := range classData.Assignments {
+		if inGroup(*currentUser, a.GroupID) {
+			assignments = append(assignments, a)
+		}
+	}
+
+	if len(assignments) == 0 {
+		clearScreen()
+		printBoxHeader(tr("My Assignments"), ColorBlue)
+		fmt.Println()
+		printColor(ColorYellow, tr("You have no assignments. Ask your instructor to add you to a group.")+"\n")
+		printColor(ColorYellow, "\n"+tr("Press Enter to continue..."))
+		readInput()
+		return
+	}
+
+	now := time.Now()
+	var items []listItem
+	for _, a := range assignments {
+		group := "Ungrouped"
+		if g := findGroup(a.GroupID); g != nil {
+			group = g.Name
+		}
+		state, _, _ := assignmentState(a, *currentUser, now)
+		attempts, best, _ := assignmentProgress(a, *currentUser)
+		detail := fmt.Sprintf("[%s] %s, %s", state, assignmentTarget(a), attemptsLabel(a, attempts))
+		if best >= 0 {
+			detail += fmt.Sprintf(", best %.0f%%", best)
+		}
+		items = append(items, listItem{label: a.Title, detail: detail, group: group})
+	}
+
+	idx := pickItem(tr("My Assignments"), ColorBlue, items)
+	if idx < 0 {
+		return
+	}
+
+	a := assignments[idx]
+	state, color, canStart := assignmentState(a, *currentUser, now)
+	if !canStart {
+		clearScreen()
+		printBoxHeader(a.Title, ColorBlue)
+		fmt.Println()
+		printColor(color, fmt.Sprintf("This assignment cannot be started: %s\n", state))
+		printColor(ColorYellow, "\n"+tr("Press Enter to continue..."))
+		readInput()
+		return
+	}
+
+	runQuiz("Assignments", a.Title, assignmentQuestions(a), &a)
+}
+
+// manageGroups is the admin menu for groups, assignments and gradebooks
+func manageGroups() {
+	for {
+		choice := runMenu(menu{
+			title: "Groups & Assignments",
+			color: ColorMagenta,
+			items: []string{
+				"Create Group",
+				"Manage Group Members",
+				"Delete Group",
+				"Create Assignment",
+				"Delete Assignment",
+				"View Gradebook",
+				"Back",
+			},
+			back: 7,
+		})
+
+		switch choice {
+		case "1":
+			createGroup()
+		case "2":
+			manageGroupMembers()
+		case "3":
+			deleteGroup()
+		case "4":
+			createAssignment()
+		case "5":
+			deleteAssignment()
+		case "6":
+			viewGradebook()
+		case "7":
+			return
+		default:
+			printColor(ColorRed, "Invalid choice. Press Enter to continue...")
+			readInput()
+		}
+	}
+}
+
+// pickGroup asks the admin to choose a group, returning -1 if there are
+// none or the choice was cancelled
+func pickGroup(title string) int {
+	if len(classData.Groups) == 0 {
+		clearScreen()
+		printBoxHeader(title, ColorMagenta)
+		fmt.Println()
+		printColor(ColorRed, "No groups have been created yet.\n")
+		printColor(ColorYellow, tr("Press Enter to continue..."))
+		readInput()
+		return -1
+	}
+
+	users := loadAllUsers()
+	var items []listItem
+	for _, g := range classData.Groups {
+		items = append(items, listItem{
+			label:  g.Name,
+			detail: fmt.Sprintf("(%d members, %d assignments)", len(groupMembers(users, g.ID)), len(groupAssignments(g.ID))),
+		})
+	}
+	return pickItem(title, ColorMagenta, items)
+}
+
+func createGroup() {
+	clearScreen()
+	printBoxHeader("Create Group", ColorGreen)
+	fmt.Println()
+
+	printColor(ColorYellow, "Enter group name (e.g., Tuesday PenTest+): ")
+	name := readInput()
+	if name == "" {
+		return
+	}
+
+	classData.Groups = append(classData.Groups, Group{
+		ID:        newID(),
+		Name:      name,
+		CreatedAt: time.Now(),
+	})
+	saveClassData()
+
+	printColor(ColorGreen+ColorBold, "\n✓ Group created! Add members from Manage Group Members.\n")
+	printColor(ColorYellow, tr("Press Enter to continue..."))
+	readInput()
+}
+
+// manageGroupMembers toggles users in and out of a group
+func manageGroupMembers() {
+	idx := pickGroup("Manage Group Members")
+	if idx < 0 {
+		return
+	}
+	group := classData.Groups[idx]
+
+	users := loadAllUsers()
+	if len(users) == 0 {
+		printColor(ColorYellow, "No users found.\n")
+		printColor(ColorYellow, tr("Press Enter to continue..."))
+		readInput()
+		return
+	}
+
+	l := listView{
+		title: "Members of " + group.Name,
+		color: ColorMagenta,
+		help:  "↑/↓ j/k move · Enter toggle membership · Esc/q done",
+	}
+
+	for {
+		var items []listItem
+		for _, u := range users {
+			mark := "[ ]"
+			if inGroup(u, group.ID) {
+				mark = "[x]"
+			}
+			items = append(items, listItem{label: mark + " " + u.Name, detail: "(ID: " + u.ID + ")"})
+		}
+
+		var choice int
+		if tuiEnabled {
+			l.items = items
+			choice, _ = l.run("")
+		} else {
+			clearScreen()
+			printBoxHeader("Members of "+group.Name, ColorMagenta)
+			fmt.Println()
+			for i, item := range items {
+				printColor(ColorCyan, fmt.Sprintf("%d. ", i+1))
+				printColor(ColorWhite, item.label+" ")
+				printColor(ColorYellow, item.detail+"\n")
+			}
+			printColor(ColorYellow, fmt.Sprintf("\nEnter user number to add/remove (1-%d) or 0 when done: ", len(users)))
+			fmt.Sscanf(readInput(), "%d", &choice)
+			choice--
+		}
+
+		if choice < 0 || choice >= len(users) {
+			return
+		}
+
+		toggleGroupMember(&users[choice], group.ID)
+		saveAllUsers(users)
+	}
+}
+
+// toggleGroupMember adds a user to a group or takes them out of it
+func toggleGroupMember(u *User, groupID string) {
+	if inGroup(*u, groupID) {
+		leaveGroup(u, groupID)
+	} else {
+		u.Groups = append(u.Groups, groupID)
+	}
+}
+
+func leaveGroup(u *User, groupID string) {
+	var kept []string
+	for _, g := range u.Groups {
+		if g != groupID {
+			kept = append(kept, g)
+		}
+	}
+	u.Groups = kept
+}
+
+// removeGroup deletes a group and its assignments and takes every user
+// out of it. saveAllUsers updates the logged in user, so a later saveUser
+// does not put the membership back.
+func removeGroup(groupID string) {
+	var groups []Group
+	for _, g := range classData.Groups {
+		if g.ID != groupID {
+			groups = append(groups, g)
+		}
+	}
+	classData.Groups = groups
+
+	var assignments []Assignment
+	for _, a := range classData.Assignments {
+		if a.GroupID != groupID {
+			assignments = append(assignments, a)
+		}
+	}
+	classData.Assignments = assignments
+	saveClassData()
+
+	users := loadAllUsers()
+	for i := range users {
+		leaveGroup(&users[i], groupID)
+	}
+	saveAllUsers(users)
+}
+
+func deleteGroup() {
+	idx := pickGroup("Delete Group")
+	if idx < 0 {
+		return
+	}
+	group := classData.Groups[idx]
+
+	clearScreen()
+	printBoxHeader("Delete Group", ColorRed)
+	printColor(ColorRed+ColorBold, fmt.Sprintf("\n⚠ WARNING: Delete %s and its %d assignments?\n", group.Name, len(groupAssignments(group.ID))))
+	printColor(ColorYellow, "Learner attempts are kept. Are you sure? (yes/no): ")
+
+	if strings.ToLower(readInput()) != "yes" {
+		printColor(ColorYellow, "\nCancelled.\n")
+		printColor(ColorYellow, tr("Press Enter to continue..."))
+		readInput()
+		return
+	}
+
+	removeGroup(group.ID)
+
+	printColor(ColorGreen+ColorBold, "\n✓ Group deleted successfully!\n")
+	printColor(ColorYellow, tr("Press Enter to continue..."))
+	readInput()
+}
+
+// parseDateInput accepts "YYYY-MM-DD" or "YYYY-MM-DD HH:MM" in local time.
+// A bare date is taken as the start or the end of that day.
+func parseDateInput(input string, endOfDay bool) (time.Time, error) {
+	if t, err := time.ParseInLocation(dateTimeLayout, input, time.Local); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", input, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected YYYY-MM-DD or YYYY-MM-DD HH:MM")
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Minute)
+	}
+	return t, nil
+}
+
+func createAssignment() {
+	idx := pickGroup("Create Assignment")
+	if idx < 0 {
+		return
+	}
+	group := classData.Groups[idx]
+
+	clearScreen()
+	printBoxHeader("Create Assignment for "+group.Name, ColorGreen)
+	fmt.Println()
+
+	a := Assignment{
+		ID:        newID(),
+		GroupID:   group.ID,
+		CreatedAt: time.Now(),
+	}
+
+	printColor(ColorYellow, "Enter assignment title: ")
+	a.Title = readInput()
+
+	printColor(ColorYellow, "Target a (1) module or (2) custom question set? ")
+	if readInput() == "2" {
+		printColor(ColorCyan, "Enter question IDs separated by commas (see List All Questions): ")
+		for _, id := range strings.Split(readInput(), ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				a.QuestionIDs = append(a.QuestionIDs, id)
+			}
+		}
+	} else {
+		printColor(ColorYellow, "Enter Category (e.g., CompTIA, Cisco): ")
+		a.Category = readInput()
+		printColor(ColorYellow, "Enter Module (e.g., PenTest+, CCNA): ")
+		a.Module = readInput()
+	}
+
+	if len(assignmentQuestions(a)) == 0 {
+		printColor(ColorRed, "\n✗ No matching questions found for this assignment.\n")
+		printColor(ColorYellow, tr("Press Enter to continue..."))
+		readInput()
+		return
+	}
+
+	for {
+		printColor(ColorYellow, "Opens (YYYY-MM-DD [HH:MM], Enter for now): ")
+		input := readInput()
+		if input == "" {
+			a.OpensAt = time.Now()
+			break
+		}
+		t, err := parseDateInput(input, false)
+		if err == nil {
+			a.OpensAt = t
+			break
+		}
+		printColor(ColorRed, fmt.Sprintf("Invalid date: %v\n", err))
+	}
+
+	for {
+		printColor(ColorYellow, "Due (YYYY-MM-DD [HH:MM], Enter for no due date): ")
+		input := readInput()
+		if input == "" {
+			break
+		}
+		t, err := parseDateInput(input, true)
+		if err == nil && t.After(a.OpensAt) {
+			a.ClosesAt = t
+			break
+		}
+		if err == nil {
+			err = fmt.Errorf("due date must be after the open date")
+		}
+		printColor(ColorRed, fmt.Sprintf("Invalid date: %v\n", err))
+	}
+
+	printColor(ColorYellow, "Attempt limit (Enter for unlimited): ")
+	fmt.Sscanf(readInput(), "%d", &a.MaxAttempts)
+	if a.MaxAttempts < 0 {
+		a.MaxAttempts = 0
+	}
+
+	a.PassMark = 70
+	printColor(ColorYellow, "Pass mark percentage [70]: ")
+	fmt.Sscanf(readInput(), "%d", &a.PassMark)
+	if a.PassMark < 0 || a.PassMark > 100 {
+		a.PassMark = 70
+	}
+
+	classData.Assignments = append(classData.Assignments, a)
+	saveClassData()
+
+	printColor(ColorGreen+ColorBold, fmt.Sprintf("\n✓ Assignment '%s' set for %s!\n", a.Title, group.Name))
+	printColor(ColorYellow, tr("Press Enter to continue..."))
+	readInput()
+}
+
+func deleteAssignment() {
+	if len(classData.Assignments) == 0 {
+		clearScreen()
+		printBoxHeader("Delete Assignment", ColorRed)
+		fmt.Println()
+		printColor(ColorRed, "No assignments available to delete.\n")
+		printColor(ColorYellow, tr("Press Enter to continue..."))
+		readInput()
+		return
+	}
+
+	var items []listItem
+	for _, a := range classData.Assignments {
+		group := "Ungrouped"
+		if g := findGroup(a.GroupID); g != nil {
+			group = g.Name
+		}
+		items = append(items, listItem{label: a.Title, detail: "(" + assignmentTarget(a) + ")", group: group})
+	}
+
+	idx := pickItem("Delete Assignment", ColorRed, items)
+	if idx < 0 {
+		return
+	}
+
+	classData.Assignments = append(classData.Assignments[:idx], classData.Assignments[idx+1:]...)
+	saveClassData()
+
+	clearScreen()
+	printBoxHeader("Delete Assignment", ColorRed)
+	printColor(ColorGreen+ColorBold, "\n✓ Assignment deleted. Learner attempts are kept.\n")
+	printColor(ColorYellow, tr("Press Enter to continue..."))
+	readInput()
+}
+
+// viewGradebook shows every member of a group against each of the
+// group's assignments
+func viewGradebook() {
+	idx := pickGroup("View Gradebook")
+	if idx < 0 {
+		return
+	}
+	group := classData.Groups[idx]
+	members := groupMembers(loadAllUsers(), group.ID)
+	assignments := groupAssignments(group.ID)
+	now := time.Now()
+
+	lines := []pageLine{
+		{ColorCyan + ColorBold, fmt.Sprintf("%s: %d members, %d assignments", group.Name, len(members), len(assignments))},
+		{},
+	}
+
+	if len(assignments) == 0 || len(members) == 0 {
+		lines = append(lines, pageLine{ColorYellow, "Nothing to show yet - add members and assignments to this group."})
+		showPager("Gradebook", ColorMagenta, lines)
+		return
+	}
+
+	for i, a := range assignments {
+		due := "no due date"
+		if !a.ClosesAt.IsZero() {
+			due = "due " + a.ClosesAt.Format(dateTimeLayout)
+		}
+		lines = append(lines, pageLine{ColorWhite, fmt.Sprintf("A%d  %s - %s, %s, pass %d%%", i+1, a.Title, assignmentTarget(a), due, a.PassMark)})
+	}
+	lines = append(lines, pageLine{})
+
+	rows := gradebookRows(members, assignments, now)
+	lines = append(lines, pageLine{ColorCyan + ColorBold, rows[0]})
+	for _, row := range rows[1:] {
+		lines = append(lines, pageLine{ColorWhite, row})
+	}
+
+	lines = append(lines, pageLine{}, pageLine{ColorYellow, "✓ passed  ✗ best score below pass mark  ! overdue  - not attempted  xN attempts"})
+	showPager("Gradebook", ColorMagenta, lines)
+}
+
+// gradebookRows lays out the gradebook table, a header followed by a row
+// per member. Columns are padded by display width because the cells hold
+// ✓ and ✗.
+func gradebookRows(members []User, assignments []Assignment, now time.Time) []string {
+	header := padRight("Learner", 24)
+	for i := range assignments {
+		header += " " + padRight(fmt.Sprintf("A%d", i+1), 14)
+	}
+	rows := []string{header + " Passed"}
+
+	for _, u := range members {
+		row := padRight(fmt.Sprintf("%s (%s)", u.Name, u.ID), 24)
+		passedCount := 0
+		for _, a := range assignments {
+			attempts, best, passed := assignmentProgress(a, u)
+			state, _, _ := assignmentState(a, u, now)
+
+			cell := "-"
+			switch {
+			case passed:
+				passedCount++
+				cell = fmt.Sprintf("✓ %.0f%% x%d", best, attempts)
+			case attempts > 0:
+				cell = fmt.Sprintf("✗ %.0f%% x%d", best, attempts)
+			case state == "Overdue":
+				cell = "! overdue"
+			}
+			row += " " + padRight(cell, 14)
+		}
+		rows = append(rows, row+fmt.Sprintf(" %d/%d", passedCount, len(assignments)))
+	}
+	return rows
+}
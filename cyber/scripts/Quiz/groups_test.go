@@ -0,0 +1,108 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRemoveGroup(t *testing.T) {
+	useDataDir(t)
+	useIntegrityKey(t)
+	saved := classData
+	t.Cleanup(func() { classData = saved })
+	classData = ClassData{
+		Groups:      []Group{{ID: "g1", Name: "Tuesday"}, {ID: "g2", Name: "Thursday"}},
+		Assignments: []Assignment{{ID: "a1", GroupID: "g1"}, {ID: "a2", GroupID: "g2"}},
+	}
+	saveAllUsers([]User{{ID: "u1", Name: "Ada", Groups: []string{"g1", "g2"}}, {ID: "u2", Name: "Bob", Groups: []string{"g1"}}})
+	currentUser = &loadAllUsers()[0]
+
+	removeGroup("g1")
+	if len(classData.Groups) != 1 || classData.Groups[0].ID != "g2" || len(classData.Assignments) != 1 || classData.Assignments[0].ID != "a2" {
+		t.Errorf("class data after removing g1: %+v", classData)
+	}
+	if !reflect.DeepEqual(currentUser.Groups, []string{"g2"}) {
+		t.Errorf("logged in user still has groups %q", currentUser.Groups)
+	}
+
+	// Saving the logged in user afterwards keeps the change
+	saveUser()
+	for _, u := range loadAllUsers() {
+		if inGroup(u, "g1") {
+			t.Errorf("%s is still in the removed group", u.Name)
+		}
+	}
+
+	// Toggling twice restores the membership
+	toggleGroupMember(currentUser, "g3")
+	toggleGroupMember(currentUser, "g2")
+	if !reflect.DeepEqual(currentUser.Groups, []string{"g3"}) {
+		t.Errorf("groups after toggling %q", currentUser.Groups)
+	}
+}
+
+func TestAssignmentState(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	attempt := func(pct int) Attempt {
+		a := Attempt{AssignmentID: "a1"}
+		for i := 0; i < 10; i++ {
+			a.Answers = append(a.Answers, AnswerRecord{Correct: i < pct/10})
+		}
+		return a
+	}
+	open := Assignment{ID: "a1", OpensAt: now.Add(-time.Hour), PassMark: 70, MaxAttempts: 2}
+	tests := []struct {
+		name     string
+		change   func(a *Assignment)
+		attempts []Attempt
+		state    string
+		canStart bool
+	}{
+		{"open", func(a *Assignment) {}, nil, "Open", true},
+		{"not yet open", func(a *Assignment) { a.OpensAt = now.Add(time.Hour) }, nil, "Opens 2026-03-10 13:00", false},
+		{"due soon", func(a *Assignment) { a.ClosesAt = now.Add(time.Hour) }, nil, "Due 2026-03-10 13:00", true},
+		{"overdue", func(a *Assignment) { a.ClosesAt = now.Add(-time.Minute) }, []Attempt{attempt(50)}, "Overdue", false},
+		{"out of attempts", func(a *Assignment) {}, []Attempt{attempt(50), attempt(60)}, "No attempts left", false},
+		{"passed", func(a *Assignment) {}, []Attempt{attempt(80)}, "Passed", true},
+		{"passed and closed", func(a *Assignment) { a.ClosesAt = now.Add(-time.Minute) }, []Attempt{attempt(80)}, "Passed", false},
+	}
+	for _, tt := range tests {
+		a := open
+		tt.change(&a)
+		state, _, canStart := assignmentState(a, User{Attempts: tt.attempts}, now)
+		if state != tt.state || canStart != tt.canStart {
+			t.Errorf("%s: %q, %v; want %q, %v", tt.name, state, canStart, tt.state, tt.canStart)
+		}
+	}
+
+	// Tampered attempts do not count towards progress
+	tampered := attempt(90)
+	tampered.tampered = true
+	if attempts, _, passed := assignmentProgress(open, User{Attempts: []Attempt{tampered}}); attempts != 0 || passed {
+		t.Errorf("tampered attempt counted: %d attempts, passed %v", attempts, passed)
+	}
+}
+
+func TestGradebookRows(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	assignments := []Assignment{
+		{ID: "a1", PassMark: 50},
+		{ID: "a2", PassMark: 50, ClosesAt: now.Add(-time.Hour)},
+	}
+	members := []User{
+		{ID: "u1", Name: "Zoë", Attempts: []Attempt{{AssignmentID: "a1", Answers: []AnswerRecord{{Correct: true}}}}},
+		{ID: "u2", Name: "Bob", Attempts: []Attempt{{AssignmentID: "a1", Answers: []AnswerRecord{{Correct: false}}}}},
+	}
+	rows := gradebookRows(members, assignments, now)
+	// ✓ and ✗ take two columns, like everything the TUI draws
+	want := []string{
+		"Learner                  A1             A2             Passed",
+		"Zoë (u1)                 ✓ 100% x1     ! overdue      1/2",
+		"Bob (u2)                 ✗ 0% x1       ! overdue      0/2",
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("gradebook rows\n%s\nwant\n%s", strings.Join(rows, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Translations. The interface is written in English and message
+// catalogues map English strings to other languages, so a missing entry
+// falls back to the English text. Catalogues ship in locales/ and can be
+// added or extended with <data dir>/locales/<code>.json:
+//
+//	{"language": "Español", "messages": {"Take Quiz": "Hacer un cuestionario"}}
+//
+// Questions carry their own translations by language code; untranslated
+// fields fall back to the original.
+
+//go:embed locales/*.json
+var builtinLocales embed.FS
+
+const sourceLanguage = "en"
+
+// catalogue is a language's messages, keyed by the English text
+type catalogue struct {
+	Language string            `json:"language"` // name of the language in itself
+	Messages map[string]string `json:"messages"`
+}
+
+// QuestionText is a question's text in another language
+type QuestionText struct {
+	Question    string   `json:"question,omitempty"`
+	Options     []string `json:"options,omitempty"`
+	Explanation string   `json:"explanation,omitempty"`
+}
+
+var (
+	catalogues = make(map[string]catalogue)
+
+	// currentLanguage is the code of the language in use
+	currentLanguage = sourceLanguage
+)
+
+// loadCatalogues reads the built-in catalogues and those in the data
+// directory, whose messages override built-in ones
+func loadCatalogues() {
+	entries, _ := builtinLocales.ReadDir("locales")
+	for _, e := range entries {
+		data, err := builtinLocales.ReadFile("locales/" + e.Name())
+		if err == nil {
+			addCatalogue(strings.TrimSuffix(e.Name(), ".json"), data)
+		}
+	}
+
+	files, _ := filepath.Glob(filepath.Join(cacheDir, "locales", "*.json"))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		if err := addCatalogue(strings.TrimSuffix(filepath.Base(file), ".json"), data); err != nil {
+			printColor(ColorRed, fmt.Sprintf("⚠ Ignoring %s: %v\n", file, err))
+		}
+	}
+}
+
+func addCatalogue(code string, data []byte) error {
+	var c catalogue
+	if err := json.Unmarshal(data, &c); err != nil {
+		return err
+	}
+	code = normalizeLanguage(code)
+	existing, ok := catalogues[code]
+	if !ok {
+		existing = catalogue{Language: c.Language, Messages: make(map[string]string)}
+	}
+	if c.Language != "" {
+		existing.Language = c.Language
+	}
+	for k, v := range c.Messages {
+		existing.Messages[k] = v
+	}
+	catalogues[code] = existing
+	return nil
+}
+
+// normalizeLanguage turns locale names like pt_BR.UTF-8 into pt-br
+func normalizeLanguage(code string) string {
+	code, _, _ = strings.Cut(code, ".")
+	code, _, _ = strings.Cut(code, "@")
+	return strings.ToLower(strings.ReplaceAll(code, "_", "-"))
+}
+
+// matchLanguage finds the available language for a code, trying the
+// base language when there is no catalogue for the region
+func matchLanguage(code string) (string, bool) {
+	code = normalizeLanguage(code)
+	if code == "" || code == "c" || code == "posix" {
+		return "", false
+	}
+	base, _, _ := strings.Cut(code, "-")
+	for _, c := range []string{code, base} {
+		if _, ok := catalogues[c]; ok || c == sourceLanguage {
+			return c, true
+		}
+	}
+	return "", false
+}
+
+// systemLanguage reads the language from the locale environment
+func systemLanguage() string {
+	for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if code, ok := matchLanguage(os.Getenv(env)); ok {
+			return code
+		}
+	}
+	return sourceLanguage
+}
+
+// selectLanguage picks the user's language, then the configured one, then
+// the system's
+func selectLanguage(user *User) {
+	currentLanguage = systemLanguage()
+	if code, ok := matchLanguage(config.Language); ok {
+		currentLanguage = code
+	}
+	if user != nil {
+		if code, ok := matchLanguage(user.Language); ok {
+			currentLanguage = code
+		}
+	}
+}
+
+// availableLanguages lists the language codes with the source first
+func availableLanguages() []string {
+	var codes []string
+	for code := range catalogues {
+		if code != sourceLanguage {
+			codes = append(codes, code)
+		}
+	}
+	sort.Strings(codes)
+	return append([]string{sourceLanguage}, codes...)
+}
+
+func languageName(code string) string {
+	if c, ok := catalogues[code]; ok && c.Language != "" {
+		return c.Language
+	}
+	if code == sourceLanguage {
+		return "English"
+	}
+	return code
+}
+
+// tr translates an interface message, falling back to the English text
+func tr(msg string) string {
+	if c, ok := catalogues[currentLanguage]; ok {
+		if t := c.Messages[msg]; t != "" {
+			return t
+		}
+	}
+	return msg
+}
+
+// trf translates a format string and fills it in
+func trf(format string, args ...any) string {
+	return fmt.Sprintf(tr(format), args...)
+}
+
+// translation returns a question's translation for a language, trying the
+// base language for regional codes
+func translation(q Question, code string) (QuestionText, bool) {
+	if t, ok := q.Translations[code]; ok {
+		return t, true
+	}
+	base, _, _ := strings.Cut(code, "-")
+	t, ok := q.Translations[base]
+	return t, ok
+}
+
+// localized returns the question in the current language. Fields without
+// a translation, and translated options that do not match the original
+// in number, keep the original text.
+func localized(q Question) Question {
+	if currentLanguage == sourceLanguage {
+		return q
+	}
+	t, ok := translation(q, currentLanguage)
+	if !ok {
+		return q
+	}
+	if t.Question != "" {
+		q.Question = t.Question
+	}
+	if len(t.Options) == len(q.Options) {
+		q.Options = t.Options
+	}
+	if t.Explanation != "" {
+		q.Explanation = t.Explanation
+	}
+	return q
+}
+
+// missingTranslations lists the languages with a catalogue that a
+// question is not fully translated into
+func missingTranslations(q Question) []string {
+	var missing []string
+	for _, code := range availableLanguages()[1:] {
+		t, ok := translation(q, code)
+		if !ok || t.Question == "" || len(t.Options) != len(q.Options) ||
+			(q.Explanation != "" && t.Explanation == "") {
+			missing = append(missing, code)
+		}
+	}
+	return missing
+}
+
+// translationLines reports, per language, the questions not fully
+// translated
+func translationLines() []pageLine {
+	languages := availableLanguages()[1:]
+	if len(languages) == 0 {
+		return []pageLine{{ColorYellow, "No languages other than English are installed."}}
+	}
+
+	missing := make(map[string][]Question)
+	for _, q := range quizData.Questions {
+		for _, code := range missingTranslations(q) {
+			missing[code] = append(missing[code], q)
+		}
+	}
+
+	var lines []pageLine
+	for _, code := range languages {
+		done := len(quizData.Questions) - len(missing[code])
+		color := ColorGreen
+		if len(missing[code]) > 0 {
+			color = ColorYellow
+		}
+		lines = append(lines, pageLine{color + ColorBold, fmt.Sprintf("%s (%s): %d of %d questions translated",
+			languageName(code), code, done, len(quizData.Questions))})
+		for _, q := range missing[code] {
+			lines = append(lines, pageLine{ColorWhite, fmt.Sprintf("  ⚠ %s [%s - %s] %s", q.ID, q.Category, q.Module, stemSummary(q.Question))})
+		}
+		lines = append(lines, pageLine{})
+	}
+	return lines
+}
+
+// chooseLanguage lets the current user pick their language
+func chooseLanguage() {
+	codes := availableLanguages()
+	var items []listItem
+	for _, code := range codes {
+		item := listItem{label: languageName(code), detail: code}
+		if code == currentLanguage {
+			item.detail += " " + tr("(current)")
+		}
+		items = append(items, item)
+	}
+
+	idx := pickItem(tr("Language"), ColorBlue, items)
+	if idx < 0 || idx >= len(codes) {
+		return
+	}
+	currentUser.Language = codes[idx]
+	saveUser()
+	selectLanguage(currentUser)
+	printColor(ColorGreen, "\n"+trf("✓ Language set to %s", languageName(currentLanguage))+"\n")
+	pause(1 * time.Second)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// useLanguage switches the interface language for the duration of a test
+func useLanguage(t *testing.T, code string) {
+	t.Helper()
+	saved, savedLanguage := catalogues, currentLanguage
+	t.Cleanup(func() { catalogues, currentLanguage = saved, savedLanguage })
+	catalogues = make(map[string]catalogue)
+	loadCatalogues()
+	currentLanguage = code
+}
+
+func TestMatchLanguage(t *testing.T) {
+	useLanguage(t, sourceLanguage)
+	addCatalogue("pt_BR", []byte(`{"language": "Português (Brasil)", "messages": {}}`))
+
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"es", "es", true},
+		{"es_MX.UTF-8", "es", true},
+		{"ES-es", "es", true},
+		{"pt-BR", "pt-br", true},
+		{"pt_BR.UTF-8@euro", "pt-br", true},
+		{"pt_PT", "", false},
+		{"en_GB.UTF-8", "en", true},
+		{"de_DE", "", false},
+		{"C", "", false},
+		{"POSIX", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := matchLanguage(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("matchLanguage(%q) = %q, %v; want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestSelectLanguage(t *testing.T) {
+	useConfig(t)
+	useLanguage(t, sourceLanguage)
+	t.Setenv("LC_ALL", "")
+	t.Setenv("LC_MESSAGES", "de_DE.UTF-8")
+	t.Setenv("LANG", "es_ES.UTF-8")
+
+	tests := []struct {
+		name         string
+		config, user string
+		want         string
+	}{
+		{"system, skipping a language without a catalogue", "", "", "es"},
+		{"configured", "en", "", "en"},
+		{"unknown configured language", "de", "", "es"},
+		{"the user's choice", "en", "es", "es"},
+		{"unknown user language", "en", "fr", "en"},
+	}
+	for _, tt := range tests {
+		config.Language = tt.config
+		selectLanguage(&User{Language: tt.user})
+		if currentLanguage != tt.want {
+			t.Errorf("%s: %q, want %q", tt.name, currentLanguage, tt.want)
+		}
+	}
+}
+
+func TestCatalogueFallback(t *testing.T) {
+	useDataDir(t)
+	os.MkdirAll(filepath.Join(cacheDir, "locales"), 0755)
+	os.WriteFile(filepath.Join(cacheDir, "locales", "es.json"),
+		[]byte(`{"messages": {"Take Quiz": "Empezar", "Untranslated upstream": "Traducido aquí"}}`), 0644)
+	os.WriteFile(filepath.Join(cacheDir, "locales", "fr.json"), []byte(`{"language": "Français", "messages": {"Exit": "Quitter"}}`), 0644)
+	os.WriteFile(filepath.Join(cacheDir, "locales", "de.json"), []byte(`{not json`), 0644)
+	useLanguage(t, "es")
+
+	tests := []struct {
+		language, msg, want string
+	}{
+		{"es", "Take Quiz", "Empezar"},                    // data directory overrides the built-in entry
+		{"es", "Untranslated upstream", "Traducido aquí"}, // and adds to it
+		{"es", "%d questions", "%d preguntas"},            // built-in entries are kept
+		{"es", "Not in any catalogue", "Not in any catalogue"},
+		{"fr", "Exit", "Quitter"},
+		{"fr", "Take Quiz", "Take Quiz"},
+		{"en", "Take Quiz", "Take Quiz"},
+	}
+	for _, tt := range tests {
+		currentLanguage = tt.language
+		if got := tr(tt.msg); got != tt.want {
+			t.Errorf("%s: tr(%q) = %q, want %q", tt.language, tt.msg, got, tt.want)
+		}
+	}
+	if got := availableLanguages(); !reflect.DeepEqual(got, []string{"en", "es", "fr"}) {
+		t.Errorf("languages %v; the malformed catalogue should be ignored", got)
+	}
+	if languageName("es") != "Español" || languageName("fr") != "Français" || languageName("en") != "English" {
+		t.Errorf("language names %q %q %q", languageName("es"), languageName("fr"), languageName("en"))
+	}
+}
+
+func TestLocalized(t *testing.T) {
+	useLanguage(t, sourceLanguage)
+	q := Question{
+		ID: "q1", Question: "Which port does SSH use?", Options: []string{"21", "22"}, Explanation: "SSH listens on 22",
+		Translations: map[string]QuestionText{
+			"es":    {Question: "¿Qué puerto usa SSH?", Options: []string{"21", "22"}},
+			"fr-ca": {Question: "Quel port utilise SSH?", Options: []string{"22"}, Explanation: "SSH écoute sur 22"},
+		},
+	}
+
+	tests := []struct {
+		language              string
+		question, explanation string
+		options               []string
+	}{
+		{"en", "Which port does SSH use?", "SSH listens on 22", []string{"21", "22"}},
+		{"es", "¿Qué puerto usa SSH?", "SSH listens on 22", []string{"21", "22"}},
+		{"es-mx", "¿Qué puerto usa SSH?", "SSH listens on 22", []string{"21", "22"}},
+		// Options that do not match the original in number are not used
+		{"fr-ca", "Quel port utilise SSH?", "SSH écoute sur 22", []string{"21", "22"}},
+		{"fr", "Which port does SSH use?", "SSH listens on 22", []string{"21", "22"}},
+	}
+	for _, tt := range tests {
+		currentLanguage = tt.language
+		got := localized(q)
+		if got.Question != tt.question || got.Explanation != tt.explanation || !reflect.DeepEqual(got.Options, tt.options) {
+			t.Errorf("%s: %q / %q / %v", tt.language, got.Question, got.Explanation, got.Options)
+		}
+	}
+
+	// Spanish is missing the explanation
+	if got := missingTranslations(q); !reflect.DeepEqual(got, []string{"es"}) {
+		t.Errorf("missing translations %v", got)
+	}
+	q.Translations["es"] = QuestionText{Question: "¿Qué puerto usa SSH?", Options: []string{"21", "22"}, Explanation: "SSH escucha en el 22"}
+	if got := missingTranslations(q); len(got) != 0 {
+		t.Errorf("missing translations %v after translating", got)
+	}
+}
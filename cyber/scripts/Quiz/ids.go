@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Identifiers. New questions, users, attempts, groups and assignments get
+// a ULID: a millisecond timestamp followed by 80 random bits, written as
+// 26 characters of Crockford base32. ULIDs sort by creation time, and IDs
+// made in the same millisecond count up from the first, so scripts and
+// imports that add many records at once cannot collide.
+//
+// Older versions built IDs from the time in seconds or from user names,
+// which could collide. migrateDuplicateIDs renames the later of any
+// duplicates on startup and points their history at the new ID.
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+var lastULID struct {
+	ms      uint64
+	entropy [10]byte
+}
+
+// newID returns a new ULID
+func newID() string {
+	ms := uint64(time.Now().UnixMilli())
+	if ms == lastULID.ms {
+		// Count up from the last ID so IDs stay unique and ordered
+		for i := len(lastULID.entropy) - 1; i >= 0; i-- {
+			lastULID.entropy[i]++
+			if lastULID.entropy[i] != 0 {
+				break
+			}
+		}
+	} else {
+		lastULID.ms = ms
+		copy(lastULID.entropy[:], randomBytes(len(lastULID.entropy)))
+	}
+
+	var raw [16]byte
+	binary.BigEndian.PutUint16(raw[0:2], uint16(ms>>32))
+	binary.BigEndian.PutUint32(raw[2:6], uint32(ms))
+	copy(raw[6:], lastULID.entropy[:])
+
+	// Read the 128 bits five at a time, after two leading zero bits
+	var b strings.Builder
+	for i := 0; i < 26; i++ {
+		v := 0
+		for bit := i*5 - 2; bit < i*5+3; bit++ {
+			v <<= 1
+			if bit >= 0 && raw[bit/8]>>(7-bit%8)&1 == 1 {
+				v |= 1
+			}
+		}
+		b.WriteByte(crockford[v])
+	}
+	return b.String()
+}
+
+// idRename records an ID the migration changed
+type idRename struct {
+	kind     string // question, user or attempt
+	old, new string
+	note     string
+}
+
+// pendingQuestionRenames holds questions renamed while the bank loaded,
+// whose references are updated by migrateDuplicateIDs
+var pendingQuestionRenames []questionRename
+
+type questionRename struct {
+	old, new         string
+	module, keptFrom string // "Category - Module" of the renamed and the kept question
+}
+
+// renameDuplicateQuestions gives every question after the first with the
+// same ID a new one. Without this the layer merge would keep only the last.
+func renameDuplicateQuestions(questions []Question) []Question {
+	first := make(map[string]Question)
+	for i, q := range questions {
+		kept, seen := first[q.ID]
+		if !seen {
+			first[q.ID] = q
+			continue
+		}
+		questions[i].ID = newID()
+		pendingQuestionRenames = append(pendingQuestionRenames, questionRename{
+			old:      q.ID,
+			new:      questions[i].ID,
+			module:   q.Category + " - " + q.Module,
+			keptFrom: kept.Category + " - " + kept.Module,
+		})
+	}
+	return questions
+}
+
+// migrateDuplicateIDs renames duplicate question, user and attempt IDs and
+// updates the references to them. Records of users that fail verification
+// are left alone, as re-signing them would hide the tampering.
+func migrateDuplicateIDs() []idRename {
+	var renames []idRename
+	for _, r := range pendingQuestionRenames {
+		note := "answers in " + r.module + " moved to it"
+		if r.module == r.keptFrom {
+			note = "both were in " + r.module + ", so past answers stay with " + r.old
+		}
+		renames = append(renames, idRename{"question", r.old, r.new, note})
+	}
+	if len(pendingQuestionRenames) > 0 {
+		saveQuestions()
+	}
+
+	users := loadAllUsers()
+	changed := false
+	seenUsers := make(map[string]int)
+	for i := range users {
+		u := &users[i]
+		backup := *u
+		backup.Attempts = make([]Attempt, len(u.Attempts))
+		for j, a := range u.Attempts {
+			a.Answers = append([]AnswerRecord(nil), a.Answers...)
+			backup.Attempts[j] = a
+		}
+
+		var own []idRename
+		moved := false
+
+		// Answers recorded in the renamed question's module were to it
+		for _, r := range pendingQuestionRenames {
+			if r.module == r.keptFrom {
+				continue
+			}
+			for j := range u.Attempts {
+				a := &u.Attempts[j]
+				if a.Category+" - "+a.Module != r.module {
+					continue
+				}
+				for k := range a.Answers {
+					if a.Answers[k].QuestionID == r.old {
+						if a.ID == "" {
+							// Legacy IDs derive from the answers, so fix them first
+							a.ID = attemptID(*a)
+						}
+						a.Answers[k].QuestionID = r.new
+						moved = true
+					}
+				}
+			}
+		}
+
+		first, dupUser := seenUsers[u.ID]
+		if !dupUser {
+			seenUsers[u.ID] = i
+		} else {
+			u.ID = newID()
+			own = append(own, idRename{"user", backup.ID, u.ID, u.Name})
+		}
+
+		seenAttempts := make(map[string]bool)
+		for j := range u.Attempts {
+			a := &u.Attempts[j]
+			if a.ID == "" {
+				continue
+			}
+			if seenAttempts[a.ID] {
+				old := a.ID
+				a.ID = newID()
+				own = append(own, idRename{"attempt", old, a.ID, fmt.Sprintf("%s, %s - %s", u.ID, a.Category, a.Module)})
+			}
+			seenAttempts[a.ID] = true
+		}
+
+		if len(own) == 0 && !moved {
+			continue
+		}
+		if len(u.integrityIssues) > 0 {
+			*u = backup
+			renames = append(renames, idRename{"user", u.ID, u.ID,
+				u.Name + " not migrated: the records fail the integrity check"})
+			continue
+		}
+
+		if dupUser {
+			moveCertificates(backup.ID, u.ID, u.Name, users[first].Name)
+		}
+		resignRecords(u)
+		renames = append(renames, own...)
+		changed = true
+	}
+
+	if changed {
+		saveAllUsers(users)
+	}
+	pendingQuestionRenames = nil
+	return renames
+}
+
+// moveCertificates moves a renamed user's pending certificates to their
+// new ID. Issued certificates are signed, so they keep the old ID.
+func moveCertificates(old, new, name, otherName string) {
+	if name == otherName {
+		return
+	}
+	certs := loadCertificates()
+	moved := false
+	for i := range certs {
+		if certs[i].UserID == old && certs[i].Name == name && certs[i].Signature == "" {
+			certs[i].UserID = new
+			moved = true
+		}
+	}
+	if moved {
+		saveCertificates(certs)
+	}
+}
+
+// printIDMigration reports what migrateDuplicateIDs changed
+func printIDMigration(renames []idRename) {
+	if len(renames) == 0 {
+		return
+	}
+	printColor(ColorYellow, "⚠ Duplicate IDs from an older version were fixed:\n")
+	for _, r := range renames {
+		if r.old == r.new {
+			printColor(ColorRed, fmt.Sprintf("  %s %s: %s\n", r.kind, r.old, r.note))
+			continue
+		}
+		printColor(ColorYellow, fmt.Sprintf("  %s %s is now %s (%s)\n", r.kind, r.old, r.new, r.note))
+	}
+}
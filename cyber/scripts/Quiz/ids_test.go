@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+)
+
+// decodeULID reverses the base32 encoding in newID
+func decodeULID(t *testing.T, id string) (ms uint64, entropy []byte) {
+	t.Helper()
+	var raw [16]byte
+	for i := 0; i < len(id); i++ {
+		v := strings.IndexByte(crockford, id[i])
+		if v < 0 {
+			t.Fatalf("%q: %q is not Crockford base32", id, id[i])
+		}
+		for n, bit := 0, i*5-2; bit < i*5+3; n, bit = n+1, bit+1 {
+			if v>>(4-n)&1 == 0 {
+				continue
+			}
+			if bit < 0 {
+				t.Fatalf("%q: the leading bits are not zero", id)
+			}
+			raw[bit/8] |= 1 << (7 - bit%8)
+		}
+	}
+	ms = uint64(binary.BigEndian.Uint16(raw[0:2]))<<32 | uint64(binary.BigEndian.Uint32(raw[2:6]))
+	return ms, raw[6:]
+}
+
+func TestNewID(t *testing.T) {
+	before := uint64(time.Now().UnixMilli())
+	ids := make([]string, 5000)
+	for i := range ids {
+		ids[i] = newID()
+	}
+	after := uint64(time.Now().UnixMilli())
+
+	for i, id := range ids {
+		if len(id) != 26 {
+			t.Fatalf("%q has %d characters", id, len(id))
+		}
+		ms, _ := decodeULID(t, id)
+		if ms < before || ms > after {
+			t.Errorf("%q has time %d, outside %d-%d", id, ms, before, after)
+		}
+		if i > 0 && id <= ids[i-1] {
+			t.Fatalf("%q does not sort after %q", id, ids[i-1])
+		}
+	}
+}
+
+func TestNewIDCountsUp(t *testing.T) {
+	tests := []struct {
+		last, want []byte
+	}{
+		{[]byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 1}, []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 2}},
+		{[]byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0xff}, []byte{0, 0, 0, 0, 0, 0, 0, 0, 1, 0}},
+		{[]byte{7, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, []byte{8, 0, 0, 0, 0, 0, 0, 0, 0, 0}},
+	}
+	for _, tt := range tests {
+		// Retry until newID runs in the same millisecond as the last ID
+		for try := 0; ; try++ {
+			ms := uint64(time.Now().UnixMilli())
+			lastULID.ms = ms
+			copy(lastULID.entropy[:], tt.last)
+			id := newID()
+			got, entropy := decodeULID(t, id)
+			if got != ms {
+				if try == 100 {
+					t.Fatal("the clock moved on every try")
+				}
+				continue
+			}
+			if !bytes.Equal(entropy, tt.want) {
+				t.Errorf("after %x: %q has entropy %x, want %x", tt.last, id, entropy, tt.want)
+			}
+			break
+		}
+	}
+}
+
+func TestRenameDuplicateQuestions(t *testing.T) {
+	t.Cleanup(func() { pendingQuestionRenames = nil })
+	pendingQuestionRenames = nil
+	questions := renameDuplicateQuestions([]Question{
+		{ID: "q1", Category: "Networking", Module: "Subnetting"},
+		{ID: "q2", Category: "Networking", Module: "Subnetting"},
+		{ID: "q1", Category: "Linux", Module: "Shell"},
+		{ID: "q1", Category: "Networking", Module: "Subnetting"},
+	})
+
+	if questions[0].ID != "q1" || questions[1].ID != "q2" {
+		t.Errorf("the first questions were renamed: %q, %q", questions[0].ID, questions[1].ID)
+	}
+	if questions[2].ID == "q1" || questions[3].ID == "q1" || questions[2].ID == questions[3].ID {
+		t.Fatalf("duplicates kept clashing IDs: %q, %q", questions[2].ID, questions[3].ID)
+	}
+	want := []questionRename{
+		{"q1", questions[2].ID, "Linux - Shell", "Networking - Subnetting"},
+		{"q1", questions[3].ID, "Networking - Subnetting", "Networking - Subnetting"},
+	}
+	if len(pendingQuestionRenames) != len(want) {
+		t.Fatalf("pending renames %+v, want %+v", pendingQuestionRenames, want)
+	}
+	for i := range want {
+		if pendingQuestionRenames[i] != want[i] {
+			t.Errorf("rename %d: %+v, want %+v", i, pendingQuestionRenames[i], want[i])
+		}
+	}
+}
+
+// addAttempt appends a signed attempt answering question qid
+func addAttempt(u *User, id, category, module, qid string, minutes int) {
+	finished := syncEpoch.Add(time.Duration(minutes) * time.Minute)
+	a := Attempt{ID: id, Category: category, Module: module, StartedAt: finished.Add(-time.Minute), FinishedAt: finished,
+		Answers: []AnswerRecord{{QuestionID: qid, Chosen: 1, Correct: true}}}
+	signAttempt(u, &a)
+	u.Attempts = append(u.Attempts, a)
+	sealChainHead(u)
+}
+
+func TestMigrateDuplicateIDs(t *testing.T) {
+	useDataDir(t)
+	useIntegrityKey(t)
+	t.Cleanup(func() { pendingQuestionRenames = nil })
+	pendingQuestionRenames = nil
+
+	quizData.Questions = renameDuplicateQuestions([]Question{
+		{ID: "q1", Question: "Mask?", Options: []string{"a", "b"}, Category: "Networking", Module: "Subnetting"},
+		{ID: "q1", Question: "List?", Options: []string{"a", "b"}, Category: "Linux", Module: "Shell"},
+	})
+	renamed := quizData.Questions[1].ID
+
+	// Two users share an ID from the name-based scheme, and Bob has two
+	// attempts with the same ID
+	const shared = "user-ada"
+	ada := User{ID: shared, Name: "Ada"}
+	addAttempt(&ada, "", "Linux", "Shell", "q1", 1)
+	addAttempt(&ada, "n1", "Networking", "Subnetting", "q1", 2)
+	bob := User{ID: shared, Name: "Bob"}
+	addAttempt(&bob, "b1", "Networking", "Subnetting", "q1", 3)
+	addAttempt(&bob, "b1", "Networking", "Subnetting", "q1", 4)
+	eve := User{ID: "user-eve", Name: "Eve"}
+	addAttempt(&eve, "e1", "Linux", "Shell", "q1", 5)
+	eve.Attempts[0].Answers[0].Chosen = 0 // edited after signing
+	legacyID := attemptID(ada.Attempts[0])
+	saveAllUsers([]User{ada, bob, eve})
+	saveCertificates([]Certificate{
+		{UserID: shared, Name: "Bob", Category: "Networking", Module: "Subnetting"},
+		{UserID: shared, Name: "Ada", Category: "Networking", Module: "Subnetting"},
+	})
+
+	renames := migrateDuplicateIDs()
+	if pendingQuestionRenames != nil {
+		t.Error("pending question renames were not cleared")
+	}
+	kinds := map[string]int{}
+	for _, r := range renames {
+		kinds[r.kind]++
+	}
+	if kinds["question"] != 1 || kinds["user"] != 2 || kinds["attempt"] != 1 {
+		t.Errorf("renames %+v", renames)
+	}
+
+	users := loadAllUsers()
+	if len(users) != 3 {
+		t.Fatalf("%d users after the migration", len(users))
+	}
+	ada, bob, eve = users[0], users[1], users[2]
+	for _, u := range []User{ada, bob} {
+		if len(u.integrityIssues) > 0 {
+			t.Errorf("%s's migrated records do not verify: %v", u.Name, u.integrityIssues)
+		}
+	}
+
+	if ada.ID != shared || bob.ID == shared {
+		t.Errorf("user IDs %q and %q, want Ada to keep %q", ada.ID, bob.ID, shared)
+	}
+	if got := ada.Attempts[0]; got.ID != legacyID || got.Answers[0].QuestionID != renamed {
+		t.Errorf("Ada's Linux attempt %q answers %q, want %q answering %q", got.ID, got.Answers[0].QuestionID, legacyID, renamed)
+	}
+	if got := ada.Attempts[1].Answers[0].QuestionID; got != "q1" {
+		t.Errorf("an answer in the kept question's module moved to %q", got)
+	}
+	if bob.Attempts[0].ID != "b1" || bob.Attempts[1].ID == "b1" {
+		t.Errorf("Bob's attempt IDs %v", attemptIDs(bob))
+	}
+
+	// Eve's records fail the check, so they are reported and left alone
+	if len(eve.integrityIssues) == 0 || eve.Attempts[0].Answers[0].QuestionID != "q1" {
+		t.Errorf("tampered user was migrated: %+v", eve.Attempts)
+	}
+	var noted bool
+	for _, r := range renames {
+		noted = noted || r.old == eve.ID && strings.Contains(r.note, "not migrated")
+	}
+	if !noted {
+		t.Errorf("renames %+v do not report Eve", renames)
+	}
+
+	// Pending certificates follow the renamed user
+	certs := loadCertificates()
+	if certs[0].UserID != bob.ID || certs[1].UserID != shared {
+		t.Errorf("certificates for %q and %q, want %q and %q", certs[0].UserID, certs[1].UserID, bob.ID, shared)
+	}
+
+	// The renamed question was saved, and a second run changes nothing
+	if !strings.Contains(mustRead(t, questionsFile), renamed) {
+		t.Error("the renamed question was not saved")
+	}
+	if again := migrateDuplicateIDs(); len(again) != 0 {
+		t.Errorf("second run renamed %+v", again)
+	}
+}
@@ -0,0 +1,445 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Tamper-evident score records. Every attempt carries an HMAC over its
+// contents and the MAC of the attempt before it, so the attempts form a
+// hash chain per user. The user's chain head seals the length of the
+// chain, so removing the newest attempts is detected too. Module scores
+// carry their own HMAC.
+//
+// Records written before this existed have no MAC and are reported as
+// unsigned rather than tampered; an admin can seal them after checking.
+// The admin config notes when each user's records started being signed
+// and how many unsigned records the user had then, so a record that loses
+// its MAC later is reported as tampered, not as a legacy record.
+//
+// The HMAC key is created when the admin password is first set and is
+// kept in admin.json sealed with that password, like the certificate
+// signing key, so reading admin.json does not give it away. The notes are
+// covered by a MAC of their own. A session unlocks the key with the admin
+// password, typed at startup or given in $CYBER_QUIZ_ADMIN_PASSWORD by the
+// classroom launcher; while it is locked quiz results are not saved, since
+// they could not be signed. admin.json and users.json are only readable by
+// their owner.
+
+// integrityKey is the HMAC key once unlocked this session
+var integrityKey []byte
+
+// integrityStatesEdited is set when the signing notes fail their MAC
+var integrityStatesEdited bool
+
+// ensureIntegrityKey unseals the HMAC key with the admin password typed
+// this session, creating it on first use. A key older versions left in
+// plain text is used as it is until the password is known, then sealed.
+func ensureIntegrityKey() {
+	if adminConfig.IntegrityKey != "" && integrityKey == nil {
+		if key, err := base64.StdEncoding.DecodeString(adminConfig.IntegrityKey); err == nil && len(key) > 0 {
+			integrityKey = key
+		}
+	}
+	if adminPassword == "" {
+		checkIntegrityStates()
+		return
+	}
+
+	if adminConfig.SealedIntegrityKey != nil {
+		if key, err := unseal(adminPassword, adminConfig.SealedIntegrityKey); err == nil {
+			integrityKey = key
+		}
+		checkIntegrityStates()
+		return
+	}
+	if integrityKey == nil {
+		integrityKey = randomBytes(32)
+	}
+	sealed, err := seal(adminPassword, integrityKey)
+	if err != nil {
+		return
+	}
+	checkIntegrityStates()
+	adminConfig.SealedIntegrityKey, adminConfig.IntegrityKey = sealed, ""
+	saveAdminConfig()
+}
+
+// recordsLocked reports whether records are signed but the key that signs
+// them has not been unlocked this session
+func recordsLocked() bool {
+	return integrityKey == nil && adminConfig.SealedIntegrityKey != nil
+}
+
+// integrityStatesMAC covers the notes of when signing began for each user
+func integrityStatesMAC(states map[string]IntegrityState) string {
+	ids := make([]string, 0, len(states))
+	for id := range states {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	parts := []string{"states/v1"}
+	for _, id := range ids {
+		parts = append(parts, id, canonicalTime(states[id].Since), fmt.Sprintf("%d", states[id].Legacy))
+	}
+	return integrityMAC(parts...)
+}
+
+// checkIntegrityStates checks the signing notes once the key is known.
+// Notes written before they had a MAC are trusted and given one.
+func checkIntegrityStates() {
+	if integrityKey == nil {
+		return
+	}
+	if adminConfig.IntegrityStatesMAC == "" {
+		if len(adminConfig.IntegrityStates) > 0 {
+			adminConfig.IntegrityStatesMAC = integrityStatesMAC(adminConfig.IntegrityStates)
+			saveAdminConfig()
+		}
+		return
+	}
+	integrityStatesEdited = !hmac.Equal([]byte(adminConfig.IntegrityStatesMAC), []byte(integrityStatesMAC(adminConfig.IntegrityStates)))
+}
+
+// IntegrityState is when signing began for a user
+type IntegrityState struct {
+	Since  time.Time `json:"since"`
+	Legacy int       `json:"legacy"` // unsigned records the user had then
+}
+
+// trackIntegrity notes that a user's records are signed from now on,
+// reporting whether the admin config changed
+func trackIntegrity(u *User) bool {
+	if integrityKey == nil {
+		return false
+	}
+	if _, ok := adminConfig.IntegrityStates[u.ID]; ok {
+		return false
+	}
+	if adminConfig.IntegrityStates == nil {
+		adminConfig.IntegrityStates = make(map[string]IntegrityState)
+	}
+	adminConfig.IntegrityStates[u.ID] = IntegrityState{Since: time.Now().UTC(), Legacy: u.unsignedRecords}
+	if !integrityStatesEdited {
+		adminConfig.IntegrityStatesMAC = integrityStatesMAC(adminConfig.IntegrityStates)
+	}
+	return true
+}
+
+func unsignedCount(u User) int {
+	n := 0
+	for _, a := range u.Attempts {
+		if a.MAC == "" {
+			n++
+		}
+	}
+	for _, modules := range u.Scores {
+		for _, s := range modules {
+			if s.MAC == "" {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// trackAllUsers starts tracking users seen for the first time, trusting
+// their records as they are now
+func trackAllUsers() {
+	changed := false
+	for _, u := range loadAllUsers() {
+		if trackIntegrity(&u) {
+			changed = true
+		}
+	}
+	if changed {
+		saveAdminConfig()
+	}
+}
+
+func integrityMAC(parts ...string) string {
+	mac := hmac.New(sha256.New, integrityKey)
+	mac.Write([]byte(strings.Join(parts, "\n")))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func canonicalTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// attemptMAC covers every recorded field of an attempt. Fields added to
+// Attempt later must only be appended here when set, so existing MACs stay
+// valid.
+func attemptMAC(userID, prev string, a Attempt) string {
+	return integrityMAC(attemptParts(userID, prev, a)...)
+}
+
+// attemptParts lists the fields an attempt's MAC covers
+func attemptParts(userID, prev string, a Attempt) []string {
+	answers := make([]string, len(a.Answers))
+	for i, ans := range a.Answers {
+		answers[i] = fmt.Sprintf("%s:%d:%t", ans.QuestionID, ans.Chosen, ans.Correct)
+		if ans.Credit != 0 {
+			answers[i] += fmt.Sprintf(":%.4f", ans.Credit)
+		}
+		if len(ans.Typed) > 0 {
+			answers[i] += ":" + strings.Join(ans.Typed, "\x1f")
+		}
+	}
+	parts := []string{"attempt/v1", userID, prev, a.Category, a.Module, a.AssignmentID,
+		canonicalTime(a.StartedAt), canonicalTime(a.FinishedAt), strings.Join(answers, ",")}
+	if a.ID != "" {
+		parts = append(parts, "id:"+a.ID)
+	}
+	if a.Origin != "" {
+		parts = append(parts, "origin:"+a.Origin)
+	}
+	return parts
+}
+
+func scoreMAC(userID, category, module string, s Score) string {
+	result := fmt.Sprintf("%d/%d", s.Correct, s.Total)
+	if s.Points != 0 {
+		result += fmt.Sprintf(" %.4f", s.Points)
+	}
+	return integrityMAC("score/v1", userID, category, module, result, canonicalTime(s.LastTaken))
+}
+
+func chainHeadMAC(userID, lastMAC string, count int) string {
+	return integrityMAC("head/v1", userID, lastMAC, fmt.Sprintf("%d", count))
+}
+
+// signAttempt links a new attempt onto the end of the user's chain
+func signAttempt(u *User, a *Attempt) {
+	a.Prev = ""
+	if n := len(u.Attempts); n > 0 {
+		a.Prev = u.Attempts[n-1].MAC
+	}
+	a.MAC = attemptMAC(u.ID, a.Prev, *a)
+}
+
+// sealChainHead records the chain length after attempts were appended
+func sealChainHead(u *User) {
+	signed, last := 0, ""
+	for _, a := range u.Attempts {
+		if a.MAC != "" {
+			signed++
+			last = a.MAC
+		}
+	}
+	u.ChainHead = ""
+	if signed > 0 {
+		u.ChainHead = chainHeadMAC(u.ID, last, signed)
+	}
+}
+
+// verifyUserRecords checks a user's attempts and scores, marking tampered
+// records and collecting a description of each problem
+func verifyUserRecords(u *User) {
+	u.integrityIssues = nil
+	u.unsignedRecords = 0
+	if integrityKey == nil {
+		// Nothing can be checked until the key is unlocked
+		return
+	}
+	if integrityStatesEdited {
+		u.integrityIssues = append(u.integrityIssues, "the notes of when signing began were edited in admin.json")
+	}
+	state, tracked := adminConfig.IntegrityStates[u.ID]
+	// signedSince reports whether a record dated t should carry a MAC
+	signedSince := func(t time.Time) bool {
+		return tracked && !t.Before(state.Since)
+	}
+
+	prev, signed, chainStarted := "", 0, false
+	for i := range u.Attempts {
+		a := &u.Attempts[i]
+		label := fmt.Sprintf("attempt %d (%s - %s, %s)", i+1, a.Category, a.Module, a.FinishedAt.Format(dateTimeLayout))
+
+		if a.MAC == "" {
+			if chainStarted {
+				a.tampered = true
+				u.integrityIssues = append(u.integrityIssues, label+": unsigned record inside the signed chain")
+			} else if signedSince(a.FinishedAt) {
+				a.tampered = true
+				u.integrityIssues = append(u.integrityIssues, label+": unsigned record dated after signing began")
+			} else {
+				u.unsignedRecords++
+			}
+			prev = ""
+			continue
+		}
+
+		chainStarted = true
+		signed++
+		switch {
+		case a.Prev != prev:
+			a.tampered = true
+			u.integrityIssues = append(u.integrityIssues, label+": chain broken, an earlier attempt was removed or reordered")
+		case !hmac.Equal([]byte(a.MAC), []byte(attemptMAC(u.ID, a.Prev, *a))):
+			a.tampered = true
+			u.integrityIssues = append(u.integrityIssues, label+": contents were modified")
+		}
+		prev = a.MAC
+	}
+
+	if chainStarted && u.ChainHead != chainHeadMAC(u.ID, prev, signed) {
+		u.integrityIssues = append(u.integrityIssues, "attempt history was truncated or the chain head was edited")
+	} else if !chainStarted && u.ChainHead != "" {
+		u.integrityIssues = append(u.integrityIssues, "signed attempts were removed")
+	}
+
+	categories := make([]string, 0, len(u.Scores))
+	for category := range u.Scores {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	for _, category := range categories {
+		for module, s := range u.Scores[category] {
+			if s.MAC == "" {
+				if signedSince(s.LastTaken) {
+					s.tampered = true
+					u.Scores[category][module] = s
+					u.integrityIssues = append(u.integrityIssues, fmt.Sprintf("score for %s - %s is unsigned but dated after signing began", category, module))
+				} else {
+					u.unsignedRecords++
+				}
+				continue
+			}
+			if !hmac.Equal([]byte(s.MAC), []byte(scoreMAC(u.ID, category, module, s))) {
+				s.tampered = true
+				u.Scores[category][module] = s
+				u.integrityIssues = append(u.integrityIssues, fmt.Sprintf("score for %s - %s was modified", category, module))
+			}
+		}
+	}
+
+	if tracked && u.unsignedRecords > state.Legacy {
+		u.integrityIssues = append(u.integrityIssues, fmt.Sprintf("%d records lost their signatures after signing began",
+			u.unsignedRecords-state.Legacy))
+	}
+}
+
+// sealUnsignedRecords signs a user's legacy records. It re-signs the whole
+// chain, so it must only be used on users without integrity issues.
+func sealUnsignedRecords(u *User) {
+	prev := ""
+	for i := range u.Attempts {
+		u.Attempts[i].Prev = prev
+		u.Attempts[i].MAC = attemptMAC(u.ID, prev, u.Attempts[i])
+		prev = u.Attempts[i].MAC
+	}
+	sealChainHead(u)
+
+	for category, modules := range u.Scores {
+		for module, s := range modules {
+			if s.MAC == "" {
+				s.MAC = scoreMAC(u.ID, category, module, s)
+				modules[module] = s
+			}
+		}
+	}
+	u.unsignedRecords = 0
+	if state, ok := adminConfig.IntegrityStates[u.ID]; ok {
+		state.Legacy = 0
+		adminConfig.IntegrityStates[u.ID] = state
+		if !integrityStatesEdited {
+			adminConfig.IntegrityStatesMAC = integrityStatesMAC(adminConfig.IntegrityStates)
+		}
+	}
+}
+
+// resignRecords signs a user's signed attempts and scores again after
+// their contents or the user's ID changed, leaving unsigned records as
+// they are. Like sealUnsignedRecords it must only be used on users
+// without integrity issues.
+func resignRecords(u *User) {
+	prev := ""
+	for i := range u.Attempts {
+		a := &u.Attempts[i]
+		if a.MAC == "" {
+			prev = ""
+			continue
+		}
+		a.Prev = prev
+		a.MAC = attemptMAC(u.ID, prev, *a)
+		prev = a.MAC
+	}
+	sealChainHead(u)
+
+	for category, modules := range u.Scores {
+		for module, s := range modules {
+			if s.MAC != "" {
+				s.MAC = scoreMAC(u.ID, category, module, s)
+				modules[module] = s
+			}
+		}
+	}
+}
+
+// integrityReportLines describes the integrity state of every user
+func integrityReportLines(users []User) (lines []pageLine, issues, unsigned int) {
+	for _, u := range users {
+		issues += len(u.integrityIssues)
+		unsigned += u.unsignedRecords
+
+		switch {
+		case len(u.integrityIssues) > 0:
+			lines = append(lines, pageLine{ColorRed + ColorBold, fmt.Sprintf("✗ %s (%s): %d problems", u.Name, u.ID, len(u.integrityIssues))})
+			for _, issue := range u.integrityIssues {
+				lines = append(lines, pageLine{ColorRed, "    ⚠ " + issue})
+			}
+		case u.unsignedRecords > 0:
+			lines = append(lines, pageLine{ColorYellow, fmt.Sprintf("? %s (%s): %d unsigned legacy records", u.Name, u.ID, u.unsignedRecords)})
+		default:
+			lines = append(lines, pageLine{ColorGreen, fmt.Sprintf("✓ %s (%s): %d attempts verified", u.Name, u.ID, len(u.Attempts))})
+		}
+	}
+
+	summary := pageLine{ColorGreen + ColorBold, fmt.Sprintf("All %d users verified.", len(users))}
+	if issues > 0 {
+		summary = pageLine{ColorRed + ColorBold, fmt.Sprintf("%d integrity problems found.", issues)}
+	} else if unsigned > 0 {
+		summary = pageLine{ColorYellow + ColorBold, fmt.Sprintf("No tampering found; %d legacy records are unsigned.", unsigned)}
+	}
+	return append([]pageLine{summary, {}}, lines...), issues, unsigned
+}
+
+// integrityCheck is the admin view of the integrity report
+func integrityCheck() {
+	users := loadAllUsers()
+	lines, _, unsigned := integrityReportLines(users)
+	showPager("Integrity Check", ColorRed, lines)
+
+	if unsigned == 0 {
+		return
+	}
+
+	clearScreen()
+	printBoxHeader("Integrity Check", ColorRed)
+	fmt.Println()
+	printColor(ColorYellow, fmt.Sprintf("%d legacy records are unsigned. Only seal them if you trust their current values.\n", unsigned))
+	printColor(ColorYellow, "Seal unsigned records for users without problems? (yes/no): ")
+	if strings.ToLower(readInput()) != "yes" {
+		return
+	}
+
+	sealed := 0
+	for i := range users {
+		if len(users[i].integrityIssues) == 0 && users[i].unsignedRecords > 0 {
+			sealUnsignedRecords(&users[i])
+			sealed++
+		}
+	}
+	saveAllUsers(users)
+	saveAdminConfig()
+
+	printColor(ColorGreen+ColorBold, fmt.Sprintf("\n✓ Sealed records for %d users.\n", sealed))
+	printColor(ColorYellow, tr("Press Enter to continue..."))
+	readInput()
+}
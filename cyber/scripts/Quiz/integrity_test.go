@@ -0,0 +1,270 @@
+package main
+
+import (
+	"encoding/base64"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+var signingBegan = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// useIntegrityKey unlocks a new integrity key with a test admin password
+func useIntegrityKey(t *testing.T) {
+	t.Helper()
+	if adminPassword == "" {
+		adminPassword = "admin-password"
+	}
+	ensureIntegrityKey()
+	if integrityKey == nil {
+		t.Fatal("the integrity key was not unlocked")
+	}
+}
+
+// signedUser returns a user whose three attempts and score were recorded
+// after signing began
+func signedUser(t *testing.T) User {
+	t.Helper()
+	useDataDir(t)
+	useIntegrityKey(t)
+
+	u := User{ID: "01HUSER0000000000000000000", Name: "Ada", Scores: map[string]map[string]Score{}}
+	for i := 0; i < 3; i++ {
+		finished := signingBegan.Add(time.Duration(i+1) * time.Hour)
+		a := Attempt{
+			ID: newID(), Category: "Networking", Module: "Subnetting",
+			StartedAt: finished.Add(-10 * time.Minute), FinishedAt: finished,
+			Answers: []AnswerRecord{{QuestionID: "q1", Chosen: 1, Correct: i > 0}, {QuestionID: "q2", Chosen: 0}},
+		}
+		signAttempt(&u, &a)
+		u.Attempts = append(u.Attempts, a)
+	}
+	sealChainHead(&u)
+
+	s := Score{Correct: 1, Total: 2, LastTaken: u.Attempts[2].FinishedAt}
+	s.MAC = scoreMAC(u.ID, "Networking", "Subnetting", s)
+	u.Scores["Networking"] = map[string]Score{"Subnetting": s}
+
+	adminConfig.IntegrityStates = map[string]IntegrityState{u.ID: {Since: signingBegan}}
+	return u
+}
+
+func TestVerifyUserRecords(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(u *User)
+		want   string // expected issue, empty for none
+	}{
+		{"untouched", func(u *User) {}, ""},
+		{"score raised", func(u *User) {
+			s := u.Scores["Networking"]["Subnetting"]
+			s.Correct = 2
+			u.Scores["Networking"]["Subnetting"] = s
+		}, "score for Networking - Subnetting was modified"},
+		{"score raised and its MAC removed", func(u *User) {
+			u.Scores["Networking"]["Subnetting"] = Score{Correct: 2, Total: 2, LastTaken: u.Attempts[2].FinishedAt}
+		}, "unsigned but dated after signing began"},
+		{"score backdated and its MAC removed", func(u *User) {
+			u.Scores["Networking"]["Subnetting"] = Score{Correct: 2, Total: 2, LastTaken: signingBegan.Add(-time.Hour)}
+		}, "1 records lost their signatures after signing began"},
+		{"answer changed", func(u *User) { u.Attempts[1].Answers[1].Correct = true }, "attempt 2 (Networking - Subnetting"},
+		{"middle attempt removed", func(u *User) { u.Attempts = append(u.Attempts[:1], u.Attempts[2:]...) }, "chain broken"},
+		{"newest attempt removed", func(u *User) { u.Attempts = u.Attempts[:2] }, "truncated"},
+		{"attempts reordered", func(u *User) { u.Attempts[0], u.Attempts[1] = u.Attempts[1], u.Attempts[0] }, "chain broken"},
+		{"all attempts removed", func(u *User) { u.Attempts = nil }, "signed attempts were removed"},
+		{"every MAC and the chain head stripped", func(u *User) {
+			for i := range u.Attempts {
+				u.Attempts[i].MAC, u.Attempts[i].Prev = "", ""
+			}
+			u.ChainHead = ""
+		}, "unsigned record dated after signing began"},
+		{"every MAC stripped and attempts backdated", func(u *User) {
+			for i := range u.Attempts {
+				u.Attempts[i].MAC, u.Attempts[i].Prev = "", ""
+				u.Attempts[i].FinishedAt = signingBegan.Add(-time.Hour)
+			}
+			u.ChainHead = ""
+		}, "3 records lost their signatures after signing began"},
+		{"unsigned attempt appended", func(u *User) {
+			u.Attempts = append(u.Attempts, Attempt{Category: "Networking", Module: "Subnetting", FinishedAt: signingBegan.Add(-time.Hour)})
+		}, "unsigned record inside the signed chain"},
+		{"re-signed with another key", func(u *User) {
+			integrityKey = randomBytes(32)
+		}, "contents were modified"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := signedUser(t)
+			tt.tamper(&u)
+			verifyUserRecords(&u)
+			issues := strings.Join(u.integrityIssues, "\n")
+			if tt.want == "" && issues != "" {
+				t.Errorf("unexpected issues:\n%s", issues)
+			}
+			if tt.want != "" && !strings.Contains(issues, tt.want) {
+				t.Errorf("issues:\n%s\nwant one containing %q", issues, tt.want)
+			}
+		})
+	}
+}
+
+func TestLegacyRecords(t *testing.T) {
+	useDataDir(t)
+	useIntegrityKey(t)
+	old := signingBegan.Add(-24 * time.Hour)
+	u := User{
+		ID:       "01HLEGACY00000000000000000",
+		Attempts: []Attempt{{Category: "Networking", Module: "Subnetting", FinishedAt: old}},
+		Scores:   map[string]map[string]Score{"Networking": {"Subnetting": {Correct: 1, Total: 2, LastTaken: old}}},
+	}
+
+	// Before tracking starts, unsigned records are legacy ones
+	verifyUserRecords(&u)
+	if len(u.integrityIssues) > 0 || u.unsignedRecords != 2 {
+		t.Fatalf("untracked user: issues %v, %d unsigned", u.integrityIssues, u.unsignedRecords)
+	}
+	if !trackIntegrity(&u) || trackIntegrity(&u) {
+		t.Fatal("trackIntegrity should only start tracking once")
+	}
+	if state := adminConfig.IntegrityStates[u.ID]; state.Legacy != 2 {
+		t.Fatalf("tracked %d legacy records, want 2", state.Legacy)
+	}
+	verifyUserRecords(&u)
+	if len(u.integrityIssues) > 0 {
+		t.Fatalf("legacy records flagged after tracking: %v", u.integrityIssues)
+	}
+
+	// Sealing signs them and leaves nothing to grandfather
+	sealUnsignedRecords(&u)
+	verifyUserRecords(&u)
+	if len(u.integrityIssues) > 0 || u.unsignedRecords != 0 {
+		t.Fatalf("after sealing: issues %v, %d unsigned", u.integrityIssues, u.unsignedRecords)
+	}
+	if state := adminConfig.IntegrityStates[u.ID]; state.Legacy != 0 {
+		t.Errorf("legacy count after sealing is %d", state.Legacy)
+	}
+
+	// so stripping a MAC from a sealed legacy record is now tampering
+	s := u.Scores["Networking"]["Subnetting"]
+	s.MAC, s.Correct = "", 2
+	u.Scores["Networking"]["Subnetting"] = s
+	verifyUserRecords(&u)
+	if len(u.integrityIssues) == 0 {
+		t.Error("a sealed record that lost its MAC was not flagged")
+	}
+}
+
+func TestResignRecords(t *testing.T) {
+	u := signedUser(t)
+	u.ID = "01HRENAMED0000000000000000"
+	adminConfig.IntegrityStates[u.ID] = adminConfig.IntegrityStates["01HUSER0000000000000000000"]
+	verifyUserRecords(&u)
+	if len(u.integrityIssues) == 0 {
+		t.Fatal("records signed for another user ID verified")
+	}
+	resignRecords(&u)
+	verifyUserRecords(&u)
+	if len(u.integrityIssues) > 0 {
+		t.Errorf("after re-signing: %v", u.integrityIssues)
+	}
+}
+
+func TestIntegrityKeySealing(t *testing.T) {
+	useDataDir(t)
+
+	// Nothing is signed before there is an admin password
+	ensureIntegrityKey()
+	if integrityKey != nil || recordsLocked() {
+		t.Fatal("a key was created without the admin password")
+	}
+
+	// Setting the password creates the key sealed under it
+	adminConfig.PasswordHash = newPasswordHash("admin-password")
+	if !checkAdminPassword("admin-password") || integrityKey == nil {
+		t.Fatal("the admin password did not unlock the key")
+	}
+	key := integrityKey
+	admin := mustRead(t, adminFile)
+	if adminConfig.SealedIntegrityKey == nil || strings.Contains(admin, `"integrity_key"`) ||
+		strings.Contains(admin, base64.StdEncoding.EncodeToString(key)) {
+		t.Fatalf("admin.json holds the key in plain text:\n%s", admin)
+	}
+	for _, file := range []string{adminFile, usersFile} {
+		os.WriteFile(file, []byte("[]"), 0644)
+		saveAdminConfig()
+		saveAllUsers(nil)
+		if info, err := os.Stat(file); err != nil || info.Mode().Perm() != 0600 {
+			t.Errorf("%s: %v, %v", file, info.Mode(), err)
+		}
+	}
+
+	// A new session is locked until the password is given
+	integrityKey, adminPassword = nil, ""
+	ensureIntegrityKey()
+	if !recordsLocked() {
+		t.Fatal("the sealed key was unlocked without the password")
+	}
+	if checkAdminPassword("wrong-password") || !recordsLocked() {
+		t.Fatal("a wrong password unlocked the key")
+	}
+	if !checkAdminPassword("admin-password") || string(integrityKey) != string(key) {
+		t.Fatal("the password did not unlock the same key")
+	}
+
+	// A plaintext key from an older version is kept, and sealed once the
+	// password is known
+	integrityKey, adminPassword = nil, ""
+	adminConfig.SealedIntegrityKey = nil
+	adminConfig.IntegrityKey = base64.StdEncoding.EncodeToString(key)
+	ensureIntegrityKey()
+	if string(integrityKey) != string(key) || recordsLocked() {
+		t.Fatal("the older plaintext key was not used")
+	}
+	checkAdminPassword("admin-password")
+	if adminConfig.IntegrityKey != "" || adminConfig.SealedIntegrityKey == nil || string(integrityKey) != string(key) {
+		t.Error("the older plaintext key was not sealed")
+	}
+}
+
+func TestPracticeMode(t *testing.T) {
+	useDataDir(t)
+	adminConfig.PasswordHash = newPasswordHash("admin-password")
+	checkAdminPassword("admin-password")
+	currentUser = &User{ID: "01HUSER0000000000000000000", Name: "Ada"}
+	saveUser()
+
+	integrityKey, adminPassword = nil, ""
+	attempt := Attempt{Category: "Networking", Module: "Ports", FinishedAt: time.Now(), Answers: []AnswerRecord{{QuestionID: "q1", Correct: true}}}
+	saveScore(attempt)
+	if u := loadAllUsers()[0]; len(u.Attempts) != 0 || len(u.Scores) != 0 {
+		t.Fatalf("a result was saved while the key was locked: %+v", u)
+	}
+
+	checkAdminPassword("admin-password")
+	saveScore(attempt)
+	if u := loadAllUsers()[0]; len(u.Attempts) != 1 || u.Attempts[0].MAC == "" || len(u.integrityIssues) > 0 {
+		t.Fatalf("result after unlocking: %+v, issues %v", u.Attempts, u.integrityIssues)
+	}
+}
+
+func TestIntegrityStatesMAC(t *testing.T) {
+	u := signedUser(t)
+	adminConfig.PasswordHash = newPasswordHash(adminPassword)
+	adminConfig.IntegrityStates = nil
+	if !trackIntegrity(&u) {
+		t.Fatal("the user was already tracked")
+	}
+	saveAdminConfig()
+
+	// Raising the legacy count would let stripped records pass as legacy
+	state := adminConfig.IntegrityStates[u.ID]
+	state.Legacy = 3
+	adminConfig.IntegrityStates[u.ID] = state
+	integrityKey = nil
+	ensureIntegrityKey()
+	verifyUserRecords(&u)
+	if !strings.Contains(strings.Join(u.integrityIssues, "\n"), "notes of when signing began were edited") {
+		t.Errorf("edited notes not reported: %v", u.integrityIssues)
+	}
+}
@@ -0,0 +1,1471 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A small model of a Cisco IOS router or switch for lab questions. It
+// keeps the modes, the running-config and enough commands for CCNA lab
+// tasks: interfaces, VLANs and switchports, static routes, OSPF, access
+// lists and the usual show commands. Keywords can be abbreviated as on a
+// real device, and global configuration commands work from any
+// configuration sub-mode.
+//
+// Unlike a real 2960, the switch keeps its VLANs in the running-config,
+// as in VTP transparent mode, so labs can check them. Every interface
+// with no shutdown is treated as connected.
+
+type iosMode int
+
+const (
+	modeUser iosMode = iota
+	modePrivileged
+	modeConfig
+	modeInterface
+	modeVLAN
+	modeRouter
+	modeACL
+)
+
+// iosCommand is a keyword of a mode, with its help text and the kind of
+// device it is for when only one has it
+type iosCommand struct {
+	keyword, help, device string
+}
+
+var iosCommands = map[iosMode][]iosCommand{
+	modeUser: {
+		{"enable", "Turn on privileged commands", ""},
+		{"exit", "Exit from the EXEC", ""},
+		{"show", "Show running system information", ""},
+	},
+	modePrivileged: {
+		{"configure", "Enter configuration mode", ""},
+		{"copy", "Copy from one file to another", ""},
+		{"disable", "Turn off privileged commands", ""},
+		{"exit", "Exit from the EXEC", ""},
+		{"show", "Show running system information", ""},
+		{"write", "Write running configuration to memory", ""},
+	},
+	modeConfig: {
+		{"access-list", "Add an access list entry", ""},
+		{"do", "To run exec commands in config mode", ""},
+		{"end", "Exit from configure mode", ""},
+		{"exit", "Exit from configure mode", ""},
+		{"hostname", "Set system's network name", ""},
+		{"interface", "Select an interface to configure", ""},
+		{"ip", "Global IP configuration subcommands", ""},
+		{"no", "Negate a command or set its defaults", ""},
+		{"router", "Enable a routing process", "router"},
+		{"vlan", "Vlan commands", "switch"},
+	},
+	modeInterface: {
+		{"description", "Interface specific description", ""},
+		{"do", "To run exec commands in config mode", ""},
+		{"end", "Exit from configure mode", ""},
+		{"exit", "Exit from interface configuration mode", ""},
+		{"ip", "Interface Internet Protocol config commands", ""},
+		{"no", "Negate a command or set its defaults", ""},
+		{"shutdown", "Shutdown the selected interface", ""},
+		{"switchport", "Set switching mode characteristics", "switch"},
+	},
+	modeVLAN: {
+		{"do", "To run exec commands in config mode", ""},
+		{"end", "Exit from configure mode", ""},
+		{"exit", "Apply changes, bump revision number, and exit mode", ""},
+		{"name", "Ascii name of the VLAN", ""},
+		{"no", "Negate a command or set its defaults", ""},
+	},
+	modeRouter: {
+		{"do", "To run exec commands in config mode", ""},
+		{"end", "Exit from configure mode", ""},
+		{"exit", "Exit from routing protocol configuration mode", ""},
+		{"network", "Enable routing on an IP network", ""},
+		{"no", "Negate a command or set its defaults", ""},
+		{"passive-interface", "Suppress routing updates on an interface", ""},
+		{"router-id", "router-id for this OSPF process", ""},
+	},
+	modeACL: {
+		{"deny", "Specify packets to reject", ""},
+		{"do", "To run exec commands in config mode", ""},
+		{"end", "Exit from configure mode", ""},
+		{"exit", "Exit from access-list configuration mode", ""},
+		{"no", "Negate a command or set its defaults", ""},
+		{"permit", "Specify packets to forward", ""},
+		{"remark", "Access list entry comment", ""},
+	},
+}
+
+var (
+	errInvalidInput = errors.New("% Invalid input detected at '^' marker.")
+	errIncomplete   = errors.New("% Incomplete command.")
+)
+
+// iosKeyword expands a keyword the way IOS does: an exact match, or the
+// only keyword the word is the start of
+func iosKeyword(word string, keywords ...string) (string, error) {
+	word = strings.ToLower(word)
+	var found []string
+	for _, k := range keywords {
+		if strings.ToLower(k) == word {
+			return k, nil
+		}
+		if strings.HasPrefix(strings.ToLower(k), word) {
+			found = append(found, k)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return "", errInvalidInput
+	case 1:
+		return found[0], nil
+	}
+	return "", fmt.Errorf("%% Ambiguous command: %q", word)
+}
+
+type iosInterface struct {
+	name        string
+	description string
+	address     string
+	mask        string
+	shutdown    bool
+	switchport  bool
+	mode        string // access or trunk, empty for the default
+	accessVLAN  int
+	nativeVLAN  int
+	allowed     string // trunk allowed VLAN list
+	aclIn       string
+	aclOut      string
+}
+
+type iosVLAN struct {
+	id   int
+	name string
+}
+
+type staticRoute struct {
+	network, mask, via string
+	distance           int
+}
+
+type ospfProcess struct {
+	id       int
+	routerID string
+	networks []string // "address wildcard area N"
+	passive  []string
+}
+
+type iosACL struct {
+	name     string
+	numbered bool
+	extended bool
+	entries  []string
+}
+
+// iosDevice is a simulated router or switch
+type iosDevice struct {
+	kind     string // router or switch
+	hostname string
+	mode     iosMode
+
+	// what the current configuration sub-mode is for
+	current *iosInterface
+	vlan    *iosVLAN
+	ospf    *ospfProcess
+	acl     *iosACL
+
+	interfaces []*iosInterface
+	vlans      map[int]*iosVLAN
+	routes     []staticRoute
+	gateway    string
+	ospfs      map[int]*ospfProcess
+	acls       []*iosACL
+}
+
+// deviceKinds are the devices a lab can simulate
+var deviceKinds = []string{"router", "switch"}
+
+// newIOSDevice returns a device with factory defaults: a router with
+// three GigabitEthernet and two serial interfaces, all shut down, or a
+// 24-port switch with every port in VLAN 1
+func newIOSDevice(kind, hostname string) *iosDevice {
+	d := &iosDevice{kind: kind, vlans: make(map[int]*iosVLAN), ospfs: make(map[int]*ospfProcess)}
+	if kind == "switch" {
+		d.hostname = "Switch"
+		for i := 1; i <= 24; i++ {
+			d.interfaces = append(d.interfaces, &iosInterface{name: fmt.Sprintf("FastEthernet0/%d", i), switchport: true, accessVLAN: 1})
+		}
+		for i := 1; i <= 2; i++ {
+			d.interfaces = append(d.interfaces, &iosInterface{name: fmt.Sprintf("GigabitEthernet0/%d", i), switchport: true, accessVLAN: 1})
+		}
+		d.interfaces = append(d.interfaces, &iosInterface{name: "Vlan1", shutdown: true})
+		d.vlans[1] = &iosVLAN{id: 1, name: "default"}
+	} else {
+		d.hostname = "Router"
+		for _, name := range []string{"GigabitEthernet0/0", "GigabitEthernet0/1", "GigabitEthernet0/2", "Serial0/0/0", "Serial0/0/1"} {
+			d.interfaces = append(d.interfaces, &iosInterface{name: name, shutdown: true})
+		}
+	}
+	if hostname != "" {
+		d.hostname = hostname
+	}
+	return d
+}
+
+// prompt returns the prompt for the current mode
+func (d *iosDevice) prompt() string {
+	switch d.mode {
+	case modeUser:
+		return d.hostname + ">"
+	case modePrivileged:
+		return d.hostname + "#"
+	case modeInterface:
+		return d.hostname + "(config-if)#"
+	case modeVLAN:
+		return d.hostname + "(config-vlan)#"
+	case modeRouter:
+		return d.hostname + "(config-router)#"
+	case modeACL:
+		if d.acl.extended {
+			return d.hostname + "(config-ext-nacl)#"
+		}
+		return d.hostname + "(config-std-nacl)#"
+	}
+	return d.hostname + "(config)#"
+}
+
+// keywords lists the commands of a mode on this device
+func (d *iosDevice) keywords(mode iosMode) []iosCommand {
+	var cmds []iosCommand
+	for _, c := range iosCommands[mode] {
+		if c.device == "" || c.device == d.kind {
+			cmds = append(cmds, c)
+		}
+	}
+	return cmds
+}
+
+// resolve expands the command at the start of words in a mode, following
+// "no" to the command it negates
+func (d *iosDevice) resolve(mode iosMode, words []string) (keyword string, negate bool, err error) {
+	var names, negatable []string
+	for _, c := range d.keywords(mode) {
+		names = append(names, c.keyword)
+		if c.keyword != "do" && c.keyword != "end" && c.keyword != "exit" && c.keyword != "no" {
+			negatable = append(negatable, c.keyword)
+		}
+	}
+	keyword, err = iosKeyword(words[0], names...)
+	if err != nil || keyword != "no" {
+		return keyword, false, err
+	}
+	if len(words) < 2 {
+		return "", true, errIncomplete
+	}
+	keyword, err = iosKeyword(words[1], negatable...)
+	return keyword, true, err
+}
+
+// exec runs a command line and returns its output
+func (d *iosDevice) exec(line string) (string, error) {
+	words := strings.Fields(line)
+	if len(words) == 0 {
+		return "", nil
+	}
+	if words[0] == "?" {
+		return d.help(), nil
+	}
+
+	out, err := d.run(words)
+	// Global commands work in sub-modes, leaving the sub-mode
+	if err == errInvalidInput && d.mode > modeConfig {
+		mode := d.mode
+		d.mode = modeConfig
+		if out, err := d.run(words); err == nil {
+			return out, nil
+		}
+		d.mode = mode
+	}
+	return out, err
+}
+
+// run runs a command in the current mode
+func (d *iosDevice) run(words []string) (string, error) {
+	keyword, negate, err := d.resolve(d.mode, words)
+	if err != nil {
+		return "", err
+	}
+	args := words[1:]
+	if negate {
+		args = words[2:]
+	}
+
+	switch keyword {
+	case "enable":
+		d.mode = modePrivileged
+		return "", nil
+	case "disable":
+		d.mode = modeUser
+		return "", nil
+	case "configure":
+		if len(args) > 0 {
+			if _, err := iosKeyword(args[0], "terminal"); err != nil {
+				return "", err
+			}
+		}
+		d.mode = modeConfig
+		return "Enter configuration commands, one per line.  End with CNTL/Z.\n", nil
+	case "end":
+		d.mode = modePrivileged
+		return "", nil
+	case "exit":
+		switch {
+		case d.mode > modeConfig:
+			d.mode = modeConfig
+		case d.mode == modeConfig:
+			d.mode = modePrivileged
+		default:
+			d.mode = modeUser
+		}
+		return "", nil
+	case "do":
+		if len(args) == 0 {
+			return "", errIncomplete
+		}
+		k, err := iosKeyword(args[0], "copy", "show", "write")
+		if err != nil {
+			return "", err
+		}
+		return d.execCommand(k, args[1:])
+	case "show", "copy", "write":
+		return d.execCommand(keyword, args)
+	}
+
+	switch d.mode {
+	case modeInterface:
+		return d.interfaceCommand(keyword, args, negate)
+	case modeVLAN:
+		return "", d.vlanCommand(keyword, args, negate)
+	case modeRouter:
+		return "", d.routerCommand(keyword, args, negate)
+	case modeACL:
+		return "", d.aclCommand(keyword, args, negate)
+	}
+	return d.globalCommand(keyword, args, negate)
+}
+
+// help lists the commands of the current mode, as ? does
+func (d *iosDevice) help() string {
+	var b strings.Builder
+	if d.mode >= modeConfig {
+		b.WriteString("Configure commands:\n")
+	} else {
+		b.WriteString("Exec commands:\n")
+	}
+	for _, c := range d.keywords(d.mode) {
+		fmt.Fprintf(&b, "  %-18s %s\n", c.keyword, c.help)
+	}
+	return b.String()
+}
+
+// execCommand runs the EXEC commands that are also available with do
+func (d *iosDevice) execCommand(keyword string, args []string) (string, error) {
+	switch keyword {
+	case "copy":
+		if len(args) != 2 {
+			return "", errIncomplete
+		}
+		if _, err := iosKeyword(args[0], "running-config"); err != nil {
+			return "", err
+		}
+		if _, err := iosKeyword(args[1], "startup-config"); err != nil {
+			return "", err
+		}
+		return "Destination filename [startup-config]?\nBuilding configuration...\n[OK]\n", nil
+	case "write":
+		if len(args) > 0 {
+			if _, err := iosKeyword(args[0], "memory"); err != nil {
+				return "", err
+			}
+		}
+		return "Building configuration...\n[OK]\n", nil
+	}
+
+	if len(args) == 0 {
+		return "", errIncomplete
+	}
+	what, err := iosKeyword(args[0], "access-lists", "ip", "running-config", "vlan")
+	if err != nil {
+		return "", err
+	}
+	switch what {
+	case "access-lists":
+		return d.showAccessLists(), nil
+	case "running-config":
+		return "Building configuration...\n\nCurrent configuration:\n" + d.runningConfig(), nil
+	case "vlan":
+		if d.kind != "switch" {
+			return "", errInvalidInput
+		}
+		if len(args) > 1 {
+			if _, err := iosKeyword(args[1], "brief"); err != nil {
+				return "", err
+			}
+		}
+		return d.showVLANs(), nil
+	}
+
+	if len(args) < 2 {
+		return "", errIncomplete
+	}
+	sub, err := iosKeyword(args[1], "interface", "protocols", "route")
+	if err != nil {
+		return "", err
+	}
+	switch sub {
+	case "interface":
+		if len(args) < 3 {
+			return "", errIncomplete
+		}
+		if _, err := iosKeyword(args[2], "brief"); err != nil {
+			return "", err
+		}
+		return d.showInterfacesBrief(), nil
+	case "protocols":
+		return d.showProtocols(), nil
+	}
+	return d.showRoutes(), nil
+}
+
+var interfaceTypes = []string{"FastEthernet", "GigabitEthernet", "Serial", "Loopback", "Vlan"}
+
+var (
+	interfaceNumber = regexp.MustCompile(`^[0-9]+(/[0-9]+)*(\.[0-9]+)?$`)
+	vlanList        = regexp.MustCompile(`^[0-9]+(-[0-9]+)?(,[0-9]+(-[0-9]+)?)*$`)
+)
+
+// interfaceName expands an interface such as "fa0/1" or "gi 0/1" to its
+// full name
+func interfaceName(words []string) (string, error) {
+	if len(words) == 0 {
+		return "", errIncomplete
+	}
+	s := strings.Join(words, "")
+	i := strings.IndexAny(s, "0123456789")
+	if i <= 0 {
+		return "", errInvalidInput
+	}
+	kind, err := iosKeyword(s[:i], interfaceTypes...)
+	if err != nil {
+		return "", err
+	}
+	if !interfaceNumber.MatchString(s[i:]) {
+		return "", errInvalidInput
+	}
+	return kind + s[i:], nil
+}
+
+// virtualInterface reports whether an interface is created by configuring
+// it rather than being part of the hardware
+func virtualInterface(name string) bool {
+	return strings.HasPrefix(name, "Loopback") || strings.HasPrefix(name, "Vlan")
+}
+
+// shortInterface abbreviates an interface name as show commands do
+func shortInterface(name string) string {
+	for _, t := range []string{"FastEthernet", "GigabitEthernet", "Serial", "Loopback", "Vlan"} {
+		if strings.HasPrefix(name, t) {
+			return t[:2] + name[len(t):]
+		}
+	}
+	return name
+}
+
+func (d *iosDevice) findInterface(name string) *iosInterface {
+	for _, i := range d.interfaces {
+		if i.name == name {
+			return i
+		}
+	}
+	return nil
+}
+
+// ipv4 reports whether s is a dotted IPv4 address
+func ipv4(s string) bool {
+	ip := net.ParseIP(s)
+	return ip != nil && ip.To4() != nil && strings.Count(s, ".") == 3
+}
+
+// subnetMask reports whether s is a valid contiguous subnet mask
+func subnetMask(s string) bool {
+	if !ipv4(s) {
+		return false
+	}
+	_, bits := net.IPMask(net.ParseIP(s).To4()).Size()
+	return bits == 32
+}
+
+// networkOf returns the network address of ip under mask
+func networkOf(ip, mask string) string {
+	return net.ParseIP(ip).To4().Mask(net.IPMask(net.ParseIP(mask).To4())).String()
+}
+
+// broadcastOf returns the broadcast address of ip's subnet under mask
+func broadcastOf(ip, mask string) string {
+	a, m := net.ParseIP(ip).To4(), net.ParseIP(mask).To4()
+	b := make(net.IP, 4)
+	for n := range b {
+		b[n] = a[n] | ^m[n]
+	}
+	return b.String()
+}
+
+// overlaps reports whether two subnets share addresses, which they do when
+// the wider one holds the other
+func overlaps(a, aMask, b, bMask string) bool {
+	if maskLength(bMask) < maskLength(aMask) {
+		aMask = bMask
+	}
+	return networkOf(a, aMask) == networkOf(b, aMask)
+}
+
+func maskLength(mask string) int {
+	ones, _ := net.IPMask(net.ParseIP(mask).To4()).Size()
+	return ones
+}
+
+// vlanID reads a VLAN number
+func vlanID(s string) (int, error) {
+	id, err := strconv.Atoi(s)
+	if err != nil || id < 1 || id > 4094 {
+		return 0, errInvalidInput
+	}
+	return id, nil
+}
+
+func (d *iosDevice) globalCommand(keyword string, args []string, negate bool) (string, error) {
+	switch keyword {
+	case "hostname":
+		if negate {
+			d.hostname = newIOSDevice(d.kind, "").hostname
+			return "", nil
+		}
+		if len(args) != 1 {
+			return "", errIncomplete
+		}
+		d.hostname = args[0]
+
+	case "interface":
+		name, err := interfaceName(args)
+		if err != nil {
+			return "", err
+		}
+		if strings.HasPrefix(name, "Vlan") && d.kind != "switch" {
+			return "", errInvalidInput
+		}
+		i := d.findInterface(name)
+		if negate {
+			if !virtualInterface(name) || name == "Vlan1" || i == nil {
+				return "", errInvalidInput
+			}
+			for n, other := range d.interfaces {
+				if other == i {
+					d.interfaces = append(d.interfaces[:n], d.interfaces[n+1:]...)
+					break
+				}
+			}
+			return "", nil
+		}
+		if i == nil {
+			if !virtualInterface(name) {
+				return "", errInvalidInput
+			}
+			i = &iosInterface{name: name, shutdown: strings.HasPrefix(name, "Vlan")}
+			d.interfaces = append(d.interfaces, i)
+		}
+		d.current, d.mode = i, modeInterface
+
+	case "vlan":
+		if len(args) != 1 {
+			return "", errIncomplete
+		}
+		id, err := vlanID(args[0])
+		if err != nil {
+			return "", err
+		}
+		if negate {
+			if id == 1 {
+				return "", errors.New("%Default VLAN 1 may not be deleted.")
+			}
+			delete(d.vlans, id)
+			return "", nil
+		}
+		d.vlan, d.mode = d.addVLAN(id), modeVLAN
+
+	case "router":
+		if len(args) < 2 {
+			return "", errIncomplete
+		}
+		if _, err := iosKeyword(args[0], "ospf"); err != nil {
+			return "", err
+		}
+		id, err := strconv.Atoi(args[1])
+		if err != nil || id < 1 || id > 65535 {
+			return "", errInvalidInput
+		}
+		if negate {
+			delete(d.ospfs, id)
+			return "", nil
+		}
+		if d.ospfs[id] == nil {
+			d.ospfs[id] = &ospfProcess{id: id}
+		}
+		d.ospf, d.mode = d.ospfs[id], modeRouter
+
+	case "access-list":
+		if len(args) == 0 {
+			return "", errIncomplete
+		}
+		n, err := strconv.Atoi(args[0])
+		extended := n >= 100 && n <= 199 || n >= 2000 && n <= 2699
+		if err != nil || !extended && !(n >= 1 && n <= 99 || n >= 1300 && n <= 1999) {
+			return "", errInvalidInput
+		}
+		if negate {
+			d.removeACL(args[0])
+			return "", nil
+		}
+		entry, err := aclEntry(args[1:], extended)
+		if err != nil {
+			return "", err
+		}
+		acl := d.findACL(args[0])
+		if acl == nil {
+			acl = &iosACL{name: args[0], numbered: true, extended: extended}
+			d.acls = append(d.acls, acl)
+		}
+		acl.add(entry)
+
+	case "ip":
+		return "", d.globalIP(args, negate)
+	}
+	return "", nil
+}
+
+func (d *iosDevice) globalIP(args []string, negate bool) error {
+	if len(args) == 0 {
+		return errIncomplete
+	}
+	keywords := []string{"access-list", "route"}
+	if d.kind == "switch" {
+		keywords = []string{"access-list", "default-gateway"}
+	}
+	sub, err := iosKeyword(args[0], keywords...)
+	if err != nil {
+		return err
+	}
+	args = args[1:]
+
+	switch sub {
+	case "default-gateway":
+		if negate {
+			d.gateway = ""
+			return nil
+		}
+		if len(args) != 1 || !ipv4(args[0]) {
+			return errInvalidInput
+		}
+		d.gateway = args[0]
+
+	case "route":
+		if len(args) < 3 {
+			return errIncomplete
+		}
+		r := staticRoute{network: args[0], mask: args[1], distance: 1}
+		if !ipv4(r.network) || !subnetMask(r.mask) {
+			return errInvalidInput
+		}
+		if networkOf(r.network, r.mask) != r.network {
+			return errors.New("%Inconsistent address and mask")
+		}
+		if ipv4(args[2]) {
+			r.via = args[2]
+		} else {
+			name, err := interfaceName(args[2:3])
+			if err != nil || d.findInterface(name) == nil {
+				return errInvalidInput
+			}
+			r.via = name
+		}
+		if len(args) > 3 {
+			n, err := strconv.Atoi(args[3])
+			if err != nil || n < 1 || n > 255 || len(args) > 4 {
+				return errInvalidInput
+			}
+			r.distance = n
+		}
+		for n, existing := range d.routes {
+			if existing.network == r.network && existing.mask == r.mask && existing.via == r.via {
+				d.routes = append(d.routes[:n], d.routes[n+1:]...)
+				break
+			}
+		}
+		if !negate {
+			d.routes = append(d.routes, r)
+		}
+
+	case "access-list":
+		if len(args) < 2 {
+			return errIncomplete
+		}
+		kind, err := iosKeyword(args[0], "extended", "standard")
+		if err != nil {
+			return err
+		}
+		name := args[1]
+		if negate {
+			d.removeACL(name)
+			return nil
+		}
+		acl := d.findACL(name)
+		if acl == nil {
+			acl = &iosACL{name: name, extended: kind == "extended"}
+			d.acls = append(d.acls, acl)
+		} else if acl.extended != (kind == "extended") {
+			return fmt.Errorf("%% A named %s IP access list with this name already exists", acl.kind())
+		}
+		d.acl, d.mode = acl, modeACL
+	}
+	return nil
+}
+
+func (d *iosDevice) addVLAN(id int) *iosVLAN {
+	if d.vlans[id] == nil {
+		d.vlans[id] = &iosVLAN{id: id, name: fmt.Sprintf("VLAN%04d", id)}
+	}
+	return d.vlans[id]
+}
+
+func (d *iosDevice) interfaceCommand(keyword string, args []string, negate bool) (string, error) {
+	i := d.current
+	switch keyword {
+	case "description":
+		i.description = ""
+		if !negate {
+			if len(args) == 0 {
+				return "", errIncomplete
+			}
+			i.description = strings.Join(args, " ")
+		}
+
+	case "shutdown":
+		i.shutdown = !negate
+
+	case "ip":
+		if len(args) == 0 {
+			return "", errIncomplete
+		}
+		sub, err := iosKeyword(args[0], "access-group", "address")
+		if err != nil {
+			return "", err
+		}
+		args = args[1:]
+		if sub == "access-group" {
+			if len(args) == 0 {
+				return "", errIncomplete
+			}
+			dir, err := iosKeyword(args[len(args)-1], "in", "out")
+			if err != nil {
+				return "", err
+			}
+			name := ""
+			if !negate {
+				if len(args) != 2 {
+					return "", errIncomplete
+				}
+				name = args[0]
+			}
+			if dir == "in" {
+				i.aclIn = name
+			} else {
+				i.aclOut = name
+			}
+			return "", nil
+		}
+
+		if i.switchport {
+			return "", errInvalidInput
+		}
+		if negate {
+			i.address, i.mask = "", ""
+			return "", nil
+		}
+		if len(args) != 2 {
+			return "", errIncomplete
+		}
+		if !ipv4(args[0]) || !subnetMask(args[1]) {
+			return "", errInvalidInput
+		}
+		network := networkOf(args[0], args[1])
+		if (network == args[0] || broadcastOf(args[0], args[1]) == args[0]) && maskLength(args[1]) < 31 {
+			return "", fmt.Errorf("Bad mask /%d for address %s", maskLength(args[1]), args[0])
+		}
+		for _, other := range d.interfaces {
+			if other != i && other.address != "" && overlaps(args[0], args[1], other.address, other.mask) {
+				return "", fmt.Errorf("%% %s overlaps with %s", network, other.name)
+			}
+		}
+		i.address, i.mask = args[0], args[1]
+
+	case "switchport":
+		if !i.switchport {
+			return "", errInvalidInput
+		}
+		if len(args) == 0 {
+			return "", errIncomplete
+		}
+		sub, err := iosKeyword(args[0], "access", "mode", "trunk")
+		if err != nil {
+			return "", err
+		}
+		args = args[1:]
+		switch sub {
+		case "mode":
+			if negate {
+				i.mode = ""
+				return "", nil
+			}
+			if len(args) != 1 {
+				return "", errIncomplete
+			}
+			mode, err := iosKeyword(args[0], "access", "trunk")
+			if err != nil {
+				return "", err
+			}
+			i.mode = mode
+
+		case "access":
+			if len(args) == 0 {
+				return "", errIncomplete
+			}
+			if _, err := iosKeyword(args[0], "vlan"); err != nil {
+				return "", err
+			}
+			if negate {
+				i.accessVLAN = 1
+				return "", nil
+			}
+			if len(args) != 2 {
+				return "", errIncomplete
+			}
+			id, err := vlanID(args[1])
+			if err != nil {
+				return "", err
+			}
+			i.accessVLAN = id
+			if d.vlans[id] == nil {
+				d.addVLAN(id)
+				return fmt.Sprintf("%% Access VLAN does not exist. Creating vlan %d\n", id), nil
+			}
+
+		case "trunk":
+			if len(args) < 2 {
+				return "", errIncomplete
+			}
+			what, err := iosKeyword(args[0], "allowed", "native")
+			if err != nil {
+				return "", err
+			}
+			if _, err := iosKeyword(args[1], "vlan"); err != nil {
+				return "", err
+			}
+			args = args[2:]
+			switch {
+			case negate && what == "native":
+				i.nativeVLAN = 0
+			case negate:
+				i.allowed = ""
+			case len(args) != 1:
+				return "", errIncomplete
+			case what == "native":
+				id, err := vlanID(args[0])
+				if err != nil {
+					return "", err
+				}
+				i.nativeVLAN = id
+			case !vlanList.MatchString(args[0]):
+				return "", errInvalidInput
+			default:
+				i.allowed = args[0]
+			}
+		}
+	}
+	return "", nil
+}
+
+func (d *iosDevice) vlanCommand(keyword string, args []string, negate bool) error {
+	if negate {
+		d.vlan.name = fmt.Sprintf("VLAN%04d", d.vlan.id)
+		return nil
+	}
+	if len(args) != 1 {
+		return errIncomplete
+	}
+	d.vlan.name = args[0]
+	return nil
+}
+
+func (d *iosDevice) routerCommand(keyword string, args []string, negate bool) error {
+	p := d.ospf
+	switch keyword {
+	case "router-id":
+		if negate {
+			p.routerID = ""
+			return nil
+		}
+		if len(args) != 1 || !ipv4(args[0]) {
+			return errInvalidInput
+		}
+		p.routerID = args[0]
+
+	case "passive-interface":
+		name, err := interfaceName(args)
+		if err != nil || d.findInterface(name) == nil {
+			return errInvalidInput
+		}
+		p.passive = removeString(p.passive, name)
+		if !negate {
+			p.passive = append(p.passive, name)
+		}
+
+	case "network":
+		if len(args) != 4 {
+			return errIncomplete
+		}
+		if !ipv4(args[0]) || !ipv4(args[1]) {
+			return errInvalidInput
+		}
+		if _, err := iosKeyword(args[2], "area"); err != nil {
+			return err
+		}
+		if _, err := strconv.ParseUint(args[3], 10, 32); err != nil && !ipv4(args[3]) {
+			return errInvalidInput
+		}
+		network := fmt.Sprintf("%s %s area %s", args[0], args[1], args[3])
+		p.networks = removeString(p.networks, network)
+		if !negate {
+			p.networks = append(p.networks, network)
+		}
+	}
+	return nil
+}
+
+func (d *iosDevice) aclCommand(keyword string, args []string, negate bool) error {
+	entry, err := aclEntry(append([]string{keyword}, args...), d.acl.extended)
+	if err != nil {
+		return err
+	}
+	if negate {
+		d.acl.entries = removeString(d.acl.entries, entry)
+		return nil
+	}
+	d.acl.add(entry)
+	return nil
+}
+
+func removeString(list []string, s string) []string {
+	var kept []string
+	for _, item := range list {
+		if item != s {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+func (d *iosDevice) findACL(name string) *iosACL {
+	for _, acl := range d.acls {
+		if acl.name == name {
+			return acl
+		}
+	}
+	return nil
+}
+
+func (d *iosDevice) removeACL(name string) {
+	for n, acl := range d.acls {
+		if acl.name == name {
+			d.acls = append(d.acls[:n], d.acls[n+1:]...)
+			return
+		}
+	}
+}
+
+func (acl *iosACL) kind() string {
+	if acl.extended {
+		return "extended"
+	}
+	return "standard"
+}
+
+// add appends an entry unless the list already has it
+func (acl *iosACL) add(entry string) {
+	for _, e := range acl.entries {
+		if e == entry {
+			return
+		}
+	}
+	acl.entries = append(acl.entries, entry)
+}
+
+// portNumbers maps the port names IOS accepts to their numbers, which is
+// how the simulator shows them
+var portNumbers = map[string]string{
+	"bgp": "179", "bootpc": "68", "bootps": "67", "domain": "53", "ftp": "21",
+	"ntp": "123", "pop3": "110", "smtp": "25", "snmp": "161", "ssh": "22",
+	"telnet": "23", "tftp": "69", "www": "80",
+}
+
+var aclProtocols = []string{"ahp", "eigrp", "esp", "gre", "icmp", "ip", "ospf", "tcp", "udp"}
+
+// aclEntry checks an access list entry and writes it in the form the
+// running-config shows
+func aclEntry(words []string, extended bool) (string, error) {
+	if len(words) == 0 {
+		return "", errIncomplete
+	}
+	action, err := iosKeyword(words[0], "deny", "permit", "remark")
+	if err != nil {
+		return "", err
+	}
+	if action == "remark" {
+		if len(words) < 2 {
+			return "", errIncomplete
+		}
+		return "remark " + strings.Join(words[1:], " "), nil
+	}
+	parts := []string{action}
+	rest := words[1:]
+
+	if !extended {
+		src, rest, err := aclAddress(rest, false)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, src)
+		if len(rest) == 1 && strings.EqualFold(rest[0], "log") {
+			parts = append(parts, "log")
+		} else if len(rest) > 0 {
+			return "", errInvalidInput
+		}
+		return strings.Join(parts, " "), nil
+	}
+
+	if len(rest) == 0 {
+		return "", errIncomplete
+	}
+	proto := strings.ToLower(rest[0])
+	if n, err := strconv.Atoi(proto); err != nil || n < 0 || n > 255 {
+		if proto, err = iosKeyword(proto, aclProtocols...); err != nil {
+			return "", err
+		}
+	}
+	parts = append(parts, proto)
+	rest = rest[1:]
+	ports := proto == "tcp" || proto == "udp"
+
+	for _, side := range []string{"source", "destination"} {
+		addr, next, err := aclAddress(rest, true)
+		if err != nil {
+			return "", fmt.Errorf("%w (%s)", err, side)
+		}
+		parts = append(parts, addr)
+		rest = next
+		if ports {
+			port, next, err := aclPort(rest)
+			if err != nil {
+				return "", err
+			}
+			if port != "" {
+				parts = append(parts, port)
+			}
+			rest = next
+		}
+	}
+
+	options := []string{"echo", "echo-reply", "log", "time-exceeded", "unreachable"}
+	if proto == "tcp" {
+		options = append(options, "established")
+	}
+	for _, w := range rest {
+		opt, err := iosKeyword(w, options...)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, opt)
+	}
+	return strings.Join(parts, " "), nil
+}
+
+// aclAddress reads "any", "host A" or "A wildcard" from the start of
+// words. A standard entry may give an address alone for a single host.
+func aclAddress(words []string, extended bool) (string, []string, error) {
+	if len(words) == 0 {
+		return "", nil, errIncomplete
+	}
+	if k, err := iosKeyword(words[0], "any", "host"); err == nil {
+		if k == "any" {
+			return "any", words[1:], nil
+		}
+		if len(words) < 2 {
+			return "", nil, errIncomplete
+		}
+		if !ipv4(words[1]) {
+			return "", nil, errInvalidInput
+		}
+		if extended {
+			return "host " + words[1], words[2:], nil
+		}
+		return words[1], words[2:], nil
+	}
+	if !ipv4(words[0]) {
+		return "", nil, errInvalidInput
+	}
+	addr, wildcard := words[0], "0.0.0.0"
+	switch {
+	case len(words) > 1 && ipv4(words[1]):
+		wildcard = words[1]
+		words = words[2:]
+	case extended:
+		return "", nil, errIncomplete
+	default:
+		words = words[1:]
+	}
+
+	switch wildcard {
+	case "0.0.0.0":
+		if extended {
+			return "host " + addr, words, nil
+		}
+		return addr, words, nil
+	case "255.255.255.255":
+		return "any", words, nil
+	}
+	// Bits the wildcard ignores are cleared, as IOS does
+	a, w := net.ParseIP(addr).To4(), net.ParseIP(wildcard).To4()
+	masked := make(net.IP, 4)
+	for n := range masked {
+		masked[n] = a[n] &^ w[n]
+	}
+	return masked.String() + " " + wildcard, words, nil
+}
+
+// aclPort reads an optional port match such as "eq 80" or "range 20 21"
+func aclPort(words []string) (string, []string, error) {
+	if len(words) == 0 {
+		return "", words, nil
+	}
+	op, err := iosKeyword(words[0], "eq", "gt", "lt", "neq", "range")
+	if err != nil {
+		return "", words, nil
+	}
+	count := 1
+	if op == "range" {
+		count = 2
+	}
+	if len(words) < 1+count {
+		return "", nil, errIncomplete
+	}
+	parts := []string{op}
+	for _, w := range words[1 : 1+count] {
+		port := strings.ToLower(w)
+		if n, ok := portNumbers[port]; ok {
+			port = n
+		}
+		if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+			return "", nil, errInvalidInput
+		}
+		parts = append(parts, port)
+	}
+	return strings.Join(parts, " "), words[1+count:], nil
+}
+
+// runningConfig returns the configuration as show running-config does
+func (d *iosDevice) runningConfig() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "!\nhostname %s\n!\n", d.hostname)
+
+	var ids []int
+	for id := range d.vlans {
+		if id != 1 {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		v := d.vlans[id]
+		fmt.Fprintf(&b, "vlan %d\n", id)
+		if v.name != fmt.Sprintf("VLAN%04d", id) {
+			fmt.Fprintf(&b, " name %s\n", v.name)
+		}
+		b.WriteString("!\n")
+	}
+
+	for _, i := range d.interfaces {
+		fmt.Fprintf(&b, "interface %s\n", i.name)
+		if i.description != "" {
+			fmt.Fprintf(&b, " description %s\n", i.description)
+		}
+		switch {
+		case i.switchport:
+			if i.accessVLAN > 1 {
+				fmt.Fprintf(&b, " switchport access vlan %d\n", i.accessVLAN)
+			}
+			if i.nativeVLAN > 0 {
+				fmt.Fprintf(&b, " switchport trunk native vlan %d\n", i.nativeVLAN)
+			}
+			if i.allowed != "" {
+				fmt.Fprintf(&b, " switchport trunk allowed vlan %s\n", i.allowed)
+			}
+			if i.mode != "" {
+				fmt.Fprintf(&b, " switchport mode %s\n", i.mode)
+			}
+		case i.address != "":
+			fmt.Fprintf(&b, " ip address %s %s\n", i.address, i.mask)
+		default:
+			b.WriteString(" no ip address\n")
+		}
+		if i.aclIn != "" {
+			fmt.Fprintf(&b, " ip access-group %s in\n", i.aclIn)
+		}
+		if i.aclOut != "" {
+			fmt.Fprintf(&b, " ip access-group %s out\n", i.aclOut)
+		}
+		if i.shutdown {
+			b.WriteString(" shutdown\n")
+		}
+		b.WriteString("!\n")
+	}
+
+	ids = nil
+	for id := range d.ospfs {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		p := d.ospfs[id]
+		fmt.Fprintf(&b, "router ospf %d\n", id)
+		if p.routerID != "" {
+			fmt.Fprintf(&b, " router-id %s\n", p.routerID)
+		}
+		for _, name := range p.passive {
+			fmt.Fprintf(&b, " passive-interface %s\n", name)
+		}
+		for _, n := range p.networks {
+			fmt.Fprintf(&b, " network %s\n", n)
+		}
+		b.WriteString("!\n")
+	}
+
+	if d.gateway != "" {
+		fmt.Fprintf(&b, "ip default-gateway %s\n", d.gateway)
+	}
+	for _, r := range d.routes {
+		fmt.Fprintf(&b, "ip route %s %s %s", r.network, r.mask, r.via)
+		if r.distance != 1 {
+			fmt.Fprintf(&b, " %d", r.distance)
+		}
+		b.WriteString("\n")
+	}
+	if d.gateway != "" || len(d.routes) > 0 {
+		b.WriteString("!\n")
+	}
+
+	for _, acl := range d.acls {
+		if acl.numbered {
+			for _, e := range acl.entries {
+				fmt.Fprintf(&b, "access-list %s %s\n", acl.name, e)
+			}
+			continue
+		}
+		fmt.Fprintf(&b, "ip access-list %s %s\n", acl.kind(), acl.name)
+		for _, e := range acl.entries {
+			fmt.Fprintf(&b, " %s\n", e)
+		}
+	}
+	if len(d.acls) > 0 {
+		b.WriteString("!\n")
+	}
+	b.WriteString("end\n")
+	return b.String()
+}
+
+func (d *iosDevice) showInterfacesBrief() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-26s %-15s %-3s %-6s %-21s %s\n", "Interface", "IP-Address", "OK?", "Method", "Status", "Protocol")
+	for _, i := range d.interfaces {
+		addr, method := "unassigned", "unset"
+		if i.address != "" {
+			addr, method = i.address, "manual"
+		}
+		status, protocol := "up", "up"
+		if i.shutdown {
+			status, protocol = "administratively down", "down"
+		}
+		fmt.Fprintf(&b, "%-26s %-15s %-3s %-6s %-21s %s\n", i.name, addr, "YES", method, status, protocol)
+	}
+	return b.String()
+}
+
+func (d *iosDevice) showRoutes() string {
+	if d.kind == "switch" {
+		if d.gateway == "" {
+			return "Default gateway is not set\n"
+		}
+		return "Default gateway is " + d.gateway + "\n"
+	}
+
+	var b strings.Builder
+	b.WriteString("Codes: L - local, C - connected, S - static, O - OSPF\n       * - candidate default\n\n")
+	last := "Gateway of last resort is not set"
+	var lines []string
+	for _, r := range d.routes {
+		code := "S"
+		if r.network == "0.0.0.0" && r.mask == "0.0.0.0" {
+			code = "S*"
+			last = fmt.Sprintf("Gateway of last resort is %s to network 0.0.0.0", r.via)
+		}
+		if ipv4(r.via) {
+			lines = append(lines, fmt.Sprintf("%-5s %s/%d [%d/0] via %s", code, r.network, maskLength(r.mask), r.distance, r.via))
+		} else {
+			lines = append(lines, fmt.Sprintf("%-5s %s/%d is directly connected, %s", code, r.network, maskLength(r.mask), r.via))
+		}
+	}
+	for _, i := range d.interfaces {
+		if i.address == "" || i.shutdown {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%-5s %s/%d is directly connected, %s", "C", networkOf(i.address, i.mask), maskLength(i.mask), i.name))
+		if maskLength(i.mask) < 32 {
+			lines = append(lines, fmt.Sprintf("%-5s %s/32 is directly connected, %s", "L", i.address, i.name))
+		}
+	}
+	b.WriteString(last + "\n\n")
+	for _, l := range lines {
+		b.WriteString(l + "\n")
+	}
+	return b.String()
+}
+
+func (d *iosDevice) showVLANs() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-4s %-32s %-9s %s\n", "VLAN", "Name", "Status", "Ports")
+	fmt.Fprintf(&b, "%s %s %s %s\n", strings.Repeat("-", 4), strings.Repeat("-", 32), strings.Repeat("-", 9), strings.Repeat("-", 31))
+	var ids []int
+	for id := range d.vlans {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		var ports []string
+		for _, i := range d.interfaces {
+			if i.switchport && i.mode != "trunk" && i.accessVLAN == id {
+				ports = append(ports, shortInterface(i.name))
+			}
+		}
+		line := fmt.Sprintf("%-4d %-32s %-9s ", id, d.vlans[id].name, "active")
+		for len(ports) > 4 {
+			b.WriteString(line + strings.Join(ports[:4], ", ") + ",\n")
+			ports = ports[4:]
+			line = strings.Repeat(" ", 48)
+		}
+		b.WriteString(strings.TrimRight(line+strings.Join(ports, ", "), " ") + "\n")
+	}
+	return b.String()
+}
+
+func (d *iosDevice) showAccessLists() string {
+	var b strings.Builder
+	for _, acl := range d.acls {
+		kind := strings.ToUpper(acl.kind()[:1]) + acl.kind()[1:]
+		fmt.Fprintf(&b, "%s IP access list %s\n", kind, acl.name)
+		seq := 10
+		for _, e := range acl.entries {
+			if strings.HasPrefix(e, "remark ") {
+				continue
+			}
+			fmt.Fprintf(&b, "    %d %s\n", seq, e)
+			seq += 10
+		}
+	}
+	return b.String()
+}
+
+func (d *iosDevice) showProtocols() string {
+	var b strings.Builder
+	var ids []int
+	for id := range d.ospfs {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		p := d.ospfs[id]
+		fmt.Fprintf(&b, "Routing Protocol is \"ospf %d\"\n", id)
+		fmt.Fprintf(&b, "  Router ID %s\n", d.ospfRouterID(p))
+		b.WriteString("  Routing for Networks:\n")
+		for _, n := range p.networks {
+			fmt.Fprintf(&b, "    %s\n", n)
+		}
+		if len(p.passive) > 0 {
+			b.WriteString("  Passive Interface(s):\n")
+			for _, name := range p.passive {
+				fmt.Fprintf(&b, "    %s\n", name)
+			}
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// ospfRouterID returns the configured router ID, or the one OSPF would
+// choose: the highest loopback address, else the highest interface address
+func (d *iosDevice) ospfRouterID(p *ospfProcess) string {
+	if p.routerID != "" {
+		return p.routerID
+	}
+	highest := func(loopbacks bool) string {
+		best := ""
+		for _, i := range d.interfaces {
+			if i.address == "" || i.shutdown || strings.HasPrefix(i.name, "Loopback") != loopbacks {
+				continue
+			}
+			if best == "" || compareIPv4(i.address, best) > 0 {
+				best = i.address
+			}
+		}
+		return best
+	}
+	if id := highest(true); id != "" {
+		return id
+	}
+	if id := highest(false); id != "" {
+		return id
+	}
+	return "0.0.0.0"
+}
+
+func compareIPv4(a, b string) int {
+	x, y := net.ParseIP(a).To4(), net.ParseIP(b).To4()
+	for n := range x {
+		if x[n] != y[n] {
+			return int(x[n]) - int(y[n])
+		}
+	}
+	return 0
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// configure runs commands from global configuration mode, failing the
+// test on the first error
+func configure(t *testing.T, d *iosDevice, commands ...string) {
+	t.Helper()
+	d.mode = modeConfig
+	for _, c := range commands {
+		if _, err := d.exec(c); err != nil {
+			t.Fatalf("%s: %v", c, err)
+		}
+	}
+}
+
+func TestIOSKeyword(t *testing.T) {
+	tests := []struct {
+		word     string
+		keywords []string
+		want     string
+		err      string
+	}{
+		{"conf", []string{"configure", "copy"}, "configure", ""},
+		{"CO", []string{"configure", "copy"}, "", "Ambiguous command"},
+		{"copy", []string{"copy", "copyright"}, "copy", ""},
+		{"Gi", interfaceTypes, "GigabitEthernet", ""},
+		{"x", []string{"configure"}, "", "Invalid input"},
+	}
+	for _, tt := range tests {
+		got, err := iosKeyword(tt.word, tt.keywords...)
+		if got != tt.want || (err == nil) != (tt.err == "") || err != nil && !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("iosKeyword(%q) = %q, %v; want %q, %q", tt.word, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestInterfaceName(t *testing.T) {
+	tests := []struct {
+		words []string
+		want  string
+	}{
+		{[]string{"fa0/1"}, "FastEthernet0/1"},
+		{[]string{"gi", "0/1"}, "GigabitEthernet0/1"},
+		{[]string{"s0/0/0"}, "Serial0/0/0"},
+		{[]string{"lo0"}, "Loopback0"},
+		{[]string{"g0/0.10"}, "GigabitEthernet0/0.10"},
+		{[]string{"0/1"}, ""},
+		{[]string{"g"}, ""},
+		{[]string{"g0/"}, ""},
+		{[]string{"tunnel0"}, ""},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		got, err := interfaceName(tt.words)
+		if got != tt.want || (err == nil) != (tt.want != "") {
+			t.Errorf("interfaceName(%q) = %q, %v; want %q", tt.words, got, err, tt.want)
+		}
+	}
+}
+
+func TestIOSModes(t *testing.T) {
+	d := newIOSDevice("router", "")
+	steps := []struct {
+		command, prompt, err string
+	}{
+		{"configure terminal", "Router>", "Invalid input"},
+		{"en", "Router#", ""},
+		{"conf t", "Router(config)#", ""},
+		{"hostname R1", "R1(config)#", ""},
+		{"int g0/0", "R1(config-if)#", ""},
+		{"router ospf 1", "R1(config-router)#", ""}, // a global command leaves the sub-mode
+		{"ip access-list extended WEB", "R1(config-ext-nacl)#", ""},
+		{"bogus", "R1(config-ext-nacl)#", "Invalid input"},
+		{"exit", "R1(config)#", ""},
+		{"vlan 10", "R1(config)#", "Invalid input"}, // routers have no VLANs
+		{"no", "R1(config)#", "Incomplete"},
+		{"end", "R1#", ""},
+		{"disable", "R1>", ""},
+		{"exit", "R1>", ""},
+	}
+	for _, s := range steps {
+		_, err := d.exec(s.command)
+		if (err == nil) != (s.err == "") || err != nil && !strings.Contains(err.Error(), s.err) {
+			t.Errorf("%q: error %v, want %q", s.command, err, s.err)
+		}
+		if got := d.prompt(); got != s.prompt {
+			t.Errorf("%q: prompt %q, want %q", s.command, got, s.prompt)
+		}
+	}
+	if help, _ := d.exec("?"); !strings.Contains(help, "enable") || strings.Contains(help, "configure") {
+		t.Errorf("user EXEC help:\n%s", help)
+	}
+}
+
+func TestIOSConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		device   string
+		commands []string
+		want     []string // lines of the running-config
+		missing  []string // lines that must not be there
+	}{
+		{"interface address", "router", []string{"int g0/1", "ip add 192.168.1.1 255.255.255.0", "no shut", "desc to  LAN"},
+			[]string{"interface GigabitEthernet0/1\n description to LAN\n ip address 192.168.1.1 255.255.255.0\n!"}, nil},
+		{"address removed", "router", []string{"int g0/1", "ip address 10.0.0.1 255.0.0.0", "no ip address"},
+			[]string{"interface GigabitEthernet0/1\n no ip address\n shutdown"}, []string{"10.0.0.1"}},
+		{"loopback created and removed", "router", []string{"int lo0", "ip add 1.1.1.1 255.255.255.255", "int lo1", "no int lo0"},
+			[]string{"interface Loopback1\n no ip address\n!"}, []string{"Loopback0"}},
+		{"static routes", "router", []string{"ip route 0.0.0.0 0.0.0.0 10.0.0.254", "ip route 172.16.0.0 255.255.0.0 s0/0/0 5",
+			"ip route 10.9.0.0 255.255.0.0 10.0.0.2", "no ip route 10.9.0.0 255.255.0.0 10.0.0.2"},
+			[]string{"ip route 0.0.0.0 0.0.0.0 10.0.0.254\nip route 172.16.0.0 255.255.0.0 Serial0/0/0 5\n!"}, []string{"10.9.0.0"}},
+		{"ospf", "router", []string{"router ospf 10", "router-id 1.1.1.1", "network 10.0.0.0 0.0.0.255 area 0",
+			"passive-interface g0/0", "network 10.0.0.0 0.0.0.255 area 0"},
+			[]string{"router ospf 10\n router-id 1.1.1.1\n passive-interface GigabitEthernet0/0\n network 10.0.0.0 0.0.0.255 area 0\n!"}, nil},
+		{"numbered acls", "router", []string{"access-list 10 permit 192.168.1.0 0.0.0.255", "access-list 10 deny any log",
+			"access-list 110 permit tcp any host 10.0.0.5 eq www", "access-list 110 permit tcp any host 10.0.0.5 eq 80"},
+			[]string{"access-list 10 permit 192.168.1.0 0.0.0.255\naccess-list 10 deny any log\naccess-list 110 permit tcp any host 10.0.0.5 eq 80\n!"}, nil},
+		{"named acl applied", "router", []string{"ip access-list standard MGMT", "permit host 10.0.0.9", "remark admins", "deny any",
+			"no deny any", "int g0/0", "ip access-group MGMT in"},
+			[]string{"interface GigabitEthernet0/0\n no ip address\n ip access-group MGMT in\n shutdown\n!",
+				"ip access-list standard MGMT\n permit 10.0.0.9\n remark admins\n!"}, []string{"deny any"}},
+		{"vlans and switchports", "switch", []string{"vlan 10", "name SALES", "vlan 20", "int fa0/1", "switchport mode access",
+			"switchport access vlan 10", "int g0/1", "switchport mode trunk", "switchport trunk native vlan 99",
+			"switchport trunk allowed vlan 10,20,99", "no vlan 20"},
+			[]string{"vlan 10\n name SALES\n!\ninterface", "interface FastEthernet0/1\n switchport access vlan 10\n switchport mode access\n!",
+				"interface GigabitEthernet0/1\n switchport trunk native vlan 99\n switchport trunk allowed vlan 10,20,99\n switchport mode trunk\n!"},
+			[]string{"vlan 20\n"}},
+		{"switch management", "switch", []string{"int vlan 1", "ip address 10.0.0.2 255.255.255.0", "no shutdown", "ip default-gateway 10.0.0.1"},
+			[]string{"interface Vlan1\n ip address 10.0.0.2 255.255.255.0\n!", "ip default-gateway 10.0.0.1\n!"}, nil},
+		{"point-to-point /31", "router", []string{"int s0/0/0", "ip address 10.0.0.0 255.255.255.254"},
+			[]string{" ip address 10.0.0.0 255.255.255.254\n"}, nil},
+		{"hostname reset", "router", []string{"hostname EDGE", "no hostname"}, []string{"hostname Router\n"}, []string{"EDGE"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newIOSDevice(tt.device, "")
+			configure(t, d, tt.commands...)
+			config := d.runningConfig()
+			for _, want := range tt.want {
+				if !strings.Contains(config, want) {
+					t.Errorf("running-config has no %q:\n%s", want, config)
+				}
+			}
+			for _, missing := range tt.missing {
+				if strings.Contains(config, missing) {
+					t.Errorf("running-config still has %q:\n%s", missing, config)
+				}
+			}
+		})
+	}
+}
+
+func TestIOSConfigErrors(t *testing.T) {
+	tests := []struct {
+		device   string
+		commands []string // all but the last must succeed
+		err      string
+	}{
+		{"router", []string{"int g0/9"}, "Invalid input"},
+		{"router", []string{"int g0/0", "ip address 10.0.0.0 255.255.255.0"}, "Bad mask /24"},
+		{"router", []string{"int g0/0", "ip address 10.0.0.255 255.255.255.0"}, "Bad mask /24"},
+		{"router", []string{"int g0/0", "ip address 10.0.0.1 255.0.255.0"}, "Invalid input"},
+		{"router", []string{"int g0/0", "ip address 10.0.0.1 255.255.255.0", "int g0/1", "ip address 10.0.0.2 255.255.0.0"}, "overlaps with GigabitEthernet0/0"},
+		{"router", []string{"int g0/0", "ip address 10.0.0.1"}, "Incomplete"},
+		{"router", []string{"ip route 10.0.0.1 255.255.255.0 10.0.0.254"}, "Inconsistent address and mask"},
+		{"router", []string{"ip route 10.0.0.0 255.255.255.0 g0/9"}, "Invalid input"},
+		{"router", []string{"access-list 100 permit 10.0.0.0 0.0.0.255"}, "Invalid input"},
+		{"router", []string{"access-list 100 permit tcp 10.0.0.0"}, "Incomplete"},
+		{"router", []string{"access-list 100 permit tcp any any eq 70000"}, "Invalid input"},
+		{"router", []string{"access-list 100 permit ip any any established"}, "Invalid input"},
+		{"router", []string{"access-list 100 permit ip any any", "ip access-list standard 100"}, "named extended IP access list"},
+		{"router", []string{"access-list 200 permit any"}, "Invalid input"},
+		{"router", []string{"int g0/0", "switchport mode access"}, "Invalid input"},
+		{"switch", []string{"int fa0/1", "ip address 10.0.0.1 255.255.255.0"}, "Invalid input"},
+		{"switch", []string{"vlan 4095"}, "Invalid input"},
+		{"switch", []string{"no vlan 1"}, "Default VLAN 1 may not be deleted"},
+		{"switch", []string{"no int vlan 1"}, "Invalid input"},
+		{"switch", []string{"int fa0/1", "switchport trunk allowed vlan 10;20"}, "Invalid input"},
+		{"switch", []string{"ip route 0.0.0.0 0.0.0.0 10.0.0.1"}, "Invalid input"},
+	}
+	for _, tt := range tests {
+		d := newIOSDevice(tt.device, "")
+		last := len(tt.commands) - 1
+		configure(t, d, tt.commands[:last]...)
+		_, err := d.exec(tt.commands[last])
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s %q: got %v, want %q", tt.device, tt.commands, err, tt.err)
+		}
+	}
+
+	// A rejected value leaves the setting as it was
+	d := newIOSDevice("switch", "")
+	configure(t, d, "int g0/1", "switchport mode trunk", "switchport trunk native vlan 99")
+	for _, c := range []string{"switchport trunk native vlan 5000", "switchport mode bogus"} {
+		if _, err := d.exec(c); err == nil {
+			t.Errorf("%s was accepted", c)
+		}
+	}
+	if config := d.runningConfig(); !strings.Contains(config, " switchport trunk native vlan 99\n switchport mode trunk\n") {
+		t.Errorf("rejected commands changed the port:\n%s", config)
+	}
+}
+
+func TestACLEntry(t *testing.T) {
+	tests := []struct {
+		entry    string
+		extended bool
+		want     string
+	}{
+		{"permit 10.1.1.1", false, "permit 10.1.1.1"},
+		{"permit host 10.1.1.1", false, "permit 10.1.1.1"},
+		{"deny 10.1.1.77 0.0.0.255 log", false, "deny 10.1.1.0 0.0.0.255 log"},
+		{"permit 0.0.0.0 255.255.255.255", false, "permit any"},
+		{"remark keep  spacing", false, "remark keep spacing"},
+		{"permit tcp 10.0.0.0 0.0.0.255 host 10.9.9.9 eq ssh", true, "permit tcp 10.0.0.0 0.0.0.255 host 10.9.9.9 eq 22"},
+		{"permit tcp any eq 1024 any range ftp telnet established", true, "permit tcp any eq 1024 any range 21 23 established"},
+		{"deny icmp any any echo log", true, "deny icmp any any echo log"},
+		{"deny UDP any 10.0.0.0 0.0.0.0 eq domain", true, "deny udp any host 10.0.0.0 eq 53"},
+		{"permit 47 any any", true, "permit 47 any any"},
+	}
+	for _, tt := range tests {
+		got, err := aclEntry(strings.Fields(tt.entry), tt.extended)
+		if err != nil || got != tt.want {
+			t.Errorf("aclEntry(%q) = %q, %v; want %q", tt.entry, got, err, tt.want)
+		}
+	}
+}
+
+func TestIOSShow(t *testing.T) {
+	r := newIOSDevice("router", "")
+	configure(t, r, "int g0/0", "ip address 10.0.0.1 255.255.255.0", "no shut",
+		"int g0/1", "ip address 10.1.0.1 255.255.0.0", // shut down, so not connected
+		"int lo0", "ip address 2.2.2.2 255.255.255.255",
+		"ip route 0.0.0.0 0.0.0.0 10.0.0.254", "router ospf 1", "network 10.0.0.0 0.0.0.255 area 0")
+	r.mode = modePrivileged
+
+	tests := []struct {
+		command string
+		want    []string
+		missing []string
+	}{
+		{"show ip route", []string{
+			"Gateway of last resort is 10.0.0.254 to network 0.0.0.0",
+			"S*    0.0.0.0/0 [1/0] via 10.0.0.254",
+			"C     10.0.0.0/24 is directly connected, GigabitEthernet0/0",
+			"L     10.0.0.1/32 is directly connected, GigabitEthernet0/0",
+			"C     2.2.2.2/32 is directly connected, Loopback0",
+		}, []string{"10.1.0.0", "L     2.2.2.2"}},
+		{"sh ip int br", []string{
+			"GigabitEthernet0/0         10.0.0.1        YES manual up                    up",
+			"GigabitEthernet0/1         10.1.0.1        YES manual administratively down down",
+		}, nil},
+		{"show ip protocols", []string{"Routing Protocol is \"ospf 1\"", "Router ID 2.2.2.2", "10.0.0.0 0.0.0.255 area 0"}, nil},
+		{"do show run", []string{"Current configuration:", "hostname Router"}, nil},
+		{"copy run start", []string{"[OK]"}, nil},
+		{"wr", []string{"[OK]"}, nil},
+	}
+	for _, tt := range tests {
+		mode := r.mode
+		if strings.HasPrefix(tt.command, "do ") {
+			r.mode = modeConfig
+		}
+		out, err := r.exec(tt.command)
+		r.mode = mode
+		if err != nil {
+			t.Errorf("%s: %v", tt.command, err)
+			continue
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(out, want) {
+				t.Errorf("%s has no %q:\n%s", tt.command, want, out)
+			}
+		}
+		for _, missing := range tt.missing {
+			if strings.Contains(out, missing) {
+				t.Errorf("%s shows %q:\n%s", tt.command, missing, out)
+			}
+		}
+	}
+
+	s := newIOSDevice("switch", "")
+	configure(t, s, "vlan 10", "name SALES", "int fa0/1", "switchport access vlan 10", "int fa0/2", "switchport access vlan 10",
+		"int g0/1", "switchport mode trunk")
+	s.mode = modePrivileged
+	out, err := s.exec("show vlan brief")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"1    default                          active    Fa0/3, Fa0/4, Fa0/5, Fa0/6,\n" + strings.Repeat(" ", 48) + "Fa0/7",
+		"Fa0/23, Fa0/24, Gi0/2\n",
+		"10   SALES                            active    Fa0/1, Fa0/2\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("show vlan brief has no %q:\n%s", want, out)
+		}
+	}
+	if _, err := r.exec("show vlan"); err == nil {
+		t.Error("a router showed VLANs")
+	}
+}
+
+func FuzzIOSDevice(f *testing.F) {
+	f.Add("en\nconf t\nint g0/0\nip add 10.0.0.1 255.255.255.0\nno shut\ndo sh ip route")
+	f.Add("vlan 10\nname X\nint fa0/1\nsw mode trunk\nsw trunk allowed vlan 1-5,10\nno vlan 10\nshow vlan")
+	f.Add("ip access-list extended A\npermit tcp any host 1.2.3.4 range 20 21\nno int lo0\nrouter ospf 1\npassive g0/0")
+	f.Fuzz(func(t *testing.T, script string) {
+		for _, kind := range deviceKinds {
+			d := newIOSDevice(kind, "")
+			for _, line := range strings.Split(script, "\n") {
+				d.exec(line)
+				d.prompt()
+			}
+			d.runningConfig()
+			d.showRoutes()
+			d.showProtocols()
+			d.showAccessLists()
+			d.showVLANs()
+		}
+	})
+}
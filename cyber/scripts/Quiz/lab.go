@@ -0,0 +1,260 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// Lab questions. The learner gets a simulated IOS router or switch and a
+// list of tasks, configures it any way they like and types done. Each
+// task is a check on the resulting running-config, so "int fa0/1" and
+// "interface FastEthernet0/1" earn the same credit, and each check is a
+// share of the question's credit.
+//
+//	"lab": {
+//	  "device": "switch",
+//	  "checks": [
+//	    {"task": "Create VLAN 10 named SALES", "section": "vlan 10", "line": "name SALES"},
+//	    {"task": "Make Fa0/1 an access port", "section": "interface Fa0/1", "line": "switchport mode access"}
+//	  ],
+//	  "solution": ["enable", "configure terminal", "vlan 10", "name SALES", ...]
+//	}
+//
+// A check passes when the line is in the running-config, under the
+// section when one is given, or is missing when absent is set. Lines are
+// compared ignoring case and spacing, and interface names in sections may
+// be abbreviated. Setup commands run in global configuration mode before
+// the learner starts at the user EXEC prompt.
+
+// Lab is the device and tasks of a lab question
+type Lab struct {
+	Device   string     `json:"device"`             // router or switch
+	Hostname string     `json:"hostname,omitempty"` // defaults to Router or Switch
+	Setup    []string   `json:"setup,omitempty"`    // configuration applied before the learner starts
+	Checks   []LabCheck `json:"checks"`
+	Solution []string   `json:"solution,omitempty"` // commands that complete the lab, shown in review
+}
+
+// LabCheck is one graded task of a lab
+type LabCheck struct {
+	Task    string `json:"task"`
+	Section string `json:"section,omitempty"` // e.g. "interface GigabitEthernet0/1"; empty for top-level lines
+	Line    string `json:"line"`
+	Absent  bool   `json:"absent,omitempty"` // pass when the line is missing
+}
+
+// device builds the lab's device and applies its setup
+func (l *Lab) device() (*iosDevice, error) {
+	d := newIOSDevice(l.Device, l.Hostname)
+	d.mode = modeConfig
+	for _, c := range l.Setup {
+		if _, err := d.exec(c); err != nil {
+			return nil, fmt.Errorf("setup command %q: %v", c, err)
+		}
+	}
+	d.mode = modeUser
+	return d, nil
+}
+
+func normalizeConfigLine(line string) string {
+	return strings.ToLower(strings.Join(strings.Fields(line), " "))
+}
+
+// configSections splits a running-config into its top-level lines, under
+// "", and the lines indented under each of them
+func configSections(config string) map[string][]string {
+	sections := make(map[string][]string)
+	current := ""
+	for _, line := range strings.Split(config, "\n") {
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "!") {
+			continue
+		}
+		norm := normalizeConfigLine(line)
+		if strings.HasPrefix(line, " ") {
+			sections[current] = append(sections[current], norm)
+			continue
+		}
+		current = norm
+		sections[""] = append(sections[""], norm)
+	}
+	return sections
+}
+
+// sectionKey normalises a check's section, expanding an interface name
+func sectionKey(section string) (string, error) {
+	words := strings.Fields(section)
+	if len(words) > 1 {
+		if k, err := iosKeyword(words[0], "interface"); err == nil {
+			name, err := interfaceName(words[1:])
+			if err != nil {
+				return "", fmt.Errorf("unknown interface in section %q", section)
+			}
+			return normalizeConfigLine(k + " " + name), nil
+		}
+	}
+	return normalizeConfigLine(section), nil
+}
+
+// passes reports whether the check holds for a running-config
+func (c LabCheck) passes(sections map[string][]string) bool {
+	key, _ := sectionKey(c.Section)
+	want := normalizeConfigLine(c.Line)
+	for _, line := range sections[key] {
+		if line == want {
+			return !c.Absent
+		}
+	}
+	return c.Absent
+}
+
+// labResults reports which checks the device passes
+func labResults(l *Lab, d *iosDevice) []bool {
+	sections := configSections(d.runningConfig())
+	results := make([]bool, len(l.Checks))
+	for i, c := range l.Checks {
+		results[i] = c.passes(sections)
+	}
+	return results
+}
+
+// labProblems checks a lab question: its setup must run, its checks must
+// not all pass before the learner starts, and its solution must pass them
+func labProblems(q Question) []string {
+	l := q.Lab
+	if !anyOf([]string{l.Device}, deviceKinds) {
+		return []string{fmt.Sprintf("question %s: lab device must be one of %s", q.ID, strings.Join(deviceKinds, ", "))}
+	}
+	if len(l.Checks) == 0 {
+		return []string{fmt.Sprintf("question %s: lab has no checks", q.ID)}
+	}
+	var problems []string
+	for i, c := range l.Checks {
+		if strings.TrimSpace(c.Line) == "" {
+			problems = append(problems, fmt.Sprintf("question %s: check %d has no line", q.ID, i+1))
+		}
+		if _, err := sectionKey(c.Section); err != nil {
+			problems = append(problems, fmt.Sprintf("question %s: check %d: %v", q.ID, i+1, err))
+		}
+	}
+	d, err := l.device()
+	if err != nil {
+		return append(problems, fmt.Sprintf("question %s: %v", q.ID, err))
+	}
+	if passed(labResults(l, d)) == len(l.Checks) {
+		problems = append(problems, fmt.Sprintf("question %s: every check passes before the lab starts", q.ID))
+	}
+
+	if len(l.Solution) > 0 {
+		for _, c := range l.Solution {
+			if _, err := d.exec(c); err != nil {
+				return append(problems, fmt.Sprintf("question %s: solution command %q: %v", q.ID, c, err))
+			}
+		}
+		for i, ok := range labResults(l, d) {
+			if !ok {
+				problems = append(problems, fmt.Sprintf("question %s: the solution fails check %d", q.ID, i+1))
+			}
+		}
+	}
+	return problems
+}
+
+func passed(results []bool) int {
+	n := 0
+	for _, ok := range results {
+		if ok {
+			n++
+		}
+	}
+	return n
+}
+
+// askLab runs a lab question until the learner types done, then grades
+// the device
+func askLab(q Question) AnswerRecord {
+	d, err := q.Lab.device()
+	if err != nil {
+		printColor(ColorRed, err.Error()+"\n")
+		return newPartialAnswer(q, nil, 0)
+	}
+
+	printColor(ColorCyan, "\n"+tr("Tasks:")+"\n")
+	for i, c := range q.Lab.Checks {
+		printColor(ColorWhite, fmt.Sprintf("  %d. %s\n", i+1, c.Task))
+	}
+	printColor(ColorCyan, "\n"+tr("Configure the device and type done when you have finished. Type ? for help.")+"\n\n")
+
+	var typed []string
+	for {
+		printColor(ColorGreen+ColorBold, d.prompt()+" ")
+		line, err := reader.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if strings.EqualFold(line, "done") || err != nil && line == "" {
+			break
+		}
+		if line == "" {
+			continue
+		}
+		typed = append(typed, line)
+		out, cmdErr := d.exec(line)
+		if cmdErr != nil {
+			printColor(ColorRed, cmdErr.Error()+"\n")
+		}
+		if out != "" {
+			printColor(ColorWhite, out)
+		}
+	}
+
+	fmt.Println()
+	results := labResults(q.Lab, d)
+	for i, ok := range results {
+		if ok {
+			printColor(ColorGreen, "✓ "+q.Lab.Checks[i].Task+"\n")
+		} else {
+			printColor(ColorRed, "✗ "+q.Lab.Checks[i].Task+"\n")
+		}
+	}
+	return newPartialAnswer(q, typed, passed(results))
+}
+
+// labReviewLines shows which tasks a lab answer completed, replaying the
+// commands typed
+func labReviewLines(q Question, a AnswerRecord) []pageLine {
+	var lines []pageLine
+	d, err := q.Lab.device()
+	if err != nil {
+		return []pageLine{{ColorRed, "   " + err.Error()}}
+	}
+	for _, c := range a.Typed {
+		d.exec(c)
+	}
+	for i, ok := range labResults(q.Lab, d) {
+		if ok {
+			lines = append(lines, pageLine{ColorGreen, "   ✓ " + q.Lab.Checks[i].Task})
+		} else {
+			lines = append(lines, pageLine{ColorRed, "   ✗ " + q.Lab.Checks[i].Task})
+		}
+	}
+	if !a.Correct && len(q.Lab.Solution) > 0 {
+		lines = append(lines, pageLine{ColorGreen, "   " + tr("A solution:")})
+		for _, c := range q.Lab.Solution {
+			lines = append(lines, pageLine{ColorGreen, "     " + c})
+		}
+	}
+	return lines
+}
+
+// labHTML lists the tasks of a lab question on a web page
+func labHTML(q Question, showAnswer bool) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<p class=\"lab\">%s</p>\n<ol class=\"tasks\">\n", html.EscapeString(tr("Tasks:")))
+	for _, c := range q.Lab.Checks {
+		fmt.Fprintf(&b, "<li>%s</li>\n", inlineHTML(c.Task))
+	}
+	b.WriteString("</ol>\n")
+	if showAnswer && len(q.Lab.Solution) > 0 {
+		fmt.Fprintf(&b, "<pre class=\"solution\">%s</pre>\n", html.EscapeString(strings.Join(q.Lab.Solution, "\n")))
+	}
+	return b.String()
+}
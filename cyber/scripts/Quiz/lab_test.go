@@ -0,0 +1,133 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// vlanLab asks for VLAN 10 named SALES with Fa0/1 in it, and for Fa0/2
+// to be brought out of VLAN 20, where its setup puts it
+func vlanLab() Question {
+	return Question{ID: "lab1", Lab: &Lab{
+		Device: "switch",
+		Setup:  []string{"vlan 20", "int fa0/2", "switchport access vlan 20"},
+		Checks: []LabCheck{
+			{Task: "Create VLAN 10 named SALES", Section: "vlan 10", Line: "name SALES"},
+			{Task: "Put Fa0/1 in VLAN 10", Section: "int fa0/1", Line: "switchport  access vlan 10"},
+			{Task: "Take Fa0/2 out of VLAN 20", Section: "interface FastEthernet0/2", Line: "switchport access vlan 20", Absent: true},
+		},
+		Solution: []string{"enable", "conf t", "vlan 10", "name SALES", "int fa0/1", "switchport access vlan 10",
+			"int fa0/2", "no switchport access vlan", "end"},
+	}}
+}
+
+func TestConfigSections(t *testing.T) {
+	sections := configSections("!\nhostname R1\n!\ninterface GigabitEthernet0/0\n ip address 10.0.0.1  255.0.0.0\n shutdown\n!\nend\n")
+	want := map[string][]string{
+		"":                             {"hostname r1", "interface gigabitethernet0/0", "end"},
+		"interface gigabitethernet0/0": {"ip address 10.0.0.1 255.0.0.0", "shutdown"},
+	}
+	if !reflect.DeepEqual(sections, want) {
+		t.Errorf("configSections = %q, want %q", sections, want)
+	}
+}
+
+func TestSectionKey(t *testing.T) {
+	tests := []struct{ section, want, err string }{
+		{"", "", ""},
+		{"int fa0/1", "interface fastethernet0/1", ""},
+		{"Interface  Gi 0/1", "interface gigabitethernet0/1", ""},
+		{"router ospf 1", "router ospf 1", ""},
+		{"interface Tunnel0", "", "unknown interface"},
+	}
+	for _, tt := range tests {
+		got, err := sectionKey(tt.section)
+		if got != tt.want || (err == nil) != (tt.err == "") || err != nil && !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("sectionKey(%q) = %q, %v; want %q, %q", tt.section, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestLabResults(t *testing.T) {
+	q := vlanLab()
+	tests := []struct {
+		name     string
+		commands []string
+		want     []bool
+	}{
+		{"nothing done", nil, []bool{false, false, false}},
+		{"the solution", q.Lab.Solution, []bool{true, true, true}},
+		{"abbreviated and in another order", []string{"en", "conf t", "int fa0/1", "sw acc vl 10", "vlan 10", "name SALES", "end"},
+			[]bool{true, true, false}},
+		{"wrong name", []string{"en", "conf t", "vlan 10", "name sales2"}, []bool{false, false, false}},
+		{"port moved elsewhere", []string{"en", "conf t", "int fa0/2", "sw acc vl 30"}, []bool{false, false, true}},
+	}
+	for _, tt := range tests {
+		d, err := q.Lab.device()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.prompt() != "Switch>" {
+			t.Fatalf("the lab starts at %q", d.prompt())
+		}
+		for _, c := range tt.commands {
+			d.exec(c)
+		}
+		if got := labResults(q.Lab, d); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLabProblems(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(l *Lab)
+		want   string // empty for no problems
+	}{
+		{"valid", func(l *Lab) {}, ""},
+		{"unknown device", func(l *Lab) { l.Device = "firewall" }, "lab device must be one of router, switch"},
+		{"no checks", func(l *Lab) { l.Checks = nil }, "lab has no checks"},
+		{"empty line", func(l *Lab) { l.Checks[0].Line = " " }, "check 1 has no line"},
+		{"bad section", func(l *Lab) { l.Checks[1].Section = "interface Tunnel0" }, "check 2: unknown interface"},
+		{"bad setup", func(l *Lab) { l.Setup = append(l.Setup, "vlan 5000") }, `setup command "vlan 5000"`},
+		{"already done", func(l *Lab) { l.Checks = l.Checks[2:]; l.Setup = nil }, "every check passes before the lab starts"},
+		{"bad solution", func(l *Lab) { l.Solution[2] = "vlan ten" }, `solution command "vlan ten"`},
+		{"incomplete solution", func(l *Lab) { l.Solution = l.Solution[:4] }, "the solution fails check 2"},
+	}
+	for _, tt := range tests {
+		q := vlanLab()
+		tt.change(q.Lab)
+		problems := strings.Join(labProblems(q), "\n")
+		if tt.want == "" && problems != "" {
+			t.Errorf("%s: unexpected problems:\n%s", tt.name, problems)
+		}
+		if tt.want != "" && !strings.Contains(problems, tt.want) {
+			t.Errorf("%s: problems:\n%s\nwant one containing %q", tt.name, problems, tt.want)
+		}
+	}
+}
+
+func TestLabReview(t *testing.T) {
+	useTheme(t, "none")
+	q := vlanLab()
+	a := AnswerRecord{Typed: []string{"enable", "conf t", "vlan 10", "name SALES"}}
+	var got []string
+	for _, l := range labReviewLines(q, a) {
+		got = append(got, strings.TrimSpace(l.text))
+	}
+	want := []string{"✓ Create VLAN 10 named SALES", "✗ Put Fa0/1 in VLAN 10", "✗ Take Fa0/2 out of VLAN 20", "A solution:"}
+	if len(got) != len(want)+len(q.Lab.Solution) || !reflect.DeepEqual(got[:len(want)], want) {
+		t.Errorf("review lines %q", got)
+	}
+
+	page := labHTML(Question{Lab: &Lab{Checks: []LabCheck{{Task: "Set <b>**R1**</b>"}}, Solution: []string{"hostname <R1>"}}}, false)
+	if !strings.Contains(page, "<li>Set &lt;b&gt;<strong>R1</strong>&lt;/b&gt;</li>") || strings.Contains(page, "solution") {
+		t.Errorf("lab HTML without answers:\n%s", page)
+	}
+	page = labHTML(Question{Lab: &Lab{Solution: []string{"hostname <R1>"}}}, true)
+	if !strings.Contains(page, "<pre class=\"solution\">hostname &lt;R1&gt;</pre>") {
+		t.Errorf("lab HTML with answers:\n%s", page)
+	}
+}
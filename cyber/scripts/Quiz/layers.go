@@ -0,0 +1,380 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+)
+
+// Layered question banks. Questions are merged by ID from, in increasing
+// precedence:
+//
+//	built-in  questions.json embedded in the binary
+//	system    /etc/cyber-quiz/questions.json (%ProgramData% on Windows)
+//	user      questions.json in the data directory, the only layer written
+//	project   .cyber-quiz/questions.json in the working directory or a parent
+//
+// The project layer is only read in sessions that have the admin password,
+// so a learner cannot replace questions and answers by running the quiz
+// from a directory of their own. Any layer, the embedded one included, may
+// be sealed; the user layer is then saved sealed the same way.
+//
+// The user layer stores only the differences from the layers below it: new
+// and edited questions, the IDs of removed ones, and for each edited
+// question a hash of the version it replaced. When the built-in bank is
+// updated, unedited questions follow the update and edited ones are kept
+// and reported as conflicts.
+
+//go:embed questions.json
+var builtinBank []byte
+
+const (
+	layerBuiltin = "built-in"
+	layerSystem  = "system"
+	layerUser    = "user"
+	layerProject = "project"
+)
+
+// bankLayer describes one source of questions
+type bankLayer struct {
+	name      string
+	path      string
+	found     bool
+	questions int
+	skipped   string // why the layer was not read
+	err       error
+}
+
+var (
+	bankLayers []bankLayer
+
+	// lowerQuestions is the merge of the layers below the user layer
+	lowerQuestions map[string]Question
+
+	// userBase maps edited question IDs to the hash of the lower version
+	// they replaced
+	userBase map[string]string
+
+	// bankConflicts lists questions edited locally whose lower version has
+	// changed since
+	bankConflicts []string
+
+	// shadowedEdits are user edits hidden by the project layer, kept so
+	// that saving does not drop them
+	shadowedEdits map[string]Question
+)
+
+func systemBankPath() string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("ProgramData"), "cyber-quiz", "questions.json")
+	}
+	return "/etc/cyber-quiz/questions.json"
+}
+
+// projectBankPath looks for .cyber-quiz/questions.json from the working
+// directory upwards
+func projectBankPath() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	for {
+		p := filepath.Join(dir, ".cyber-quiz", "questions.json")
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+func questionHash(q Question) string {
+	q.layer = ""
+	data, _ := json.Marshal(q)
+	return sha256Hex(data)
+}
+
+// readLayer opens a bank file; a missing file is not an error
+func readLayer(layer *bankLayer) (QuizData, *bankSealing) {
+	if layer.path == "" {
+		return QuizData{}, nil
+	}
+	data, err := os.ReadFile(layer.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			layer.err = err
+		}
+		return QuizData{}, nil
+	}
+	layer.found = true
+
+	bank, sealing, err := openBank(data)
+	if err != nil {
+		layer.err = err
+		return QuizData{}, nil
+	}
+	layer.questions = len(bank.Questions)
+	return bank, sealing
+}
+
+// loadQuestionBanks merges all layers into quizData. Only a broken user
+// layer is fatal, since saving would overwrite it.
+func loadQuestionBanks() error {
+	builtin, builtinSealing, err := openBank(builtinBank)
+	if err != nil {
+		return fmt.Errorf("built-in bank: %w", err)
+	}
+
+	bankLayers = []bankLayer{
+		{name: layerBuiltin, path: "(embedded)", found: true, questions: len(builtin.Questions)},
+		{name: layerSystem, path: systemBankPath()},
+		{name: layerUser, path: questionsFile},
+		{name: layerProject, path: projectBankPath()},
+	}
+	system, systemSealing := readLayer(&bankLayers[1])
+	user, sealing := readLayer(&bankLayers[2])
+	if bankLayers[2].err != nil {
+		return fmt.Errorf("%s: %w", questionsFile, bankLayers[2].err)
+	}
+	var project QuizData
+	if bankLayers[3].path != "" && adminPassword == "" {
+		bankLayers[3].skipped = "skipped without the admin password"
+	} else {
+		project, _ = readLayer(&bankLayers[3])
+	}
+	user.Questions = renameDuplicateQuestions(user.Questions)
+
+	// Edits to a sealed lower bank are saved sealed too, so they do not
+	// give its answers away
+	currentSealing = sealing
+	if currentSealing == nil {
+		currentSealing = systemSealing
+	}
+	if currentSealing == nil {
+		currentSealing = builtinSealing
+	}
+
+	var order []string
+	merged := make(map[string]Question)
+	add := func(layer string, questions []Question) {
+		for _, q := range questions {
+			if _, seen := merged[q.ID]; !seen {
+				order = append(order, q.ID)
+			}
+			q.layer = layer
+			merged[q.ID] = q
+		}
+	}
+
+	add(layerBuiltin, builtin.Questions)
+	add(layerSystem, system.Questions)
+
+	lowerQuestions = make(map[string]Question, len(merged))
+	for id, q := range merged {
+		lowerQuestions[id] = q
+	}
+
+	// Apply the user layer as a three-way merge against the lower layers
+	userBase = make(map[string]string)
+	bankConflicts = nil
+	for _, id := range user.Removed {
+		delete(merged, id)
+	}
+	var edits []Question
+	for _, q := range user.Questions {
+		lower, exists := lowerQuestions[q.ID]
+		if !exists {
+			edits = append(edits, q)
+			continue
+		}
+		lowerHash := questionHash(lower)
+		if questionHash(q) == lowerHash {
+			continue // identical copy, e.g. from a full bank written by older versions
+		}
+		base, known := user.Base[q.ID]
+		if !known {
+			base = lowerHash
+		}
+		if base != lowerHash {
+			bankConflicts = append(bankConflicts, q.ID)
+		}
+		userBase[q.ID] = base
+		edits = append(edits, q)
+	}
+	add(layerUser, edits)
+
+	shadowedEdits = make(map[string]Question)
+	for _, q := range project.Questions {
+		if prev, ok := merged[q.ID]; ok && prev.layer == layerUser {
+			shadowedEdits[q.ID] = prev
+		}
+	}
+	add(layerProject, project.Questions)
+
+	quizData.Questions = nil
+	for _, id := range order {
+		if q, ok := merged[id]; ok {
+			quizData.Questions = append(quizData.Questions, q)
+		}
+	}
+	sort.Strings(bankConflicts)
+
+	return nil
+}
+
+// loadProjectBank reads the project layer once the admin password is
+// known, for sessions that were given it after the banks were loaded
+func loadProjectBank() {
+	if len(bankLayers) < 4 || bankLayers[3].skipped == "" {
+		return
+	}
+	if err := loadQuestionBanks(); err != nil {
+		printColor(ColorRed, fmt.Sprintf("✗ Cannot open the question bank: %v\n", err))
+		return
+	}
+	setBankDirBaseline()
+}
+
+// allLayersData is the merged bank of every layer, for sealing into a
+// bank that stands on its own
+func allLayersData() QuizData {
+	return QuizData{Questions: append([]Question{}, quizData.Questions...)}
+}
+
+// userLayerData is what the user layer has to store for quizData to be
+// reproduced on the next load
+func userLayerData() QuizData {
+	data := QuizData{Questions: []Question{}}
+	present := make(map[string]bool)
+
+	for _, q := range quizData.Questions {
+		present[q.ID] = true
+		if q.layer == layerProject {
+			shadowed, ok := shadowedEdits[q.ID]
+			if !ok {
+				continue
+			}
+			q = shadowed
+		}
+		lower, exists := lowerQuestions[q.ID]
+		if exists && questionHash(lower) == questionHash(q) {
+			continue
+		}
+		if exists {
+			if data.Base == nil {
+				data.Base = make(map[string]string)
+			}
+			base, known := userBase[q.ID]
+			if !known {
+				base = questionHash(lower)
+			}
+			data.Base[q.ID] = base
+		}
+		data.Questions = append(data.Questions, q)
+	}
+
+	for id := range lowerQuestions {
+		if !present[id] {
+			data.Removed = append(data.Removed, id)
+		}
+	}
+	sort.Strings(data.Removed)
+
+	return data
+}
+
+// acceptLocalEdit marks a locally edited question as based on the current
+// lower version, resolving any conflict in favour of the edit
+func acceptLocalEdit(id string) {
+	delete(userBase, id)
+	for i, c := range bankConflicts {
+		if c == id {
+			bankConflicts = append(bankConflicts[:i], bankConflicts[i+1:]...)
+			break
+		}
+	}
+}
+
+// resetQuestion drops the local version of a question in favour of the
+// lower layers
+func resetQuestion(id string) error {
+	lower, exists := lowerQuestions[id]
+	if !exists {
+		return fmt.Errorf("%s is not in the built-in or system bank", id)
+	}
+	acceptLocalEdit(id)
+	for i, q := range quizData.Questions {
+		if q.ID == id {
+			if q.layer == layerProject {
+				return fmt.Errorf("%s comes from the project bank, which is not written by the quiz", id)
+			}
+			quizData.Questions[i] = lower
+			saveQuestions()
+			return nil
+		}
+	}
+	quizData.Questions = append(quizData.Questions, lower)
+	saveQuestions()
+	return nil
+}
+
+// bankSourceLines describes the layers, where each module comes from and
+// any merge conflicts
+func bankSourceLines() []pageLine {
+	lines := []pageLine{{ColorCyan + ColorBold, "Question bank layers (later layers override earlier ones by ID)"}}
+	for _, l := range bankLayers {
+		status := fmt.Sprintf("%d questions", l.questions)
+		color := ColorGreen
+		switch {
+		case l.err != nil:
+			status, color = "error: "+l.err.Error(), ColorRed
+		case l.skipped != "":
+			status, color = l.skipped, ColorYellow
+		case l.path == "":
+			status, color = "not found", ColorYellow
+		case !l.found:
+			status, color = "not present", ColorYellow
+		}
+		path := l.path
+		if path == "" {
+			path = "-"
+		}
+		lines = append(lines, pageLine{color, fmt.Sprintf("  %-9s %-45s %s", l.name, path, status)})
+	}
+	if currentSealing != nil {
+		lines = append(lines, pageLine{ColorWhite, "  user layer is saved " + bankDescription()})
+	}
+
+	lines = append(lines, pageLine{}, pageLine{ColorCyan + ColorBold, "Modules"})
+	modules := getAvailableModules()
+	var categories []string
+	for category := range modules {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	for _, category := range categories {
+		mods := modules[category]
+		sort.Strings(mods)
+		for _, mod := range mods {
+			lines = append(lines, pageLine{ColorWhite, fmt.Sprintf("  %-30s %3d questions  %s",
+				category+" - "+mod, countQuestions(category, mod), moduleSource(category, mod))})
+		}
+	}
+
+	if len(bankConflicts) > 0 {
+		lines = append(lines, pageLine{}, pageLine{ColorRed + ColorBold, "Conflicts: edited locally and changed in a lower layer since"})
+		for _, id := range bankConflicts {
+			lines = append(lines, pageLine{ColorRed, "  ⚠ " + id})
+		}
+		lines = append(lines, pageLine{ColorYellow, "  Keep the local version with 'banks keep <id>' or take the lower one with 'banks reset <id>'."})
+	}
+
+	return lines
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// useLayers embeds the given built-in questions, runs the test from an
+// empty working directory and restores the bank state afterwards
+func useLayers(t *testing.T, builtin ...Question) (work string) {
+	t.Helper()
+	useDataDir(t)
+	savedBank, savedLayers, savedLower, savedBase := builtinBank, bankLayers, lowerQuestions, userBase
+	savedConflicts, savedShadowed, savedSealing := bankConflicts, shadowedEdits, currentSealing
+	wd, _ := os.Getwd()
+	t.Cleanup(func() {
+		builtinBank, bankLayers, lowerQuestions, userBase = savedBank, savedLayers, savedLower, savedBase
+		bankConflicts, shadowedEdits, currentSealing = savedConflicts, savedShadowed, savedSealing
+		os.Chdir(wd)
+	})
+
+	builtinBank, _ = json.Marshal(QuizData{Questions: builtin})
+	work = t.TempDir()
+	os.Chdir(work)
+	return work
+}
+
+// writeBank writes a plaintext bank file, creating its directory
+func writeBank(t *testing.T, path string, data QuizData) {
+	t.Helper()
+	raw, _ := json.Marshal(data)
+	os.MkdirAll(filepath.Dir(path), 0755)
+	if err := os.WriteFile(path, raw, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// loadedLayers returns "id:layer" for each loaded question, in bank order
+func loadedLayers(t *testing.T) string {
+	t.Helper()
+	if err := loadQuestionBanks(); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, q := range quizData.Questions {
+		got = append(got, q.ID+":"+q.layer)
+	}
+	return strings.Join(got, " ")
+}
+
+func TestLoadQuestionBanks(t *testing.T) {
+	q1, q2, q3 := testQuestion("q1", "One"), testQuestion("q2", "One"), testQuestion("q3", "One")
+	work := useLayers(t, q1, q2, q3)
+
+	edited := q2
+	edited.Question = "Edited locally?"
+	user := QuizData{Questions: []Question{q1, edited, testQuestion("u1", "Two")}, Removed: []string{"q3"}}
+	writeBank(t, questionsFile, user)
+	project := q1
+	project.Question = "From the project?"
+	writeBank(t, filepath.Join(work, ".cyber-quiz", "questions.json"), QuizData{Questions: []Question{project}})
+	os.MkdirAll(filepath.Join(work, "sub", "dir"), 0755)
+	os.Chdir(filepath.Join(work, "sub", "dir"))
+
+	// Learners do not get the project layer
+	if got := loadedLayers(t); got != "q1:built-in q2:user u1:user" {
+		t.Errorf("without the admin password: %s", got)
+	}
+	if bankLayers[3].skipped == "" || bankLayers[3].found {
+		t.Errorf("project layer %+v was read without the admin password", bankLayers[3])
+	}
+
+	// Saving stores only the differences, so q1 is dropped from the file
+	want := QuizData{
+		Questions: []Question{edited, testQuestion("u1", "Two")},
+		Removed:   []string{"q3"},
+		Base:      map[string]string{"q2": questionHash(q2)},
+	}
+	got, _ := json.Marshal(userLayerData())
+	if wantJSON, _ := json.Marshal(want); string(got) != string(wantJSON) {
+		t.Errorf("user layer\n got %s\nwant %s", got, wantJSON)
+	}
+
+	adminPassword = "admin-password"
+	if got := loadedLayers(t); got != "q1:project q2:user u1:user" {
+		t.Errorf("with the admin password: %s", got)
+	}
+	if quizData.Questions[0].Question != "From the project?" {
+		t.Errorf("q1 is %q", quizData.Questions[0].Question)
+	}
+}
+
+func TestBuiltinUpdate(t *testing.T) {
+	q1, q2 := testQuestion("q1", "One"), testQuestion("q2", "One")
+	useLayers(t, q1, q2)
+	edited := q2
+	edited.Question = "Edited locally?"
+	writeBank(t, questionsFile, QuizData{Questions: []Question{edited}, Base: map[string]string{"q2": questionHash(q2)}})
+
+	// The new release changes both questions
+	newQ1, newQ2 := q1, q2
+	newQ1.Question, newQ2.Question = "Updated q1?", "Updated q2?"
+	builtinBank, _ = json.Marshal(QuizData{Questions: []Question{newQ1, newQ2}})
+
+	if got := loadedLayers(t); got != "q1:built-in q2:user" {
+		t.Fatalf("loaded %s", got)
+	}
+	if quizData.Questions[0].Question != "Updated q1?" || quizData.Questions[1].Question != "Edited locally?" {
+		t.Errorf("unedited question should follow the update and the edit should be kept: %q, %q",
+			quizData.Questions[0].Question, quizData.Questions[1].Question)
+	}
+	if !reflect.DeepEqual(bankConflicts, []string{"q2"}) {
+		t.Errorf("conflicts %v", bankConflicts)
+	}
+
+	// Keeping the edit rebases it on the new version
+	acceptLocalEdit("q2")
+	if data := userLayerData(); len(bankConflicts) > 0 || data.Base["q2"] != questionHash(newQ2) {
+		t.Errorf("after keeping: conflicts %v, base %v", bankConflicts, data.Base)
+	}
+}
+
+func TestSealedBuiltinBank(t *testing.T) {
+	t.Setenv(bankKeyEnv, "distribution-key")
+	bank := secretBank()
+	useLayers(t)
+	s, err := newBankSealing("distribution", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if builtinBank, err = sealBank(QuizData{Questions: bank.Questions}, s); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(builtinBank), "SECRET") {
+		t.Fatal("the sealed bank shows an answer")
+	}
+
+	if got := loadedLayers(t); got != "q1:built-in q2:built-in q3:built-in" {
+		t.Fatalf("loaded %s", got)
+	}
+	if quizData.Questions[0].Answer != 1 {
+		t.Errorf("answer %d after unsealing", quizData.Questions[0].Answer)
+	}
+
+	// An edit is saved sealed with the built-in bank's key
+	edited := quizData.Questions[0]
+	edited.Explanation = "SECRET: edited"
+	quizData.Questions[0] = edited
+	raw, err := encodeQuestions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(raw), "SECRET") {
+		t.Errorf("the edit of a sealed built-in question was saved in plain text:\n%s", raw)
+	}
+
+	// and every layer can be sealed into a bank to build in
+	if all := allLayersData(); len(all.Questions) != 3 {
+		t.Errorf("all layers hold %d questions", len(all.Questions))
+	}
+}
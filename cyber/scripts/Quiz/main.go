@@ -3,15 +3,18 @@ package main
 import (
 	"bufio"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
 
-// Color codes for CLI
-const (
+// Color codes for CLI, set by the theme (see applyTheme)
+var (
 	ColorReset   = "\033[0m"
 	ColorRed     = "\033[31m"
 	ColorGreen   = "\033[32m"
@@ -23,10 +26,8 @@ const (
 	ColorBold    = "\033[1m"
 )
 
-// Admin credentials
-const (
-	DefaultAdminPassword = "admin123"
-)
+// adminPasswordEnv answers the admin password prompt of subcommands
+const adminPasswordEnv = "CYBER_QUIZ_ADMIN_PASSWORD"
 
 // User represents a quiz user with their progress
 type User struct {
@@ -34,13 +35,95 @@ type User struct {
 	Name      string                      `json:"name"`
 	CreatedAt time.Time                   `json:"created_at"`
 	Scores    map[string]map[string]Score `json:"scores"` // category -> module -> score
+	Attempts  []Attempt                   `json:"attempts,omitempty"`
+	Groups    []string                    `json:"groups,omitempty"` // IDs of groups the user belongs to
+	ChainHead string                      `json:"chain_head,omitempty"`
+	Language  string                      `json:"language,omitempty"` // interface language picked by the user
+	Missed    map[string]int              `json:"missed,omitempty"`   // missed deck: question ID -> correct answers in a row
+	Presets   []QuizPreset                `json:"presets,omitempty"`  // saved custom quizzes
+
+	integrityIssues []string // problems found by verifyUserRecords
+	unsignedRecords int      // legacy records without a MAC
 }
 
 // Score tracks user performance in a module
 type Score struct {
 	Correct   int       `json:"correct"`
 	Total     int       `json:"total"`
+	Points    float64   `json:"points,omitempty"` // with partial credit, when it differs from Correct
 	LastTaken time.Time `json:"last_taken"`
+	MAC       string    `json:"mac,omitempty"`
+
+	tampered bool
+}
+
+// Attempt records one sitting of a module and the answer given to each question
+type Attempt struct {
+	ID           string         `json:"id,omitempty"`     // globally unique, see attemptID for older records
+	Origin       string         `json:"origin,omitempty"` // device ID of the installation that recorded it
+	Category     string         `json:"category"`
+	Module       string         `json:"module"`
+	AssignmentID string         `json:"assignment_id,omitempty"`
+	StartedAt    time.Time      `json:"started_at"`
+	FinishedAt   time.Time      `json:"finished_at"`
+	Answers      []AnswerRecord `json:"answers"`
+	Prev         string         `json:"prev,omitempty"` // MAC of the previous attempt in the chain
+	MAC          string         `json:"mac,omitempty"`
+
+	tampered bool
+}
+
+// AnswerRecord is the learner's response to a single question
+type AnswerRecord struct {
+	QuestionID string   `json:"question_id"`
+	Chosen     int      `json:"chosen"` // index of the chosen option, -1 if invalid
+	Correct    bool     `json:"correct"`
+	Typed      []string `json:"typed,omitempty"`  // commands typed for each step of a command-line question
+	Credit     float64  `json:"credit,omitempty"` // share of the steps right when not all are
+}
+
+// Correct returns the number of questions answered correctly
+func (a Attempt) Correct() int {
+	correct := 0
+	for _, ans := range a.Answers {
+		if ans.Correct {
+			correct++
+		}
+	}
+	return correct
+}
+
+// Points is the number of questions right, counting the partial credit of
+// command-line questions
+func (a Attempt) Points() float64 {
+	points := 0.0
+	for _, ans := range a.Answers {
+		if ans.Correct {
+			points++
+		} else {
+			points += ans.Credit
+		}
+	}
+	return points
+}
+
+// Percent is the attempt's score as a percentage
+func (a Attempt) Percent() float64 {
+	if len(a.Answers) == 0 {
+		return 0
+	}
+	return a.Points() / float64(len(a.Answers)) * 100
+}
+
+// Percent is the score as a percentage, with any partial credit
+func (s Score) Percent() float64 {
+	if s.Total == 0 {
+		return 0
+	}
+	if s.Points > 0 {
+		return s.Points / float64(s.Total) * 100
+	}
+	return float64(s.Correct) / float64(s.Total) * 100
 }
 
 // Question represents a quiz question
@@ -51,19 +134,64 @@ type Question struct {
 	Answer   int      `json:"answer"` // index of correct answer
 	Category string   `json:"category"`
 	Module   string   `json:"module"`
+
+	Objectives []string   `json:"objectives,omitempty"` // objective IDs from the pack catalogue
+	Pack       string     `json:"pack,omitempty"`       // name of the installed pack it came from
+	Tags       []string   `json:"tags,omitempty"`
+	Difficulty string     `json:"difficulty,omitempty"` // easy, medium or hard
+	Author     string     `json:"author,omitempty"`
+	Source     string     `json:"source,omitempty"` // where the question came from, e.g. a book or URL
+	CreatedAt  *time.Time `json:"created_at,omitempty"`
+	UpdatedAt  *time.Time `json:"updated_at,omitempty"`
+
+	Exhibit string          `json:"exhibit,omitempty"` // preformatted text shown as written
+	Images  []QuestionImage `json:"images,omitempty"`
+	Shell   string          `json:"shell,omitempty"` // bash or ios, for command-line questions
+	Steps   []SimStep       `json:"steps,omitempty"` // commands to type instead of choosing an option
+	Lab     *Lab            `json:"lab,omitempty"`   // a simulated device to configure
+
+	Explanation  string                  `json:"explanation,omitempty"`  // shown after answering
+	Translations map[string]QuestionText `json:"translations,omitempty"` // by language code
+
+	layer string // bank layer the question was loaded from
 }
 
-// QuizData holds all quiz questions
+// QuizData holds all quiz questions. Removed and Base are only used in
+// the user layer of a layered bank.
 type QuizData struct {
-	Questions []Question `json:"questions"`
+	Questions []Question        `json:"questions"`
+	Removed   []string          `json:"removed,omitempty"` // IDs removed from lower layers
+	Base      map[string]string `json:"base,omitempty"`    // ID -> hash of the lower version an edit replaced
 }
 
-// AdminConfig stores admin password
+// AdminConfig stores admin password and certificate settings
 type AdminConfig struct {
-	Password string `json:"password"`
+	Password             string                    `json:"password,omitempty"`              // plaintext in older versions, replaced by PasswordHash
+	PasswordHash         *passwordHash             `json:"password_hash,omitempty"`         // verifier; the password itself is never stored
+	CertificateThreshold int                       `json:"certificate_threshold,omitempty"` // percentage, 0 uses the default
+	SigningKey           *sealedData               `json:"signing_key,omitempty"`           // Ed25519 key sealed with the password
+	SigningPublicKey     string                    `json:"signing_public_key,omitempty"`
+	DeviceKey            string                    `json:"device_key,omitempty"`        // plaintext in older versions, replaced by SealedDeviceKey
+	SealedDeviceKey      *sealedData               `json:"sealed_device_key,omitempty"` // Ed25519 seed signing this device's sync bundles
+	DevicePublicKey      string                    `json:"device_public_key,omitempty"`
+	IntegrityKey         string                    `json:"integrity_key,omitempty"`        // plaintext in older versions, replaced by SealedIntegrityKey
+	SealedIntegrityKey   *sealedData               `json:"sealed_integrity_key,omitempty"` // HMAC key for score records, sealed with the password
+	IntegrityStates      map[string]IntegrityState `json:"integrity_states,omitempty"`     // by user ID
+	IntegrityStatesMAC   string                    `json:"integrity_states_mac,omitempty"`
+	TrustedPackKeys      []string                  `json:"trusted_pack_keys,omitempty"` // Ed25519 keys accepted for packs
+	DeviceID             string                    `json:"device_id,omitempty"`
+	DeviceName           string                    `json:"device_name,omitempty"`
+	SyncDir              string                    `json:"sync_dir,omitempty"`        // shared folder reconciled on startup
+	SyncPeers            []SyncPeer                `json:"sync_peers,omitempty"`      // devices whose bundles are accepted
+	BankDir              string                    `json:"bank_dir,omitempty"`        // question directory edits are written back to
+	BankDirFormat        string                    `json:"bank_dir_format,omitempty"` // "json" or "yaml" for new question files
+	Author               string                    `json:"author,omitempty"`          // default author of new questions
 }
 
 var (
+	// adminPassword is the admin password once typed this session
+	adminPassword string
+
 	currentUser   *User
 	quizData      QuizData
 	adminConfig   AdminConfig
@@ -72,17 +200,75 @@ var (
 	usersFile     string
 	questionsFile string
 	adminFile     string
+	groupsFile    string
+
+	certificatesFile string
+	packsFile        string
 )
 
 func main() {
+	plain := flag.Bool("plain", false, "use the line-based interface instead of the full-screen one")
+	portable := flag.Bool("portable", false, "keep all data next to the program (also enabled by a "+portableMarker+" file there)")
+	dataDir := flag.String("data-dir", "", "directory for users, questions and settings")
+	theme := flag.String("theme", "", "colour theme: "+strings.Join(themeNames(), ", "))
+	noAnimations := flag.Bool("no-animations", false, "do not pause after messages")
+	ascii := flag.Bool("ascii", false, "plain ASCII output without emoji or box drawing")
+	screenReaderFlag := flag.Bool("screen-reader", false, "linear output for screen readers: no screen clearing, plain sentences")
+	var overrides configFlags
+	flag.Var(&overrides, "set", "override a setting, as key=value (repeatable; see 'config show')")
+	flag.Usage = usage
+	flag.Parse()
+
 	reader = bufio.NewReader(os.Stdin)
 
+	// Settings given by their own flags take precedence over -set
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "data-dir":
+			overrides = append(overrides, configOverride{"data_dir", *dataDir, "flag -data-dir"})
+		case "theme":
+			overrides = append(overrides, configOverride{"theme", *theme, "flag -theme"})
+		case "no-animations":
+			overrides = append(overrides, configOverride{"animations", strconv.FormatBool(!*noAnimations), "flag -no-animations"})
+		case "ascii":
+			overrides = append(overrides, configOverride{"ascii", strconv.FormatBool(*ascii), "flag -ascii"})
+		case "screen-reader":
+			overrides = append(overrides, configOverride{"screen_reader", strconv.FormatBool(*screenReaderFlag), "flag -screen-reader"})
+		}
+	})
+
 	// Setup cache directory
-	setupCacheDirectory()
+	dir, isPortable, notice := defaultDataDir(*portable)
+	if err := loadConfig(dir, overrides); err != nil {
+		fmt.Fprintf(os.Stderr, "✗ Invalid configuration: %v\n", err)
+		os.Exit(2)
+	}
+	setupCacheDirectory(dir, isPortable, notice)
 
 	// Load or create data files
 	loadData()
 
+	// Subcommands run without the interactive interface
+	if flag.NArg() > 0 {
+		os.Exit(runCommand(flag.Args()))
+	}
+
+	// The full-screen interface needs cursor control, box drawing and a
+	// visual layout
+	initTerminal(*plain || asciiOnly || screenReader || dumbTerminal)
+	if portableMode {
+		printColor(ColorCyan, fmt.Sprintf("📁 Portable mode, data stored in: %s\n", cacheDir))
+	} else {
+		printColor(ColorCyan, fmt.Sprintf("📁 Data stored in: %s\n", cacheDir))
+	}
+	if dataDirNotice != "" {
+		printColor(ColorYellow+ColorBold, dataDirNotice+"\n")
+		pause(2 * time.Second)
+	}
+	unlockRecords()
+	syncOnStartup()
+	pause(1 * time.Second)
+
 	// User login/registration
 	userLogin()
 
@@ -92,14 +278,23 @@ func main() {
 	}
 }
 
-func setupCacheDirectory() {
-	// Get user cache directory
-	homeDir, err := os.UserCacheDir()
-	if err != nil {
-		homeDir, _ = os.UserHomeDir()
-		cacheDir = filepath.Join(homeDir, ".cyber-quiz")
-	} else {
-		cacheDir = filepath.Join(homeDir, "cyber-quiz")
+// defaultDataDir is the portable directory next to the binary when asked
+// for and writable, otherwise the user cache directory
+func defaultDataDir(portable bool) (dir string, isPortable bool, notice string) {
+	dir = hostDataDir()
+	if p := portableDir(portable); p != "" {
+		if dirWritable(p) {
+			return p, true, ""
+		}
+		notice = fmt.Sprintf("⚠ %s is read-only; using %s instead, so progress stays on this computer", p, dir)
+	}
+	return dir, false, notice
+}
+
+func setupCacheDirectory(dir string, isPortable bool, notice string) {
+	cacheDir, portableMode, dataDirNotice = dir, isPortable, notice
+	if config.DataDir != "" {
+		cacheDir, portableMode, dataDirNotice = config.DataDir, false, ""
 	}
 
 	// Create cache directory if it doesn't exist
@@ -109,9 +304,9 @@ func setupCacheDirectory() {
 	usersFile = filepath.Join(cacheDir, "users.json")
 	questionsFile = filepath.Join(cacheDir, "questions.json")
 	adminFile = filepath.Join(cacheDir, "admin.json")
-
-	printColor(ColorCyan, fmt.Sprintf("📁 Data stored in: %s\n", cacheDir))
-	time.Sleep(1 * time.Second)
+	groupsFile = filepath.Join(cacheDir, "groups.json")
+	certificatesFile = filepath.Join(cacheDir, "certificates.json")
+	packsFile = filepath.Join(cacheDir, "packs.json")
 }
 
 func loadData() {
@@ -119,195 +314,96 @@ func loadData() {
 	if _, err := os.Stat(adminFile); err == nil {
 		data, _ := os.ReadFile(adminFile)
 		json.Unmarshal(data, &adminConfig)
-	} else {
-		// Create default admin config
-		adminConfig.Password = DefaultAdminPassword
+	}
+	if adminConfig.Password != "" {
+		// Older versions kept the password itself
+		adminConfig.PasswordHash = newPasswordHash(adminConfig.Password)
+		adminConfig.Password = ""
 		saveAdminConfig()
 	}
+	if password := os.Getenv(adminPasswordEnv); password != "" {
+		checkAdminPassword(password)
+	}
+	ensureIntegrityKey()
+	ensureDeviceID()
 
-	// Load questions
-	if _, err := os.Stat(questionsFile); err == nil {
-		data, _ := os.ReadFile(questionsFile)
-		json.Unmarshal(data, &quizData)
-	} else {
-		// Create default questions
-		createDefaultQuestions()
-		saveQuestions()
+	// Load questions from the built-in, system, user and project banks
+	if err := loadQuestionBanks(); err != nil {
+		printColor(ColorRed, fmt.Sprintf("✗ Cannot open the question bank: %v\n", err))
+		os.Exit(1)
 	}
+
+	loadCatalogues()
+	selectLanguage(nil)
+
+	// Load groups and assignments
+	loadClassData()
+	loadPacks()
+	setBankDirBaseline()
+	prepareRecords()
 }
 
-func createDefaultQuestions() {
-	quizData.Questions = []Question{
-		// CompTIA PenTest+ Questions
-		{
-			ID:       "pt1",
-			Question: "What is the primary purpose of a penetration test?",
-			Options: []string{
-				"To fix all vulnerabilities",
-				"To identify and exploit vulnerabilities in a controlled manner",
-				"To install security software",
-				"To train employees",
-			},
-			Answer:   1,
-			Category: "CompTIA",
-			Module:   "PenTest+",
-		},
-		{
-			ID:       "pt2",
-			Question: "Which phase comes first in a penetration testing methodology?",
-			Options: []string{
-				"Exploitation",
-				"Reporting",
-				"Planning and Reconnaissance",
-				"Post-Exploitation",
-			},
-			Answer:   2,
-			Category: "CompTIA",
-			Module:   "PenTest+",
-		},
-		{
-			ID:       "pt3",
-			Question: "What tool is commonly used for network scanning?",
-			Options: []string{
-				"Wireshark",
-				"Nmap",
-				"Metasploit",
-				"John the Ripper",
-			},
-			Answer:   1,
-			Category: "CompTIA",
-			Module:   "PenTest+",
-		},
-		{
-			ID:       "pt4",
-			Question: "What does OSINT stand for?",
-			Options: []string{
-				"Operating System Intelligence",
-				"Open Source Intelligence",
-				"Online Security Internet",
-				"Organized Security Interface",
-			},
-			Answer:   1,
-			Category: "CompTIA",
-			Module:   "PenTest+",
-		},
-		{
-			ID:       "pt5",
-			Question: "Which of the following is a social engineering attack?",
-			Options: []string{
-				"SQL Injection",
-				"Buffer Overflow",
-				"Phishing",
-				"XSS Attack",
-			},
-			Answer:   2,
-			Category: "CompTIA",
-			Module:   "PenTest+",
-		},
-
-		// Cisco CCNA Questions
-		{
-			ID:       "ccna1",
-			Question: "What is the default administrative distance of OSPF?",
-			Options: []string{
-				"90",
-				"100",
-				"110",
-				"120",
-			},
-			Answer:   2,
-			Category: "Cisco",
-			Module:   "CCNA",
-		},
-		{
-			ID:       "ccna2",
-			Question: "Which layer of the OSI model does a switch operate at?",
-			Options: []string{
-				"Layer 1 - Physical",
-				"Layer 2 - Data Link",
-				"Layer 3 - Network",
-				"Layer 4 - Transport",
-			},
-			Answer:   1,
-			Category: "Cisco",
-			Module:   "CCNA",
-		},
-		{
-			ID:       "ccna3",
-			Question: "What is the maximum number of usable hosts in a /26 subnet?",
-			Options: []string{
-				"30",
-				"62",
-				"126",
-				"254",
-			},
-			Answer:   1,
-			Category: "Cisco",
-			Module:   "CCNA",
-		},
-		{
-			ID:       "ccna4",
-			Question: "Which protocol is used by ping?",
-			Options: []string{
-				"TCP",
-				"UDP",
-				"ICMP",
-				"ARP",
-			},
-			Answer:   2,
-			Category: "Cisco",
-			Module:   "CCNA",
-		},
-		{
-			ID:       "ccna5",
-			Question: "What does STP stand for?",
-			Options: []string{
-				"Simple Transfer Protocol",
-				"Spanning Tree Protocol",
-				"Secure Transmission Protocol",
-				"Switch Transport Protocol",
-			},
-			Answer:   1,
-			Category: "Cisco",
-			Module:   "CCNA",
-		},
+// prepareRecords migrates duplicate IDs and starts signing the records of
+// new users. Both sign records, so they wait while the key is locked.
+func prepareRecords() {
+	if recordsLocked() {
+		return
 	}
+	printIDMigration(migrateDuplicateIDs())
+	trackAllUsers()
 }
 
-func userLogin() {
-	clearScreen()
-	printBoxHeader("Cyber Learning Quiz Application", ColorCyan)
-	fmt.Println()
-
-	printColor(ColorYellow, "Are you a:\n")
-	fmt.Println("1. New User")
-	fmt.Println("2. Returning User")
-	printColor(ColorYellow, "\nEnter choice (1-2): ")
+// unlockRecords asks for the admin password when records are signed and
+// it was not given in the environment. Without it this session is practice
+// only: quiz results are not saved and sync is skipped.
+func unlockRecords() {
+	if !recordsLocked() {
+		return
+	}
+	printColor(ColorYellow, "Quiz records are signed with a key sealed under the admin password.\n")
+	if password := readSecret("Admin password (Enter for practice mode): "); password != "" && checkAdminPassword(password) {
+		prepareRecords()
+		loadProjectBank()
+		return
+	}
+	printColor(ColorYellow+ColorBold, "⚠ Practice mode: quiz results will not be saved this session.\n")
+	pause(2 * time.Second)
+}
 
-	choice := readInput()
+func userLogin() {
+	for {
+		choice := runMenu(menu{
+			title:    tr("Cyber Learning Quiz Application"),
+			color:    ColorCyan,
+			subtitle: tr("Are you a:"),
+			items:    []string{tr("New User"), tr("Returning User")},
+		})
 
-	switch choice {
-	case "1":
-		createNewUser()
-	case "2":
-		loginExistingUser()
-	default:
-		printColor(ColorRed, "Invalid choice. Creating new user...\n")
-		time.Sleep(1 * time.Second)
-		createNewUser()
+		switch choice {
+		case "1":
+			createNewUser()
+		case "2":
+			if !loginExistingUser() {
+				continue
+			}
+		default:
+			printColor(ColorRed, tr("Invalid choice. Creating new user...")+"\n")
+			pause(1 * time.Second)
+			createNewUser()
+		}
+		return
 	}
 }
 
 func createNewUser() {
 	clearScreen()
-	printBoxHeader("New User Registration", ColorGreen)
+	printBoxHeader(tr("New User Registration"), ColorGreen)
 	fmt.Println()
 
-	printColor(ColorYellow, "Enter your name: ")
+	printColor(ColorYellow, tr("Enter your name: "))
 	name := readInput()
 
-	// Generate user-friendly ID
-	userID := generateUserID(name)
+	userID := newID()
 
 	currentUser = &User{
 		ID:        userID,
@@ -317,112 +413,114 @@ func createNewUser() {
 	}
 
 	saveUser()
+	selectLanguage(currentUser)
 
-	printColor(ColorGreen, fmt.Sprintf("\n✓ Welcome, %s! Your User ID is: ", name))
+	printColor(ColorGreen, "\n"+trf("✓ Welcome, %s! Your User ID is: ", name))
 	printColor(ColorBold+ColorCyan, userID+"\n")
-	printColor(ColorYellow, "\nPress Enter to continue...")
+	printColor(ColorYellow, "\n"+tr("Press Enter to continue..."))
 	readInput()
 }
 
-func generateUserID(name string) string {
-	// Convert name to lowercase and remove spaces
-	baseName := strings.ToLower(strings.ReplaceAll(name, " ", ""))
-
-	// Load existing users to find next number
-	users := loadAllUsers()
-	count := 1
-
-	for {
-		userID := fmt.Sprintf("%s%d", baseName, count)
-		exists := false
-
-		for _, u := range users {
-			if u.ID == userID {
-				exists = true
-				break
-			}
-		}
-
-		if !exists {
-			return userID
-		}
-		count++
-	}
-}
-
-func loginExistingUser() {
+// loginExistingUser logs in a returning user. It returns false when the
+// user backed out of the list, so the login menu can be shown again.
+func loginExistingUser() bool {
 	users := loadAllUsers()
 
 	if len(users) == 0 {
-		printColor(ColorRed, "\nNo existing users found. Creating new user...\n")
-		time.Sleep(1 * time.Second)
+		printColor(ColorRed, "\n"+tr("No existing users found. Creating new user...")+"\n")
+		pause(1 * time.Second)
 		createNewUser()
-		return
+		return true
 	}
 
-	clearScreen()
-	printBoxHeader("Returning Users", ColorBlue)
-	fmt.Println()
+	var userIndex int
+	if tuiEnabled {
+		l := listView{title: tr("Returning Users"), color: ColorBlue}
+		for _, user := range users {
+			l.items = append(l.items, listItem{label: user.Name, detail: "(ID: " + user.ID + ")"})
+		}
+		if userIndex, _ = l.run(""); userIndex < 0 {
+			return false
+		}
+	} else {
+		clearScreen()
+		printBoxHeader(tr("Returning Users"), ColorBlue)
+		fmt.Println()
 
-	for i, user := range users {
-		printColor(ColorCyan, fmt.Sprintf("%d. ", i+1))
-		printColor(ColorWhite, fmt.Sprintf("%s ", user.Name))
-		printColor(ColorYellow, fmt.Sprintf("(ID: %s)\n", user.ID))
-	}
+		for i, user := range users {
+			printColor(ColorCyan, fmt.Sprintf("%d. ", i+1))
+			printColor(ColorWhite, fmt.Sprintf("%s ", user.Name))
+			printColor(ColorYellow, fmt.Sprintf("(ID: %s)\n", user.ID))
+		}
 
-	printColor(ColorYellow, "\nEnter user number: ")
-	choice := readInput()
+		printColor(ColorYellow, "\n"+tr("Enter user number: "))
+		choice := readInput()
 
-	var userIndex int
-	fmt.Sscanf(choice, "%d", &userIndex)
-	userIndex--
+		fmt.Sscanf(choice, "%d", &userIndex)
+		userIndex--
+	}
 
 	if userIndex >= 0 && userIndex < len(users) {
 		currentUser = &users[userIndex]
-		printColor(ColorGreen, fmt.Sprintf("\n✓ Welcome back, %s!\n", currentUser.Name))
+		selectLanguage(currentUser)
+		printColor(ColorGreen, "\n"+trf("✓ Welcome back, %s!", currentUser.Name)+"\n")
 	} else {
-		printColor(ColorRed, "Invalid selection. Creating new user...\n")
-		time.Sleep(1 * time.Second)
+		printColor(ColorRed, tr("Invalid selection. Creating new user...")+"\n")
+		pause(1 * time.Second)
 		createNewUser()
-		return
+		return true
 	}
 
-	printColor(ColorYellow, "Press Enter to continue...")
+	printColor(ColorYellow, tr("Press Enter to continue..."))
 	readInput()
+	return true
 }
 
 func showMainMenu() {
-	clearScreen()
-	printBoxHeader(fmt.Sprintf("User: %s (%s)", currentUser.Name, currentUser.ID), ColorCyan)
-	printColor(ColorBold+ColorMagenta, "           MAIN MENU\n")
-	fmt.Println()
-
-	fmt.Println("1. 📝 Take Quiz")
-	fmt.Println("2. 📊 View Scores")
-	fmt.Println("3. 👤 Switch User")
-	fmt.Println("4. 🔧 Admin Panel")
-	fmt.Println("5. ❌ Exit")
-	printColor(ColorYellow, "\nEnter choice (1-5): ")
-
-	choice := readInput()
-
-	switch choice {
-	case "1":
-		selectQuizModule()
-	case "2":
-		viewScores()
-	case "3":
-		userLogin()
-	case "4":
-		adminPanel()
-	case "5":
-		printColor(ColorGreen, "\nThank you for using Cyber Learning Quiz!\n")
-		printColor(ColorCyan, "Your progress has been saved.\n")
-		os.Exit(0)
-	default:
-		printColor(ColorRed, "Invalid choice. Press Enter to continue...")
+	type entry struct {
+		label  string
+		action func()
+	}
+	entries := []entry{
+		{"📝 " + tr("Take Quiz"), selectQuizModule},
+		{"🧩 " + tr("Custom Quiz"), customQuiz},
+	}
+	for _, p := range currentUser.Presets {
+		p := p
+		entries = append(entries, entry{"   ▶ " + p.Name, func() { runPreset(p) }})
+	}
+	entries = append(entries,
+		entry{"🔁 " + trf("Missed Questions (%d)", len(currentUser.Missed)), practiseMissed},
+		entry{"📅 " + tr("My Assignments"), myAssignments},
+		entry{"📊 " + tr("View Scores"), viewScores},
+		entry{"👤 " + tr("Switch User"), userLogin},
+		entry{"🌐 " + tr("Language"), chooseLanguage},
+		entry{"🔧 " + tr("Admin Panel"), adminPanel},
+		entry{"❌ " + tr("Exit"), func() {
+			shutdownTerminal()
+			printColor(ColorGreen, "\n"+tr("Thank you for using Cyber Learning Quiz!")+"\n")
+			printColor(ColorCyan, tr("Your progress has been saved.")+"\n")
+			os.Exit(0)
+		}},
+	)
+
+	var items []string
+	for _, e := range entries {
+		items = append(items, e.label)
+	}
+	choice, err := strconv.Atoi(runMenu(menu{
+		title:    trf("User: %s (%s)", currentUser.Name, currentUser.ID),
+		color:    ColorCyan,
+		subtitle: "           " + tr("MAIN MENU"),
+		items:    items,
+		back:     len(items),
+	}))
+	if err != nil || choice < 1 || choice > len(entries) {
+		printColor(ColorRed, tr("Invalid choice. Press Enter to continue..."))
 		readInput()
+		return
 	}
+	entries[choice-1].action()
 }
 
 func adminPanel() {
@@ -430,36 +528,46 @@ func adminPanel() {
 	printBoxHeader("Admin Authentication", ColorRed)
 	fmt.Println()
 
-	printColor(ColorYellow, "Enter admin password: ")
-	password := readInput()
-
-	if password != adminConfig.Password {
+	if adminConfig.PasswordHash == nil {
+		if !setAdminPassword() {
+			return
+		}
+	} else if password := readSecret("Enter admin password: "); !checkAdminPassword(password) {
 		printColor(ColorRed, "\n✗ Access Denied! Incorrect password.\n")
-		printColor(ColorYellow, "Press Enter to continue...")
+		printColor(ColorYellow, tr("Press Enter to continue..."))
 		readInput()
 		return
 	}
 
 	printColor(ColorGreen, "\n✓ Access Granted!\n")
-	time.Sleep(1 * time.Second)
+	pause(1 * time.Second)
 
 	for {
-		clearScreen()
-		printBoxHeader("Admin Panel", ColorRed)
-		printColor(ColorBold+ColorYellow, "⚠ Administrator Mode Active ⚠\n")
-		fmt.Println()
-
-		fmt.Println("1. ➕ Add New Question")
-		fmt.Println("2. ➖ Remove Question")
-		fmt.Println("3. 📁 Add New Module")
-		fmt.Println("4. 🗑️  Remove Module")
-		fmt.Println("5. 👥 Manage Users")
-		fmt.Println("6. 📋 List All Questions")
-		fmt.Println("7. 🔑 Change Admin Password")
-		fmt.Println("8. ⬅️  Back to Main Menu")
-		printColor(ColorYellow, "\nEnter choice (1-8): ")
-
-		choice := readInput()
+		choice := runMenu(menu{
+			title:    "Admin Panel",
+			color:    ColorRed,
+			subtitle: "⚠ Administrator Mode Active ⚠",
+			items: []string{
+				"➕ Add New Question",
+				"➖ Remove Question",
+				"✏️  Edit Question",
+				"📁 Add New Module",
+				"🗑️  Remove Module",
+				"📡 Import Scan Results",
+				"👥 Manage Users",
+				"🏫 Groups & Assignments",
+				"📄 Progress Reports",
+				"📋 List All Questions",
+				"🔍 Search Questions",
+				"📑 Duplicate Questions",
+				"📈 Question Analytics",
+				"🎓 Certificates",
+				"🛡️  Integrity Check",
+				"🔑 Change Admin Password",
+				"⬅️  Back to Main Menu",
+			},
+			back: 17,
+		})
 
 		switch choice {
 		case "1":
@@ -467,16 +575,34 @@ func adminPanel() {
 		case "2":
 			removeQuestion()
 		case "3":
-			addNewModule()
+			editQuestion()
 		case "4":
-			removeModule()
+			addNewModule()
 		case "5":
-			manageUsers()
+			removeModule()
 		case "6":
-			listAllQuestions()
+			importScanResults()
 		case "7":
-			changeAdminPassword()
+			manageUsers()
 		case "8":
+			manageGroups()
+		case "9":
+			progressReports()
+		case "10":
+			listAllQuestions()
+		case "11":
+			searchQuestions()
+		case "12":
+			duplicateQuestions()
+		case "13":
+			questionAnalytics()
+		case "14":
+			manageCertificates()
+		case "15":
+			integrityCheck()
+		case "16":
+			changeAdminPassword()
+		case "17":
 			return
 		default:
 			printColor(ColorRed, "Invalid choice. Press Enter to continue...")
@@ -486,15 +612,34 @@ func adminPanel() {
 }
 
 func selectQuizModule() {
+	modules := getAvailableModules()
+
+	if tuiEnabled && len(modules) > 0 {
+		var moduleList []struct{ category, module string }
+		l := listView{title: tr("Select Quiz Module"), color: ColorBlue}
+		for category, mods := range modules {
+			for _, mod := range mods {
+				l.items = append(l.items, listItem{
+					label:  mod,
+					detail: trf("(%d questions)", countQuestions(category, mod)) + " · " + moduleSource(category, mod),
+					group:  category,
+				})
+				moduleList = append(moduleList, struct{ category, module string }{category, mod})
+			}
+		}
+		if idx, _ := l.run(""); idx >= 0 {
+			takeQuiz(moduleList[idx].category, moduleList[idx].module)
+		}
+		return
+	}
+
 	clearScreen()
-	printBoxHeader("Select Quiz Module", ColorBlue)
+	printBoxHeader(tr("Select Quiz Module"), ColorBlue)
 	fmt.Println()
 
-	modules := getAvailableModules()
-
 	if len(modules) == 0 {
-		printColor(ColorRed, "No quiz modules available.\n")
-		printColor(ColorYellow, "Press Enter to continue...")
+		printColor(ColorRed, tr("No quiz modules available.")+"\n")
+		printColor(ColorYellow, tr("Press Enter to continue..."))
 		readInput()
 		return
 	}
@@ -508,14 +653,15 @@ func selectQuizModule() {
 			questionCount := countQuestions(category, mod)
 			printColor(ColorWhite, fmt.Sprintf("  %d. ", idx))
 			printColor(ColorGreen, fmt.Sprintf("%s ", mod))
-			printColor(ColorYellow, fmt.Sprintf("(%d questions)\n", questionCount))
+			printColor(ColorYellow, trf("(%d questions)", questionCount)+" ")
+			printColor(ColorCyan, moduleSource(category, mod)+"\n")
 			moduleList[idx] = struct{ category, module string }{category, mod}
 			idx++
 		}
 	}
 
-	printColor(ColorRed, fmt.Sprintf("\n%d. Back to Main Menu\n", idx))
-	printColor(ColorYellow, "\nEnter choice: ")
+	printColor(ColorRed, fmt.Sprintf("\n%d. %s\n", idx, tr("Back to Main Menu")))
+	printColor(ColorYellow, "\n"+tr("Enter choice: "))
 
 	var choice int
 	fmt.Sscanf(readInput(), "%d", &choice)
@@ -527,113 +673,136 @@ func selectQuizModule() {
 	if mod, exists := moduleList[choice]; exists {
 		takeQuiz(mod.category, mod.module)
 	} else {
-		printColor(ColorRed, "Invalid choice.\n")
-		printColor(ColorYellow, "Press Enter to continue...")
+		printColor(ColorRed, tr("Invalid choice.")+"\n")
+		printColor(ColorYellow, tr("Press Enter to continue..."))
 		readInput()
 	}
 }
 
 func takeQuiz(category, module string) {
-	questions := getQuestionsByModule(category, module)
+	runQuiz(category, module, prepareQuestions(getQuestionsByModule(category, module)), nil)
+}
 
+// runQuiz asks the given questions and records the attempt, optionally
+// against an assignment. An assignment attempt is recorded under the
+// assignment's module, or none for a custom set, and category and module
+// only title the quiz.
+func runQuiz(category, module string, questions []Question, assignment *Assignment) {
 	if len(questions) == 0 {
-		printColor(ColorRed, "No questions available for this module.\n")
-		printColor(ColorYellow, "Press Enter to continue...")
+		printColor(ColorRed, tr("No questions available for this module.")+"\n")
+		printColor(ColorYellow, tr("Press Enter to continue..."))
 		readInput()
 		return
 	}
 
-	correct := 0
-	total := len(questions)
-
-	for i, q := range questions {
-		clearScreen()
-		printColor(ColorCyan+ColorBold, fmt.Sprintf("╔════════════════════════════════════════╗\n"))
-		printColor(ColorCyan, fmt.Sprintf("║ %s - %s\n", category, module))
-		printColor(ColorYellow, fmt.Sprintf("║ Question %d of %d\n", i+1, total))
-		printColor(ColorCyan+ColorBold, fmt.Sprintf("╚════════════════════════════════════════╝\n\n"))
-
-		printColor(ColorWhite+ColorBold, q.Question+"\n\n")
-
-		for j, opt := range q.Options {
-			printColor(ColorCyan, fmt.Sprintf("%d. ", j+1))
-			fmt.Println(opt)
-		}
-
-		printColor(ColorYellow, "\nYour answer (1-4): ")
-		var answer int
-		fmt.Sscanf(readInput(), "%d", &answer)
-		answer--
-
-		if answer == q.Answer {
-			printColor(ColorGreen+ColorBold, "\n✓ Correct!\n")
-			correct++
-		} else {
-			printColor(ColorRed+ColorBold, "\n✗ Incorrect. ")
-			printColor(ColorGreen, fmt.Sprintf("The correct answer was: %s\n", q.Options[q.Answer]))
-		}
-
-		printColor(ColorYellow, "\nPress Enter to continue...")
-		readInput()
+	attempt := Attempt{
+		ID:        newID(),
+		Origin:    adminConfig.DeviceID,
+		Category:  category,
+		Module:    module,
+		StartedAt: time.Now(),
+	}
+	if assignment != nil {
+		attempt.AssignmentID = assignment.ID
+		attempt.Category, attempt.Module = assignment.Category, assignment.Module
 	}
 
+	heading := fmt.Sprintf("%s - %s", category, module)
+	attempt.Answers = askQuestions(heading, questions)
+	correct, total := attempt.Correct(), len(questions)
+	points := attempt.Points()
+
 	// Save score
-	saveScore(category, module, correct, total)
+	attempt.FinishedAt = time.Now()
+	saveScore(attempt)
 
 	// Show results
 	clearScreen()
-	printBoxHeader("Quiz Completed", ColorGreen)
+	printBoxHeader(tr("Quiz Completed"), ColorGreen)
 	fmt.Println()
 
-	percentage := float64(correct) / float64(total) * 100
-
-	printColor(ColorCyan, fmt.Sprintf("Module: %s - %s\n", category, module))
-	printColor(ColorWhite, fmt.Sprintf("Score: %d/%d ", correct, total))
+	percentage := attempt.Percent()
 
-	if percentage >= 80 {
-		printColor(ColorGreen+ColorBold, fmt.Sprintf("(%.1f%%) 🎉\n", percentage))
-	} else if percentage >= 60 {
-		printColor(ColorYellow+ColorBold, fmt.Sprintf("(%.1f%%) 👍\n", percentage))
+	grades := gradesFor(category, module)
+	if screenReader {
+		result := tr("below the pass mark")
+		if percentage >= float64(grades.Merit) {
+			result = tr("a merit")
+		} else if percentage >= float64(grades.Pass) {
+			result = tr("a pass")
+		}
+		fmt.Println(trf("Module %s - %s finished. You answered %d of %d questions correctly, %.0f percent, which is %s.",
+			category, module, correct, total, percentage, result))
 	} else {
-		printColor(ColorRed+ColorBold, fmt.Sprintf("(%.1f%%) 📚\n", percentage))
+		printColor(ColorCyan, trf("Module: %s - %s", category, module)+"\n")
+		printColor(ColorWhite, trf("Score: %d/%d", correct, total)+" ")
+
+		if percentage >= float64(grades.Merit) {
+			printColor(ColorGreen+ColorBold, fmt.Sprintf("(%.1f%%) 🎉\n", percentage))
+		} else if percentage >= float64(grades.Pass) {
+			printColor(ColorYellow+ColorBold, fmt.Sprintf("(%.1f%%) 👍\n", percentage))
+		} else {
+			printColor(ColorRed+ColorBold, fmt.Sprintf("(%.1f%%) 📚\n", percentage))
+		}
+	}
+	if points != float64(correct) {
+		printColor(ColorCyan, trf("With partial credit for command steps and labs: %.2f of %d points.", points, total)+"\n")
 	}
 
-	printColor(ColorYellow, "\nPress Enter to continue...")
-	readInput()
+	if cert := requestCertificate(attempt); cert != nil {
+		printColor(ColorMagenta+ColorBold, "\n🎓 "+trf("You qualify for a %s - %s certificate!", category, module)+"\n")
+		printColor(ColorCyan, tr("Your instructor can issue it from the admin panel.")+"\n")
+	}
+
+	if assignment != nil {
+		if percentage >= float64(assignment.PassMark) {
+			printColor(ColorGreen+ColorBold, "\n"+trf("✓ Assignment passed (pass mark %d%%)", assignment.PassMark)+"\n")
+		} else {
+			printColor(ColorRed+ColorBold, "\n"+trf("✗ Below the pass mark of %d%%", assignment.PassMark)+"\n")
+		}
+	}
+
+	offerReview(heading, questions, attempt.Answers)
 }
 
 func viewScores() {
 	clearScreen()
-	printBoxHeader("Your Scores", ColorMagenta)
+	printBoxHeader(tr("Your Scores"), ColorMagenta)
 	fmt.Println()
 
+	if len(currentUser.integrityIssues) > 0 {
+		printColor(ColorRed+ColorBold, tr("⚠ Some of your records failed the integrity check and were modified outside the quiz.")+"\n\n")
+	}
+
 	if len(currentUser.Scores) == 0 {
-		printColor(ColorYellow, "No scores recorded yet. Take a quiz to get started!\n")
+		printColor(ColorYellow, tr("No scores recorded yet. Take a quiz to get started!")+"\n")
 	} else {
 		for category, modules := range currentUser.Scores {
 			printColor(ColorCyan+ColorBold, fmt.Sprintf("\n%s:\n", category))
 			for module, score := range modules {
-				percentage := float64(score.Correct) / float64(score.Total) * 100
+				percentage := score.Percent()
 
 				printColor(ColorWhite, fmt.Sprintf("  %s: ", module))
 				printColor(ColorYellow, fmt.Sprintf("%d/%d ", score.Correct, score.Total))
 
-				if percentage >= 80 {
-					printColor(ColorGreen, fmt.Sprintf("(%.1f%%) ", percentage))
-				} else if percentage >= 60 {
-					printColor(ColorYellow, fmt.Sprintf("(%.1f%%) ", percentage))
-				} else {
-					printColor(ColorRed, fmt.Sprintf("(%.1f%%) ", percentage))
-				}
+				printColor(gradeColor(percentage, gradesFor(category, module)), fmt.Sprintf("(%.1f%%) ", percentage))
+
+				printColor(ColorCyan, "- "+trf("Last taken: %s", score.LastTaken.Format("2006-01-02 15:04")))
 
-				printColor(ColorCyan, fmt.Sprintf("- Last taken: %s\n",
-					score.LastTaken.Format("2006-01-02 15:04")))
+				if score.tampered {
+					printColor(ColorRed+ColorBold, " ⚠ TAMPERED")
+				} else if score.MAC == "" {
+					printColor(ColorYellow, " (unverified)")
+				}
+				fmt.Println()
 			}
 		}
 	}
 
-	printColor(ColorYellow, "\nPress Enter to continue...")
-	readInput()
+	printColor(ColorYellow, "\n"+tr("Type 'e' to export a progress report, or press Enter to continue..."))
+	if strings.ToLower(readInput()) == "e" {
+		exportReportPrompt(userProgressReport(*currentUser))
+	}
 }
 
 func addNewQuestion() {
@@ -650,81 +819,310 @@ func addNewQuestion() {
 	printColor(ColorYellow, "\nEnter Question: ")
 	question := readInput()
 
-	options := make([]string, 4)
-	for i := 0; i < 4; i++ {
-		printColor(ColorCyan, fmt.Sprintf("Enter Option %d: ", i+1))
-		options[i] = readInput()
-	}
-
-	printColor(ColorYellow, "\nEnter correct answer number (1-4): ")
+	var options []string
 	var answer int
-	fmt.Sscanf(readInput(), "%d", &answer)
-	answer--
+	commands := Question{ID: "new"}
+	printColor(ColorYellow, "Is this a command-line question, answered by typing commands? (y/N): ")
+	if strings.ToLower(readInput()) == "y" {
+		if !promptSteps(&commands) {
+			printColor(ColorRed, "A command-line question needs at least one step.\n")
+			printColor(ColorYellow, tr("Press Enter to continue..."))
+			readInput()
+			return
+		}
+	} else {
+		options = make([]string, config.OptionCount)
+		for i := range options {
+			printColor(ColorCyan, fmt.Sprintf("Enter Option %d: ", i+1))
+			options[i] = readInput()
+		}
+
+		printColor(ColorYellow, fmt.Sprintf("\nEnter correct answer number (1-%d): ", len(options)))
+		fmt.Sscanf(readInput(), "%d", &answer)
+		answer--
 
-	if answer < 0 || answer > 3 {
-		printColor(ColorRed, "Invalid answer number.\n")
-		printColor(ColorYellow, "Press Enter to continue...")
+		if answer < 0 || answer >= len(options) {
+			printColor(ColorRed, "Invalid answer number.\n")
+			printColor(ColorYellow, tr("Press Enter to continue..."))
+			readInput()
+			return
+		}
+	}
+
+	if !warnDuplicates(Question{Question: question, Options: options, Answer: answer}) {
+		printColor(ColorYellow, "Question not added.\n")
+		printColor(ColorYellow, tr("Press Enter to continue..."))
 		readInput()
 		return
 	}
 
+	printColor(ColorCyan, "\nOptional details, press Enter to skip:\n")
+	printColor(ColorYellow, "Tags, comma separated: ")
+	tags := splitList(readInput())
+	difficulty := promptDifficulty("")
+	author := promptWithDefault("Author", adminConfig.Author)
+	printColor(ColorYellow, "Source (book, course or URL): ")
+	source := readInput()
+	media := Question{Question: question}
+	promptMedia(&media)
+
+	now := time.Now().UTC()
 	newQuestion := Question{
-		ID:       fmt.Sprintf("q%d", time.Now().Unix()),
-		Question: question,
-		Options:  options,
-		Answer:   answer,
-		Category: category,
-		Module:   module,
+		ID:         newID(),
+		Question:   media.Question,
+		Exhibit:    media.Exhibit,
+		Images:     media.Images,
+		Options:    options,
+		Answer:     answer,
+		Shell:      commands.Shell,
+		Steps:      commands.Steps,
+		Category:   category,
+		Module:     module,
+		Tags:       tags,
+		Difficulty: difficulty,
+		Author:     author,
+		Source:     source,
+		CreatedAt:  &now,
+	}
+	if author != adminConfig.Author {
+		adminConfig.Author = author
+		saveAdminConfig()
 	}
 
 	quizData.Questions = append(quizData.Questions, newQuestion)
 	saveQuestions()
 
 	printColor(ColorGreen+ColorBold, "\n✓ Question added successfully!\n")
-	printColor(ColorYellow, "Press Enter to continue...")
+	printColor(ColorYellow, tr("Press Enter to continue..."))
 	readInput()
 }
 
 func removeQuestion() {
-	clearScreen()
-	printBoxHeader("Remove Question", ColorRed)
-	fmt.Println()
+	choice := pickQuestion("Remove Question", ColorRed, "remove")
+	if choice == 0 {
+		return
+	}
 
-	if len(quizData.Questions) == 0 {
-		printColor(ColorRed, "No questions available to remove.\n")
-		printColor(ColorYellow, "Press Enter to continue...")
+	if choice < 1 || choice > len(quizData.Questions) {
+		printColor(ColorRed, "Invalid choice.\n")
+		printColor(ColorYellow, tr("Press Enter to continue..."))
 		readInput()
 		return
 	}
 
+	// Remove question
+	quizData.Questions = append(quizData.Questions[:choice-1], quizData.Questions[choice:]...)
+	saveQuestions()
+
+	printColor(ColorGreen+ColorBold, "\n✓ Question removed successfully!\n")
+	printColor(ColorYellow, tr("Press Enter to continue..."))
+	readInput()
+}
+
+// pickQuestion lets the admin choose a question and returns its 1-based
+// position in quizData, or 0 if cancelled
+func pickQuestion(title, color, action string) int {
+	if len(quizData.Questions) == 0 {
+		clearScreen()
+		printBoxHeader(title, color)
+		fmt.Println()
+		printColor(ColorRed, fmt.Sprintf("No questions available to %s.\n", action))
+		printColor(ColorYellow, tr("Press Enter to continue..."))
+		readInput()
+		return 0
+	}
+
+	if tuiEnabled {
+		l := listView{title: title, color: color}
+		for _, q := range quizData.Questions {
+			l.items = append(l.items, listItem{label: stemSummary(q.Question), group: q.Category + " - " + q.Module})
+		}
+		idx, _ := l.run("")
+		return idx + 1
+	}
+
+	clearScreen()
+	printBoxHeader(title, color)
+	fmt.Println()
+
 	for i, q := range quizData.Questions {
 		printColor(ColorCyan, fmt.Sprintf("%d. ", i+1))
 		printColor(ColorYellow, fmt.Sprintf("[%s - %s] ", q.Category, q.Module))
-		printColor(ColorWhite, fmt.Sprintf("%s\n", q.Question))
+		printColor(ColorWhite, fmt.Sprintf("%s\n", stemSummary(q.Question)))
 	}
 
-	printColor(ColorYellow, fmt.Sprintf("\nEnter question number to remove (1-%d) or 0 to cancel: ", len(quizData.Questions)))
+	printColor(ColorYellow, fmt.Sprintf("\nEnter question number to %s (1-%d) or 0 to cancel: ", action, len(quizData.Questions)))
 	var choice int
 	fmt.Sscanf(readInput(), "%d", &choice)
+	return choice
+}
 
+func editQuestion() {
+	choice := pickQuestion("Edit Question", ColorYellow, "edit")
 	if choice == 0 {
 		return
 	}
 
 	if choice < 1 || choice > len(quizData.Questions) {
 		printColor(ColorRed, "Invalid choice.\n")
-		printColor(ColorYellow, "Press Enter to continue...")
+		printColor(ColorYellow, tr("Press Enter to continue..."))
 		readInput()
 		return
 	}
 
-	// Remove question
-	quizData.Questions = append(quizData.Questions[:choice-1], quizData.Questions[choice:]...)
+	editQuestionAt(choice - 1)
+}
+
+// editQuestionAt edits a question in place, using a form in full-screen
+// mode or field-by-field prompts otherwise
+func editQuestionAt(index int) {
+	q := quizData.Questions[index]
+	q.Options = append([]string(nil), q.Options...)
+
+	if tuiEnabled {
+		f := form{
+			title: "Edit Question " + q.ID,
+			color: ColorYellow,
+			fields: []formField{
+				{label: "Category", value: q.Category},
+				{label: "Module", value: q.Module},
+				{label: "Question", value: escapeLines(q.Question)},
+			},
+		}
+		for i, opt := range q.Options {
+			f.fields = append(f.fields, formField{label: fmt.Sprintf("Option %d", i+1), value: opt})
+		}
+		answerField := len(f.fields)
+		f.fields = append(f.fields,
+			formField{label: "Correct answer", value: fmt.Sprintf("%d", q.Answer+1)},
+			formField{label: "Tags", value: strings.Join(q.Tags, ", ")},
+			formField{label: "Difficulty", value: q.Difficulty},
+			formField{label: "Author", value: q.Author},
+			formField{label: "Source", value: q.Source},
+			formField{label: "Exhibit", value: escapeLines(q.Exhibit)},
+			formField{label: "Images", value: formatImages(q.Images)},
+		)
+
+		for {
+			if !f.run() {
+				return
+			}
+
+			var answer int
+			fmt.Sscanf(f.fields[answerField].value, "%d", &answer)
+			if len(q.Options) == 0 {
+				answer = q.Answer + 1
+			} else if answer < 1 || answer > len(q.Options) {
+				f.message = fmt.Sprintf("Correct answer must be between 1 and %d.", len(q.Options))
+				continue
+			}
+			difficulty := strings.ToLower(strings.TrimSpace(f.fields[answerField+2].value))
+			if difficulty != "" && !anyOf([]string{difficulty}, difficulties) {
+				f.message = "Difficulty must be one of " + strings.Join(difficulties, ", ") + "."
+				continue
+			}
+			images := parseImages(f.fields[answerField+6].value)
+			if problems := imageProblems(Question{ID: q.ID, Images: images}, ""); len(problems) > 0 {
+				f.message = problems[0]
+				continue
+			}
+
+			q.Category = f.fields[0].value
+			q.Module = f.fields[1].value
+			q.Question = unescapeLines(f.fields[2].value)
+			for i := range q.Options {
+				q.Options[i] = f.fields[3+i].value
+			}
+			q.Answer = answer - 1
+			q.Tags = splitList(f.fields[answerField+1].value)
+			q.Difficulty = difficulty
+			q.Author = strings.TrimSpace(f.fields[answerField+3].value)
+			q.Source = strings.TrimSpace(f.fields[answerField+4].value)
+			q.Exhibit = unescapeLines(f.fields[answerField+5].value)
+			q.Images = images
+			break
+		}
+	} else {
+		clearScreen()
+		printBoxHeader("Edit Question "+q.ID, ColorYellow)
+		fmt.Println()
+		printColor(ColorCyan, "Press Enter to keep the current value.\n\n")
+
+		q.Category = promptWithDefault("Category", q.Category)
+		q.Module = promptWithDefault("Module", q.Module)
+		q.Question = unescapeLines(promptWithDefault("Question", escapeLines(q.Question)))
+		for i := range q.Options {
+			q.Options[i] = promptWithDefault(fmt.Sprintf("Option %d", i+1), q.Options[i])
+		}
+
+		if len(q.Options) == 0 {
+			printColor(ColorCyan, "Command steps and labs are edited in the question file or bank directory.\n")
+		} else {
+			var answer int
+			fmt.Sscanf(promptWithDefault("Correct answer number", fmt.Sprintf("%d", q.Answer+1)), "%d", &answer)
+			if answer < 1 || answer > len(q.Options) {
+				printColor(ColorRed, "Invalid answer number.\n")
+				printColor(ColorYellow, tr("Press Enter to continue..."))
+				readInput()
+				return
+			}
+			q.Answer = answer - 1
+		}
+
+		printColor(ColorCyan, "\nType - to clear an optional detail.\n")
+		q.Tags = splitList(clearable(promptWithDefault("Tags", strings.Join(q.Tags, ", "))))
+		q.Difficulty = promptDifficulty(q.Difficulty)
+		q.Author = clearable(promptWithDefault("Author", q.Author))
+		q.Source = clearable(promptWithDefault("Source", q.Source))
+		q.Exhibit = unescapeLines(clearable(promptWithDefault("Exhibit", escapeLines(q.Exhibit))))
+		q.Images = parseImages(clearable(promptWithDefault("Images (file: alt; ...)", formatImages(q.Images))))
+		if problems := imageProblems(q, ""); len(problems) > 0 {
+			printColor(ColorRed, problems[0]+"\n")
+			printColor(ColorYellow, tr("Press Enter to continue..."))
+			readInput()
+			return
+		}
+	}
+
+	now := time.Now().UTC()
+	q.UpdatedAt = &now
+	quizData.Questions[index] = q
+	acceptLocalEdit(q.ID)
 	saveQuestions()
 
-	printColor(ColorGreen+ColorBold, "\n✓ Question removed successfully!\n")
-	printColor(ColorYellow, "Press Enter to continue...")
-	readInput()
+	if !tuiEnabled {
+		printColor(ColorGreen+ColorBold, "\n✓ Question updated successfully!\n")
+		printColor(ColorYellow, tr("Press Enter to continue..."))
+		readInput()
+	}
+}
+
+// promptWithDefault asks for a value, keeping the current one on empty input
+func promptWithDefault(label, current string) string {
+	printColor(ColorYellow, fmt.Sprintf("%s [%s]: ", label, current))
+	if value := readInput(); value != "" {
+		return value
+	}
+	return current
+}
+
+// clearable treats "-" as clearing an optional value
+func clearable(value string) string {
+	if value == "-" {
+		return ""
+	}
+	return value
+}
+
+// promptDifficulty asks for a difficulty until it is valid or empty
+func promptDifficulty(current string) string {
+	for {
+		value := strings.ToLower(clearable(promptWithDefault("Difficulty ("+strings.Join(difficulties, ", ")+")", current)))
+		if value == "" || anyOf([]string{value}, difficulties) {
+			return value
+		}
+		printColor(ColorRed, "Difficulty must be one of "+strings.Join(difficulties, ", ")+".\n")
+	}
 }
 
 func addNewModule() {
@@ -747,41 +1145,61 @@ func addNewModule() {
 	if strings.ToLower(readInput()) == "y" {
 		addNewQuestion()
 	} else {
-		printColor(ColorYellow, "Press Enter to continue...")
+		printColor(ColorYellow, tr("Press Enter to continue..."))
 		readInput()
 	}
 }
 
 func removeModule() {
-	clearScreen()
-	printBoxHeader("Remove Module", ColorRed)
-	fmt.Println()
-
 	modules := getAvailableModules()
 
-	if len(modules) == 0 {
-		printColor(ColorRed, "No modules available to remove.\n")
-		printColor(ColorYellow, "Press Enter to continue...")
-		readInput()
-		return
-	}
-
 	idx := 1
 	moduleList := make(map[int]struct{ category, module string })
+	var choice int
 
-	for category, mods := range modules {
-		printColor(ColorCyan+ColorBold, fmt.Sprintf("\n%s:\n", category))
-		for _, mod := range mods {
-			questionCount := countQuestions(category, mod)
-			printColor(ColorWhite, fmt.Sprintf("  %d. %s (%d questions)\n", idx, mod, questionCount))
-			moduleList[idx] = struct{ category, module string }{category, mod}
-			idx++
+	if tuiEnabled && len(modules) > 0 {
+		l := listView{title: "Remove Module", color: ColorRed}
+		for category, mods := range modules {
+			for _, mod := range mods {
+				l.items = append(l.items, listItem{
+					label:  mod,
+					detail: trf("(%d questions)", countQuestions(category, mod)) + " · " + moduleSource(category, mod),
+					group:  category,
+				})
+				moduleList[idx] = struct{ category, module string }{category, mod}
+				idx++
+			}
 		}
-	}
+		selected, _ := l.run("")
+		choice = selected + 1
 
-	printColor(ColorYellow, fmt.Sprintf("\nEnter module number to remove (1-%d) or 0 to cancel: ", idx-1))
-	var choice int
-	fmt.Sscanf(readInput(), "%d", &choice)
+		clearScreen()
+		printBoxHeader("Remove Module", ColorRed)
+	} else {
+		clearScreen()
+		printBoxHeader("Remove Module", ColorRed)
+		fmt.Println()
+
+		if len(modules) == 0 {
+			printColor(ColorRed, "No modules available to remove.\n")
+			printColor(ColorYellow, tr("Press Enter to continue..."))
+			readInput()
+			return
+		}
+
+		for category, mods := range modules {
+			printColor(ColorCyan+ColorBold, fmt.Sprintf("\n%s:\n", category))
+			for _, mod := range mods {
+				questionCount := countQuestions(category, mod)
+				printColor(ColorWhite, fmt.Sprintf("  %d. %s (%d questions, %s)\n", idx, mod, questionCount, moduleSource(category, mod)))
+				moduleList[idx] = struct{ category, module string }{category, mod}
+				idx++
+			}
+		}
+
+		printColor(ColorYellow, fmt.Sprintf("\nEnter module number to remove (1-%d) or 0 to cancel: ", idx-1))
+		fmt.Sscanf(readInput(), "%d", &choice)
+	}
 
 	if choice == 0 {
 		return
@@ -810,7 +1228,7 @@ func removeModule() {
 		printColor(ColorRed, "Invalid choice.\n")
 	}
 
-	printColor(ColorYellow, "Press Enter to continue...")
+	printColor(ColorYellow, tr("Press Enter to continue..."))
 	readInput()
 }
 
@@ -823,29 +1241,54 @@ func manageUsers() {
 
 	if len(users) == 0 {
 		printColor(ColorYellow, "No users found.\n")
-		printColor(ColorYellow, "Press Enter to continue...")
+		printColor(ColorYellow, tr("Press Enter to continue..."))
 		readInput()
 		return
 	}
 
-	for i, user := range users {
-		printColor(ColorCyan, fmt.Sprintf("%d. ", i+1))
-		printColor(ColorWhite, fmt.Sprintf("%s ", user.Name))
-		printColor(ColorYellow, fmt.Sprintf("(ID: %s) ", user.ID))
-		printColor(ColorGreen, fmt.Sprintf("- Created: %s\n", user.CreatedAt.Format("2006-01-02")))
-	}
+	choice := ""
+	var userNum int
 
-	fmt.Println("\n1. Delete User")
-	fmt.Println("2. Back")
-	printColor(ColorYellow, "\nEnter choice: ")
+	if tuiEnabled {
+		l := listView{title: "User Management", color: ColorMagenta, help: "↑/↓ j/k move · d delete · Esc/q back"}
+		for _, user := range users {
+			detail := fmt.Sprintf("(ID: %s) - Created: %s", user.ID, user.CreatedAt.Format("2006-01-02"))
+			if n := len(user.integrityIssues); n > 0 {
+				detail += fmt.Sprintf(" ⚠ %d tampered records", n)
+			}
+			l.items = append(l.items, listItem{label: user.Name, detail: detail})
+		}
+		idx, action := l.run("d")
+		if action == 'd' {
+			choice, userNum = "1", idx
+		}
+		clearScreen()
+		printBoxHeader("User Management", ColorMagenta)
+	} else {
+		for i, user := range users {
+			printColor(ColorCyan, fmt.Sprintf("%d. ", i+1))
+			printColor(ColorWhite, fmt.Sprintf("%s ", user.Name))
+			printColor(ColorYellow, fmt.Sprintf("(ID: %s) ", user.ID))
+			printColor(ColorGreen, fmt.Sprintf("- Created: %s", user.CreatedAt.Format("2006-01-02")))
+			if n := len(user.integrityIssues); n > 0 {
+				printColor(ColorRed+ColorBold, fmt.Sprintf(" ⚠ %d tampered records", n))
+			}
+			fmt.Println()
+		}
+
+		fmt.Println("\n1. Delete User")
+		fmt.Println("2. Back")
+		printColor(ColorYellow, "\nEnter choice: ")
 
-	choice := readInput()
+		choice = readInput()
+		if choice == "1" {
+			printColor(ColorYellow, "Enter user number to delete: ")
+			fmt.Sscanf(readInput(), "%d", &userNum)
+			userNum--
+		}
+	}
 
 	if choice == "1" {
-		printColor(ColorYellow, "Enter user number to delete: ")
-		var userNum int
-		fmt.Sscanf(readInput(), "%d", &userNum)
-		userNum--
 
 		if userNum >= 0 && userNum < len(users) {
 			printColor(ColorRed+ColorBold, fmt.Sprintf("\n⚠ WARNING: Delete user %s?\n", users[userNum].Name))
@@ -853,8 +1296,7 @@ func manageUsers() {
 
 			if readInput() == "DELETE" {
 				users = append(users[:userNum], users[userNum+1:]...)
-				data, _ := json.MarshalIndent(users, "", "  ")
-				os.WriteFile(usersFile, data, 0644)
+				saveAllUsers(users)
 
 				printColor(ColorGreen+ColorBold, "\n✓ User deleted successfully!\n")
 			} else {
@@ -863,66 +1305,182 @@ func manageUsers() {
 		}
 	}
 
-	printColor(ColorYellow, "Press Enter to continue...")
+	printColor(ColorYellow, tr("Press Enter to continue..."))
 	readInput()
 }
 
 func listAllQuestions() {
+	if tuiEnabled {
+		browseQuestions()
+		return
+	}
+
 	clearScreen()
 	printBoxHeader("All Questions", ColorBlue)
 	fmt.Println()
+	printColor(ColorCyan, fmt.Sprintf("Question bank: %s\n", bankDescription()))
 
 	modules := getAvailableModules()
+	var categories []string
+	for category := range modules {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
 
-	for category, mods := range modules {
+	for _, category := range categories {
 		printColor(ColorCyan+ColorBold, fmt.Sprintf("\n%s:\n", category))
+		mods := modules[category]
+		sort.Strings(mods)
 		for _, mod := range mods {
 			questions := getQuestionsByModule(category, mod)
-			printColor(ColorGreen, fmt.Sprintf("\n  %s (%d questions):\n", mod, len(questions)))
+			printColor(ColorGreen, fmt.Sprintf("\n  %s (%d questions, %s):\n", mod, len(questions), moduleSource(category, mod)))
 			for i, q := range questions {
 				printColor(ColorYellow, fmt.Sprintf("    %d. ", i+1))
-				printColor(ColorWhite, fmt.Sprintf("%s\n", q.Question))
+				printColor(ColorWhite, stemSummary(q.Question))
+				if missing := missingTranslations(q); len(missing) > 0 {
+					printColor(ColorRed, " ⚠ untranslated: "+strings.Join(missing, ", "))
+				}
+				fmt.Println()
+				if meta := questionMeta(q); meta != "" {
+					printColor(ColorCyan, "       "+meta+"\n")
+				}
 			}
 		}
 	}
 
-	printColor(ColorYellow, "\nPress Enter to continue...")
+	printColor(ColorYellow, "\n"+tr("Press Enter to continue..."))
 	readInput()
 }
 
+// browseQuestions shows every question in a scrollable list where the
+// admin can open a question for editing or delete it
+func browseQuestions() {
+	l := listView{
+		title: "All Questions",
+		color: ColorBlue,
+		help:  "↑/↓ j/k PgUp/PgDn move · Enter/e edit · d delete · Esc/q back",
+	}
+
+	for {
+		l.subtitle = "Question bank: " + bankDescription()
+
+		// Sort a copy of the indexes so the list groups by module
+		order := make([]int, len(quizData.Questions))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(a, b int) bool {
+			qa, qb := quizData.Questions[order[a]], quizData.Questions[order[b]]
+			if qa.Category != qb.Category {
+				return qa.Category < qb.Category
+			}
+			return qa.Module < qb.Module
+		})
+
+		l.items = l.items[:0]
+		for _, i := range order {
+			q := quizData.Questions[i]
+			detail := q.ID
+			if meta := questionMeta(q); meta != "" {
+				detail += " · " + meta
+			}
+			if missing := missingTranslations(q); len(missing) > 0 {
+				detail += " ⚠ untranslated: " + strings.Join(missing, ", ")
+			}
+			l.items = append(l.items, listItem{label: stemSummary(q.Question), detail: detail, group: q.Category + " - " + q.Module})
+		}
+		l.move(0)
+
+		idx, action := l.run("ed")
+		if idx < 0 {
+			return
+		}
+
+		switch action {
+		case '\r', 'e':
+			editQuestionAt(order[idx])
+		case 'd':
+			q := quizData.Questions[order[idx]]
+			clearScreen()
+			printBoxHeader("Remove Question", ColorRed)
+			printColor(ColorWhite, fmt.Sprintf("\n%s\n", stemSummary(q.Question)))
+			printColor(ColorYellow, "\nDelete this question? (y/n): ")
+			if strings.ToLower(readInput()) == "y" {
+				quizData.Questions = append(quizData.Questions[:order[idx]], quizData.Questions[order[idx]+1:]...)
+				saveQuestions()
+			}
+		}
+	}
+}
+
 func changeAdminPassword() {
 	clearScreen()
 	printBoxHeader("Change Admin Password", ColorRed)
 	fmt.Println()
 
-	printColor(ColorYellow, "Enter current password: ")
-	current := readInput()
+	current := readSecret("Enter current password: ")
 
-	if current != adminConfig.Password {
+	if !checkAdminPassword(current) {
 		printColor(ColorRed, "\n✗ Incorrect password!\n")
-		printColor(ColorYellow, "Press Enter to continue...")
+		printColor(ColorYellow, tr("Press Enter to continue..."))
 		readInput()
 		return
 	}
 
-	printColor(ColorYellow, "Enter new password: ")
-	newPass := readInput()
-
-	printColor(ColorYellow, "Confirm new password: ")
-	confirmPass := readInput()
+	newPass := readSecret("Enter new password: ")
+	confirmPass := readSecret("Confirm new password: ")
 
+	if len(newPass) < 8 {
+		printColor(ColorRed, "\n✗ The password must be at least 8 characters.\n")
+		printColor(ColorYellow, tr("Press Enter to continue..."))
+		readInput()
+		return
+	}
 	if newPass != confirmPass {
 		printColor(ColorRed, "\n✗ Passwords don't match!\n")
-		printColor(ColorYellow, "Press Enter to continue...")
+		printColor(ColorYellow, tr("Press Enter to continue..."))
 		readInput()
 		return
 	}
 
-	adminConfig.Password = newPass
+	// The certificate signing, integrity and device keys are sealed with
+	// the admin password
+	keys := []struct {
+		name   string
+		sealed **sealedData
+	}{
+		{"certificate signing key", &adminConfig.SigningKey},
+		{"integrity key", &adminConfig.SealedIntegrityKey},
+		{"device key", &adminConfig.SealedDeviceKey},
+	}
+	resealed := make([]*sealedData, len(keys))
+	for i, k := range keys {
+		if *k.sealed == nil {
+			continue
+		}
+		key, err := unseal(current, *k.sealed)
+		if err == nil {
+			resealed[i], err = seal(newPass, key)
+		}
+		if err != nil {
+			printColor(ColorRed, fmt.Sprintf("\n✗ Could not re-seal the %s: %v\n", k.name, err))
+			printColor(ColorYellow, tr("Press Enter to continue..."))
+			readInput()
+			return
+		}
+	}
+	for i, k := range keys {
+		if resealed[i] != nil {
+			*k.sealed = resealed[i]
+		}
+	}
+
+	adminConfig.PasswordHash = newPasswordHash(newPass)
+	adminPassword = newPass
 	saveAdminConfig()
 
 	printColor(ColorGreen+ColorBold, "\n✓ Admin password changed successfully!\n")
-	printColor(ColorYellow, "Press Enter to continue...")
+	printColor(ColorYellow, tr("Press Enter to continue..."))
 	readInput()
 }
 
@@ -968,7 +1526,41 @@ func countQuestions(category, module string) int {
 	return count
 }
 
-func saveScore(category, module string, correct, total int) {
+func newAnswerRecord(q Question, chosen int) AnswerRecord {
+	if chosen < 0 || chosen >= len(q.Options) {
+		chosen = -1
+	}
+	return AnswerRecord{QuestionID: q.ID, Chosen: chosen, Correct: chosen == q.Answer}
+}
+
+// saveScore records a finished attempt and updates the module's latest
+// score. Assignment attempts are graded in the gradebook and leave the
+// module scores alone.
+func saveScore(attempt Attempt) {
+	if recordsLocked() {
+		return
+	}
+	if trackIntegrity(currentUser) {
+		saveAdminConfig()
+	}
+	if attempt.AssignmentID == "" {
+		updateModuleScore(attempt)
+	}
+
+	if integrityKey != nil {
+		signAttempt(currentUser, &attempt)
+	}
+	currentUser.Attempts = append(currentUser.Attempts, attempt)
+	sealChainHead(currentUser)
+
+	saveUser()
+	exportToSyncDir()
+}
+
+func updateModuleScore(attempt Attempt) {
+	category, module := attempt.Category, attempt.Module
+	correct, total := attempt.Correct(), len(attempt.Answers)
+
 	if currentUser.Scores == nil {
 		currentUser.Scores = make(map[string]map[string]Score)
 	}
@@ -977,13 +1569,18 @@ func saveScore(category, module string, correct, total int) {
 		currentUser.Scores[category] = make(map[string]Score)
 	}
 
-	currentUser.Scores[category][module] = Score{
+	score := Score{
 		Correct:   correct,
 		Total:     total,
-		LastTaken: time.Now(),
+		LastTaken: attempt.FinishedAt,
 	}
-
-	saveUser()
+	if points := attempt.Points(); points != float64(correct) {
+		score.Points = points
+	}
+	if integrityKey != nil {
+		score.MAC = scoreMAC(currentUser.ID, category, module, score)
+	}
+	currentUser.Scores[category][module] = score
 }
 
 func saveUser() {
@@ -1003,7 +1600,28 @@ func saveUser() {
 	}
 
 	data, _ := json.MarshalIndent(users, "", "  ")
-	os.WriteFile(usersFile, data, 0644)
+	writePrivateFile(usersFile, data)
+}
+
+// saveAllUsers overwrites the users file, keeping the logged in user in sync
+func saveAllUsers(users []User) {
+	for _, u := range users {
+		if currentUser != nil && u.ID == currentUser.ID {
+			*currentUser = u
+		}
+	}
+
+	data, _ := json.MarshalIndent(users, "", "  ")
+	writePrivateFile(usersFile, data)
+}
+
+// writePrivateFile writes a file only its owner can read, tightening the
+// mode of files older versions created readable by everyone
+func writePrivateFile(path string, data []byte) error {
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return err
+	}
+	return os.Chmod(path, 0600)
 }
 
 func loadAllUsers() []User {
@@ -1014,20 +1632,74 @@ func loadAllUsers() []User {
 		json.Unmarshal(data, &users)
 	}
 
+	for i := range users {
+		verifyUserRecords(&users[i])
+	}
+
 	return users
 }
 
 func saveQuestions() {
-	data, _ := json.MarshalIndent(quizData, "", "  ")
+	writeQuestionsFile()
+	if err := writeBackBankDir(); err != nil {
+		printColor(ColorRed, fmt.Sprintf("✗ Could not update the question directory: %v\n", err))
+	}
+}
+
+func writeQuestionsFile() {
+	data, err := encodeQuestions()
+	if err != nil {
+		printColor(ColorRed, fmt.Sprintf("✗ Could not save questions: %v\n", err))
+		return
+	}
 	os.WriteFile(questionsFile, data, 0644)
 }
 
+// checkAdminPassword reports whether password is the admin password. A
+// correct password is kept for the session to unlock the signing key and
+// the integrity key.
+func checkAdminPassword(password string) bool {
+	if password == "" || !adminConfig.PasswordHash.matches(password) {
+		return false
+	}
+	adminPassword = password
+	ensureIntegrityKey()
+	return true
+}
+
+// setAdminPassword asks for the first admin password
+func setAdminPassword() bool {
+	printColor(ColorYellow, "No admin password has been set yet. Choose one now.\n")
+	password := readSecret("New admin password: ")
+	if len(password) < 8 {
+		printColor(ColorRed, "\n✗ The password must be at least 8 characters.\n")
+		printColor(ColorYellow, tr("Press Enter to continue..."))
+		readInput()
+		return false
+	}
+	if readSecret("Confirm new password: ") != password {
+		printColor(ColorRed, "\n✗ Passwords don't match!\n")
+		printColor(ColorYellow, tr("Press Enter to continue..."))
+		readInput()
+		return false
+	}
+	adminConfig.PasswordHash = newPasswordHash(password)
+	adminPassword = password
+	saveAdminConfig()
+	ensureIntegrityKey()
+	return true
+}
+
 func saveAdminConfig() {
 	data, _ := json.MarshalIndent(adminConfig, "", "  ")
-	os.WriteFile(adminFile, data, 0644)
+	writePrivateFile(adminFile, data)
 }
 
 func clearScreen() {
+	if screenReader || dumbTerminal {
+		fmt.Println()
+		return
+	}
 	fmt.Print("\033[H\033[2J")
 }
 
@@ -1036,12 +1708,77 @@ func readInput() string {
 	return strings.TrimSpace(input)
 }
 
+// readSecret asks for a password on standard error, without echoing it
+// when standard input is a terminal
+func readSecret(prompt string) string {
+	fmt.Fprint(os.Stderr, prompt)
+	fd := int(os.Stdin.Fd())
+	state, err := makeRaw(fd)
+	if err != nil {
+		return readInput()
+	}
+	defer restoreTerm(fd, state)
+
+	var secret []byte
+	for {
+		c, err := reader.ReadByte()
+		if err != nil {
+			continue // raw reads time out periodically
+		}
+		switch c {
+		case '\r', '\n', 4: // Enter or Ctrl-D
+			fmt.Fprint(os.Stderr, "\r\n")
+			return string(secret)
+		case 3: // Ctrl-C
+			restoreTerm(fd, state)
+			shutdownTerminal()
+			fmt.Fprint(os.Stderr, "\r\n")
+			os.Exit(130)
+		case 8, 127:
+			if len(secret) > 0 {
+				secret = secret[:len(secret)-1]
+			}
+		default:
+			secret = append(secret, c)
+		}
+	}
+}
+
 func printColor(color, text string) {
-	fmt.Print(color + text + ColorReset)
+	fmt.Print(color + outputText(text) + ColorReset)
 }
 
 func printBoxHeader(title, color string) {
-	fmt.Println(color + "╔════════════════════════════════════════╗" + ColorReset)
-	fmt.Printf(color+"║ "+ColorReset+ColorBold+"%-38s"+ColorReset+color+" ║"+ColorReset+"\n", title)
-	fmt.Println(color + "╚════════════════════════════════════════╝" + ColorReset)
+	if screenReader {
+		fmt.Println(outputText(title))
+		return
+	}
+	printBox(color, []pageLine{{ColorBold, title}})
+}
+
+// printBox frames lines in a box at least 40 columns wide, widening it for
+// long lines and wrapping them to the terminal
+func printBox(color string, lines []pageLine) {
+	if screenReader {
+		for _, l := range lines {
+			fmt.Println(outputText(l.text))
+		}
+		return
+	}
+	w, _ := termSize()
+	inner := 38
+	for _, l := range lines {
+		inner = max(inner, displayWidth(outputText(l.text)))
+	}
+	inner = max(min(inner, w-4), 10)
+
+	printColor(color, "╔"+strings.Repeat("═", inner+2)+"╗\n")
+	for _, l := range lines {
+		for _, text := range wrapText(outputText(l.text), inner) {
+			printColor(color, "║ ")
+			printColor(l.color, padRight(text, inner))
+			printColor(color, " ║\n")
+		}
+	}
+	printColor(color, "╚"+strings.Repeat("═", inner+2)+"╝\n")
 }
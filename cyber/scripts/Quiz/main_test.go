@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+// useDataDir points the data files at a fresh directory and resets the
+// admin settings and session for the duration of a test
+func useDataDir(t *testing.T) string {
+	t.Helper()
+	savedDirs := []string{cacheDir, usersFile, questionsFile, adminFile, groupsFile, certificatesFile, packsFile}
+	savedConfig, savedPassword, savedUser, savedData := adminConfig, adminPassword, currentUser, quizData
+	savedKey, savedEdited := integrityKey, integrityStatesEdited
+	t.Cleanup(func() {
+		cacheDir, usersFile, questionsFile, adminFile = savedDirs[0], savedDirs[1], savedDirs[2], savedDirs[3]
+		groupsFile, certificatesFile, packsFile = savedDirs[4], savedDirs[5], savedDirs[6]
+		adminConfig, adminPassword, currentUser, quizData = savedConfig, savedPassword, savedUser, savedData
+		integrityKey, integrityStatesEdited = savedKey, savedEdited
+	})
+
+	dir := t.TempDir()
+	setupCacheDirectory(dir, false, "")
+	adminConfig, adminPassword, currentUser, quizData = AdminConfig{}, "", nil, QuizData{}
+	integrityKey, integrityStatesEdited = nil, false
+	return dir
+}
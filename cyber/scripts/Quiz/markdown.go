@@ -0,0 +1,694 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Markdown. Question text and explanations are written in a subset of
+// Markdown, shown word-wrapped to the terminal and as HTML in web pages:
+//
+//	**bold**, *italic* or _italic_, `inline code`
+//	- bullet lists and 1. numbered lists, nested by indenting two spaces
+//	| pipe | tables |, with a |---|---| line under the header row
+//	```bash fenced code blocks, highlighted by language
+//	# headings
+//
+// Without colour, bold and italics are dropped and inline code keeps its
+// backticks. Screen readers get tables row by row, each cell named by its
+// header.
+
+const (
+	blockPara = iota
+	blockHeading
+	blockCode
+	blockList
+	blockTable
+)
+
+// textBlock is a block of Markdown text
+type textBlock struct {
+	kind   int
+	level  int      // heading level
+	lang   string   // language of a code block
+	lines  []string // paragraph or heading text, code lines or table rows
+	header bool     // the first table row is a header
+	items  []listEntry
+}
+
+// listEntry is an item of a list
+type listEntry struct {
+	level  int    // nesting depth, from 0
+	marker string // "•" or the item number with its dot
+	text   string
+}
+
+var (
+	listItemPattern  = regexp.MustCompile(`^(\s*)([-*+]|\d{1,3}[.)])\s+(.*)$`)
+	headingPattern   = regexp.MustCompile(`^(#{1,6})\s+(.*?)[\s#]*$`)
+	tableRulePattern = regexp.MustCompile(`^\|?(\s*:?-+:?\s*\|)*\s*:?-+:?\s*\|?$`)
+)
+
+// parseBlocks splits Markdown text into blocks. Lines of a paragraph are
+// joined, as in Markdown.
+func parseBlocks(text string) []textBlock {
+	var blocks []textBlock
+	var para []string
+	flush := func() {
+		if len(para) > 0 {
+			blocks = append(blocks, textBlock{kind: blockPara, lines: []string{strings.Join(para, " ")}})
+			para = nil
+		}
+	}
+	// open is the list or table the previous line belonged to, or -1
+	open := -1
+
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.ReplaceAll(lines[i], "\t", "    ")
+		trimmed := strings.TrimSpace(line)
+
+		if fence, ok := codeFence(trimmed); ok {
+			flush()
+			open = -1
+			block := textBlock{kind: blockCode, lang: strings.ToLower(strings.TrimSpace(trimmed[len(fence):]))}
+			for i++; i < len(lines); i++ {
+				if strings.HasPrefix(strings.TrimSpace(lines[i]), fence) {
+					break
+				}
+				block.lines = append(block.lines, strings.ReplaceAll(lines[i], "\t", "    "))
+			}
+			blocks = append(blocks, block)
+			continue
+		}
+
+		switch m := listItemPattern.FindStringSubmatch(line); {
+		case trimmed == "":
+			flush()
+			open = -1
+
+		case m != nil:
+			flush()
+			if open < 0 || blocks[open].kind != blockList {
+				blocks = append(blocks, textBlock{kind: blockList})
+				open = len(blocks) - 1
+			}
+			marker := "•"
+			if unicode.IsDigit(rune(m[2][0])) {
+				marker = strings.TrimRight(m[2], ".)") + "."
+			}
+			blocks[open].items = append(blocks[open].items, listEntry{level: len(m[1]) / 2, marker: marker, text: m[3]})
+
+		case strings.HasPrefix(trimmed, "|"):
+			flush()
+			if open < 0 || blocks[open].kind != blockTable {
+				blocks = append(blocks, textBlock{kind: blockTable})
+				open = len(blocks) - 1
+			}
+			if len(blocks[open].lines) == 1 && !blocks[open].header && tableRulePattern.MatchString(trimmed) {
+				blocks[open].header = true
+				continue
+			}
+			blocks[open].lines = append(blocks[open].lines, trimmed)
+
+		case headingPattern.MatchString(trimmed):
+			flush()
+			open = -1
+			m := headingPattern.FindStringSubmatch(trimmed)
+			blocks = append(blocks, textBlock{kind: blockHeading, level: len(m[1]), lines: []string{m[2]}})
+
+		case open >= 0 && blocks[open].kind == blockList && line != trimmed:
+			// An indented line carries on the last item
+			items := blocks[open].items
+			items[len(items)-1].text += " " + trimmed
+
+		default:
+			open = -1
+			para = append(para, trimmed)
+		}
+	}
+	flush()
+	return blocks
+}
+
+// codeFence returns the fence that opens a code block, ``` or ~~~
+func codeFence(line string) (string, bool) {
+	for _, fence := range []string{"```", "~~~"} {
+		if strings.HasPrefix(line, fence) {
+			return fence, true
+		}
+	}
+	return "", false
+}
+
+// stemSummary is the first paragraph of a question as plain text, for lists
+func stemSummary(text string) string {
+	blocks := parseBlocks(text)
+	for i, b := range blocks {
+		if b.kind == blockPara || b.kind == blockHeading {
+			if i < len(blocks)-1 {
+				return plainText(b.lines[0]) + " …"
+			}
+			return plainText(b.lines[0])
+		}
+	}
+	return strings.TrimSpace(text)
+}
+
+// Inline markup
+
+// span is a run of text in one style
+type span struct {
+	text   string
+	bold   bool
+	italic bool
+	code   bool
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// parseInline splits text into styled spans. A marker with no closing
+// partner is kept as text, and _ inside a word such as snake_case is not
+// a marker.
+func parseInline(s string) []span {
+	var spans []span
+	var cur strings.Builder
+	var bold, italic rune
+	flush := func() {
+		if cur.Len() > 0 {
+			spans = append(spans, span{text: cur.String(), bold: bold != 0, italic: italic != 0})
+			cur.Reset()
+		}
+	}
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		rest := string(runes[i+1:])
+		prevWord := i > 0 && isWordRune(runes[i-1])
+		prevSpace := i == 0 || unicode.IsSpace(runes[i-1])
+
+		switch {
+		case r == '\\' && i+1 < len(runes) && strings.ContainsRune("\\`*_|#", runes[i+1]):
+			cur.WriteRune(runes[i+1])
+			i++
+
+		case r == '`' && strings.ContainsRune(rest, '`'):
+			flush()
+			end := strings.IndexRune(rest, '`')
+			spans = append(spans, span{text: rest[:end], code: true})
+			i += len([]rune(rest[:end])) + 1
+
+		case (r == '*' || r == '_') && i+1 < len(runes) && runes[i+1] == r:
+			after := i + 2
+			nextWord := after < len(runes) && isWordRune(runes[after])
+			nextSpace := after >= len(runes) || unicode.IsSpace(runes[after])
+			marker := string([]rune{r, r})
+			switch {
+			case bold == r && !prevSpace && !(r == '_' && nextWord):
+				flush()
+				bold = 0
+			case bold == 0 && !nextSpace && !(r == '_' && prevWord) && strings.Contains(string(runes[after:]), marker):
+				flush()
+				bold = r
+			default:
+				cur.WriteString(marker)
+			}
+			i++
+
+		case r == '*' || r == '_':
+			nextWord := i+1 < len(runes) && isWordRune(runes[i+1])
+			nextSpace := i+1 >= len(runes) || unicode.IsSpace(runes[i+1])
+			switch {
+			case italic == r && !prevSpace && !(r == '_' && nextWord):
+				flush()
+				italic = 0
+			case italic == 0 && !nextSpace && !(r == '_' && prevWord) && strings.ContainsRune(rest, r):
+				flush()
+				italic = r
+			default:
+				cur.WriteRune(r)
+			}
+
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	flush()
+	return spans
+}
+
+// plainText drops the inline markup of text
+func plainText(s string) string {
+	var b strings.Builder
+	for _, sp := range parseInline(s) {
+		b.WriteString(sp.text)
+	}
+	return b.String()
+}
+
+// styled renders a span for the terminal, returning to the base colour
+// after it
+func (sp span) styled(base string) string {
+	style := ""
+	if sp.bold {
+		style += ColorBold
+	}
+	if sp.italic && ColorReset != "" {
+		style += "\033[3m"
+	}
+	if sp.code {
+		style += ColorCyan
+	}
+	if style == "" {
+		return sp.text
+	}
+	return style + sp.text + ColorReset + base
+}
+
+// styledLines word-wraps text with inline markup to the given width
+func styledLines(text string, width int, base string) []string {
+	width = max(width, 10)
+
+	// Split the spans into words, keeping the style of each piece
+	var words [][]span
+	var word []span
+	for _, sp := range parseInline(text) {
+		if sp.code && ColorReset == "" {
+			sp.text, sp.code = "`"+sp.text+"`", false
+		}
+		for k, part := range strings.Split(sp.text, " ") {
+			if k > 0 && len(word) > 0 {
+				words = append(words, word)
+				word = nil
+			}
+			if part != "" {
+				piece := sp
+				piece.text = part
+				word = append(word, piece)
+			}
+		}
+	}
+	if len(word) > 0 {
+		words = append(words, word)
+	}
+
+	var lines []string
+	var line strings.Builder
+	lineWidth := 0
+	for _, w := range words {
+		for spansWidth(w) > width {
+			// Break a word longer than the line
+			if lineWidth > 0 {
+				lines = append(lines, line.String())
+				line.Reset()
+				lineWidth = 0
+			}
+			var head []span
+			head, w = cutSpans(w, width)
+			for _, sp := range head {
+				line.WriteString(sp.styled(base))
+			}
+			lines = append(lines, line.String())
+			line.Reset()
+		}
+		ww := spansWidth(w)
+		if lineWidth > 0 && lineWidth+1+ww > width {
+			lines = append(lines, line.String())
+			line.Reset()
+			lineWidth = 0
+		}
+		if lineWidth > 0 {
+			line.WriteByte(' ')
+			lineWidth++
+		}
+		for _, sp := range w {
+			line.WriteString(sp.styled(base))
+		}
+		lineWidth += ww
+	}
+	if lineWidth > 0 || len(lines) == 0 {
+		lines = append(lines, line.String())
+	}
+	return lines
+}
+
+func spansWidth(spans []span) int {
+	w := 0
+	for _, sp := range spans {
+		w += displayWidth(sp.text)
+	}
+	return w
+}
+
+// cutSpans splits spans after width columns
+func cutSpans(spans []span, width int) ([]span, []span) {
+	var head []span
+	for i, sp := range spans {
+		w := displayWidth(sp.text)
+		if w <= width {
+			head = append(head, sp)
+			width -= w
+			continue
+		}
+		runes := []rune(sp.text)
+		cut := 0
+		for used := 0; cut < len(runes) && used+runeWidth(runes[cut]) <= width; cut++ {
+			used += runeWidth(runes[cut])
+		}
+		first, second := sp, sp
+		first.text, second.text = string(runes[:cut]), string(runes[cut:])
+		if first.text != "" {
+			head = append(head, first)
+		}
+		return head, append([]span{second}, spans[i+1:]...)
+	}
+	return head, nil
+}
+
+// renderMarkdown lays out Markdown text for a terminal of the given width
+func renderMarkdown(text string, width int, color string) []pageLine {
+	var lines []pageLine
+	for i, block := range parseBlocks(text) {
+		if i > 0 {
+			lines = append(lines, pageLine{})
+		}
+		switch block.kind {
+		case blockCode:
+			lines = append(lines, codeLines(block.lines, block.lang, width)...)
+		case blockHeading:
+			for _, l := range styledLines(block.lines[0], width, color+ColorBold) {
+				lines = append(lines, pageLine{color + ColorBold, l})
+			}
+		case blockList:
+			for _, item := range block.items {
+				indent := strings.Repeat("  ", item.level)
+				hang := indent + strings.Repeat(" ", displayWidth(item.marker)+1)
+				for j, l := range styledLines(item.text, width-displayWidth(hang), color) {
+					prefix := hang
+					if j == 0 {
+						prefix = indent + item.marker + " "
+					}
+					lines = append(lines, pageLine{color, prefix + l})
+				}
+			}
+		case blockTable:
+			lines = append(lines, tableLines(block, width, color)...)
+		default:
+			for _, l := range styledLines(block.lines[0], width, color) {
+				lines = append(lines, pageLine{color, l})
+			}
+		}
+	}
+	return lines
+}
+
+// tableCells splits a table row into the plain text of its cells
+func tableCells(row string) []string {
+	row = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(row), "|"), "|")
+	row = strings.ReplaceAll(row, `\|`, "\x00")
+	var cells []string
+	for _, cell := range strings.Split(row, "|") {
+		cells = append(cells, strings.ReplaceAll(plainText(strings.TrimSpace(cell)), "\x00", "|"))
+	}
+	return cells
+}
+
+// tableLines lays out a table, narrowing the widest columns until it fits
+func tableLines(block textBlock, width int, color string) []pageLine {
+	var rows [][]string
+	cols := 0
+	for _, r := range block.lines {
+		cells := tableCells(r)
+		rows = append(rows, cells)
+		cols = max(cols, len(cells))
+	}
+
+	var lines []pageLine
+	if screenReader {
+		for i, row := range rows {
+			if block.header && i == 0 {
+				continue
+			}
+			var parts []string
+			for c, cell := range row {
+				if block.header && c < len(rows[0]) && rows[0][c] != "" {
+					cell = rows[0][c] + ": " + cell
+				}
+				parts = append(parts, cell)
+			}
+			lines = append(lines, pageLine{"", trf("Row %d: %s", len(lines)+1, strings.Join(parts, "; "))})
+		}
+		return lines
+	}
+
+	widths := make([]int, cols)
+	for _, row := range rows {
+		for c, cell := range row {
+			widths[c] = max(widths[c], displayWidth(cell))
+		}
+	}
+	for {
+		total, widest := 3*(cols-1), 0
+		for c, w := range widths {
+			total += w
+			if w > widths[widest] {
+				widest = c
+			}
+		}
+		if total <= width || widths[widest] <= 3 {
+			break
+		}
+		widths[widest]--
+	}
+
+	for i, row := range rows {
+		cells := make([]string, cols)
+		for c := range cells {
+			if c < len(row) {
+				cells[c] = row[c]
+			}
+			cells[c] = padRight(cells[c], widths[c])
+		}
+		style := color
+		if block.header && i == 0 {
+			style += ColorBold
+		}
+		lines = append(lines, pageLine{style, strings.TrimRight(strings.Join(cells, " │ "), " ")})
+		if block.header && i == 0 {
+			var rule []string
+			for _, w := range widths {
+				rule = append(rule, strings.Repeat("─", w))
+			}
+			lines = append(lines, pageLine{color, strings.Join(rule, "─┼─")})
+		}
+	}
+	return lines
+}
+
+// codeLines frames a code block; long lines are cut rather than wrapped
+func codeLines(code []string, lang string, width int) []pageLine {
+	var lines []pageLine
+	if screenReader {
+		lines = append(lines, pageLine{"", tr("Code:")})
+		for _, l := range code {
+			lines = append(lines, pageLine{"", l})
+		}
+		return append(lines, pageLine{"", tr("End of code.")})
+	}
+	for _, l := range code {
+		lines = append(lines, pageLine{ColorCyan, "│ " + ColorReset + highlightCode(fit(l, width-2), lang)})
+	}
+	return lines
+}
+
+// printMarkdown prints Markdown text word-wrapped to the terminal
+func printMarkdown(text, color string) {
+	w, _ := termSize()
+	for _, line := range renderMarkdown(text, w, color) {
+		printColor(line.color, line.text+"\n")
+	}
+}
+
+// Syntax highlighting
+
+var langAliases = map[string]string{
+	"sh": "bash", "shell": "bash", "zsh": "bash", "console": "bash",
+	"py": "python", "ps1": "powershell", "pwsh": "powershell",
+	"cisco": "ios", "js": "javascript", "php": "javascript",
+}
+
+var codeKeywords = map[string]string{
+	"bash":       "if then else elif fi for while do done case esac function return in export local sudo echo cat grep awk sed curl wget nc nmap ssh chmod chown",
+	"python":     "def class return if elif else for while in import from as with try except finally raise lambda not and or is None True False print",
+	"powershell": "function param if else elseif foreach for while return try catch finally get-process get-childitem invoke-webrequest invoke-expression new-object set-executionpolicy write-host",
+	"sql":        "select from where insert into values update set delete union all and or not null order by group having join on as drop table create",
+	"ios":        "interface ip address no shutdown router ospf eigrp network vlan switchport mode access trunk enable configure terminal line vty password login hostname show running-config access-list permit deny",
+	"javascript": "function var let const return if else for while new this document window alert eval script true false null",
+	"c":          "int char void return if else for while struct sizeof include define unsigned long static const",
+}
+
+var commentPrefixes = map[string]string{
+	"bash": "#", "python": "#", "powershell": "#", "sql": "--", "ios": "!", "javascript": "//", "c": "//",
+}
+
+// highlightCode colours the keywords, strings, numbers and comments of a
+// line of code
+func highlightCode(line, lang string) string {
+	if l, ok := langAliases[lang]; ok {
+		lang = l
+	}
+	words, known := codeKeywords[lang]
+	if !known || ColorReset == "" {
+		return line
+	}
+	keywords := make(map[string]bool)
+	for _, w := range strings.Fields(words) {
+		keywords[w] = true
+	}
+	comment := commentPrefixes[lang]
+
+	var b strings.Builder
+	runes := []rune(line)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case comment != "" && strings.HasPrefix(string(runes[i:]), comment) && (i == 0 || unicode.IsSpace(runes[i-1])):
+			b.WriteString(ColorBlue + string(runes[i:]) + ColorReset)
+			return b.String()
+		case r == '"' || r == '\'':
+			j := i + 1
+			for j < len(runes) && runes[j] != r {
+				if runes[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			j = min(j+1, len(runes))
+			b.WriteString(ColorGreen + string(runes[i:j]) + ColorReset)
+			i = j
+		case unicode.IsLetter(r) || r == '_' || r == '-':
+			j := i
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_' || runes[j] == '-') {
+				j++
+			}
+			word := string(runes[i:j])
+			if keywords[strings.ToLower(word)] {
+				b.WriteString(ColorMagenta + word + ColorReset)
+			} else {
+				b.WriteString(word)
+			}
+			i = j
+		case unicode.IsDigit(r):
+			j := i
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.' || runes[j] == '/') {
+				j++
+			}
+			b.WriteString(ColorYellow + string(runes[i:j]) + ColorReset)
+			i = j
+		default:
+			b.WriteRune(r)
+			i++
+		}
+	}
+	return b.String()
+}
+
+// HTML
+
+// inlineHTML renders inline markup as HTML
+func inlineHTML(s string) string {
+	var b strings.Builder
+	for _, sp := range parseInline(s) {
+		text := html.EscapeString(sp.text)
+		if sp.code {
+			text = "<code>" + text + "</code>"
+		}
+		if sp.italic {
+			text = "<em>" + text + "</em>"
+		}
+		if sp.bold {
+			text = "<strong>" + text + "</strong>"
+		}
+		b.WriteString(text)
+	}
+	return b.String()
+}
+
+// markdownHTML renders Markdown text as HTML
+func markdownHTML(text string) string {
+	esc := html.EscapeString
+	var b strings.Builder
+	for _, block := range parseBlocks(text) {
+		switch block.kind {
+		case blockCode:
+			fmt.Fprintf(&b, "<pre><code class=\"language-%s\">%s</code></pre>\n", esc(block.lang), esc(strings.Join(block.lines, "\n")))
+		case blockHeading:
+			// The page and each question already have headings above these
+			level := min(block.level+2, 6)
+			fmt.Fprintf(&b, "<h%d>%s</h%d>\n", level, inlineHTML(block.lines[0]), level)
+		case blockList:
+			listHTML(&b, block.items)
+		case blockTable:
+			b.WriteString("<table>\n")
+			for i, row := range block.lines {
+				cell := "td"
+				if block.header && i == 0 {
+					cell = "th"
+				}
+				b.WriteString("<tr>")
+				row = strings.ReplaceAll(strings.TrimSuffix(strings.TrimPrefix(row, "|"), "|"), `\|`, "\x00")
+				for _, c := range strings.Split(row, "|") {
+					fmt.Fprintf(&b, "<%s>%s</%s>", cell, strings.ReplaceAll(inlineHTML(strings.TrimSpace(c)), "\x00", "|"), cell)
+				}
+				b.WriteString("</tr>\n")
+			}
+			b.WriteString("</table>\n")
+		default:
+			fmt.Fprintf(&b, "<p>%s</p>\n", inlineHTML(block.lines[0]))
+		}
+	}
+	return b.String()
+}
+
+// listHTML writes list items as nested ul and ol elements
+func listHTML(b *strings.Builder, items []listEntry) {
+	var open []string // element of each open list, outermost first
+	for i, item := range items {
+		tag := "ul"
+		if item.marker != "•" {
+			tag = "ol"
+		}
+		depth := min(item.level+1, len(open)+1)
+		if i > 0 && depth <= len(open) {
+			b.WriteString("</li>\n")
+		}
+		for len(open) > depth {
+			fmt.Fprintf(b, "</%s>\n</li>\n", open[len(open)-1])
+			open = open[:len(open)-1]
+		}
+		if len(open) == depth && open[depth-1] != tag {
+			// A list of the other kind follows at the same depth
+			fmt.Fprintf(b, "</%s>\n", open[depth-1])
+			open = open[:depth-1]
+		}
+		if len(open) < depth {
+			fmt.Fprintf(b, "<%s>\n", tag)
+			open = append(open, tag)
+		}
+		fmt.Fprintf(b, "<li>%s", inlineHTML(item.text))
+	}
+	b.WriteString("</li>\n")
+	for len(open) > 0 {
+		fmt.Fprintf(b, "</%s>\n", open[len(open)-1])
+		open = open[:len(open)-1]
+		if len(open) > 0 {
+			b.WriteString("</li>\n")
+		}
+	}
+}
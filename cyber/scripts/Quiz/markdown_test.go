@@ -0,0 +1,196 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// useTheme switches the colours for the duration of a test
+func useTheme(t *testing.T, theme string) {
+	t.Helper()
+	useConfig(t)
+	config.Theme, config.ScreenReader = theme, false
+	applyOutputSettings()
+}
+
+func TestParseInline(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []span
+	}{
+		{"plain text", []span{{text: "plain text"}}},
+		{"**bold** and *italic*", []span{{text: "bold", bold: true}, {text: " and "}, {text: "italic", italic: true}}},
+		{"__bold__ and _italic_", []span{{text: "bold", bold: true}, {text: " and "}, {text: "italic", italic: true}}},
+		{"**bold _both_**", []span{{text: "bold ", bold: true}, {text: "both", bold: true, italic: true}}},
+		{"run `ls *.txt` now", []span{{text: "run "}, {text: "ls *.txt", code: true}, {text: " now"}}},
+		{"snake_case_name", []span{{text: "snake_case_name"}}},
+		{"2 * 3 * 4", []span{{text: "2 * 3 * 4"}}},
+		{"**unclosed and *half", []span{{text: "**unclosed and *half"}}},
+		{"a `lone backtick", []span{{text: "a `lone backtick"}}},
+		{`\*not italic\* and \\`, []span{{text: `*not italic* and \`}}},
+		{"*ünïcode* ok", []span{{text: "ünïcode", italic: true}, {text: " ok"}}},
+	}
+	for _, tt := range tests {
+		if got := parseInline(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseInline(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseBlocks(t *testing.T) {
+	text := "# Title #\n" +
+		"First line\nsecond line\n\n" +
+		"- one\n  - nested\n    carried on\n2. two\n\n" +
+		"| Port | Service |\n|---:|:---|\n| 22 | SSH \\| SFTP |\n\n" +
+		"```Bash\nls -l\n\techo\n```\n" +
+		"after"
+	want := []textBlock{
+		{kind: blockHeading, level: 1, lines: []string{"Title"}},
+		{kind: blockPara, lines: []string{"First line second line"}},
+		{kind: blockList, items: []listEntry{
+			{level: 0, marker: "•", text: "one"},
+			{level: 1, marker: "•", text: "nested carried on"},
+			{level: 0, marker: "2.", text: "two"},
+		}},
+		{kind: blockTable, header: true, lines: []string{"| Port | Service |", `| 22 | SSH \| SFTP |`}},
+		{kind: blockCode, lang: "bash", lines: []string{"ls -l", "    echo"}},
+		{kind: blockPara, lines: []string{"after"}},
+	}
+	if got := parseBlocks(text); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseBlocks:\n got %+v\nwant %+v", got, want)
+	}
+
+	// An unterminated fence runs to the end, and a table without a rule
+	// line has no header
+	got := parseBlocks("| a | b |\n| c | d |\n~~~\ncode")
+	if len(got) != 2 || got[0].header || len(got[0].lines) != 2 || got[1].kind != blockCode || got[1].lines[0] != "code" {
+		t.Errorf("headerless table and open fence: %+v", got)
+	}
+}
+
+func TestStemSummary(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"Which **port**?", "Which port?"},
+		{"Which port?\n\n```\nnc -l\n```", "Which port? …"},
+		{"```\ncode only\n```", "```\ncode only\n```"},
+	}
+	for _, tt := range tests {
+		if got := stemSummary(tt.in); got != tt.want {
+			t.Errorf("stemSummary(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestStyledLines(t *testing.T) {
+	useTheme(t, "none")
+	tests := []struct {
+		in    string
+		width int
+		want  []string
+	}{
+		{"", 20, []string{""}},
+		{"the quick brown fox jumps", 10, []string{"the quick", "brown fox", "jumps"}},
+		{"**bold** words *stay* together", 12, []string{"bold words", "stay", "together"}},
+		{"run `ls -la` here", 20, []string{"run `ls -la` here"}},
+		{"abcdefghijklmnopqrstuvwxyz end", 10, []string{"abcdefghij", "klmnopqrst", "uvwxyz end"}},
+		{"日本語のテキスト", 10, []string{"日本語のテ", "キスト"}},
+	}
+	for _, tt := range tests {
+		if got := styledLines(tt.in, tt.width, ""); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("styledLines(%q, %d) = %q, want %q", tt.in, tt.width, got, tt.want)
+		}
+	}
+
+	useTheme(t, "default")
+	got := styledLines("a **b** `c`", 40, ColorBlue)
+	want := "a " + ColorBold + "b" + ColorReset + ColorBlue + " " + ColorCyan + "c" + ColorReset + ColorBlue
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("styled spans %q, want %q", got, want)
+	}
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	useTheme(t, "none")
+	text := "Intro\n\n1. first item wraps\n   - sub\n\n| Port | Service |\n|---|---|\n| 22 | SSH |\n| 3389 | Remote Desktop |"
+	var got []string
+	for _, l := range renderMarkdown(text, 16, "") {
+		got = append(got, l.text)
+	}
+	want := []string{
+		"Intro",
+		"",
+		"1. first item",
+		"   wraps",
+		"  • sub",
+		"",
+		"Port │ Service",
+		"─────┼──────────",
+		"22   │ SSH",
+		"3389 │ Remote D…",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("renderMarkdown:\n got %q\nwant %q", got, want)
+	}
+
+	useConfig(t)
+	config.ScreenReader = true
+	applyOutputSettings()
+	got = nil
+	for _, l := range renderMarkdown("| Port | Service |\n|---|---|\n| 22 | SSH |\n\n```\nls\n```", 40, "") {
+		got = append(got, l.text)
+	}
+	want = []string{"Row 1: Port: 22; Service: SSH", "", "Code:", "ls", "End of code."}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("screen reader:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestHighlightCode(t *testing.T) {
+	useTheme(t, "default")
+	kw := func(s string) string { return ColorMagenta + s + ColorReset }
+	tests := []struct {
+		line, lang, want string
+	}{
+		{"echo hi # note", "sh", kw("echo") + " hi " + ColorBlue + "# note" + ColorReset},
+		{"curl a#b", "bash", kw("curl") + " a#b"},
+		{`print("x\"y")`, "py", kw("print") + "(" + ColorGreen + `"x\"y"` + ColorReset + ")"},
+		{"SELECT 1 -- all", "sql", kw("SELECT") + " " + ColorYellow + "1" + ColorReset + " " + ColorBlue + "-- all" + ColorReset},
+		{"ip address 10.0.0.1/24", "cisco", kw("ip") + " " + kw("address") + " " + ColorYellow + "10.0.0.1/24" + ColorReset},
+		{"echo 'open", "bash", kw("echo") + " " + ColorGreen + "'open" + ColorReset},
+		{"plain words", "brainfuck", "plain words"},
+	}
+	for _, tt := range tests {
+		if got := highlightCode(tt.line, tt.lang); got != tt.want {
+			t.Errorf("highlightCode(%q, %q) = %q, want %q", tt.line, tt.lang, got, tt.want)
+		}
+	}
+
+	useTheme(t, "none")
+	if got := highlightCode("echo hi # note", "bash"); got != "echo hi # note" {
+		t.Errorf("highlighted without colour: %q", got)
+	}
+}
+
+func TestMarkdownHTML(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"paragraph", "Use <b> & **bold**", "<p>Use &lt;b&gt; &amp; <strong>bold</strong></p>\n"},
+		{"heading", "## Ports", "<h4>Ports</h4>\n"},
+		{"code", "```html\n<script>\n```", "<pre><code class=\"language-html\">&lt;script&gt;</code></pre>\n"},
+		{"table", "| A | B |\n|---|---|\n| `x\\|y` | *z* |",
+			"<table>\n<tr><th>A</th><th>B</th></tr>\n<tr><td><code>x|y</code></td><td><em>z</em></td></tr>\n</table>\n"},
+		{"flat list", "- a\n- b", "<ul>\n<li>a</li>\n<li>b</li>\n</ul>\n"},
+		{"nested list", "1. a\n  - b\n  - c\n2. d",
+			"<ol>\n<li>a<ul>\n<li>b</li>\n<li>c</li>\n</ul>\n</li>\n<li>d</li>\n</ol>\n"},
+		{"list ending nested", "- a\n  1. b",
+			"<ul>\n<li>a<ol>\n<li>b</li>\n</ol>\n</li>\n</ul>\n"},
+		{"list kind changes", "- a\n1. b", "<ul>\n<li>a</li>\n</ul>\n<ol>\n<li>b</li>\n</ol>\n"},
+		{"skipped level", "- a\n      - b", "<ul>\n<li>a<ul>\n<li>b</li>\n</ul>\n</li>\n</ul>\n"},
+	}
+	for _, tt := range tests {
+		if got := markdownHTML(tt.in); got != tt.want {
+			t.Errorf("%s:\n got %q\nwant %q", tt.name, got, tt.want)
+		}
+	}
+}
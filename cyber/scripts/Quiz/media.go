@@ -0,0 +1,555 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Question media. Besides its Markdown text (see markdown.go), a question
+// can have an exhibit and images.
+//
+// An exhibit is preformatted text shown in a frame as written, such as a
+// log excerpt, packet dump or ASCII topology. Images are files in a media
+// directory: media/ inside a pack, <data dir>/media for questions added
+// here, or media/ in the question directory.
+//
+// Terminals that support it show images with the kitty, iTerm2 or sixel
+// graphics protocols; others get coloured half blocks or, in ASCII mode,
+// characters. Screen readers get the alt text, which every image needs.
+
+// QuestionImage is an image attached to a question
+type QuestionImage struct {
+	File string `json:"file"` // path under the media directory
+	Alt  string `json:"alt"`  // description for screen readers and text output
+}
+
+// imageModes are the ways of showing images; auto picks one for the terminal
+var imageModes = []string{"auto", "kitty", "iterm", "sixel", "blocks", "ascii", "off"}
+
+// questionBody lays out a question's text, exhibit and images for a
+// terminal of the given width. Images are drawn with characters; graphics
+// protocols are used by printQuestionBody.
+func questionBody(q Question, width int, textColor string) []pageLine {
+	lines := renderMarkdown(q.Question, width, textColor)
+	if q.Exhibit != "" {
+		lines = append(lines, pageLine{})
+		lines = append(lines, exhibitLines(q.Exhibit, width)...)
+	}
+	for _, img := range q.Images {
+		lines = append(lines, pageLine{})
+		lines = append(lines, imageLines(q, img, width)...)
+	}
+	return lines
+}
+
+// exhibitLines frames preformatted text
+func exhibitLines(text string, width int) []pageLine {
+	body := strings.Split(strings.TrimRight(strings.ReplaceAll(text, "\t", "    "), "\n"), "\n")
+	if screenReader {
+		lines := []pageLine{{"", tr("Exhibit:")}}
+		for _, l := range body {
+			lines = append(lines, pageLine{"", l})
+		}
+		return append(lines, pageLine{"", tr("End of exhibit.")})
+	}
+
+	inner := 0
+	for _, l := range body {
+		inner = max(inner, displayWidth(l))
+	}
+	inner = min(inner, width-4)
+	title := "─ " + tr("Exhibit") + " "
+	lines := []pageLine{{ColorYellow, "┌" + title + strings.Repeat("─", max(inner+2-displayWidth(title), 0)) + "┐"}}
+	for _, l := range body {
+		lines = append(lines, pageLine{ColorYellow, "│ " + ColorReset + padRight(fit(l, inner), inner) + ColorYellow + " │"})
+	}
+	return append(lines, pageLine{ColorYellow, "└" + strings.Repeat("─", inner+2) + "┘"})
+}
+
+// mediaPath finds an image file for a question
+func mediaPath(q Question, file string) (string, bool) {
+	if strings.Contains(file, "..") {
+		return "", false
+	}
+	var dirs []string
+	if q.Pack != "" {
+		dirs = append(dirs, filepath.Join(cacheDir, "packs", q.Pack, "media"))
+	}
+	dirs = append(dirs, filepath.Join(cacheDir, "media"))
+	if adminConfig.BankDir != "" {
+		dirs = append(dirs, filepath.Join(adminConfig.BankDir, "media"))
+	}
+	for _, dir := range dirs {
+		path := filepath.Join(dir, filepath.FromSlash(file))
+		if _, err := os.Stat(path); err == nil {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+func loadImage(q Question, img QuestionImage) (image.Image, []byte, error) {
+	path, ok := mediaPath(q, img.File)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s not found", img.File)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	decoded, _, err := image.Decode(bytes.NewReader(data))
+	return decoded, data, err
+}
+
+// imageMode decides how images are shown on this terminal
+func imageMode() string {
+	switch {
+	case screenReader:
+		return "off"
+	case asciiOnly || dumbTerminal:
+		if config.Images == "off" {
+			return "off"
+		}
+		return "ascii"
+	case config.Images != "auto":
+		return config.Images
+	case os.Getenv("KITTY_WINDOW_ID") != "" || os.Getenv("TERM") == "xterm-kitty":
+		return "kitty"
+	case os.Getenv("TERM_PROGRAM") == "iTerm.app" || os.Getenv("TERM_PROGRAM") == "WezTerm":
+		return "iterm"
+	case strings.Contains(os.Getenv("TERM"), "sixel") || os.Getenv("TERM") == "foot" || os.Getenv("TERM") == "mlterm":
+		return "sixel"
+	case activeTheme == "none" || activeTheme == "mono":
+		return "ascii"
+	}
+	return "blocks"
+}
+
+// imageLines draws an image with characters, with its alt text as a
+// caption
+func imageLines(q Question, img QuestionImage, width int) []pageLine {
+	caption := pageLine{ColorCyan, "🖼 " + img.Alt}
+	if screenReader {
+		return []pageLine{{"", trf("Image: %s", img.Alt)}}
+	}
+	mode := imageMode()
+	if mode == "off" {
+		return []pageLine{caption}
+	}
+	decoded, _, err := loadImage(q, img)
+	if err != nil {
+		return []pageLine{caption, {ColorRed, "⚠ " + err.Error()}}
+	}
+
+	cols := min(width-2, 64)
+	if mode == "ascii" {
+		return append(asciiArt(decoded, cols), caption)
+	}
+	return append(blockArt(decoded, cols), caption)
+}
+
+// sample averages the pixels of an image cell
+func sample(img image.Image, x0, y0, x1, y1 int) (r, g, b uint32) {
+	n := uint32(0)
+	for y := y0; y < max(y1, y0+1); y++ {
+		for x := x0; x < max(x1, x0+1); x++ {
+			pr, pg, pb, pa := img.At(x, y).RGBA()
+			// Transparent pixels show the dark terminal background
+			r, g, b = r+pr*pa/0xffff>>8, g+pg*pa/0xffff>>8, b+pb*pa/0xffff>>8
+			n++
+		}
+	}
+	return r / n, g / n, b / n
+}
+
+// artSize picks the character cells for an image at most cols wide and
+// maxArtRows high. Cells are about twice as high as they are wide.
+func artSize(bounds image.Rectangle, cols int) (int, int) {
+	cols = max(min(cols, bounds.Dx(), maxArtRows*2*bounds.Dx()/max(bounds.Dy(), 1)), 1)
+	return cols, max(bounds.Dy()*cols/bounds.Dx()/2, 1)
+}
+
+const maxArtRows = 20
+
+// asciiArt draws an image with characters by brightness
+func asciiArt(img image.Image, cols int) []pageLine {
+	const ramp = " .:-=+*#%@"
+	bounds := img.Bounds()
+	cols, rows := artSize(bounds, cols)
+	var lines []pageLine
+	for row := 0; row < rows; row++ {
+		var b strings.Builder
+		y0 := bounds.Min.Y + row*bounds.Dy()/rows
+		y1 := bounds.Min.Y + (row+1)*bounds.Dy()/rows
+		for col := 0; col < cols; col++ {
+			x0 := bounds.Min.X + col*bounds.Dx()/cols
+			x1 := bounds.Min.X + (col+1)*bounds.Dx()/cols
+			r, g, bl := sample(img, x0, y0, x1, y1)
+			lum := (299*r + 587*g + 114*bl) / 1000
+			b.WriteByte(ramp[lum*uint32(len(ramp)-1)/255])
+		}
+		lines = append(lines, pageLine{"", strings.TrimRight(b.String(), " ")})
+	}
+	return lines
+}
+
+// blockArt draws an image with half blocks in 24-bit colour, two pixels
+// per character cell
+func blockArt(img image.Image, cols int) []pageLine {
+	bounds := img.Bounds()
+	cols, rows := artSize(bounds, cols)
+	var lines []pageLine
+	for row := 0; row < rows; row++ {
+		var b strings.Builder
+		for col := 0; col < cols; col++ {
+			x0 := bounds.Min.X + col*bounds.Dx()/cols
+			x1 := bounds.Min.X + (col+1)*bounds.Dx()/cols
+			top := bounds.Min.Y + row*2*bounds.Dy()/(rows*2)
+			mid := bounds.Min.Y + (row*2+1)*bounds.Dy()/(rows*2)
+			bottom := bounds.Min.Y + (row+1)*bounds.Dy()/rows
+			tr, tg, tb := sample(img, x0, top, x1, mid)
+			br, bg, bb := sample(img, x0, mid, x1, bottom)
+			fmt.Fprintf(&b, "\033[38;2;%d;%d;%dm\033[48;2;%d;%d;%dm▀", tr, tg, tb, br, bg, bb)
+		}
+		b.WriteString("\033[0m")
+		lines = append(lines, pageLine{"", b.String()})
+	}
+	return lines
+}
+
+// printQuestionBody prints a question's text, exhibit and images, using a
+// graphics protocol for images when the terminal has one
+func printQuestionBody(q Question, width int, textColor string) {
+	for _, line := range questionBody(Question{Question: q.Question, Exhibit: q.Exhibit}, width, textColor) {
+		printColor(line.color, line.text+"\n")
+	}
+	for _, img := range q.Images {
+		fmt.Println()
+		mode := imageMode()
+		if mode != "kitty" && mode != "iterm" && mode != "sixel" {
+			for _, line := range imageLines(q, img, width) {
+				printColor(line.color, line.text+"\n")
+			}
+			continue
+		}
+		decoded, data, err := loadImage(q, img)
+		if err != nil {
+			printColor(ColorRed, "⚠ "+err.Error()+"\n")
+			continue
+		}
+		switch mode {
+		case "kitty":
+			fmt.Print(kittyImage(decoded))
+		case "iterm":
+			fmt.Printf("\033]1337;File=inline=1;size=%d;preserveAspectRatio=1:%s\a", len(data), base64.StdEncoding.EncodeToString(data))
+		case "sixel":
+			fmt.Print(sixelImage(decoded, 480))
+		}
+		fmt.Println()
+		printColor(ColorCyan, "🖼 "+img.Alt+"\n")
+	}
+}
+
+// kittyImage sends an image with the kitty graphics protocol, as PNG in
+// chunks of base64
+func kittyImage(img image.Image) string {
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	payload := base64.StdEncoding.EncodeToString(buf.Bytes())
+
+	var b strings.Builder
+	for first := true; payload != ""; first = false {
+		chunk := payload[:min(4096, len(payload))]
+		payload = payload[len(chunk):]
+		more := 0
+		if payload != "" {
+			more = 1
+		}
+		if first {
+			fmt.Fprintf(&b, "\033_Ga=T,f=100,m=%d;%s\033\\", more, chunk)
+		} else {
+			fmt.Fprintf(&b, "\033_Gm=%d;%s\033\\", more, chunk)
+		}
+	}
+	return b.String()
+}
+
+// sixelImage encodes an image as sixels, scaled to at most maxWidth pixels
+// and reduced to a 6×6×6 colour cube
+func sixelImage(img image.Image, maxWidth int) string {
+	bounds := img.Bounds()
+	w := min(bounds.Dx(), maxWidth)
+	h := max(bounds.Dy()*w/bounds.Dx(), 1)
+
+	level := func(v uint32) int { return int(v*5+127) / 255 }
+	index := make([][]int, h)
+	used := make(map[int]bool)
+	for y := 0; y < h; y++ {
+		index[y] = make([]int, w)
+		for x := 0; x < w; x++ {
+			c := color.RGBAModel.Convert(img.At(bounds.Min.X+x*bounds.Dx()/w, bounds.Min.Y+y*bounds.Dy()/h)).(color.RGBA)
+			i := level(uint32(c.R))*36 + level(uint32(c.G))*6 + level(uint32(c.B))
+			index[y][x] = i
+			used[i] = true
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "\033Pq\"1;1;%d;%d", w, h)
+	for i := range used {
+		fmt.Fprintf(&b, "#%d;2;%d;%d;%d", i, i/36*20, i/6%6*20, i%6*20)
+	}
+	for band := 0; band < h; band += 6 {
+		for i := range used {
+			row := make([]byte, w)
+			any := false
+			for x := 0; x < w; x++ {
+				bits := 0
+				for dy := 0; dy < 6 && band+dy < h; dy++ {
+					if index[band+dy][x] == i {
+						bits |= 1 << dy
+					}
+				}
+				row[x] = byte(63 + bits)
+				any = any || bits != 0
+			}
+			if !any {
+				continue
+			}
+			fmt.Fprintf(&b, "#%d", i)
+			for x := 0; x < w; {
+				run := 1
+				for x+run < w && row[x+run] == row[x] {
+					run++
+				}
+				if run > 3 {
+					fmt.Fprintf(&b, "!%d%c", run, row[x])
+				} else {
+					b.WriteString(strings.Repeat(string(row[x]), run))
+				}
+				x += run
+			}
+			b.WriteByte('$')
+		}
+		b.WriteByte('-')
+	}
+	b.WriteString("\033\\")
+	return b.String()
+}
+
+// questionHTML renders a question for a web page, with code blocks,
+// the exhibit and images inline
+func questionHTML(q Question, showAnswer bool) string {
+	esc := html.EscapeString
+	var b strings.Builder
+	fmt.Fprintf(&b, "<section class=\"question\" id=\"%s\">\n", esc(q.ID))
+	fmt.Fprintf(&b, "<p class=\"meta\">%s · %s - %s</p>\n", esc(q.ID), esc(q.Category), esc(q.Module))
+	b.WriteString(markdownHTML(q.Question))
+	if q.Exhibit != "" {
+		fmt.Fprintf(&b, "<figure class=\"exhibit\"><figcaption>Exhibit</figcaption><pre>%s</pre></figure>\n", esc(q.Exhibit))
+	}
+	for _, img := range q.Images {
+		src := esc(img.File)
+		if path, ok := mediaPath(q, img.File); ok {
+			if data, err := os.ReadFile(path); err == nil {
+				src = imageDataURI(path, data)
+			}
+		}
+		fmt.Fprintf(&b, "<figure><img src=\"%s\" alt=\"%s\"><figcaption>%s</figcaption></figure>\n", src, esc(img.Alt), esc(img.Alt))
+	}
+	if q.Lab != nil {
+		b.WriteString(labHTML(q, showAnswer))
+	} else if len(q.Steps) > 0 {
+		b.WriteString(commandHTML(q, showAnswer))
+	}
+	if len(q.Options) > 0 {
+		b.WriteString("<ol>\n")
+	}
+	for i, opt := range q.Options {
+		if showAnswer && i == q.Answer {
+			fmt.Fprintf(&b, "<li class=\"answer\">%s ✓</li>\n", esc(opt))
+		} else {
+			fmt.Fprintf(&b, "<li>%s</li>\n", esc(opt))
+		}
+	}
+	if len(q.Options) > 0 {
+		b.WriteString("</ol>\n")
+	}
+	if showAnswer && q.Explanation != "" {
+		fmt.Fprintf(&b, "<div class=\"explanation\">\n%s</div>\n", markdownHTML(q.Explanation))
+	}
+	b.WriteString("</section>\n")
+	return b.String()
+}
+
+func imageDataURI(path string, data []byte) string {
+	mime := "image/png"
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jpg", ".jpeg":
+		mime = "image/jpeg"
+	case ".gif":
+		mime = "image/gif"
+	case ".svg":
+		mime = "image/svg+xml"
+	}
+	return "data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
+
+// questionsPageHTML is a self-contained page of questions
+func questionsPageHTML(title string, questions []Question, showAnswers bool) string {
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html lang=\"en\">\n<head>\n<meta charset=\"utf-8\">\n")
+	fmt.Fprintf(&b, "<title>%s</title>\n", html.EscapeString(title))
+	b.WriteString(`<style>
+body { font-family: sans-serif; max-width: 52em; margin: 2em auto; color: #222; }
+.question { border-bottom: 1px solid #ddd; padding: 1em 0; }
+.meta { color: #777; font-size: 0.85em; }
+pre { background: #f5f5f5; padding: 0.8em; overflow-x: auto; }
+.exhibit pre { background: #fffbe6; border: 1px solid #e6d98c; }
+figure { margin: 1em 0; }
+img { max-width: 100%; }
+.answer { font-weight: bold; color: #1a7f37; }
+.explanation { color: #555; }
+</style>
+</head>
+<body>
+`)
+	fmt.Fprintf(&b, "<h1>%s</h1>\n", html.EscapeString(title))
+	for _, q := range questions {
+		b.WriteString(questionHTML(q, showAnswers))
+	}
+	b.WriteString("</body>\n</html>\n")
+	return b.String()
+}
+
+// imageProblems checks that a question's images name a file and have alt
+// text or, when mediaDir is given, that the files are there
+func imageProblems(q Question, mediaDir string) []string {
+	var problems []string
+	for _, img := range q.Images {
+		switch {
+		case mediaDir != "":
+			if _, err := os.Stat(filepath.Join(mediaDir, filepath.FromSlash(img.File))); err != nil && img.File != "" {
+				problems = append(problems, fmt.Sprintf("question %s: image %s is not in %s", q.ID, img.File, mediaDir))
+			}
+		case img.File == "" || strings.Contains(img.File, ".."):
+			problems = append(problems, fmt.Sprintf("question %s: image %q must be a file in the media directory", q.ID, img.File))
+		case strings.TrimSpace(img.Alt) == "":
+			problems = append(problems, fmt.Sprintf("question %s: image %s needs alt text", q.ID, img.File))
+		}
+	}
+	return problems
+}
+
+// escapeLines writes multi-line text on one line for the edit form, with
+// newlines as \n and backslashes doubled
+func escapeLines(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+// unescapeLines reverses escapeLines
+func unescapeLines(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			switch s[i+1] {
+			case 'n':
+				b.WriteByte('\n')
+				i++
+				continue
+			case '\\':
+				b.WriteByte('\\')
+				i++
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// formatImages lists images for the edit form as "file: alt; file: alt"
+func formatImages(images []QuestionImage) string {
+	var parts []string
+	for _, img := range images {
+		parts = append(parts, img.File+": "+img.Alt)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// parseImages reads images written by formatImages
+func parseImages(s string) []QuestionImage {
+	var images []QuestionImage
+	for _, part := range strings.Split(s, ";") {
+		file, alt, _ := strings.Cut(part, ":")
+		if file = strings.TrimSpace(file); file != "" {
+			images = append(images, QuestionImage{File: file, Alt: strings.TrimSpace(alt)})
+		}
+	}
+	return images
+}
+
+// readBlock reads lines, keeping their indentation, up to one holding only
+// a dot
+func readBlock() string {
+	var lines []string
+	for {
+		line, err := reader.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+		if strings.TrimSpace(line) == "." || err != nil && line == "" {
+			break
+		}
+		lines = append(lines, line)
+		if err != nil {
+			break
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// promptMedia asks for an optional code block, exhibit and images while
+// adding a question
+func promptMedia(q *Question) {
+	printColor(ColorYellow, "Code block language, e.g. bash or python (Enter for none): ")
+	if lang := strings.ToLower(readInput()); lang != "" {
+		printColor(ColorCyan, "Enter the code, then a line with only a dot:\n")
+		if code := readBlock(); strings.TrimSpace(code) != "" {
+			q.Question += "\n\n```" + lang + "\n" + code + "\n```"
+		}
+	}
+
+	printColor(ColorYellow, "Add an exhibit, such as a log or config excerpt? (y/N): ")
+	if strings.ToLower(readInput()) == "y" {
+		printColor(ColorCyan, "Enter the exhibit, then a line with only a dot:\n")
+		q.Exhibit = strings.TrimRight(readBlock(), "\n")
+	}
+
+	mediaDir := filepath.Join(cacheDir, "media")
+	for {
+		printColor(ColorYellow, fmt.Sprintf("Image file in %s (Enter for none): ", mediaDir))
+		file := readInput()
+		if file == "" {
+			return
+		}
+		if _, ok := mediaPath(*q, file); !ok {
+			printColor(ColorRed, fmt.Sprintf("%s is not in %s.\n", file, mediaDir))
+			continue
+		}
+		alt := ""
+		for alt == "" {
+			printColor(ColorYellow, "Describe the image for screen readers: ")
+			alt = readInput()
+		}
+		q.Images = append(q.Images, QuestionImage{File: file, Alt: alt})
+	}
+}
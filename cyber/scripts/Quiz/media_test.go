@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testImage is a w×h image, white on the left half and black on the right
+func testImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if x < w/2 {
+				img.Set(x, y, color.White)
+			} else {
+				img.Set(x, y, color.Black)
+			}
+		}
+	}
+	return img
+}
+
+// writeMedia saves a PNG under the data directory's media folder
+func writeMedia(t *testing.T, file string, img image.Image) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(cacheDir, "media", filepath.FromSlash(file))
+	os.MkdirAll(filepath.Dir(path), 0755)
+	if err := os.WriteFile(path, b.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestExhibitLines(t *testing.T) {
+	useTheme(t, "none")
+	lines := exhibitLines("GET /admin HTTP/1.1\n\tHost: example\n", 80)
+	if len(lines) != 4 {
+		t.Fatalf("got %d lines, want a frame around 2", len(lines))
+	}
+	for _, l := range lines {
+		if w := displayWidth(l.text); w != displayWidth(lines[0].text) {
+			t.Errorf("%q is %d wide, the frame is %d", l.text, w, displayWidth(lines[0].text))
+		}
+	}
+	if !strings.Contains(lines[0].text, "Exhibit") || !strings.Contains(lines[2].text, "    Host: example") {
+		t.Errorf("frame:\n%v", lines)
+	}
+
+	// Long lines are cut to the terminal
+	narrow := exhibitLines(strings.Repeat("x", 100), 30)
+	if w := displayWidth(narrow[1].text); w != 30 {
+		t.Errorf("framed line is %d wide in a 30 column terminal", w)
+	}
+
+	screenReader = true
+	lines = exhibitLines("line one\nline two", 80)
+	got := make([]string, len(lines))
+	for i, l := range lines {
+		got[i] = l.text
+	}
+	if want := "Exhibit:|line one|line two|End of exhibit."; strings.Join(got, "|") != want {
+		t.Errorf("screen reader exhibit %q, want %q", strings.Join(got, "|"), want)
+	}
+}
+
+func TestMediaPath(t *testing.T) {
+	useDataDir(t)
+	writeMedia(t, "net/topology.png", testImage(4, 4))
+	bankDir := t.TempDir()
+	os.MkdirAll(filepath.Join(bankDir, "media"), 0755)
+	os.WriteFile(filepath.Join(bankDir, "media", "shared.png"), nil, 0644)
+	adminConfig.BankDir = bankDir
+
+	tests := []struct {
+		file string
+		want string // empty when not found
+	}{
+		{"net/topology.png", filepath.Join(cacheDir, "media", "net", "topology.png")},
+		{"shared.png", filepath.Join(bankDir, "media", "shared.png")},
+		{"missing.png", ""},
+		{"../admin.json", ""},
+		{"net/../../admin.json", ""},
+	}
+	for _, tt := range tests {
+		got, ok := mediaPath(Question{}, tt.file)
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("mediaPath(%q) = %q, %v; want %q", tt.file, got, ok, tt.want)
+		}
+	}
+}
+
+func TestArtSize(t *testing.T) {
+	tests := []struct {
+		w, h, cols         int
+		wantCols, wantRows int
+	}{
+		{100, 50, 40, 40, 10},
+		{20, 10, 40, 20, 5},    // never wider than the image
+		{100, 1000, 64, 4, 20}, // tall images are capped at maxArtRows
+		{1, 1, 40, 1, 1},
+	}
+	for _, tt := range tests {
+		cols, rows := artSize(image.Rect(0, 0, tt.w, tt.h), tt.cols)
+		if cols != tt.wantCols || rows != tt.wantRows {
+			t.Errorf("artSize(%dx%d, %d) = %d, %d; want %d, %d", tt.w, tt.h, tt.cols, cols, rows, tt.wantCols, tt.wantRows)
+		}
+	}
+}
+
+func TestCharacterArt(t *testing.T) {
+	img := testImage(40, 20)
+	art := asciiArt(img, 20)
+	if len(art) != 5 {
+		t.Fatalf("ascii art has %d rows, want 5", len(art))
+	}
+	for _, l := range art {
+		// White draws as the densest character and black as spaces,
+		// which are trimmed
+		if l.text != strings.Repeat("@", 10) {
+			t.Errorf("ascii row %q", l.text)
+		}
+	}
+
+	blocks := blockArt(img, 20)
+	if len(blocks) != 5 {
+		t.Fatalf("block art has %d rows, want 5", len(blocks))
+	}
+	row := blocks[0].text
+	if n := strings.Count(row, "▀"); n != 20 {
+		t.Errorf("block row has %d cells, want 20", n)
+	}
+	if !strings.HasPrefix(row, "\033[38;2;255;255;255m\033[48;2;255;255;255m▀") || !strings.HasSuffix(row, "\033[38;2;0;0;0m\033[48;2;0;0;0m▀\033[0m") {
+		t.Errorf("block row colours: %q", row)
+	}
+}
+
+func TestImageLines(t *testing.T) {
+	useDataDir(t)
+	useTheme(t, "none")
+	writeMedia(t, "diagram.png", testImage(40, 20))
+	img := QuestionImage{File: "diagram.png", Alt: "Two hosts behind a firewall"}
+
+	tests := []struct {
+		name   string
+		setup  func()
+		rows   int    // lines before the caption
+		inText string // expected in the last line
+	}{
+		{"ascii", func() { config.Images = "ascii" }, 10, "🖼 Two hosts behind a firewall"},
+		{"blocks", func() { config.Images = "blocks" }, 10, "🖼 Two hosts behind a firewall"},
+		{"off", func() { config.Images = "off" }, 0, "🖼 Two hosts behind a firewall"},
+		{"missing file", func() { config.Images, img.File = "ascii", "gone.png" }, 1, "🖼 Two hosts behind a firewall"},
+		{"screen reader", func() { screenReader = true }, 0, "Image: Two hosts behind a firewall"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setup()
+			// Tests don't run on a terminal, so pretend to
+			asciiOnly, dumbTerminal = false, false
+			lines := imageLines(Question{}, img, 80)
+			if len(lines) != tt.rows+1 {
+				t.Fatalf("got %d lines, want %d", len(lines), tt.rows+1)
+			}
+			last := lines[len(lines)-1].text
+			if tt.name == "missing file" {
+				last = lines[0].text
+				if !strings.Contains(lines[1].text, "gone.png not found") {
+					t.Errorf("error line %q", lines[1].text)
+				}
+			}
+			if last != tt.inText {
+				t.Errorf("caption %q, want %q", last, tt.inText)
+			}
+		})
+	}
+}
+
+func TestImageMode(t *testing.T) {
+	useConfig(t)
+	tests := []struct {
+		name   string
+		images string
+		setup  func()
+		want   string
+	}{
+		{"screen reader", "kitty", func() { screenReader = true }, "off"},
+		{"ascii terminal", "kitty", func() { asciiOnly = true }, "ascii"},
+		{"ascii terminal, images off", "off", func() { asciiOnly = true }, "off"},
+		{"configured", "sixel", func() {}, "sixel"},
+		{"kitty", "auto", func() { os.Setenv("KITTY_WINDOW_ID", "1") }, "kitty"},
+		{"iTerm", "auto", func() { os.Setenv("TERM_PROGRAM", "iTerm.app") }, "iterm"},
+		{"foot", "auto", func() { os.Setenv("TERM", "foot") }, "sixel"},
+		{"no colour", "auto", func() { activeTheme = "none" }, "ascii"},
+		{"other", "auto", func() {}, "blocks"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TERM", "xterm-256color")
+			t.Setenv("KITTY_WINDOW_ID", "")
+			t.Setenv("TERM_PROGRAM", "")
+			config.Images = tt.images
+			screenReader, asciiOnly, dumbTerminal, activeTheme = false, false, false, "default"
+			tt.setup()
+			if got := imageMode(); got != tt.want {
+				t.Errorf("imageMode() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGraphicsProtocols(t *testing.T) {
+	img := testImage(8, 12)
+	sixel := sixelImage(img, 4)
+	if !strings.HasPrefix(sixel, "\033Pq\"1;1;4;6") || !strings.HasSuffix(sixel, "\033\\") {
+		t.Errorf("sixel image is not scaled to 4×6: %q", sixel)
+	}
+	// Black and white from the colour cube
+	if !strings.Contains(sixel, "#0;2;0;0;0") || !strings.Contains(sixel, "#215;2;100;100;100") {
+		t.Errorf("sixel palette: %q", sixel)
+	}
+
+	kitty := kittyImage(img)
+	if !strings.HasPrefix(kitty, "\033_G") || !strings.HasSuffix(kitty, "\033\\") {
+		t.Errorf("kitty image: %q", kitty)
+	}
+}
+
+func TestQuestionHTML(t *testing.T) {
+	useDataDir(t)
+	data := writeMedia(t, "capture.png", testImage(2, 2))
+	q := Question{
+		ID: "q1", Category: "Web", Module: "XSS",
+		Question: "Which payload is **reflected**?",
+		Exhibit:  "<script>alert(1)</script>",
+		Images:   []QuestionImage{{File: "capture.png", Alt: `Burp "Repeater" tab`}, {File: "missing.png", Alt: "gone"}},
+		Options:  []string{"<b>", "&amp;"},
+		Answer:   1, Explanation: "Entities are *escaped*.",
+	}
+
+	tests := []struct {
+		showAnswer bool
+		want       []string
+		notWant    []string
+	}{
+		{false,
+			[]string{
+				"<strong>reflected</strong>",
+				"<pre>&lt;script&gt;alert(1)&lt;/script&gt;</pre>",
+				`<img src="` + imageDataURI("capture.png", data) + `" alt="Burp &#34;Repeater&#34; tab">`,
+				`<img src="missing.png" alt="gone">`,
+				"<li>&lt;b&gt;</li>", "<li>&amp;amp;</li>",
+			},
+			[]string{"<script>", "✓", "explanation"}},
+		{true,
+			[]string{`<li class="answer">&amp;amp; ✓</li>`, "<em>escaped</em>"},
+			nil},
+	}
+	for _, tt := range tests {
+		got := questionHTML(q, tt.showAnswer)
+		for _, want := range tt.want {
+			if !strings.Contains(got, want) {
+				t.Errorf("showAnswer=%v: missing %q in\n%s", tt.showAnswer, want, got)
+			}
+		}
+		for _, notWant := range tt.notWant {
+			if strings.Contains(got, notWant) {
+				t.Errorf("showAnswer=%v: unexpected %q in\n%s", tt.showAnswer, notWant, got)
+			}
+		}
+	}
+}
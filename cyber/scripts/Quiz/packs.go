@@ -0,0 +1,621 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Question packs are zip archives (.cqpack) for sharing curated banks:
+//
+//	manifest.json   name, version, author, licence, objectives and the
+//	                SHA-256 of every other file
+//	questions.json  the questions, in the plain bank format
+//	media/...       images and exhibits referenced by the questions
+//	signature.json  Ed25519 signature over manifest.json
+//
+// Installed questions are tagged with the pack name, so a module can be
+// traced to its pack and version, and upgrades replace the pack's questions
+// by ID so learner history stays linked.
+
+const (
+	packFormat    = "cyber-quiz-pack/v1"
+	packExtension = ".cqpack"
+	maxPackSize   = 64 << 20
+)
+
+var packNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._+-]*$`)
+
+// PackObjective is an entry of a pack's objectives catalogue
+type PackObjective struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+}
+
+// PackManifest describes a question pack
+type PackManifest struct {
+	Format      string            `json:"format"`
+	Name        string            `json:"name"`
+	Version     string            `json:"version"`
+	Author      string            `json:"author"`
+	Licence     string            `json:"licence"`
+	Description string            `json:"description,omitempty"`
+	Objectives  []PackObjective   `json:"objectives,omitempty"`
+	BuiltAt     time.Time         `json:"built_at"`
+	Files       map[string]string `json:"files"` // path -> SHA-256, filled in by pack build
+}
+
+type packSignature struct {
+	PublicKey string `json:"public_key"`
+	Signature string `json:"signature"`
+}
+
+// InstalledPack records a pack in packs.json
+type InstalledPack struct {
+	Name           string    `json:"name"`
+	Version        string    `json:"version"`
+	Author         string    `json:"author"`
+	Licence        string    `json:"licence"`
+	KeyFingerprint string    `json:"key_fingerprint"`
+	Questions      int       `json:"questions"`
+	InstalledAt    time.Time `json:"installed_at"`
+}
+
+var installedPacks []InstalledPack
+
+// packArchive is a pack read from disk whose signature and hashes checked out
+type packArchive struct {
+	manifest  PackManifest
+	questions []Question
+	files     map[string][]byte
+	publicKey ed25519.PublicKey
+}
+
+func loadPacks() {
+	installedPacks = nil
+	if data, err := os.ReadFile(packsFile); err == nil {
+		json.Unmarshal(data, &installedPacks)
+	}
+}
+
+func savePacks() {
+	data, _ := json.MarshalIndent(installedPacks, "", "  ")
+	os.WriteFile(packsFile, data, 0644)
+}
+
+func findPack(name string) *InstalledPack {
+	for i := range installedPacks {
+		if installedPacks[i].Name == name {
+			return &installedPacks[i]
+		}
+	}
+	return nil
+}
+
+// moduleSource names the packs and bank layers a module's questions came
+// from
+func moduleSource(category, module string) string {
+	seen := make(map[string]bool)
+	var sources []string
+	for _, q := range getQuestionsByModule(category, module) {
+		source := q.layer
+		if source == "" {
+			source = layerUser
+		}
+		if p := findPack(q.Pack); p != nil {
+			source = fmt.Sprintf("pack %s %s", p.Name, p.Version)
+		}
+		if !seen[source] {
+			seen[source] = true
+			sources = append(sources, source)
+		}
+	}
+	sort.Strings(sources)
+	return strings.Join(sources, " + ")
+}
+
+// compareVersions compares dotted versions numerically where possible
+func compareVersions(a, b string) int {
+	pa := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	pb := strings.Split(strings.TrimPrefix(b, "v"), ".")
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var x, y string
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		nx, errx := strconv.Atoi(x)
+		ny, erry := strconv.Atoi(y)
+		switch {
+		case errx == nil && erry == nil && nx != ny:
+			if nx < ny {
+				return -1
+			}
+			return 1
+		case (errx != nil || erry != nil) && x != y:
+			return strings.Compare(x, y)
+		}
+	}
+	return 0
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return fmt.Sprintf("%x", sum)
+}
+
+// validatePack checks the manifest and questions for mistakes
+func validatePack(m PackManifest, questions []Question) error {
+	var problems []string
+	if !packNamePattern.MatchString(m.Name) {
+		problems = append(problems, fmt.Sprintf("invalid pack name %q", m.Name))
+	}
+	if m.Version == "" {
+		problems = append(problems, "version is missing")
+	}
+	if m.Author == "" {
+		problems = append(problems, "author is missing")
+	}
+	if m.Licence == "" {
+		problems = append(problems, "licence is missing")
+	}
+
+	objectives := make(map[string]bool)
+	for _, o := range m.Objectives {
+		objectives[o.ID] = true
+	}
+	problems = append(problems, validateQuestions(questions, objectives)...)
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// validateQuestions lists mistakes in a set of questions. Objective IDs are
+// only checked against a non-nil catalogue.
+func validateQuestions(questions []Question, objectives map[string]bool) []string {
+	var problems []string
+	ids := make(map[string]bool)
+	for i, q := range questions {
+		label := fmt.Sprintf("question %d (%s)", i+1, q.ID)
+		switch {
+		case q.ID == "":
+			problems = append(problems, fmt.Sprintf("question %d has no ID", i+1))
+		case ids[q.ID]:
+			problems = append(problems, label+": duplicate ID")
+		}
+		ids[q.ID] = true
+
+		if q.Question == "" || q.Category == "" || q.Module == "" {
+			problems = append(problems, label+": question, category and module are required")
+		}
+		if q.Difficulty != "" && !anyOf([]string{q.Difficulty}, difficulties) {
+			problems = append(problems, fmt.Sprintf("question %s: difficulty must be one of %s", q.ID, strings.Join(difficulties, ", ")))
+		}
+		switch {
+		case q.Lab != nil:
+			problems = append(problems, labProblems(q)...)
+		case len(q.Steps) > 0:
+			problems = append(problems, stepProblems(q)...)
+		case q.Shell != "":
+			problems = append(problems, label+": shell is set but the question has no steps")
+		case len(q.Options) < 2 || q.Answer < 0 || q.Answer >= len(q.Options):
+			problems = append(problems, label+": needs at least two options and a valid answer")
+		}
+		for _, o := range q.Objectives {
+			if objectives != nil && !objectives[o] {
+				problems = append(problems, fmt.Sprintf("%s: objective %q is not in the catalogue", label, o))
+			}
+		}
+		problems = append(problems, imageProblems(q, "")...)
+	}
+	return problems
+}
+
+// buildPack signs the pack source in dir and writes the archive to out.
+// dir holds manifest.json, questions.json and an optional media directory.
+func buildPack(dir, out string) (string, PackManifest, error) {
+	var m PackManifest
+	data, err := os.ReadFile(filepath.Join(dir, "manifest.json"))
+	if err != nil {
+		return "", m, err
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return "", m, fmt.Errorf("manifest.json: %w", err)
+	}
+
+	data, err = os.ReadFile(filepath.Join(dir, "questions.json"))
+	if err != nil {
+		return "", m, err
+	}
+	bank, _, err := openBank(data)
+	if err != nil {
+		return "", m, fmt.Errorf("questions.json: %w", err)
+	}
+	for i := range bank.Questions {
+		bank.Questions[i].Pack = ""
+	}
+	if err := validatePack(m, bank.Questions); err != nil {
+		return "", m, fmt.Errorf("pack is not valid:\n  %w", err)
+	}
+	var missing []string
+	for _, q := range bank.Questions {
+		missing = append(missing, imageProblems(q, filepath.Join(dir, "media"))...)
+	}
+	if len(missing) > 0 {
+		return "", m, fmt.Errorf("pack is not valid:\n  %s", strings.Join(missing, "\n  "))
+	}
+
+	files := make(map[string][]byte)
+	files["questions.json"], _ = json.MarshalIndent(bank, "", "  ")
+
+	mediaDir := filepath.Join(dir, "media")
+	if _, err := os.Stat(mediaDir); err == nil {
+		err = filepath.WalkDir(mediaDir, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			rel, _ := filepath.Rel(dir, p)
+			files[filepath.ToSlash(rel)], err = os.ReadFile(p)
+			return err
+		})
+		if err != nil {
+			return "", m, err
+		}
+	}
+
+	m.Format = packFormat
+	m.BuiltAt = time.Now().UTC()
+	m.Files = make(map[string]string)
+	for name, content := range files {
+		m.Files[name] = sha256Hex(content)
+	}
+	manifest, _ := json.MarshalIndent(m, "", "  ")
+
+	key, err := signingKey()
+	if err != nil {
+		return "", m, fmt.Errorf("cannot open the signing key: %w", err)
+	}
+	sig, _ := json.MarshalIndent(packSignature{
+		PublicKey: base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)),
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key, manifest)),
+	}, "", "  ")
+
+	if out == "" {
+		out = fmt.Sprintf("%s-%s%s", m.Name, m.Version, packExtension)
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	names := []string{"manifest.json", "signature.json"}
+	files["manifest.json"], files["signature.json"] = manifest, sig
+	for name := range m.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names[2:])
+	for _, name := range names {
+		w, err := zw.Create(name)
+		if err != nil {
+			return "", m, err
+		}
+		w.Write(files[name])
+	}
+	if err := zw.Close(); err != nil {
+		return "", m, err
+	}
+
+	return out, m, os.WriteFile(out, buf.Bytes(), 0644)
+}
+
+// openPack reads a pack and checks its signature and file hashes. Whether
+// the signing key is trusted is up to the caller.
+func openPack(file string) (*packArchive, error) {
+	zr, err := zip.OpenReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	p := &packArchive{files: make(map[string][]byte)}
+	var total int64
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		name := path.Clean(f.Name)
+		if name != f.Name || path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") || strings.Contains(name, "\\") {
+			return nil, fmt.Errorf("unsafe path %q in pack", f.Name)
+		}
+		if total += int64(f.UncompressedSize64); total > maxPackSize {
+			return nil, errors.New("pack is too large")
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(io.LimitReader(rc, maxPackSize))
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		p.files[name] = data
+	}
+
+	manifest, sigData := p.files["manifest.json"], p.files["signature.json"]
+	if manifest == nil || sigData == nil {
+		return nil, errors.New("not a question pack: manifest or signature missing")
+	}
+
+	var sig packSignature
+	if err := json.Unmarshal(sigData, &sig); err != nil {
+		return nil, fmt.Errorf("signature.json: %w", err)
+	}
+	pub, err1 := base64.StdEncoding.DecodeString(sig.PublicKey)
+	signature, err2 := base64.StdEncoding.DecodeString(sig.Signature)
+	if err1 != nil || err2 != nil || len(pub) != ed25519.PublicKeySize {
+		return nil, errors.New("signature.json is malformed")
+	}
+	p.publicKey = pub
+	if !ed25519.Verify(p.publicKey, manifest, signature) {
+		return nil, errors.New("signature does not match the manifest")
+	}
+
+	if err := json.Unmarshal(manifest, &p.manifest); err != nil {
+		return nil, fmt.Errorf("manifest.json: %w", err)
+	}
+	if p.manifest.Format != packFormat {
+		return nil, fmt.Errorf("unsupported pack format %q", p.manifest.Format)
+	}
+
+	for name, data := range p.files {
+		if name == "manifest.json" || name == "signature.json" {
+			continue
+		}
+		want, listed := p.manifest.Files[name]
+		if !listed {
+			return nil, fmt.Errorf("%s is not listed in the manifest", name)
+		}
+		if sha256Hex(data) != want {
+			return nil, fmt.Errorf("%s was modified after signing", name)
+		}
+	}
+	for name := range p.manifest.Files {
+		if _, ok := p.files[name]; !ok {
+			return nil, fmt.Errorf("%s is missing from the pack", name)
+		}
+	}
+
+	var bank QuizData
+	if err := json.Unmarshal(p.files["questions.json"], &bank); err != nil {
+		return nil, fmt.Errorf("questions.json: %w", err)
+	}
+	p.questions = bank.Questions
+	if err := validatePack(p.manifest, p.questions); err != nil {
+		return nil, fmt.Errorf("pack is not valid:\n  %w", err)
+	}
+
+	return p, nil
+}
+
+// packKeyTrusted reports whether packs signed with pub may be installed
+// without confirmation: our own signing key or one the admin accepted
+func packKeyTrusted(pub ed25519.PublicKey) bool {
+	if own, err := signingPublicKey(); err == nil && own.Equal(pub) {
+		return true
+	}
+	encoded := base64.StdEncoding.EncodeToString(pub)
+	for _, k := range adminConfig.TrustedPackKeys {
+		if k == encoded {
+			return true
+		}
+	}
+	return false
+}
+
+// packInstallResult summarises what an install changed
+type packInstallResult struct {
+	previous                string
+	added, updated, removed int
+	duplicates              []string // new questions that look like ones outside the pack
+}
+
+// installPack adds or upgrades a verified pack. Questions keep their IDs
+// across versions, so attempts recorded against an older version still
+// link to the upgraded questions.
+func installPack(p *packArchive, trust, force bool) (packInstallResult, error) {
+	var res packInstallResult
+	m := p.manifest
+
+	if !packKeyTrusted(p.publicKey) {
+		if !trust {
+			return res, fmt.Errorf("pack is signed by an unknown key (fingerprint %s); check the fingerprint with the author and install with -trust",
+				keyFingerprint(p.publicKey))
+		}
+		adminConfig.TrustedPackKeys = append(adminConfig.TrustedPackKeys, base64.StdEncoding.EncodeToString(p.publicKey))
+		saveAdminConfig()
+	}
+
+	if old := findPack(m.Name); old != nil {
+		res.previous = old.Version
+		if compareVersions(m.Version, old.Version) < 0 && !force {
+			return res, fmt.Errorf("%s %s is older than the installed %s; use -force to downgrade", m.Name, m.Version, old.Version)
+		}
+	}
+
+	incoming := make(map[string]bool)
+	for _, q := range p.questions {
+		incoming[q.ID] = true
+	}
+	var conflicts []string
+	existing := make(map[string]bool)
+	for _, q := range quizData.Questions {
+		if q.Pack == m.Name {
+			existing[q.ID] = true
+		} else if incoming[q.ID] {
+			conflicts = append(conflicts, q.ID)
+		}
+	}
+	if len(conflicts) > 0 {
+		return res, fmt.Errorf("question IDs already used outside the pack: %s", strings.Join(conflicts, ", "))
+	}
+
+	// Unpack media before touching the bank so a failed write changes nothing
+	if err := unpackPackFiles(filepath.Join(cacheDir, "packs", m.Name), p.files); err != nil {
+		return res, err
+	}
+
+	var kept []Question
+	for _, q := range quizData.Questions {
+		if q.Pack != m.Name {
+			kept = append(kept, q)
+		}
+	}
+	var added []Question
+	for _, q := range p.questions {
+		if !existing[q.ID] {
+			added = append(added, q)
+		}
+	}
+	res.duplicates = duplicateWarnings(added, kept)
+	for _, q := range p.questions {
+		q.Pack = m.Name
+		kept = append(kept, q)
+		if existing[q.ID] {
+			res.updated++
+		} else {
+			res.added++
+		}
+	}
+	for id := range existing {
+		if !incoming[id] {
+			res.removed++
+		}
+	}
+	quizData.Questions = kept
+	saveQuestions()
+
+	entry := InstalledPack{
+		Name:           m.Name,
+		Version:        m.Version,
+		Author:         m.Author,
+		Licence:        m.Licence,
+		KeyFingerprint: keyFingerprint(p.publicKey),
+		Questions:      len(p.questions),
+		InstalledAt:    time.Now(),
+	}
+	if old := findPack(m.Name); old != nil {
+		*old = entry
+	} else {
+		installedPacks = append(installedPacks, entry)
+	}
+	savePacks()
+
+	return res, nil
+}
+
+// unpackPackFiles writes a pack's media into a directory beside dir and
+// then swaps it in, so the files already installed stay in place until
+// the new ones are all written
+func unpackPackFiles(dir string, files map[string][]byte) error {
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return err
+	}
+	staging, err := os.MkdirTemp(filepath.Dir(dir), "."+filepath.Base(dir)+"-new-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+
+	for name, data := range files {
+		if name == "questions.json" || name == "signature.json" {
+			continue
+		}
+		target := filepath.Join(staging, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(target, data, 0644); err != nil {
+			return err
+		}
+	}
+	if err := os.Chmod(staging, 0755); err != nil {
+		return err
+	}
+
+	old := staging + "-old"
+	if err := os.Rename(dir, old); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Rename(staging, dir); err != nil {
+		os.Rename(old, dir)
+		return err
+	}
+	return os.RemoveAll(old)
+}
+
+// uninstallPack removes a pack's questions and files. Learner attempts
+// are kept, so reinstalling the pack restores the history links.
+func uninstallPack(name string) (int, error) {
+	if findPack(name) == nil {
+		return 0, fmt.Errorf("pack %q is not installed", name)
+	}
+
+	var kept []Question
+	removed := 0
+	for _, q := range quizData.Questions {
+		if q.Pack == name {
+			removed++
+			continue
+		}
+		kept = append(kept, q)
+	}
+	quizData.Questions = kept
+	saveQuestions()
+
+	var packs []InstalledPack
+	for _, p := range installedPacks {
+		if p.Name != name {
+			packs = append(packs, p)
+		}
+	}
+	installedPacks = packs
+	savePacks()
+
+	return removed, os.RemoveAll(filepath.Join(cacheDir, "packs", name))
+}
+
+func printPackManifest(p *packArchive) {
+	m := p.manifest
+	printColor(ColorWhite, fmt.Sprintf("  Pack:      %s %s\n", m.Name, m.Version))
+	printColor(ColorWhite, fmt.Sprintf("  Author:    %s\n", m.Author))
+	printColor(ColorWhite, fmt.Sprintf("  Licence:   %s\n", m.Licence))
+	if m.Description != "" {
+		printColor(ColorWhite, fmt.Sprintf("  About:     %s\n", m.Description))
+	}
+	printColor(ColorWhite, fmt.Sprintf("  Built:     %s\n", m.BuiltAt.Local().Format(dateTimeLayout)))
+	printColor(ColorWhite, fmt.Sprintf("  Contents:  %d questions, %d objectives, %d files\n",
+		len(p.questions), len(m.Objectives), len(m.Files)))
+
+	trust := "trusted"
+	if !packKeyTrusted(p.publicKey) {
+		trust = "NOT trusted yet"
+	}
+	printColor(ColorCyan, fmt.Sprintf("  Signing key fingerprint: %s (%s)\n", keyFingerprint(p.publicKey), trust))
+}
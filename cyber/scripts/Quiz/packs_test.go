@@ -0,0 +1,174 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// packQuestion is a valid question for a pack
+func packQuestion(id, text string) Question {
+	return Question{ID: id, Question: text, Options: []string{"a", "b"}, Category: "Networking", Module: "Ports"}
+}
+
+// buildTestPack signs a pack of the given version with the admin key and
+// returns the archive's path
+func buildTestPack(t *testing.T, version string, media map[string]string, questions ...Question) string {
+	t.Helper()
+	dir := t.TempDir()
+	manifest, _ := json.Marshal(PackManifest{Name: "ports", Version: version, Author: "Ada", Licence: "CC-BY-4.0"})
+	bank, _ := json.Marshal(QuizData{Questions: questions})
+	os.WriteFile(filepath.Join(dir, "manifest.json"), manifest, 0644)
+	os.WriteFile(filepath.Join(dir, "questions.json"), bank, 0644)
+	for name, content := range media {
+		os.MkdirAll(filepath.Join(dir, "media"), 0755)
+		os.WriteFile(filepath.Join(dir, "media", name), []byte(content), 0644)
+	}
+	out, _, err := buildPack(dir, filepath.Join(t.TempDir(), "ports-"+version+packExtension))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// rewritePack copies a pack, changing or adding the named files
+func rewritePack(t *testing.T, file string, change map[string]string) string {
+	t.Helper()
+	zr, err := zip.OpenReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	out := filepath.Join(t.TempDir(), "changed"+packExtension)
+	f, _ := os.Create(out)
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for _, entry := range zr.File {
+		rc, _ := entry.Open()
+		w, _ := zw.Create(entry.Name)
+		if content, ok := change[entry.Name]; ok {
+			w.Write([]byte(content))
+			delete(change, entry.Name)
+		} else {
+			io.Copy(w, rc)
+		}
+		rc.Close()
+	}
+	for name, content := range change {
+		w, _ := zw.Create(name)
+		w.Write([]byte(content))
+	}
+	zw.Close()
+	return out
+}
+
+func TestOpenPack(t *testing.T) {
+	useDataDir(t)
+	adminPassword = "admin-password"
+	good := buildTestPack(t, "1.0.0", map[string]string{"diagram.txt": "R1 -- R2"}, packQuestion("p1", "Port of SSH?"))
+
+	p, err := openPack(good)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.manifest.Name != "ports" || len(p.questions) != 1 || string(p.files["media/diagram.txt"]) != "R1 -- R2" || !packKeyTrusted(p.publicKey) {
+		t.Errorf("opened pack %+v", p.manifest)
+	}
+
+	tests := []struct {
+		name   string
+		change map[string]string
+		want   string
+	}{
+		{"edited media", map[string]string{"media/diagram.txt": "R1 -- R3"}, "media/diagram.txt was modified after signing"},
+		{"extra file", map[string]string{"media/extra.txt": "x"}, "media/extra.txt is not listed in the manifest"},
+		{"edited manifest", map[string]string{"manifest.json": `{"format":"` + packFormat + `","name":"ports"}`}, "signature does not match the manifest"},
+		{"no signature", map[string]string{"signature.json": "{}"}, "signature.json is malformed"},
+		{"unsafe path", map[string]string{"../evil.txt": "x"}, "unsafe path"},
+	}
+	for _, tt := range tests {
+		if _, err := openPack(rewritePack(t, good, tt.change)); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestInstallPack(t *testing.T) {
+	useDataDir(t)
+	saved := installedPacks
+	t.Cleanup(func() { installedPacks = saved })
+	installedPacks = nil
+	adminPassword = "admin-password"
+	quizData.Questions = []Question{packQuestion("local1", "Which port does DNS use?")}
+	media := filepath.Join(cacheDir, "packs", "ports", "media")
+
+	v1 := buildTestPack(t, "1.0.0", map[string]string{"old.txt": "old"}, packQuestion("p1", "Port of SSH?"), packQuestion("p2", "Port of HTTP?"))
+	v2 := buildTestPack(t, "1.1.0", map[string]string{"new.txt": "new"}, packQuestion("p1", "Port of SSH, usually?"), packQuestion("p3", "Port of HTTPS?"))
+
+	// Packs signed by another key need -trust
+	own := adminConfig
+	adminConfig.SigningKey, adminConfig.SigningPublicKey = nil, ""
+	p, _ := openPack(v1)
+	if _, err := installPack(p, false, false); err == nil || !strings.Contains(err.Error(), "unknown key") {
+		t.Fatalf("untrusted pack: %v", err)
+	}
+	if _, err := installPack(p, true, false); err != nil || !packKeyTrusted(p.publicKey) {
+		t.Fatalf("trusted install: %v", err)
+	}
+	adminConfig.SigningKey, adminConfig.SigningPublicKey = own.SigningKey, own.SigningPublicKey
+
+	p, _ = openPack(v2)
+	res, err := installPack(p, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.previous != "1.0.0" || res.added != 1 || res.updated != 1 || res.removed != 1 {
+		t.Errorf("upgrade result %+v", res)
+	}
+	var ids []string
+	for _, q := range quizData.Questions {
+		ids = append(ids, q.ID+":"+q.Pack)
+	}
+	if strings.Join(ids, " ") != "local1: p1:ports p3:ports" {
+		t.Errorf("bank after upgrade %q", ids)
+	}
+	if _, err := os.Stat(filepath.Join(media, "old.txt")); !os.IsNotExist(err) {
+		t.Error("media of the old version was left behind")
+	}
+	if len(installedPacks) != 1 || installedPacks[0].Version != "1.1.0" {
+		t.Errorf("installed packs %+v", installedPacks)
+	}
+
+	// Downgrades need -force
+	p, _ = openPack(v1)
+	if _, err := installPack(p, false, false); err == nil || !strings.Contains(err.Error(), "older than the installed 1.1.0") {
+		t.Errorf("downgrade: %v", err)
+	}
+
+	// IDs used outside the pack are refused
+	p, _ = openPack(buildTestPack(t, "2.0.0", nil, packQuestion("local1", "Taken?")))
+	if _, err := installPack(p, false, false); err == nil || !strings.Contains(err.Error(), "already used outside the pack: local1") {
+		t.Errorf("conflicting IDs: %v", err)
+	}
+
+	// A failed unpack leaves the installed files and bank alone
+	p, _ = openPack(v2)
+	p.manifest.Version = "3.0.0"
+	p.files["media/new.txt/inside"] = []byte("cannot be written under a file")
+	if _, err := installPack(p, false, false); err == nil {
+		t.Fatal("install with an unwritable file succeeded")
+	}
+	if data, err := os.ReadFile(filepath.Join(media, "new.txt")); err != nil || string(data) != "new" {
+		t.Errorf("installed media after a failed install: %q, %v", data, err)
+	}
+	if installedPacks[0].Version != "1.1.0" || len(quizData.Questions) != 3 {
+		t.Errorf("failed install changed the bank: %+v", installedPacks)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(cacheDir, "packs")); len(entries) != 1 {
+		t.Errorf("staging directories left behind: %v", entries)
+	}
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A minimal PDF writer: A4 pages, the standard Helvetica fonts, text and
+// simple vector graphics. Enough for reports without external tools.
+// Coordinates passed to the drawing methods are measured from the top
+// left corner of the page.
+
+const (
+	pdfPageWidth  = 595.28
+	pdfPageHeight = 841.89
+	pdfMargin     = 50.0
+)
+
+type pdfDoc struct {
+	pages []*bytes.Buffer
+	info  map[string]string
+	y     float64 // flow layout cursor
+}
+
+func newPDF() *pdfDoc {
+	d := &pdfDoc{info: map[string]string{"Producer": "cyber-quiz"}}
+	d.addPage()
+	return d
+}
+
+func (d *pdfDoc) addPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+	d.y = pdfMargin
+}
+
+func (d *pdfDoc) page() *bytes.Buffer {
+	return d.pages[len(d.pages)-1]
+}
+
+func (d *pdfDoc) setInfo(key, value string) {
+	d.info[key] = value
+}
+
+// text draws a string with its baseline at (x, y)
+func (d *pdfDoc) text(x, y, size float64, bold bool, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(d.page(), "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, pdfPageHeight-y, pdfEscape(s))
+}
+
+// setColor sets the fill and stroke colour from 0-255 components
+func (d *pdfDoc) setColor(r, g, b int) {
+	fr, fg, fb := float64(r)/255, float64(g)/255, float64(b)/255
+	fmt.Fprintf(d.page(), "%.3f %.3f %.3f rg %.3f %.3f %.3f RG\n", fr, fg, fb, fr, fg, fb)
+}
+
+func (d *pdfDoc) line(x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(d.page(), "%.2f w %.2f %.2f m %.2f %.2f l S\n", width, x1, pdfPageHeight-y1, x2, pdfPageHeight-y2)
+}
+
+func (d *pdfDoc) rect(x, y, w, h float64, fill bool) {
+	op := "S"
+	if fill {
+		op = "f"
+	}
+	fmt.Fprintf(d.page(), "%.2f %.2f %.2f %.2f re %s\n", x, pdfPageHeight-y-h, w, h, op)
+}
+
+func (d *pdfDoc) polyline(points [][2]float64, width float64) {
+	if len(points) < 2 {
+		return
+	}
+	fmt.Fprintf(d.page(), "%.2f w %.2f %.2f m", width, points[0][0], pdfPageHeight-points[0][1])
+	for _, p := range points[1:] {
+		fmt.Fprintf(d.page(), " %.2f %.2f l", p[0], pdfPageHeight-p[1])
+	}
+	d.page().WriteString(" S\n")
+}
+
+// textWidth estimates the width of Helvetica text
+func pdfTextWidth(s string, size float64) float64 {
+	return float64(len([]rune(s))) * size * 0.52
+}
+
+// ensure starts a new page if the next block would not fit
+func (d *pdfDoc) ensure(height float64) {
+	if d.y+height > pdfPageHeight-pdfMargin {
+		d.addPage()
+	}
+}
+
+func (d *pdfDoc) space(height float64) {
+	d.y += height
+}
+
+// para writes word-wrapped text at the flow cursor
+func (d *pdfDoc) para(size float64, bold bool, s string) {
+	maxChars := int((pdfPageWidth - 2*pdfMargin) / (size * 0.52))
+	for _, line := range wrapText(s, maxChars) {
+		d.ensure(size * 1.4)
+		d.y += size * 1.2
+		d.text(pdfMargin, d.y, size, bold, line)
+		d.y += size * 0.2
+	}
+}
+
+// row writes one table row with cells starting at the given x offsets
+func (d *pdfDoc) row(cols []float64, size float64, bold bool, cells []string) {
+	d.ensure(size * 1.6)
+	d.y += size * 1.3
+	for i, cell := range cells {
+		width := pdfPageWidth - pdfMargin - cols[i]
+		if i+1 < len(cols) {
+			width = cols[i+1] - cols[i] - 4
+		}
+		d.text(cols[i], d.y, size, bold, fit(cell, int(width/(size*0.52))))
+	}
+	d.y += size * 0.3
+}
+
+// pdfEscape converts text to WinAnsi bytes and escapes string delimiters
+func pdfEscape(s string) string {
+	replacements := map[rune]byte{
+		'€': 0x80, '…': 0x85, '‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94,
+		'•': 0x95, '–': 0x96, '—': 0x97, '™': 0x99,
+	}
+
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteByte(byte(r))
+		case r >= 0x20 && r < 0x7f, r >= 0xa0 && r <= 0xff:
+			b.WriteByte(byte(r))
+		case replacements[r] != 0:
+			b.WriteByte(replacements[r])
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// bytes assembles the PDF file
+func (d *pdfDoc) bytes() []byte {
+	var out bytes.Buffer
+	var offsets []int
+	obj := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// 1 catalog, 2 page tree, 3-4 fonts, 5 info, then a page and content
+	// stream object for each page
+	var kids []string
+	for i := range d.pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 6+2*i))
+	}
+
+	obj("<< /Type /Catalog /Pages 2 0 R >>")
+	obj(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+
+	keys := make([]string, 0, len(d.info))
+	for k := range d.info {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var info strings.Builder
+	info.WriteString("<<")
+	for _, k := range keys {
+		fmt.Fprintf(&info, " /%s (%s)", k, pdfEscape(d.info[k]))
+	}
+	info.WriteString(" >>")
+	obj(info.String())
+
+	for i, p := range d.pages {
+		obj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, 7+2*i))
+		obj(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", p.Len(), p.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return out.Bytes()
+}
+
+// trendChart draws a line chart of attempt scores in a w by h box at (x, y)
+func (d *pdfDoc) trendChart(x, y, w, h float64, title string, trend []float64) {
+	d.setColor(0, 0, 0)
+	d.text(x, y+10, 9, true, fit(title, int(w/(9*0.52))))
+
+	top, bottom := y+18, y+h-12
+	d.setColor(220, 220, 220)
+	for _, level := range []float64{0, 50, 100} {
+		ly := bottom - level/100*(bottom-top)
+		d.line(x+18, ly, x+w, ly, 0.5)
+	}
+	d.setColor(120, 120, 120)
+	for _, level := range []float64{0, 50, 100} {
+		ly := bottom - level/100*(bottom-top)
+		d.text(x, ly+3, 7, false, fmt.Sprintf("%.0f", level))
+	}
+	d.text(x+18, y+h, 7, false, fmt.Sprintf("attempts (%d)", len(trend)))
+
+	var points [][2]float64
+	for i, v := range trend {
+		px := x + 22
+		if len(trend) > 1 {
+			px = x + 22 + float64(i)/float64(len(trend)-1)*(w-26)
+		}
+		points = append(points, [2]float64{px, bottom - v/100*(bottom-top)})
+	}
+
+	d.setColor(10, 109, 143)
+	d.polyline(points, 1.5)
+	for _, p := range points {
+		d.rect(p[0]-2, p[1]-2, 4, 4, true)
+	}
+	d.setColor(0, 0, 0)
+}
+
+func reportPDF(r ProgressReport) []byte {
+	d := newPDF()
+	d.setInfo("Title", r.Title)
+
+	d.setColor(10, 109, 143)
+	d.para(18, true, r.Title)
+	d.setColor(120, 120, 120)
+	d.para(9, false, "Generated "+r.Generated.Format(dateTimeLayout))
+	d.setColor(0, 0, 0)
+
+	cols := []float64{pdfMargin, pdfMargin + 190, pdfMargin + 245, pdfMargin + 290, pdfMargin + 340, pdfMargin + 400}
+
+	for _, l := range r.Learners {
+		d.space(14)
+		d.ensure(80)
+		d.para(14, true, fmt.Sprintf("%s (%s)", l.Name, l.UserID))
+		d.para(10, false, fmt.Sprintf("%d attempts, %s spent", l.Attempts, formatDuration(l.TimeSpent)))
+		if note := tamperedNote(l); note != "" {
+			d.setColor(192, 57, 43)
+			d.para(10, true, note)
+			d.setColor(0, 0, 0)
+		}
+
+		if len(l.Modules) == 0 {
+			d.para(10, false, "No quizzes taken yet.")
+			continue
+		}
+
+		d.space(4)
+		d.row(cols, 9, true, []string{"Module", "Attempts", "Best", "Latest", "Time spent", "Last taken"})
+		for _, m := range l.Modules {
+			d.row(cols, 9, false, []string{
+				m.Category + " - " + m.Module,
+				fmt.Sprintf("%d", m.Attempts),
+				fmt.Sprintf("%.0f%%", m.Best),
+				fmt.Sprintf("%.0f%%", m.Latest),
+				formatDuration(m.TimeSpent),
+				formatDate(m.LastTaken),
+			})
+		}
+
+		// Trend charts, two per row
+		const chartW, chartH = 230.0, 110.0
+		for i, m := range l.Modules {
+			if i%2 == 0 {
+				d.space(10)
+				d.ensure(chartH)
+			}
+			x := pdfMargin + float64(i%2)*(chartW+35)
+			d.trendChart(x, d.y, chartW, chartH, m.Category+" - "+m.Module, m.Trend)
+			if i%2 == 1 || i == len(l.Modules)-1 {
+				d.space(chartH)
+			}
+		}
+
+		d.space(8)
+		d.para(11, true, "Weakest topics")
+		for _, m := range l.Weakest {
+			d.para(10, false, fmt.Sprintf("• %s - %s: %.0f%% accuracy", m.Category, m.Module, m.Accuracy()))
+		}
+		if len(l.Missed) > 0 {
+			d.space(4)
+			d.para(11, true, "Most missed questions")
+			for _, q := range l.Missed {
+				d.para(10, false, fmt.Sprintf("• [%s] %s (missed %d times)", q.ID, stemSummary(q.Question), q.Misses))
+			}
+		}
+	}
+
+	return d.bytes()
+}
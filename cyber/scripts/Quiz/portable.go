@@ -0,0 +1,354 @@
+package main
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Portable mode keeps all data in a directory next to the binary, so a
+// USB drive carries its learners' progress between machines. It is turned
+// on by the -portable flag or a marker file beside the executable. When
+// the drive is read-only the host data directory is used instead.
+
+const (
+	portableMarker  = "cyber-quiz.portable"
+	portableDataDir = "cyber-quiz-data"
+)
+
+var (
+	portableMode bool
+
+	// dataDirNotice explains why the data directory differs from the one
+	// asked for
+	dataDirNotice string
+)
+
+// hostDataDir is the data directory of a normal installation
+func hostDataDir() string {
+	homeDir, err := os.UserCacheDir()
+	if err != nil {
+		homeDir, _ = os.UserHomeDir()
+		return filepath.Join(homeDir, ".cyber-quiz")
+	}
+	return filepath.Join(homeDir, "cyber-quiz")
+}
+
+func executableDir() string {
+	exe, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	return filepath.Dir(exe)
+}
+
+// portableDir returns the portable data directory if portable mode was
+// asked for, by flag or marker file
+func portableDir(requested bool) string {
+	dir := executableDir()
+	if dir == "" {
+		return ""
+	}
+	if !requested {
+		if _, err := os.Stat(filepath.Join(dir, portableMarker)); err != nil {
+			return ""
+		}
+	}
+	return filepath.Join(dir, portableDataDir)
+}
+
+// dirWritable reports whether files can be created in dir, creating it if
+// needed. Read-only media fail here.
+func dirWritable(dir string) bool {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return false
+	}
+	f, err := os.CreateTemp(dir, ".write-test-*")
+	if err != nil {
+		return false
+	}
+	name := f.Name()
+	f.Close()
+	os.Remove(name)
+	return true
+}
+
+// mergeReport summarises what mergeDataDir changed
+type mergeReport struct {
+	usersAdded, usersMerged int
+	usersSkipped            []string // users whose local records failed the integrity check
+	usersRejected           []string // users whose incoming records do not match their chain head
+	attemptsAdded           int
+	scoresUpdated           int
+	skipped                 int // tampered or unsigned records left out
+}
+
+// mergeDataDir merges learner progress from another data directory, such
+// as a portable drive used with the same profiles, into this one. Only
+// records that pass the other directory's integrity check are taken; they
+// are re-signed with this installation's key. With both set the merged
+// result is written back to the other directory as well.
+func mergeDataDir(other string, both bool) (mergeReport, error) {
+	var report mergeReport
+
+	if abs, err := filepath.Abs(other); err == nil {
+		other = abs
+	}
+	if other == cacheDir {
+		return report, fmt.Errorf("%s is the current data directory", other)
+	}
+
+	var otherAdmin AdminConfig
+	data, err := os.ReadFile(filepath.Join(other, "admin.json"))
+	if err != nil {
+		return report, err
+	}
+	if err := json.Unmarshal(data, &otherAdmin); err != nil {
+		return report, fmt.Errorf("admin.json: %w", err)
+	}
+
+	var otherUsers []User
+	data, err = os.ReadFile(filepath.Join(other, "users.json"))
+	if err != nil {
+		return report, err
+	}
+	if err := json.Unmarshal(data, &otherUsers); err != nil {
+		return report, fmt.Errorf("users.json: %w", err)
+	}
+	otherKey, err := otherIntegrityKey(otherAdmin)
+	if err != nil {
+		return report, err
+	}
+	withIntegrityConfig(otherAdmin, otherKey, func() {
+		for i := range otherUsers {
+			verifyUserRecords(&otherUsers[i])
+		}
+	})
+
+	users := mergeUsers(loadAllUsers(), otherUsers, &report)
+	saveAllUsers(users)
+
+	if both {
+		// Users that were not merged keep their record in the other
+		// directory; the old file is kept as users.json.bak since unsigned
+		// records there are not carried over
+		var out []User
+		if otherAdmin.IntegrityStates == nil {
+			otherAdmin.IntegrityStates = make(map[string]IntegrityState)
+		}
+		withIntegrityConfig(otherAdmin, otherKey, func() {
+			for _, u := range users {
+				if len(u.integrityIssues) == 0 {
+					rechainUser(&u)
+					out = append(out, u)
+
+					// Our legacy records go over unsigned, so the other
+					// directory must expect them
+					state, ok := otherAdmin.IntegrityStates[u.ID]
+					if !ok {
+						state.Since = time.Now().UTC()
+					}
+					state.Legacy = max(state.Legacy, unsignedCount(u))
+					otherAdmin.IntegrityStates[u.ID] = state
+				}
+			}
+			if otherKey != nil {
+				otherAdmin.IntegrityStatesMAC = integrityStatesMAC(otherAdmin.IntegrityStates)
+			}
+		})
+		for _, theirs := range otherUsers {
+			for _, id := range report.usersSkipped {
+				if theirs.ID == id {
+					out = append(out, theirs)
+				}
+			}
+		}
+
+		path := filepath.Join(other, "users.json")
+		if err := os.Rename(path, path+".bak"); err != nil {
+			return report, err
+		}
+		data, _ := json.MarshalIndent(out, "", "  ")
+		if err := writePrivateFile(path, data); err != nil {
+			return report, err
+		}
+		data, _ = json.MarshalIndent(otherAdmin, "", "  ")
+		if err := writePrivateFile(filepath.Join(other, "admin.json"), data); err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
+
+// mergeUsers merges verified progress of other users into users, matching
+// profiles by ID. Users whose local records fail the integrity check are
+// left alone, since re-signing would hide the tampering.
+func mergeUsers(users, others []User, report *mergeReport) []User {
+	index := make(map[string]int)
+	for i, u := range users {
+		index[u.ID] = i
+	}
+
+	for _, theirs := range others {
+		i, exists := index[theirs.ID]
+		if !exists {
+			users = append(users, User{
+				ID:        theirs.ID,
+				Name:      theirs.Name,
+				CreatedAt: theirs.CreatedAt,
+				Scores:    make(map[string]map[string]Score),
+				Groups:    theirs.Groups,
+			})
+			i = len(users) - 1
+			index[theirs.ID] = i
+			report.usersAdded++
+		} else if len(users[i].integrityIssues) > 0 {
+			report.usersSkipped = append(report.usersSkipped, theirs.ID)
+			continue
+		} else {
+			report.usersMerged++
+		}
+		mergeUser(&users[i], theirs, report)
+	}
+
+	return users
+}
+
+// mergeUser adds their verified attempts and newer scores to ours and
+// re-signs the result
+func mergeUser(ours *User, theirs User, report *mergeReport) {
+	if theirs.CreatedAt.Before(ours.CreatedAt) {
+		ours.CreatedAt = theirs.CreatedAt
+	}
+	for _, g := range theirs.Groups {
+		if !inGroup(*ours, g) {
+			ours.Groups = append(ours.Groups, g)
+		}
+	}
+
+	// The attempt log is a grow-only set keyed by attempt ID. Our unsigned
+	// legacy attempts stay first and unsigned; signed ones from both sides
+	// follow in time order
+	var legacy, signed []Attempt
+	seen := make(map[string]bool)
+	for _, a := range ours.Attempts {
+		seen[attemptID(a)] = true
+		if a.MAC == "" && !a.tampered {
+			legacy = append(legacy, a)
+		} else {
+			signed = append(signed, a)
+		}
+	}
+	for _, a := range theirs.Attempts {
+		if a.MAC == "" || a.tampered {
+			report.skipped++
+			continue
+		}
+		if seen[attemptID(a)] {
+			continue
+		}
+		seen[attemptID(a)] = true
+		signed = append(signed, a)
+		report.attemptsAdded++
+	}
+	// Sorting by time and ID gives every device the same order, so merges
+	// converge whichever way round they happen
+	sort.SliceStable(signed, func(i, j int) bool {
+		if !signed[i].FinishedAt.Equal(signed[j].FinishedAt) {
+			return signed[i].FinishedAt.Before(signed[j].FinishedAt)
+		}
+		return attemptID(signed[i]) < attemptID(signed[j])
+	})
+	ours.Attempts = append(legacy, signed...)
+
+	if ours.Scores == nil {
+		ours.Scores = make(map[string]map[string]Score)
+	}
+	for category, modules := range theirs.Scores {
+		for module, s := range modules {
+			if s.MAC == "" || s.tampered {
+				report.skipped++
+				continue
+			}
+			if ours.Scores[category] == nil {
+				ours.Scores[category] = make(map[string]Score)
+			}
+			if mine, ok := ours.Scores[category][module]; ok && !mine.LastTaken.Before(s.LastTaken) {
+				continue
+			}
+			ours.Scores[category][module] = s
+			report.scoresUpdated++
+		}
+	}
+
+	rechainUser(ours)
+}
+
+// rechainUser re-signs a user's signed attempts and scores with the
+// current integrity key, leaving unsigned legacy records as they are
+func rechainUser(u *User) {
+	prev := ""
+	for i := range u.Attempts {
+		a := &u.Attempts[i]
+		if a.MAC == "" {
+			prev = ""
+			continue
+		}
+		a.Prev = prev
+		a.MAC = attemptMAC(u.ID, prev, *a)
+		a.tampered = false
+		prev = a.MAC
+	}
+	sealChainHead(u)
+
+	for category, modules := range u.Scores {
+		for module, s := range modules {
+			if s.MAC != "" {
+				s.MAC = scoreMAC(u.ID, category, module, s)
+				s.tampered = false
+				modules[module] = s
+			}
+		}
+	}
+	u.integrityIssues = nil
+}
+
+// otherIntegrityKey unseals another installation's integrity key. Copies
+// of a data directory share the admin password, so the one typed this
+// session is tried. It returns nil if the other one never signed records.
+func otherIntegrityKey(other AdminConfig) ([]byte, error) {
+	if other.SealedIntegrityKey != nil {
+		key, err := unseal(adminPassword, other.SealedIntegrityKey)
+		if err != nil {
+			return nil, errors.New("the other directory's integrity key is sealed with a different admin password")
+		}
+		return key, nil
+	}
+	if other.IntegrityKey != "" {
+		return base64.StdEncoding.DecodeString(other.IntegrityKey)
+	}
+	return nil, nil
+}
+
+// withIntegrityConfig runs fn with another installation's integrity key
+// and signing records
+func withIntegrityConfig(other AdminConfig, key []byte, fn func()) {
+	savedKey, savedStates, savedEdited := integrityKey, adminConfig.IntegrityStates, integrityStatesEdited
+	integrityKey, adminConfig.IntegrityStates = key, other.IntegrityStates
+	integrityStatesEdited = key != nil && other.IntegrityStatesMAC != "" &&
+		!hmac.Equal([]byte(other.IntegrityStatesMAC), []byte(integrityStatesMAC(other.IntegrityStates)))
+	defer func() {
+		integrityKey, adminConfig.IntegrityStates, integrityStatesEdited = savedKey, savedStates, savedEdited
+	}()
+	fn()
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// useInstallation switches the data files to dir and unlocks its own
+// integrity key with the shared test admin password
+func useInstallation(t *testing.T, dir string) {
+	t.Helper()
+	setupCacheDirectory(dir, false, "")
+	adminConfig, integrityKey, integrityStatesEdited = AdminConfig{}, nil, false
+	if data, err := os.ReadFile(adminFile); err == nil {
+		json.Unmarshal(data, &adminConfig)
+	}
+	adminPassword = "admin-password"
+	adminConfig.PasswordHash = newPasswordHash(adminPassword)
+	useIntegrityKey(t)
+}
+
+// saveTracked signs the users' records from now on and saves them
+func saveTracked(users ...User) {
+	for i := range users {
+		trackIntegrity(&users[i])
+	}
+	saveAllUsers(users)
+	saveAdminConfig()
+}
+
+func TestMergeDataDir(t *testing.T) {
+	host := useDataDir(t)
+	drive := t.TempDir()
+
+	// On the drive Ada took two quizzes and Carol one, whose answers were
+	// edited afterwards
+	useInstallation(t, drive)
+	ada := User{ID: "01HADA0000000000000000000", Name: "Ada"}
+	recordAttempt(&ada, "shared", 0)
+	recordAttempt(&ada, "drive1", 20)
+	carol := User{ID: "01HCAROL000000000000000000", Name: "Carol"}
+	recordAttempt(&carol, "c1", 5)
+	recordAttempt(&carol, "c2", 6)
+	carol.Attempts[1].Answers[0].Chosen = 3
+	saveTracked(ada, carol)
+
+	// On the host Ada took the shared quiz and another one
+	useInstallation(t, host)
+	hostAda := User{ID: ada.ID, Name: "Ada"}
+	recordAttempt(&hostAda, "shared", 0)
+	recordAttempt(&hostAda, "host1", 10)
+	saveTracked(hostAda)
+
+	if _, err := mergeDataDir(host, false); err == nil {
+		t.Error("merged the data directory into itself")
+	}
+	report, err := mergeDataDir(drive, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.usersMerged != 1 || report.usersAdded != 1 || report.attemptsAdded != 2 || report.skipped != 1 {
+		t.Errorf("report %+v", report)
+	}
+	want := map[string][]string{ada.ID: {"shared", "host1", "drive1"}, carol.ID: {"c1"}}
+	checkUsers := func(where string) {
+		t.Helper()
+		users := loadAllUsers()
+		if len(users) != 2 {
+			t.Fatalf("%s: %d users", where, len(users))
+		}
+		for _, u := range users {
+			if got := attemptIDs(u); !reflect.DeepEqual(got, want[u.ID]) || len(u.integrityIssues) > 0 {
+				t.Errorf("%s: %s has %v, issues %v; want %v", where, u.Name, got, u.integrityIssues, want[u.ID])
+			}
+		}
+	}
+	checkUsers("host")
+
+	// With -both the drive gets the same history, signed with its own key
+	if _, err := os.Stat(filepath.Join(drive, "users.json.bak")); err != nil {
+		t.Error("the drive's old users.json was not kept")
+	}
+	useInstallation(t, drive)
+	checkUsers("drive")
+}
+
+func TestMergeDataDirPassword(t *testing.T) {
+	host := useDataDir(t)
+	drive := t.TempDir()
+	useInstallation(t, drive)
+	saveTracked(User{ID: "01HADA0000000000000000000", Name: "Ada"})
+
+	useInstallation(t, host)
+	adminPassword = "another-password"
+	if _, err := mergeDataDir(drive, false); err == nil || !strings.Contains(err.Error(), "different admin password") {
+		t.Errorf("merge with another password: %v", err)
+	}
+}
+
+func TestDirWritable(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "new", "data")
+	if !dirWritable(dir) {
+		t.Fatal("a new directory is not writable")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("the write test left %v behind", entries)
+	}
+	file := filepath.Join(dir, "file")
+	os.WriteFile(file, nil, 0644)
+	if dirWritable(filepath.Join(file, "data")) {
+		t.Error("a directory under a file is writable")
+	}
+}
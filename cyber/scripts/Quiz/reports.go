@@ -0,0 +1,523 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Progress reports for a single learner or a whole group, exported as
+// CSV for spreadsheets, Markdown for the wiki, a self-contained HTML page
+// with inline SVG charts, or a PDF.
+
+// ModuleProgress summarises a learner's attempts at one module
+type ModuleProgress struct {
+	Category  string
+	Module    string
+	Attempts  int
+	Best      float64 // percentage
+	Latest    float64 // percentage
+	LastTaken time.Time
+	TimeSpent time.Duration
+	Trend     []float64 // percentage of each attempt, oldest first
+	Answered  int
+	Correct   int
+}
+
+// Accuracy is the share of answered questions that were correct
+func (m ModuleProgress) Accuracy() float64 {
+	if m.Answered == 0 {
+		return m.Best
+	}
+	return float64(m.Correct) / float64(m.Answered) * 100
+}
+
+// MissedQuestion is a question the learner has answered wrongly
+type MissedQuestion struct {
+	ID       string
+	Question string
+	Misses   int
+}
+
+// LearnerReport is the progress of one user
+type LearnerReport struct {
+	UserID    string
+	Name      string
+	Modules   []ModuleProgress
+	Attempts  int
+	TimeSpent time.Duration
+	Weakest   []ModuleProgress
+	Missed    []MissedQuestion
+	Tampered  int // records left out because they failed the integrity check
+}
+
+// ProgressReport covers one learner or every member of a group
+type ProgressReport struct {
+	Title     string
+	Generated time.Time
+	Learners  []LearnerReport
+}
+
+func buildLearnerReport(u User) LearnerReport {
+	r := LearnerReport{UserID: u.ID, Name: u.Name}
+	modules := make(map[string]*ModuleProgress)
+	var order []string
+	misses := make(map[string]int)
+
+	for _, a := range u.Attempts {
+		if a.tampered {
+			r.Tampered++
+			continue
+		}
+		if len(a.Answers) == 0 {
+			continue
+		}
+		for _, ans := range a.Answers {
+			if !ans.Correct {
+				misses[ans.QuestionID]++
+			}
+		}
+		// a custom set of questions set as an assignment has no module
+		if a.Module == "" {
+			continue
+		}
+
+		key := a.Category + "\x00" + a.Module
+		m := modules[key]
+		if m == nil {
+			m = &ModuleProgress{Category: a.Category, Module: a.Module}
+			modules[key] = m
+			order = append(order, key)
+		}
+
+		pct := a.Percent()
+		m.Attempts++
+		m.Trend = append(m.Trend, pct)
+		m.Latest = pct
+		if pct > m.Best {
+			m.Best = pct
+		}
+		m.LastTaken = a.FinishedAt
+		if !a.StartedAt.IsZero() && a.FinishedAt.After(a.StartedAt) {
+			m.TimeSpent += a.FinishedAt.Sub(a.StartedAt)
+		}
+		m.Answered += len(a.Answers)
+		m.Correct += a.Correct()
+	}
+
+	// Scores recorded before attempts were kept count as a single attempt
+	for category, mods := range u.Scores {
+		for module, score := range mods {
+			key := category + "\x00" + module
+			if score.tampered {
+				r.Tampered++
+				continue
+			}
+			if modules[key] != nil || score.Total == 0 {
+				continue
+			}
+			pct := score.Percent()
+			modules[key] = &ModuleProgress{
+				Category: category, Module: module, Attempts: 1,
+				Best: pct, Latest: pct, LastTaken: score.LastTaken, Trend: []float64{pct},
+			}
+			order = append(order, key)
+		}
+	}
+
+	sort.Strings(order)
+	for _, key := range order {
+		m := *modules[key]
+		r.Modules = append(r.Modules, m)
+		r.Attempts += m.Attempts
+		r.TimeSpent += m.TimeSpent
+	}
+
+	r.Weakest = append([]ModuleProgress(nil), r.Modules...)
+	sort.SliceStable(r.Weakest, func(i, j int) bool { return r.Weakest[i].Accuracy() < r.Weakest[j].Accuracy() })
+	if len(r.Weakest) > 3 {
+		r.Weakest = r.Weakest[:3]
+	}
+
+	for id, count := range misses {
+		text := "(question no longer in the bank)"
+		for _, q := range quizData.Questions {
+			if q.ID == id {
+				text = stemSummary(q.Question)
+				break
+			}
+		}
+		r.Missed = append(r.Missed, MissedQuestion{ID: id, Question: text, Misses: count})
+	}
+	sort.Slice(r.Missed, func(i, j int) bool {
+		if r.Missed[i].Misses != r.Missed[j].Misses {
+			return r.Missed[i].Misses > r.Missed[j].Misses
+		}
+		return r.Missed[i].ID < r.Missed[j].ID
+	})
+	if len(r.Missed) > 5 {
+		r.Missed = r.Missed[:5]
+	}
+
+	return r
+}
+
+func userProgressReport(u User) ProgressReport {
+	return ProgressReport{
+		Title:     fmt.Sprintf("Progress report: %s (%s)", u.Name, u.ID),
+		Generated: time.Now(),
+		Learners:  []LearnerReport{buildLearnerReport(u)},
+	}
+}
+
+func groupProgressReport(g Group, users []User) ProgressReport {
+	r := ProgressReport{Title: "Group progress report: " + g.Name, Generated: time.Now()}
+	for _, u := range groupMembers(users, g.ID) {
+		r.Learners = append(r.Learners, buildLearnerReport(u))
+	}
+	return r
+}
+
+// tamperedNote warns that a learner's report leaves out tampered records
+func tamperedNote(l LearnerReport) string {
+	if l.Tampered == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d tampered records failed the integrity check and are left out of this report", l.Tampered)
+}
+
+func formatDuration(d time.Duration) string {
+	if d <= 0 {
+		return "-"
+	}
+	d = d.Round(time.Second)
+	if h := int(d.Hours()); h > 0 {
+		return fmt.Sprintf("%dh %02dm", h, int(d.Minutes())%60)
+	}
+	return fmt.Sprintf("%dm %02ds", int(d.Minutes()), int(d.Seconds())%60)
+}
+
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format(dateTimeLayout)
+}
+
+// sparkline renders percentages as a row of block characters
+func sparkline(values []float64) string {
+	blocks := []rune("▁▂▃▄▅▆▇█")
+	var b strings.Builder
+	for _, v := range values {
+		i := int(v / 100 * float64(len(blocks)-1))
+		if i < 0 {
+			i = 0
+		}
+		if i >= len(blocks) {
+			i = len(blocks) - 1
+		}
+		b.WriteRune(blocks[i])
+	}
+	return b.String()
+}
+
+func slugify(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteRune('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// exportProgressReport writes the report in the given format to the
+// reports directory and returns the file path
+func exportProgressReport(r ProgressReport, format string) (string, error) {
+	var data []byte
+	switch format {
+	case "csv":
+		var b strings.Builder
+		if err := writeReportCSV(&b, r); err != nil {
+			return "", err
+		}
+		data = []byte(b.String())
+	case "md":
+		data = []byte(reportMarkdown(r))
+	case "html":
+		data = []byte(reportHTML(r))
+	case "pdf":
+		data = reportPDF(r)
+	default:
+		return "", fmt.Errorf("unknown report format %q (use csv, html, md or pdf)", format)
+	}
+
+	dir := filepath.Join(cacheDir, "reports")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s.%s", slugify(r.Title), r.Generated.Format("20060102-150405"), format))
+	return path, os.WriteFile(path, data, 0644)
+}
+
+func writeReportCSV(b *strings.Builder, r ProgressReport) error {
+	w := csv.NewWriter(b)
+	w.Write([]string{"user_id", "name", "category", "module", "attempts", "best_pct", "latest_pct", "accuracy_pct", "last_taken", "time_spent_seconds", "tampered_records_excluded"})
+	for _, l := range r.Learners {
+		for _, m := range l.Modules {
+			w.Write([]string{
+				l.UserID, l.Name, m.Category, m.Module,
+				fmt.Sprintf("%d", m.Attempts),
+				fmt.Sprintf("%.1f", m.Best),
+				fmt.Sprintf("%.1f", m.Latest),
+				fmt.Sprintf("%.1f", m.Accuracy()),
+				m.LastTaken.Format(time.RFC3339),
+				fmt.Sprintf("%.0f", m.TimeSpent.Seconds()),
+				fmt.Sprintf("%d", l.Tampered),
+			})
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func reportMarkdown(r ProgressReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n_Generated %s_\n", r.Title, r.Generated.Format(dateTimeLayout))
+
+	for _, l := range r.Learners {
+		fmt.Fprintf(&b, "\n## %s (%s)\n\n", l.Name, l.UserID)
+		fmt.Fprintf(&b, "%d attempts, %s spent\n\n", l.Attempts, formatDuration(l.TimeSpent))
+		if note := tamperedNote(l); note != "" {
+			fmt.Fprintf(&b, "**⚠ %s**\n\n", note)
+		}
+
+		if len(l.Modules) == 0 {
+			b.WriteString("No quizzes taken yet.\n")
+			continue
+		}
+
+		b.WriteString("| Module | Attempts | Best | Latest | Time spent | Last taken | Trend |\n")
+		b.WriteString("|---|---:|---:|---:|---:|---|---|\n")
+		for _, m := range l.Modules {
+			fmt.Fprintf(&b, "| %s - %s | %d | %.0f%% | %.0f%% | %s | %s | %s |\n",
+				mdEscape(m.Category), mdEscape(m.Module), m.Attempts, m.Best, m.Latest,
+				formatDuration(m.TimeSpent), formatDate(m.LastTaken), sparkline(m.Trend))
+		}
+
+		b.WriteString("\n**Weakest topics**\n\n")
+		for _, m := range l.Weakest {
+			fmt.Fprintf(&b, "- %s - %s: %.0f%% accuracy\n", mdEscape(m.Category), mdEscape(m.Module), m.Accuracy())
+		}
+		if len(l.Missed) > 0 {
+			b.WriteString("\n**Most missed questions**\n\n")
+			for _, q := range l.Missed {
+				fmt.Fprintf(&b, "- `%s` %s (missed %d times)\n", q.ID, mdEscape(stemSummary(q.Question)), q.Misses)
+			}
+		}
+	}
+
+	return b.String()
+}
+
+func mdEscape(s string) string {
+	return strings.ReplaceAll(s, "|", "\\|")
+}
+
+func reportHTML(r ProgressReport) string {
+	var b strings.Builder
+	esc := html.EscapeString
+
+	b.WriteString("<!DOCTYPE html>\n<html lang=\"en\">\n<head>\n<meta charset=\"utf-8\">\n")
+	fmt.Fprintf(&b, "<title>%s</title>\n", esc(r.Title))
+	b.WriteString(`<style>
+body { font-family: system-ui, sans-serif; margin: 2em auto; max-width: 960px; color: #222; }
+h1 { color: #0a6d8f; } h2 { border-bottom: 2px solid #0a6d8f; padding-bottom: 4px; }
+table { border-collapse: collapse; width: 100%; margin: 1em 0; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+th { background: #eef6f9; } td.num { text-align: right; }
+.charts { display: flex; flex-wrap: wrap; gap: 12px; }
+.chart { border: 1px solid #ddd; padding: 6px; }
+.muted { color: #777; }
+.warning { color: #c0392b; font-weight: bold; }
+</style>
+</head>
+<body>
+`)
+	fmt.Fprintf(&b, "<h1>%s</h1>\n<p class=\"muted\">Generated %s</p>\n", esc(r.Title), r.Generated.Format(dateTimeLayout))
+
+	for _, l := range r.Learners {
+		fmt.Fprintf(&b, "<h2>%s <span class=\"muted\">(%s)</span></h2>\n", esc(l.Name), esc(l.UserID))
+		fmt.Fprintf(&b, "<p>%d attempts, %s spent</p>\n", l.Attempts, formatDuration(l.TimeSpent))
+		if note := tamperedNote(l); note != "" {
+			fmt.Fprintf(&b, "<p class=\"warning\">⚠ %s</p>\n", esc(note))
+		}
+
+		if len(l.Modules) == 0 {
+			b.WriteString("<p>No quizzes taken yet.</p>\n")
+			continue
+		}
+
+		b.WriteString("<table>\n<tr><th>Module</th><th>Attempts</th><th>Best</th><th>Latest</th><th>Time spent</th><th>Last taken</th></tr>\n")
+		for _, m := range l.Modules {
+			fmt.Fprintf(&b, "<tr><td>%s - %s</td><td class=\"num\">%d</td><td class=\"num\">%.0f%%</td><td class=\"num\">%.0f%%</td><td class=\"num\">%s</td><td>%s</td></tr>\n",
+				esc(m.Category), esc(m.Module), m.Attempts, m.Best, m.Latest, formatDuration(m.TimeSpent), formatDate(m.LastTaken))
+		}
+		b.WriteString("</table>\n")
+
+		b.WriteString("<div class=\"charts\">\n")
+		b.WriteString(svgBarChart(l.Modules))
+		for _, m := range l.Modules {
+			b.WriteString(svgTrendChart(m.Category+" - "+m.Module, m.Trend))
+		}
+		b.WriteString("</div>\n")
+
+		b.WriteString("<h3>Weakest topics</h3>\n<ul>\n")
+		for _, m := range l.Weakest {
+			fmt.Fprintf(&b, "<li>%s - %s: %.0f%% accuracy</li>\n", esc(m.Category), esc(m.Module), m.Accuracy())
+		}
+		b.WriteString("</ul>\n")
+
+		if len(l.Missed) > 0 {
+			b.WriteString("<h3>Most missed questions</h3>\n<ul>\n")
+			for _, q := range l.Missed {
+				fmt.Fprintf(&b, "<li><code>%s</code> %s (missed %d times)</li>\n", esc(q.ID), esc(stemSummary(q.Question)), q.Misses)
+			}
+			b.WriteString("</ul>\n")
+		}
+	}
+
+	b.WriteString("</body>\n</html>\n")
+	return b.String()
+}
+
+// svgTrendChart draws a line chart of attempt scores
+func svgTrendChart(title string, trend []float64) string {
+	const w, h, pad = 280.0, 140.0, 24.0
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "<svg class=\"chart\" width=\"%.0f\" height=\"%.0f\" viewBox=\"0 0 %.0f %.0f\" xmlns=\"http://www.w3.org/2000/svg\">\n", w, h, w, h)
+	fmt.Fprintf(&b, "<text x=\"%.0f\" y=\"14\" font-size=\"11\">%s</text>\n", pad, html.EscapeString(title))
+	for _, level := range []float64{0, 50, 100} {
+		y := h - pad - level/100*(h-2*pad)
+		fmt.Fprintf(&b, "<line x1=\"%.0f\" y1=\"%.1f\" x2=\"%.0f\" y2=\"%.1f\" stroke=\"#ddd\"/>\n", pad, y, w-8, y)
+		fmt.Fprintf(&b, "<text x=\"2\" y=\"%.1f\" font-size=\"9\" fill=\"#777\">%.0f</text>\n", y+3, level)
+	}
+
+	var points []string
+	for i, v := range trend {
+		x := pad
+		if len(trend) > 1 {
+			x = pad + float64(i)/float64(len(trend)-1)*(w-pad-8)
+		}
+		y := h - pad - v/100*(h-2*pad)
+		points = append(points, fmt.Sprintf("%.1f,%.1f", x, y))
+		fmt.Fprintf(&b, "<circle cx=\"%.1f\" cy=\"%.1f\" r=\"3\" fill=\"#0a6d8f\"/>\n", x, y)
+	}
+	if len(points) > 1 {
+		fmt.Fprintf(&b, "<polyline points=\"%s\" fill=\"none\" stroke=\"#0a6d8f\" stroke-width=\"2\"/>\n", strings.Join(points, " "))
+	}
+	fmt.Fprintf(&b, "<text x=\"%.0f\" y=\"%.0f\" font-size=\"9\" fill=\"#777\">attempts (%d)</text>\n", pad, h-6, len(trend))
+	b.WriteString("</svg>\n")
+	return b.String()
+}
+
+// svgBarChart draws the best score of every module
+func svgBarChart(modules []ModuleProgress) string {
+	const w, rowH, labelW = 280.0, 18.0, 110.0
+	h := rowH*float64(len(modules)) + 24
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "<svg class=\"chart\" width=\"%.0f\" height=\"%.0f\" viewBox=\"0 0 %.0f %.0f\" xmlns=\"http://www.w3.org/2000/svg\">\n", w, h, w, h)
+	b.WriteString("<text x=\"4\" y=\"14\" font-size=\"11\">Best score per module</text>\n")
+	for i, m := range modules {
+		y := 22 + float64(i)*rowH
+		barW := m.Best / 100 * (w - labelW - 40)
+		color := "#c0392b"
+		if g := gradesFor(m.Category, m.Module); m.Best >= float64(g.Merit) {
+			color = "#27ae60"
+		} else if m.Best >= float64(g.Pass) {
+			color = "#f39c12"
+		}
+		fmt.Fprintf(&b, "<text x=\"4\" y=\"%.0f\" font-size=\"10\">%s</text>\n", y+11, html.EscapeString(fit(m.Module, 18)))
+		fmt.Fprintf(&b, "<rect x=\"%.0f\" y=\"%.0f\" width=\"%.1f\" height=\"%.0f\" fill=\"%s\"/>\n", labelW, y+2, barW, rowH-6, color)
+		fmt.Fprintf(&b, "<text x=\"%.1f\" y=\"%.0f\" font-size=\"10\">%.0f%%</text>\n", labelW+barW+4, y+11, m.Best)
+	}
+	b.WriteString("</svg>\n")
+	return b.String()
+}
+
+// progressReports is the admin menu for generating reports
+func progressReports() {
+	choice := runMenu(menu{
+		title: "Progress Reports",
+		color: ColorBlue,
+		items: []string{"Report for a user", "Report for a group", "Back"},
+		back:  3,
+	})
+
+	var report ProgressReport
+	switch choice {
+	case "1":
+		users := loadAllUsers()
+		var items []listItem
+		for _, u := range users {
+			items = append(items, listItem{label: u.Name, detail: "(ID: " + u.ID + ")"})
+		}
+		idx := pickItem("Report for a user", ColorBlue, items)
+		if idx < 0 {
+			return
+		}
+		report = userProgressReport(users[idx])
+	case "2":
+		idx := pickGroup("Report for a group")
+		if idx < 0 {
+			return
+		}
+		report = groupProgressReport(classData.Groups[idx], loadAllUsers())
+	default:
+		return
+	}
+
+	exportReportPrompt(report)
+}
+
+// exportReportPrompt asks for a format and writes the report
+func exportReportPrompt(report ProgressReport) {
+	clearScreen()
+	printBoxHeader("Export Progress Report", ColorBlue)
+	fmt.Println()
+	printColor(ColorCyan, report.Title+"\n\n")
+	printColor(ColorYellow, "Format (csv, html, md, pdf or 'all', Enter to cancel): ")
+
+	format := strings.ToLower(readInput())
+	if format == "" {
+		return
+	}
+	formats := []string{format}
+	if format == "all" {
+		formats = []string{"csv", "html", "md", "pdf"}
+	}
+
+	for _, f := range formats {
+		path, err := exportProgressReport(report, f)
+		if err != nil {
+			printColor(ColorRed, fmt.Sprintf("\n✗ Export failed: %v\n", err))
+			continue
+		}
+		printColor(ColorGreen+ColorBold, fmt.Sprintf("\n✓ Report saved to %s\n", path))
+	}
+
+	printColor(ColorYellow, "\n"+tr("Press Enter to continue..."))
+	readInput()
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// reportUser has two Networking attempts, a legacy Linux score, a custom
+// assignment and a tampered attempt and score
+func reportUser() User {
+	at := func(minutes int) time.Time { return syncEpoch.Add(time.Duration(minutes) * time.Minute) }
+	answers := func(results ...bool) []AnswerRecord {
+		var records []AnswerRecord
+		for i, ok := range results {
+			records = append(records, AnswerRecord{QuestionID: []string{"q1", "q2", "q3", "q4"}[i], Correct: ok})
+		}
+		return records
+	}
+	return User{ID: "u1", Name: "Ada",
+		Attempts: []Attempt{
+			{Category: "Networking", Module: "Ports", StartedAt: at(0), FinishedAt: at(5), Answers: answers(true, false, false, false)},
+			{Category: "Networking", Module: "Ports", StartedAt: at(10), FinishedAt: at(12), Answers: answers(true, true, true, false)},
+			{Category: "Assignments", AssignmentID: "a1", FinishedAt: at(20), Answers: answers(false)},
+			{Category: "Networking", Module: "Ports", FinishedAt: at(30), Answers: answers(true, true, true, true), tampered: true},
+		},
+		Scores: map[string]map[string]Score{
+			"Linux":    {"Shell": {Correct: 9, Total: 10, LastTaken: at(-60)}},
+			"Security": {"Crypto": {Correct: 10, Total: 10, LastTaken: at(-30), tampered: true}},
+		},
+	}
+}
+
+func TestBuildLearnerReport(t *testing.T) {
+	useDataDir(t)
+	quizData.Questions = []Question{{ID: "q1", Question: "First **one**?"}, {ID: "q2", Question: "Second?"}}
+	r := buildLearnerReport(reportUser())
+
+	if len(r.Modules) != 2 || r.Attempts != 3 || r.Tampered != 2 {
+		t.Fatalf("modules %+v, %d attempts, %d tampered", r.Modules, r.Attempts, r.Tampered)
+	}
+	linux, ports := r.Modules[0], r.Modules[1]
+	if linux.Module != "Shell" || linux.Best != 90 || linux.Attempts != 1 {
+		t.Errorf("legacy score %+v", linux)
+	}
+	if ports.Best != 75 || ports.Latest != 75 || !reflect.DeepEqual(ports.Trend, []float64{25, 75}) ||
+		ports.TimeSpent != 7*time.Minute || ports.Accuracy() != 50 {
+		t.Errorf("Networking - Ports %+v", ports)
+	}
+	if r.Weakest[0].Module != "Ports" || r.TimeSpent != 7*time.Minute {
+		t.Errorf("weakest %+v, time %v", r.Weakest, r.TimeSpent)
+	}
+
+	// The assignment's miss counts, the tampered attempt's answers do not
+	want := []MissedQuestion{{"q4", "(question no longer in the bank)", 2}, {"q1", "First one?", 1}, {"q2", "Second?", 1}, {"q3", "(question no longer in the bank)", 1}}
+	if !reflect.DeepEqual(r.Missed, want) {
+		t.Errorf("missed %+v, want %+v", r.Missed, want)
+	}
+}
+
+func TestReportFormats(t *testing.T) {
+	useDataDir(t)
+	report := ProgressReport{Title: "Progress report", Generated: syncEpoch, Learners: []LearnerReport{buildLearnerReport(reportUser())}}
+
+	var csvOut strings.Builder
+	if err := writeReportCSV(&csvOut, report); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(csvOut.String()), "\n")
+	if len(lines) != 3 || !strings.HasSuffix(lines[0], ",tampered_records_excluded") || !strings.HasSuffix(lines[2], ",420,2") {
+		t.Errorf("CSV:\n%s", csvOut.String())
+	}
+
+	note := "2 tampered records failed the integrity check and are left out of this report"
+	for name, out := range map[string]string{
+		"Markdown": reportMarkdown(report),
+		"HTML":     reportHTML(report),
+		"PDF":      string(reportPDF(report)),
+	} {
+		if !strings.Contains(out, note) {
+			t.Errorf("%s report does not mention the tampered records", name)
+		}
+	}
+
+	report.Learners[0] = buildLearnerReport(User{ID: "u2", Name: "Bob"})
+	if md := reportMarkdown(report); strings.Contains(md, "tampered") || !strings.Contains(md, "No quizzes taken yet.") {
+		t.Errorf("Markdown for a new learner:\n%s", md)
+	}
+}
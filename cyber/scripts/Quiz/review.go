@@ -0,0 +1,275 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// Reviewing and drilling missed questions. After a quiz the learner can
+// go through their answers and retry the ones they got wrong. Every wrong
+// answer also puts the question in the user's missed deck, which spans
+// modules; a question leaves the deck once it has been answered correctly
+// quiz.missed_streak times in a row.
+
+// askQuestions puts the questions to the learner and returns their
+// answers, recorded against the original option order
+func askQuestions(heading string, questions []Question) []AnswerRecord {
+	var answers []AnswerRecord
+	total := len(questions)
+
+	for i, original := range questions {
+		// The question is shown in the learner's language, possibly with
+		// its options shuffled
+		q, order := shuffledOptions(localized(original))
+		record := func(answer int) {
+			if answer >= 0 && answer < len(order) {
+				answer = order[answer]
+			}
+			answers = append(answers, newAnswerRecord(original, answer))
+		}
+
+		interactive := q.Lab != nil || len(q.Steps) > 0
+		if tuiEnabled && !interactive {
+			answer := tuiQuestion(heading, i, total, q)
+			record(answer)
+			tuiAnswerFeedback(heading, i, total, q, answer)
+			continue
+		}
+
+		clearScreen()
+		if screenReader {
+			fmt.Printf("%s. %s\n\n", heading, trf("Question %d of %d.", i+1, total))
+		} else {
+			printBox(ColorCyan+ColorBold, []pageLine{
+				{ColorCyan, heading},
+				{ColorYellow, trf("Question %d of %d", i+1, total)},
+				{ColorGreen, progressBar(i, total, 30)},
+			})
+			fmt.Println()
+		}
+
+		w, _ := termSize()
+		printQuestionBody(q, w, ColorWhite+ColorBold)
+
+		if interactive {
+			var a AnswerRecord
+			if q.Lab != nil {
+				a = askLab(q)
+			} else {
+				a = askCommands(q)
+			}
+			answers = append(answers, a)
+			parts := gradedParts(q)
+			right := int(a.Credit*float64(parts) + 0.5)
+			switch {
+			case a.Correct:
+				printColor(ColorGreen+ColorBold, "\n"+tr("✓ Correct!")+"\n")
+			case a.Credit > 0 && q.Lab != nil:
+				printColor(ColorYellow+ColorBold, "\n"+trf("Partly right: %d of %d tasks.", right, parts)+"\n")
+			case a.Credit > 0:
+				printColor(ColorYellow+ColorBold, "\n"+trf("Partly right: %d of %d steps.", right, parts)+"\n")
+			default:
+				printColor(ColorRed+ColorBold, "\n"+tr("✗ Incorrect.")+"\n")
+			}
+		} else {
+			fmt.Println()
+			for j, opt := range q.Options {
+				printColor(ColorCyan, fmt.Sprintf("%d. ", j+1))
+				fmt.Println(opt)
+			}
+
+			printColor(ColorYellow, "\n"+trf("Your answer (1-%d): ", len(q.Options)))
+			var answer int
+			fmt.Sscanf(readInput(), "%d", &answer)
+			answer--
+			record(answer)
+
+			if answer == q.Answer {
+				printColor(ColorGreen+ColorBold, "\n"+tr("✓ Correct!")+"\n")
+			} else {
+				printColor(ColorRed+ColorBold, "\n"+tr("✗ Incorrect.")+" ")
+				printColor(ColorGreen, trf("The correct answer was: %s", q.Options[q.Answer])+"\n")
+			}
+		}
+		if q.Explanation != "" {
+			fmt.Println()
+			printMarkdown(q.Explanation, ColorCyan)
+		}
+
+		printColor(ColorYellow, "\n"+tr("Press Enter to continue..."))
+		readInput()
+	}
+
+	updateMissedDeck(answers)
+	return answers
+}
+
+// updateMissedDeck adds wrongly answered questions to the current user's
+// deck and counts correct answers towards removing them
+func updateMissedDeck(answers []AnswerRecord) {
+	if currentUser == nil {
+		return
+	}
+	for _, a := range answers {
+		if !a.Correct {
+			if currentUser.Missed == nil {
+				currentUser.Missed = make(map[string]int)
+			}
+			currentUser.Missed[a.QuestionID] = 0
+			continue
+		}
+		if streak, ok := currentUser.Missed[a.QuestionID]; ok {
+			if streak+1 >= config.MissedStreak {
+				delete(currentUser.Missed, a.QuestionID)
+			} else {
+				currentUser.Missed[a.QuestionID] = streak + 1
+			}
+		}
+	}
+	saveUser()
+}
+
+// missedQuestions returns the questions in the current user's deck that
+// are still in the bank
+func missedQuestions() []Question {
+	var questions []Question
+	for _, q := range quizData.Questions {
+		if _, ok := currentUser.Missed[q.ID]; ok {
+			questions = append(questions, q)
+		}
+	}
+	return questions
+}
+
+// reviewLines lists each question with the learner's answer and the
+// correct one
+func reviewLines(questions []Question, answers []AnswerRecord) []pageLine {
+	var lines []pageLine
+	w, _ := termSize()
+	for i, a := range answers {
+		if i >= len(questions) {
+			break
+		}
+		q := localized(questions[i])
+
+		mark, color := "✓", ColorGreen
+		if !a.Correct {
+			mark, color = "✗", ColorRed
+		}
+		body := questionBody(q, w-3, ColorBold)
+		lines = append(lines, pageLine{color + ColorBold, fmt.Sprintf("%s %d. %s", mark, i+1, body[0].text)})
+		for _, l := range body[1:] {
+			lines = append(lines, pageLine{l.color, "   " + l.text})
+		}
+
+		if q.Lab != nil {
+			lines = append(lines, labReviewLines(q, a)...)
+		} else if len(q.Steps) > 0 {
+			lines = append(lines, commandReviewLines(q, a)...)
+		} else {
+			chosen := tr("(no valid answer)")
+			if a.Chosen >= 0 && a.Chosen < len(q.Options) {
+				chosen = q.Options[a.Chosen]
+			}
+			lines = append(lines, pageLine{color, "   " + trf("Your answer: %s", chosen)})
+			if !a.Correct {
+				lines = append(lines, pageLine{ColorGreen, "   " + trf("Correct answer: %s", q.Options[q.Answer])})
+			}
+		}
+		if q.Explanation != "" {
+			for _, l := range renderMarkdown(q.Explanation, w-3, ColorCyan) {
+				lines = append(lines, pageLine{l.color, "   " + l.text})
+			}
+		}
+		lines = append(lines, pageLine{})
+	}
+	return lines
+}
+
+// offerReview lets the learner review a finished round and retry the
+// questions they missed, as often as they like
+func offerReview(heading string, questions []Question, answers []AnswerRecord) {
+	retryHeading := tr("Retry missed") + ": " + heading
+	for {
+		missed := missedInRound(questions, answers)
+		prompt := tr("Type 'r' to review your answers, or press Enter to continue...")
+		if len(missed) > 0 {
+			prompt = trf("Type 'r' to review your answers, 'm' to retry the %d missed, or press Enter to continue...", len(missed))
+		}
+		printColor(ColorYellow, "\n"+prompt)
+
+		switch strings.ToLower(readInput()) {
+		case "r":
+			showPager(tr("Review")+": "+heading, ColorCyan, reviewLines(questions, answers))
+			clearScreen()
+		case "m":
+			if len(missed) == 0 {
+				return
+			}
+			heading = retryHeading
+			questions, answers = missed, askQuestions(heading, missed)
+			showRoundResult(heading, answers)
+		default:
+			return
+		}
+	}
+}
+
+// missedInRound returns the questions of a round answered wrongly
+func missedInRound(questions []Question, answers []AnswerRecord) []Question {
+	var missed []Question
+	for i, a := range answers {
+		if !a.Correct && i < len(questions) {
+			missed = append(missed, questions[i])
+		}
+	}
+	return missed
+}
+
+// showRoundResult summarises a practice round, which is not scored
+func showRoundResult(heading string, answers []AnswerRecord) {
+	correct := 0
+	for _, a := range answers {
+		if a.Correct {
+			correct++
+		}
+	}
+	clearScreen()
+	printBoxHeader(heading, ColorGreen)
+	fmt.Println()
+	printColor(ColorWhite, trf("You answered %d of %d correctly.", correct, len(answers))+"\n")
+	if currentUser != nil {
+		printColor(ColorCyan, trf("Questions in your missed deck: %d", len(currentUser.Missed))+"\n")
+	}
+}
+
+// practiseMissed drills the current user's missed deck. Practice rounds
+// update the deck but are not recorded as attempts.
+func practiseMissed() {
+	questions := missedQuestions()
+	if len(questions) == 0 {
+		clearScreen()
+		printBoxHeader(tr("Missed Questions"), ColorMagenta)
+		fmt.Println()
+		printColor(ColorGreen, tr("Your missed deck is empty. Questions you get wrong are added here.")+"\n")
+		printColor(ColorYellow, "\n"+tr("Press Enter to continue..."))
+		readInput()
+		return
+	}
+
+	// Questions closest to leaving the deck come last
+	rand.Shuffle(len(questions), func(i, j int) { questions[i], questions[j] = questions[j], questions[i] })
+	sort.SliceStable(questions, func(i, j int) bool {
+		return currentUser.Missed[questions[i].ID] < currentUser.Missed[questions[j].ID]
+	})
+	if config.QuizLength > 0 && len(questions) > config.QuizLength {
+		questions = questions[:config.QuizLength]
+	}
+
+	heading := tr("Missed Questions")
+	answers := askQuestions(heading, questions)
+	showRoundResult(heading, answers)
+	offerReview(heading, questions, answers)
+}
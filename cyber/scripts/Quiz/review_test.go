@@ -0,0 +1,94 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUpdateMissedDeck(t *testing.T) {
+	useDataDir(t)
+	useConfig(t)
+	config.MissedStreak = 2
+	currentUser = &User{ID: "01HUSER0000000000000000000", Name: "Ada"}
+	saveUser()
+
+	rounds := []struct {
+		answers []AnswerRecord
+		want    map[string]int
+	}{
+		{[]AnswerRecord{{QuestionID: "q1"}, {QuestionID: "q2"}, {QuestionID: "q3", Correct: true}},
+			map[string]int{"q1": 0, "q2": 0}},
+		{[]AnswerRecord{{QuestionID: "q1", Correct: true}, {QuestionID: "q2", Correct: true}},
+			map[string]int{"q1": 1, "q2": 1}},
+		// A wrong answer starts the streak again
+		{[]AnswerRecord{{QuestionID: "q1", Correct: true}, {QuestionID: "q2"}},
+			map[string]int{"q2": 0}},
+		{[]AnswerRecord{{QuestionID: "q2", Correct: true}, {QuestionID: "q2", Correct: true}},
+			map[string]int{}},
+	}
+	for i, r := range rounds {
+		updateMissedDeck(r.answers)
+		got := loadAllUsers()[0].Missed
+		if got == nil {
+			got = map[string]int{}
+		}
+		if !reflect.DeepEqual(got, r.want) {
+			t.Errorf("round %d: deck %v, want %v", i+1, got, r.want)
+		}
+	}
+}
+
+func TestMissedQuestions(t *testing.T) {
+	useDataDir(t)
+	q1, q2, q3 := testQuestion("q1", "One"), testQuestion("q2", "Two"), testQuestion("q3", "One")
+	quizData.Questions = []Question{q1, q2, q3}
+	currentUser = &User{Missed: map[string]int{"q3": 1, "q1": 0, "removed": 0}}
+
+	// The deck spans modules and skips questions no longer in the bank
+	if got := missedQuestions(); !reflect.DeepEqual(got, []Question{q1, q3}) {
+		t.Errorf("missed deck %v", got)
+	}
+
+	answers := []AnswerRecord{{QuestionID: "q1", Correct: true}, {QuestionID: "q2"}, {QuestionID: "q3"}}
+	if got := missedInRound(quizData.Questions, answers); !reflect.DeepEqual(got, []Question{q2, q3}) {
+		t.Errorf("missed in the round %v", got)
+	}
+	if got := missedInRound(quizData.Questions[:1], answers); len(got) != 0 {
+		t.Errorf("answers without a question counted as missed: %v", got)
+	}
+}
+
+func TestReviewLines(t *testing.T) {
+	useLanguage(t, sourceLanguage)
+	useTheme(t, "none")
+	q := Question{ID: "q1", Question: "Which port does SSH use?", Options: []string{"21", "22"}, Answer: 1,
+		Category: "Networking", Module: "Ports", Explanation: "SSH listens on 22."}
+	q2 := Question{ID: "q2", Question: "Which port does DNS use?", Options: []string{"53", "80"}, Answer: 0}
+
+	var text []string
+	for _, l := range reviewLines([]Question{q, q2}, []AnswerRecord{{Chosen: 0}, {Chosen: 0, Correct: true}}) {
+		text = append(text, l.text)
+	}
+	got := strings.Join(text, "\n")
+	for _, want := range []string{
+		"✗ 1. Which port does SSH use?", "Your answer: 21", "Correct answer: 22", "SSH listens on 22.",
+		"✓ 2. Which port does DNS use?", "Your answer: 53",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("review does not contain %q:\n%s", want, got)
+		}
+	}
+	if strings.Count(got, "Correct answer") != 1 {
+		t.Errorf("the correct answer is shown for a right answer:\n%s", got)
+	}
+
+	// Answers recorded without a valid option say so
+	text = nil
+	for _, l := range reviewLines([]Question{q}, []AnswerRecord{{Chosen: -1}}) {
+		text = append(text, l.text)
+	}
+	if got := strings.Join(text, "\n"); !strings.Contains(got, "Your answer: (no valid answer)") {
+		t.Errorf("review of an unanswered question:\n%s", got)
+	}
+}
@@ -0,0 +1,717 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Revision questions from real scan output. Reports saved during lab work
+// are parsed for the hosts, ports and web paths they found, and turned
+// into scenario questions such as "which port is open on 192.168.0.143?"
+// in a module named after the engagement. The formats understood are
+// nmap normal and XML output, RustScan (including the nmap output it runs
+// into), dirb, gobuster and DirBuster reports; a file may hold several.
+
+type scanPort struct {
+	number  int
+	proto   string
+	state   string
+	service string // nmap's service name
+	version string // product and version, when nmap ran -sV
+}
+
+type scanHost struct {
+	address string
+	name    string
+	ports   []scanPort
+}
+
+// label names a host for questions, with its name when the scan had one
+func (h *scanHost) label() string {
+	if h.name != "" && h.name != h.address {
+		return fmt.Sprintf("%s (%s)", h.address, h.name)
+	}
+	return h.address
+}
+
+func (h *scanHost) openPorts() []scanPort {
+	var open []scanPort
+	for _, p := range h.ports {
+		if p.state == "open" {
+			open = append(open, p)
+		}
+	}
+	return open
+}
+
+// webPath is a path found by a web content scanner
+type webPath struct {
+	base   string // scheme, host and port, e.g. http://192.168.0.143:3000
+	path   string
+	status int
+	tool   string
+}
+
+// scanFindings gathers what a set of scan reports found
+type scanFindings struct {
+	hosts []*scanHost
+	paths []webPath
+	tools []string
+}
+
+func (f *scanFindings) host(address string) *scanHost {
+	for _, h := range f.hosts {
+		if h.address == address {
+			return h
+		}
+	}
+	h := &scanHost{address: address}
+	f.hosts = append(f.hosts, h)
+	return h
+}
+
+// addPort records a port, keeping the most detailed report of it
+func (f *scanFindings) addPort(address string, p scanPort) {
+	h := f.host(address)
+	for i, existing := range h.ports {
+		if existing.number == p.number && existing.proto == p.proto {
+			if p.service == "" {
+				p.service = existing.service
+			}
+			if p.version == "" {
+				p.version = existing.version
+			}
+			h.ports[i] = p
+			return
+		}
+	}
+	h.ports = append(h.ports, p)
+}
+
+func (f *scanFindings) addPath(p webPath) {
+	p.base = strings.TrimRight(p.base, "/")
+	if p.path == "" {
+		p.path = "/"
+	}
+	for _, existing := range f.paths {
+		if existing.base == p.base && existing.path == p.path {
+			return
+		}
+	}
+	f.paths = append(f.paths, p)
+}
+
+func (f *scanFindings) addTool(tool string) {
+	for _, t := range f.tools {
+		if t == tool {
+			return
+		}
+	}
+	f.tools = append(f.tools, tool)
+}
+
+func (f *scanFindings) empty() bool {
+	return len(f.hosts) == 0 && len(f.paths) == 0
+}
+
+var (
+	ansiSequence   = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+	nmapReportLine = regexp.MustCompile(`^Nmap scan report for (\S+)(?: \(([^)]+)\))?`)
+	nmapPortLine   = regexp.MustCompile(`^(\d+)/(tcp|udp|sctp)\s+(\S+)\s+(\S+)`)
+	rustscanOpen   = regexp.MustCompile(`^Open \[?([0-9A-Fa-f:.]+?)\]?:(\d+)$`)
+	dirbFound      = regexp.MustCompile(`^\+ (https?://\S+) \(CODE:(\d+)\|SIZE:\d+\)`)
+	dirbDirectory  = regexp.MustCompile(`^==> DIRECTORY: (https?://\S+)`)
+	gobusterURL    = regexp.MustCompile(`^\[\+\] Url:\s+(https?://\S+)`)
+	gobusterFound  = regexp.MustCompile(`^(/\S*|https?://\S+)\s+\(Status: (\d+)\)`)
+	dirbusterGroup = regexp.MustCompile(`^(?:Dirs|Files) found with a (\d+) respon[cs]e:$`)
+	dirbusterBase  = regexp.MustCompile(`^https?://[^\s/]+/?$`)
+)
+
+// parse reads one scan report into the findings
+func (f *scanFindings) parse(data []byte) error {
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("<?xml")) || bytes.HasPrefix(trimmed, []byte("<nmaprun")) {
+		return f.parseNmapXML(data)
+	}
+
+	text := ansiSequence.ReplaceAllString(string(data), "")
+	// dirb and gobuster redraw their progress with carriage returns
+	lines := strings.FieldsFunc(text, func(r rune) bool { return r == '\n' || r == '\r' })
+
+	var host, base, dirbusterStatus string
+	versionColumn := -1
+	for _, raw := range lines {
+		line := strings.TrimSpace(raw)
+		switch {
+		case strings.HasPrefix(line, "Nmap scan report for"):
+			m := nmapReportLine.FindStringSubmatch(line)
+			if m == nil {
+				// No target, as in hand-edited notes: drop the ports that
+				// follow rather than give them to the previous host
+				host = ""
+				continue
+			}
+			host = m[1]
+			name := ""
+			if m[2] != "" {
+				host, name = m[2], m[1]
+			}
+			f.host(host).name = name
+			f.addTool("nmap")
+
+		case strings.HasPrefix(line, "PORT ") && strings.Contains(line, "STATE"):
+			versionColumn = strings.Index(raw, "VERSION")
+
+		case host != "" && nmapPortLine.MatchString(line):
+			m := nmapPortLine.FindStringSubmatch(line)
+			n, _ := strconv.Atoi(m[1])
+			p := scanPort{number: n, proto: m[2], state: m[3], service: strings.TrimSuffix(m[4], "?")}
+			if versionColumn > 0 && len(raw) > versionColumn {
+				p.version = strings.TrimSpace(raw[versionColumn:])
+			}
+			f.addPort(host, p)
+
+		case rustscanOpen.MatchString(line):
+			m := rustscanOpen.FindStringSubmatch(line)
+			n, _ := strconv.Atoi(m[2])
+			f.addPort(m[1], scanPort{number: n, proto: "tcp", state: "open"})
+			f.addTool("RustScan")
+
+		case strings.HasPrefix(line, "URL_BASE: "):
+			base = strings.TrimSpace(strings.TrimPrefix(line, "URL_BASE: "))
+			f.addTool("dirb")
+
+		case dirbFound.MatchString(line):
+			m := dirbFound.FindStringSubmatch(line)
+			code, _ := strconv.Atoi(m[2])
+			f.addURL(m[1], code, "dirb")
+
+		case dirbDirectory.MatchString(line):
+			f.addURL(dirbDirectory.FindStringSubmatch(line)[1], 200, "dirb")
+
+		case gobusterURL.MatchString(line):
+			base = gobusterURL.FindStringSubmatch(line)[1]
+			f.addTool("gobuster")
+
+		case gobusterFound.MatchString(line):
+			m := gobusterFound.FindStringSubmatch(line)
+			code, _ := strconv.Atoi(m[2])
+			if strings.HasPrefix(m[1], "/") {
+				f.addPath(webPath{base: base, path: m[1], status: code, tool: "gobuster"})
+			} else {
+				f.addURL(m[1], code, "gobuster")
+			}
+
+		case strings.HasPrefix(line, "DirBuster"):
+			f.addTool("DirBuster")
+
+		case dirbusterBase.MatchString(line):
+			base = line
+
+		case dirbusterGroup.MatchString(line):
+			dirbusterStatus = dirbusterGroup.FindStringSubmatch(line)[1]
+
+		case dirbusterStatus != "" && base != "" && strings.HasPrefix(line, "/"):
+			code, _ := strconv.Atoi(dirbusterStatus)
+			f.addPath(webPath{base: base, path: line, status: code, tool: "DirBuster"})
+
+		case line == "" || strings.HasPrefix(line, "---"):
+			dirbusterStatus = ""
+		}
+	}
+	return nil
+}
+
+// addURL records a full URL reported by a web scanner
+func (f *scanFindings) addURL(raw string, status int, tool string) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return
+	}
+	f.addPath(webPath{base: u.Scheme + "://" + u.Host, path: u.Path, status: status, tool: tool})
+}
+
+// nmapRun is the part of nmap's XML output the importer reads
+type nmapRun struct {
+	Hosts []struct {
+		Status struct {
+			State string `xml:"state,attr"`
+		} `xml:"status"`
+		Addresses []struct {
+			Addr string `xml:"addr,attr"`
+			Type string `xml:"addrtype,attr"`
+		} `xml:"address"`
+		Hostnames []struct {
+			Name string `xml:"name,attr"`
+		} `xml:"hostnames>hostname"`
+		Ports []struct {
+			Protocol string `xml:"protocol,attr"`
+			PortID   int    `xml:"portid,attr"`
+			State    struct {
+				State string `xml:"state,attr"`
+			} `xml:"state"`
+			Service struct {
+				Name    string `xml:"name,attr"`
+				Product string `xml:"product,attr"`
+				Version string `xml:"version,attr"`
+			} `xml:"service"`
+		} `xml:"ports>port"`
+	} `xml:"host"`
+}
+
+func (f *scanFindings) parseNmapXML(data []byte) error {
+	var run nmapRun
+	if err := xml.Unmarshal(data, &run); err != nil {
+		return fmt.Errorf("reading nmap XML: %w", err)
+	}
+	f.addTool("nmap")
+	for _, h := range run.Hosts {
+		if h.Status.State == "down" {
+			continue
+		}
+		address := ""
+		for _, a := range h.Addresses {
+			if a.Type != "mac" {
+				address = a.Addr
+				break
+			}
+		}
+		if address == "" {
+			continue
+		}
+		host := f.host(address)
+		if len(h.Hostnames) > 0 {
+			host.name = h.Hostnames[0].Name
+		}
+		for _, p := range h.Ports {
+			version := strings.TrimSpace(p.Service.Product + " " + p.Service.Version)
+			f.addPort(address, scanPort{number: p.PortID, proto: p.Protocol, state: p.State.State, service: p.Service.Name, version: version})
+		}
+	}
+	return nil
+}
+
+// readScans parses scan report files, returning the names of files in
+// which nothing was found
+func readScans(paths []string) (scanFindings, []string, error) {
+	var f scanFindings
+	var empty []string
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return f, empty, err
+		}
+		before := len(f.hosts) + len(f.paths)
+		if err := f.parse(data); err != nil {
+			return f, empty, fmt.Errorf("%s: %w", p, err)
+		}
+		if len(f.hosts)+len(f.paths) == before {
+			empty = append(empty, filepath.Base(p))
+		}
+	}
+	sort.SliceStable(f.paths, func(i, j int) bool {
+		if f.paths[i].base != f.paths[j].base {
+			return f.paths[i].base < f.paths[j].base
+		}
+		return f.paths[i].path < f.paths[j].path
+	})
+	for _, h := range f.hosts {
+		sort.Slice(h.ports, func(i, j int) bool { return h.ports[i].number < h.ports[j].number })
+	}
+	return f, empty, nil
+}
+
+// summary describes the findings in one line
+func (f *scanFindings) summary() string {
+	open := 0
+	for _, h := range f.hosts {
+		open += len(h.openPorts())
+	}
+	return fmt.Sprintf("%d hosts, %d open ports and %d web paths from %s", len(f.hosts), open, len(f.paths), strings.Join(f.tools, ", "))
+}
+
+// serviceNames describes nmap's service names in options
+var serviceNames = map[string]string{
+	"domain":        "DNS",
+	"ftp":           "FTP (file transfer)",
+	"http":          "HTTP (web server)",
+	"http-proxy":    "HTTP proxy or alternate web server",
+	"https":         "HTTPS (web server over TLS)",
+	"imap":          "IMAP (mailbox access)",
+	"microsoft-ds":  "SMB (Windows file sharing)",
+	"ms-wbt-server": "RDP (Remote Desktop)",
+	"mysql":         "MySQL database",
+	"netbios-ssn":   "NetBIOS session service",
+	"pop3":          "POP3 (mail retrieval)",
+	"postgresql":    "PostgreSQL database",
+	"ppp":           "PPP (point-to-point protocol)",
+	"rpcbind":       "RPC port mapper",
+	"smtp":          "SMTP (mail transfer)",
+	"snmp":          "SNMP (network management)",
+	"ssh":           "SSH (remote shell)",
+	"telnet":        "Telnet",
+}
+
+// wellKnownPorts are ports whose service can be trusted without version
+// detection
+var wellKnownPorts = map[int]string{
+	21: "ftp", 22: "ssh", 23: "telnet", 25: "smtp", 53: "domain", 80: "http",
+	110: "pop3", 111: "rpcbind", 139: "netbios-ssn", 143: "imap", 443: "https",
+	445: "microsoft-ds", 3306: "mysql", 3389: "ms-wbt-server", 5432: "postgresql",
+}
+
+// commonPorts are distractors for open port questions
+var commonPorts = []int{21, 22, 23, 25, 53, 80, 110, 139, 443, 445, 3306, 3389, 8080}
+
+// pathKinds are the kinds of web path the importer asks about, each
+// recognised by words in the path
+var pathKinds = []struct {
+	question, why string
+	words         []string
+}{
+	{"Which discovered path is most likely an admin interface?",
+		"Admin interfaces are high-value targets: try default credentials and check whether they are reachable without logging in.",
+		[]string{"admin", "administrator", "administration", "adm", "manage", "manager", "management", "console", "dashboard", "phpmyadmin", "cpanel", "backend", "controlpanel"}},
+	{"Which discovered path most likely leads to a login page?",
+		"Login pages are where to test for weak or default credentials and user enumeration.",
+		[]string{"login", "signin", "logon", "auth", "sso"}},
+	{"Which discovered path most likely serves files, worth checking for backups and sensitive documents?",
+		"File listings and download areas often hold backups, configuration files and documents that were never meant to be public.",
+		[]string{"ftp", "files", "file", "download", "downloads", "upload", "uploads", "backup", "backups", "dump", "git", "svn", "share"}},
+	{"Which discovered file tells web crawlers what not to index, and often points to hidden paths?",
+		"robots.txt is public by design, and the paths it asks crawlers to avoid are often the interesting ones.",
+		[]string{"robots"}},
+	{"Which discovered path is most likely an API endpoint?",
+		"APIs often check authorisation less carefully than the pages that use them.",
+		[]string{"api", "rest", "graphql", "swagger"}},
+}
+
+// statusMeanings explain the HTTP status codes a content scanner reports
+var statusMeanings = map[int]string{
+	200: "The resource exists and was returned",
+	301: "It redirects, often to add a trailing slash to a directory",
+	302: "It redirects, often to a login page",
+	401: "It needs authentication",
+	403: "It exists, but access is forbidden",
+	404: "It does not exist",
+	500: "The request made the application fail, which may leak error details",
+}
+
+// pathWords splits the last segment of a path into lower-case words
+func pathWords(p string) []string {
+	p = strings.ToLower(strings.TrimRight(p, "/"))
+	p = p[strings.LastIndex(p, "/")+1:]
+	return strings.FieldsFunc(p, func(r rune) bool { return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9') })
+}
+
+// scanQuestionSet builds the questions of one import
+type scanQuestionSet struct {
+	category, module, source string
+	questions                []Question
+}
+
+// add appends a question whose options are sorted, so that the answer can
+// be anywhere among them
+func (s *scanQuestionSet) add(question, answer string, distractors []string, explanation string, tags []string, difficulty string) {
+	options := []string{answer}
+	for _, d := range distractors {
+		if len(options) == max(config.OptionCount, 2) {
+			break
+		}
+		if d != answer && !anyOf([]string{d}, options) {
+			options = append(options, d)
+		}
+	}
+	if len(options) < 2 {
+		return
+	}
+	sort.Strings(options)
+	idx := 0
+	for i, o := range options {
+		if o == answer {
+			idx = i
+		}
+	}
+	now := time.Now().UTC()
+	s.questions = append(s.questions, Question{
+		ID:          newID(),
+		Question:    question,
+		Options:     options,
+		Answer:      idx,
+		Category:    s.category,
+		Module:      s.module,
+		Tags:        append([]string{"scan"}, tags...),
+		Difficulty:  difficulty,
+		Author:      adminConfig.Author,
+		Source:      s.source,
+		CreatedAt:   &now,
+		Explanation: explanation,
+	})
+}
+
+// scenarioQuestions turns scan findings into questions in a module named
+// after the engagement
+func scenarioQuestions(f scanFindings, category, engagement, source string) []Question {
+	s := &scanQuestionSet{category: category, module: engagement, source: source}
+	s.portQuestions(f)
+	s.serviceQuestions(f)
+	s.pathQuestions(f)
+	s.statusQuestions(f)
+	return s.questions
+}
+
+func (s *scanQuestionSet) portQuestions(f scanFindings) {
+	for _, h := range f.hosts {
+		open := h.openPorts()
+		isOpen := make(map[string]bool)
+		var names []string
+		for _, p := range open {
+			isOpen[fmt.Sprintf("%d/%s", p.number, p.proto)] = true
+			names = append(names, fmt.Sprintf("%d/%s", p.number, p.proto))
+		}
+		var distractors []string
+		for _, p := range h.ports {
+			if p.state != "open" {
+				distractors = append(distractors, fmt.Sprintf("%d/%s", p.number, p.proto))
+			}
+		}
+		for _, n := range commonPorts {
+			if d := fmt.Sprintf("%d/tcp", n); !isOpen[d] {
+				distractors = append(distractors, d)
+			}
+		}
+
+		if len(open) == 0 {
+			continue
+		}
+		// one question per host: the other open ports would be right too
+		p := open[0]
+		explanation := fmt.Sprintf("The scan found %s open on %s.", names[0], h.label())
+		if len(open) > 1 {
+			explanation = fmt.Sprintf("The scan found these ports open on %s: %s.", h.label(), strings.Join(names, ", "))
+		}
+		s.add(fmt.Sprintf("Which of these ports did the scan find open on %s?", h.label()), fmt.Sprintf("%d/%s", p.number, p.proto), distractors, explanation, []string{"ports", "recon"}, "easy")
+	}
+}
+
+func webBases(f scanFindings) map[string]string {
+	bases := make(map[string]string)
+	for _, p := range f.paths {
+		u, err := url.Parse(p.base)
+		if err != nil {
+			continue
+		}
+		port := u.Port()
+		if port == "" {
+			port = map[string]string{"https": "443"}[u.Scheme]
+			if port == "" {
+				port = "80"
+			}
+		}
+		bases[u.Hostname()+":"+port] = p.base
+	}
+	return bases
+}
+
+func (s *scanQuestionSet) serviceQuestions(f scanFindings) {
+	bases := webBases(f)
+	for _, h := range f.hosts {
+		for _, p := range h.openPorts() {
+			service, reason := "", ""
+			base, web := bases[fmt.Sprintf("%s:%d", h.address, p.number)]
+			switch {
+			case web:
+				service = "http"
+				if strings.HasPrefix(base, "https") {
+					service = "https"
+				}
+				reason = fmt.Sprintf("The web content scan got HTTP responses from %s/, so a web server is listening there.", base)
+				if p.service != "" && p.service != service && !strings.HasPrefix(p.service, "http") {
+					reason += fmt.Sprintf(" nmap's %q label only comes from its table of usual port numbers, because version detection (-sV) was not run.", p.service)
+				}
+			case p.version != "" && serviceNames[p.service] != "":
+				service = p.service
+				reason = fmt.Sprintf("nmap's version detection identified %s on this port.", p.version)
+			case wellKnownPorts[p.number] != "" && (p.service == "" || p.service == wellKnownPorts[p.number]):
+				service = wellKnownPorts[p.number]
+				reason = fmt.Sprintf("Port %d is the standard port for %s, though only version detection (-sV) would confirm it.", p.number, serviceNames[service])
+			default:
+				continue
+			}
+
+			var distractors []string
+			if p.service != service && serviceNames[p.service] != "" {
+				distractors = append(distractors, serviceNames[p.service])
+			}
+			for _, name := range []string{"ssh", "microsoft-ds", "mysql", "domain", "ms-wbt-server", "ftp"} {
+				if name != service {
+					distractors = append(distractors, serviceNames[name])
+				}
+			}
+			question := fmt.Sprintf("What service most likely runs on port %d/%s of %s?", p.number, p.proto, h.label())
+			s.add(question, serviceNames[service], distractors, reason, []string{"services", "recon"}, "medium")
+		}
+	}
+}
+
+func (s *scanQuestionSet) pathQuestions(f scanFindings) {
+	byBase := make(map[string][]webPath)
+	var bases []string
+	for _, p := range f.paths {
+		if byBase[p.base] == nil {
+			bases = append(bases, p.base)
+		}
+		byBase[p.base] = append(byBase[p.base], p)
+	}
+
+	for _, base := range bases {
+		paths := byBase[base]
+		for _, kind := range pathKinds {
+			var answer *webPath
+			var others []string
+			seen := make(map[string]bool)
+			for i, p := range paths {
+				matched := anyOf(pathWords(p.path), kind.words)
+				if len(pathWords(p.path)) == 0 {
+					matched = false
+				}
+				switch {
+				case matched && answer == nil:
+					answer = &paths[i]
+				case !matched && !seen[strings.ToLower(p.path)]:
+					others = append(others, p.path)
+				}
+				seen[strings.ToLower(p.path)] = true
+			}
+			if answer == nil {
+				continue
+			}
+			question := kind.question
+			if len(bases) > 1 {
+				question = strings.TrimSuffix(question, "?") + " on " + base + "?"
+			}
+			explanation := fmt.Sprintf("%s found %s%s (status %d). %s", answer.tool, base, answer.path, answer.status, kind.why)
+			s.add(question, answer.path, others, explanation, []string{"web", "enumeration"}, "medium")
+		}
+	}
+}
+
+func (s *scanQuestionSet) statusQuestions(f scanFindings) {
+	asked := make(map[int]bool)
+	for _, p := range f.paths {
+		meaning := statusMeanings[p.status]
+		if meaning == "" || p.status == 200 || asked[p.status] {
+			continue
+		}
+		asked[p.status] = true
+		var distractors []string
+		for _, code := range []int{200, 301, 401, 403, 404, 500} {
+			if m := statusMeanings[code]; !strings.HasPrefix(m, "It redirects") || p.status/100 != 3 {
+				distractors = append(distractors, m)
+			}
+		}
+		question := fmt.Sprintf("%s reported %s%s with status %d. What does that tell you?", p.tool, p.base, p.path, p.status)
+		explanation := fmt.Sprintf("HTTP %d: %s.", p.status, strings.ToLower(meaning[:1])+meaning[1:])
+		s.add(question, meaning, distractors, explanation, []string{"web", "http"}, "easy")
+	}
+}
+
+// newScanQuestions drops questions already in the module, so a report can
+// be imported again after more scanning
+func newScanQuestions(questions []Question) (fresh []Question, skipped int) {
+	existing := make(map[string]bool)
+	for _, q := range quizData.Questions {
+		existing[q.Category+"\x00"+q.Module+"\x00"+q.Question] = true
+	}
+	for _, q := range questions {
+		if existing[q.Category+"\x00"+q.Module+"\x00"+q.Question] {
+			skipped++
+			continue
+		}
+		fresh = append(fresh, q)
+	}
+	return fresh, skipped
+}
+
+// engagementName suggests a module name from the folder of the reports
+func engagementName(paths []string) string {
+	if len(paths) == 0 {
+		return ""
+	}
+	abs, err := filepath.Abs(paths[0])
+	if err != nil {
+		return ""
+	}
+	return filepath.Base(filepath.Dir(abs))
+}
+
+// importScanResults lets the admin turn scan reports into questions
+func importScanResults() {
+	clearScreen()
+	printBoxHeader("Import Scan Results", ColorGreen)
+	fmt.Println()
+	printColor(ColorCyan, "Questions are made from nmap (normal or XML), RustScan, dirb, gobuster\nand DirBuster output.\n\n")
+
+	printColor(ColorYellow, "Report files, separated by spaces: ")
+	paths := strings.Fields(readInput())
+	if len(paths) == 0 {
+		return
+	}
+	f, empty, err := readScans(paths)
+	if err != nil {
+		printColor(ColorRed, fmt.Sprintf("✗ %v\n", err))
+		printColor(ColorYellow, tr("Press Enter to continue..."))
+		readInput()
+		return
+	}
+	for _, name := range empty {
+		printColor(ColorYellow, fmt.Sprintf("⚠ Nothing found in %s.\n", name))
+	}
+	if f.empty() {
+		printColor(ColorYellow, tr("Press Enter to continue..."))
+		readInput()
+		return
+	}
+	printColor(ColorGreen, "Found "+f.summary()+".\n\n")
+
+	category := promptWithDefault("Category", "Engagements")
+	engagement := promptWithDefault("Engagement (module name)", engagementName(paths))
+	var names []string
+	for _, p := range paths {
+		names = append(names, filepath.Base(p))
+	}
+	questions, skipped := newScanQuestions(scenarioQuestions(f, category, engagement, "scan: "+strings.Join(names, ", ")))
+
+	fmt.Println()
+	for _, q := range questions {
+		printColor(ColorWhite, "  • "+q.Question+"\n")
+	}
+	if skipped > 0 {
+		printColor(ColorCyan, fmt.Sprintf("%d questions are already in the module.\n", skipped))
+	}
+	if len(questions) == 0 {
+		printColor(ColorYellow, "No new questions.\n")
+		printColor(ColorYellow, tr("Press Enter to continue..."))
+		readInput()
+		return
+	}
+
+	printColor(ColorYellow, fmt.Sprintf("\nAdd these %d questions to %s - %s? (y/N): ", len(questions), category, engagement))
+	if strings.ToLower(readInput()) != "y" {
+		return
+	}
+	quizData.Questions = append(quizData.Questions, questions...)
+	saveQuestions()
+	printColor(ColorGreen+ColorBold, fmt.Sprintf("\n✓ Added %d questions.\n", len(questions)))
+	printColor(ColorYellow, tr("Press Enter to continue..."))
+	readInput()
+}
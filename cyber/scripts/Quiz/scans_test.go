@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const nmapNormal = `Starting Nmap 7.94 ( https://nmap.org ) at 2024-03-01 10:00 UTC
+Nmap scan report for target.lab (192.168.0.143)
+Host is up (0.00050s latency).
+Not shown: 997 closed tcp ports (reset)
+PORT     STATE    SERVICE VERSION
+22/tcp   open     ssh     OpenSSH 8.9p1 Ubuntu 3ubuntu0.6
+80/tcp   open     http    Apache httpd 2.4.52
+3306/tcp filtered mysql?
+
+Nmap scan report for 192.168.0.1
+PORT   STATE SERVICE
+53/udp open  domain
+`
+
+const nmapXML = `<?xml version="1.0"?>
+<nmaprun>
+<host><status state="up"/><address addr="10.0.0.5" addrtype="ipv4"/><address addr="00:11:22:33:44:55" addrtype="mac"/>
+<hostnames><hostname name="web.lab"/></hostnames>
+<ports><port protocol="tcp" portid="443"><state state="open"/><service name="https" product="nginx" version="1.18"/></port></ports>
+</host>
+<host><status state="down"/><address addr="10.0.0.6" addrtype="ipv4"/></host>
+</nmaprun>`
+
+const rustscanOutput = "\x1b[32mOpen 10.0.0.7:22\x1b[0m\nOpen [fe80::1]:8080\n"
+
+const dirbOutput = `URL_BASE: http://10.0.0.8/
+---- Scanning URL: http://10.0.0.8/ ----
++ http://10.0.0.8/robots.txt (CODE:200|SIZE:32)
+==> DIRECTORY: http://10.0.0.8/admin/
+`
+
+const gobusterOutput = "[+] Url:                     http://10.0.0.9:3000\n" +
+	"\r/login                (Status: 200) [Size: 1200]\n" +
+	"/api                  (Status: 401) [Size: 12]\n"
+
+const dirbusterOutput = `DirBuster 1.0-RC1 - Report
+http://10.0.0.10:80
+--------------------------------
+Dirs found with a 403 response:
+
+/server-status/
+
+Files found with a 200 responce:
+
+/index.php
+`
+
+// scanSummary flattens findings for comparison
+func scanSummary(f scanFindings) []string {
+	var out []string
+	for _, h := range f.hosts {
+		out = append(out, "host "+h.label())
+		for _, p := range h.ports {
+			out = append(out, fmt.Sprintf("  %d/%s %s %s %s", p.number, p.proto, p.state, p.service, p.version))
+		}
+	}
+	for _, p := range f.paths {
+		out = append(out, fmt.Sprintf("path %s%s %d %s", p.base, p.path, p.status, p.tool))
+	}
+	return out
+}
+
+func TestScanParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		tools []string
+		want  []string
+	}{
+		{"nmap normal", nmapNormal, []string{"nmap"}, []string{
+			"host 192.168.0.143 (target.lab)",
+			"  22/tcp open ssh OpenSSH 8.9p1 Ubuntu 3ubuntu0.6",
+			"  80/tcp open http Apache httpd 2.4.52",
+			"  3306/tcp filtered mysql ",
+			"host 192.168.0.1",
+			"  53/udp open domain ",
+		}},
+		{"nmap report line without a target", "Nmap scan report for  00\n22/tcp open ssh\n", []string{}, nil},
+		{"ports after a malformed report line", "Nmap scan report for 10.0.0.1\n22/tcp open ssh\nNmap scan report for \n80/tcp open http\n",
+			[]string{"nmap"}, []string{"host 10.0.0.1", "  22/tcp open ssh "}},
+		{"port lines without a host", "22/tcp open ssh\n", []string{}, nil},
+		{"nmap XML", nmapXML, []string{"nmap"}, []string{"host 10.0.0.5 (web.lab)", "  443/tcp open https nginx 1.18"}},
+		{"RustScan", rustscanOutput, []string{"RustScan"}, []string{"host 10.0.0.7", "  22/tcp open  ", "host fe80::1", "  8080/tcp open  "}},
+		{"dirb", dirbOutput, []string{"dirb"}, []string{"path http://10.0.0.8/robots.txt 200 dirb", "path http://10.0.0.8/admin/ 200 dirb"}},
+		{"gobuster", gobusterOutput, []string{"gobuster"}, []string{"path http://10.0.0.9:3000/login 200 gobuster", "path http://10.0.0.9:3000/api 401 gobuster"}},
+		{"DirBuster", dirbusterOutput, []string{"DirBuster"}, []string{"path http://10.0.0.10:80/server-status/ 403 DirBuster", "path http://10.0.0.10:80/index.php 200 DirBuster"}},
+		{"unrelated text", "hello\nworld\n", []string{}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var f scanFindings
+			if err := f.parse([]byte(tt.input)); err != nil {
+				t.Fatal(err)
+			}
+			if got := scanSummary(f); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+			if f.tools == nil {
+				f.tools = []string{}
+			}
+			if !reflect.DeepEqual(f.tools, tt.tools) {
+				t.Errorf("tools %v, want %v", f.tools, tt.tools)
+			}
+		})
+	}
+}
+
+func TestScanParseBadXML(t *testing.T) {
+	var f scanFindings
+	if err := f.parse([]byte("<?xml version=\"1.0\"?><nmaprun><host>")); err == nil {
+		t.Error("truncated XML was accepted")
+	}
+}
+
+func TestScenarioQuestions(t *testing.T) {
+	var f scanFindings
+	for _, report := range []string{nmapNormal, dirbOutput, gobusterOutput} {
+		if err := f.parse([]byte(report)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	questions := scenarioQuestions(f, "Pentesting", "Lab 1", "nmap.txt")
+	if len(questions) == 0 {
+		t.Fatal("no questions")
+	}
+	for _, q := range questions {
+		if q.Answer < 0 || q.Answer >= len(q.Options) {
+			t.Errorf("%q: answer %d out of range", q.Question, q.Answer)
+			continue
+		}
+		if !sort.StringsAreSorted(q.Options) {
+			t.Errorf("%q: options not sorted: %v", q.Question, q.Options)
+		}
+		seen := make(map[string]bool)
+		for _, o := range q.Options {
+			if seen[o] {
+				t.Errorf("%q: option %q repeated", q.Question, o)
+			}
+			seen[o] = true
+		}
+		if q.Category != "Pentesting" || q.Module != "Lab 1" || q.Source != "nmap.txt" {
+			t.Errorf("%q: filed as %s - %s from %s", q.Question, q.Category, q.Module, q.Source)
+		}
+	}
+	if problems := validateQuestions(questions, nil); len(problems) > 0 {
+		t.Errorf("invalid questions: %v", problems)
+	}
+}
+
+func FuzzScanParse(f *testing.F) {
+	for _, seed := range []string{nmapNormal, nmapXML, rustscanOutput, dirbOutput, gobusterOutput, dirbusterOutput, "Nmap scan report for  00"} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var findings scanFindings
+		if findings.parse(data) != nil {
+			return
+		}
+		scenarioQuestions(findings, "Fuzz", "Fuzz", "fuzz")
+	})
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Full-text search over the question bank. The index holds the words of
+// each question's stem, options, explanation, tags and module; a query
+// matches a question when every term matches one of its words exactly, as
+// a prefix or within a small edit distance, so "nmpa" still finds nmap
+// questions.
+// Terms of the form field:value filter on metadata instead:
+//
+//	nmap scan tag:recon difficulty:easy module:PenTest+
+
+// Field weights: a match in the tags counts for more than one in the
+// options or explanation
+var searchFields = []struct {
+	name   string
+	weight int
+}{
+	{"tags", 4},
+	{"question", 3},
+	{"options", 1},
+	{"explanation", 1},
+	{"module", 1},
+}
+
+// searchFilters are the metadata fields a query can filter on
+var searchFilters = map[string]func(q Question) []string{
+	"tag":        func(q Question) []string { return q.Tags },
+	"difficulty": func(q Question) []string { return []string{q.Difficulty} },
+	"category":   func(q Question) []string { return []string{q.Category} },
+	"module":     func(q Question) []string { return []string{q.Module} },
+	"author":     func(q Question) []string { return []string{q.Author} },
+	"source":     func(q Question) []string { return []string{q.Source} },
+	"id":         func(q Question) []string { return []string{q.ID} },
+}
+
+// searchIndex maps each word to the questions and fields it appears in
+type searchIndex struct {
+	questions []Question
+	words     map[string][]wordHit
+}
+
+type wordHit struct {
+	question int
+	field    int
+}
+
+// searchResult is a matching question with its position in the bank
+type searchResult struct {
+	index   int
+	score   int
+	matched []string // fields the terms were found in
+}
+
+// searchWords splits text into lower-case words
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '+'
+	})
+}
+
+// newSearchIndex indexes the questions, including their translations
+func newSearchIndex(questions []Question) *searchIndex {
+	idx := &searchIndex{questions: questions, words: make(map[string][]wordHit)}
+	for i, q := range questions {
+		texts := [][]string{q.Tags, append([]string{q.Question, q.Exhibit}, stepTasks(q)...), q.Options, {q.Explanation}, {q.Category, q.Module}}
+		for _, t := range q.Translations {
+			texts[1] = append(texts[1], t.Question)
+			texts[2] = append(texts[2], t.Options...)
+			texts[3] = append(texts[3], t.Explanation)
+		}
+		for field, text := range texts {
+			for _, w := range searchWords(strings.Join(text, " ")) {
+				hits := idx.words[w]
+				if n := len(hits); n > 0 && hits[n-1] == (wordHit{i, field}) {
+					continue
+				}
+				idx.words[w] = append(hits, wordHit{i, field})
+			}
+		}
+	}
+	return idx
+}
+
+// editDistance counts the insertions, deletions, substitutions and
+// swaps of neighbouring letters that turn a into b
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(ra)][len(rb)]
+}
+
+// termScore rates how well a query term matches a word: 3 for the same
+// word, 2 for a prefix and 1 for a near miss, 0 for no match
+func termScore(term, word string) int {
+	switch {
+	case term == word:
+		return 3
+	case len(term) >= 2 && strings.HasPrefix(word, term):
+		return 2
+	}
+	allowed := 0
+	switch n := len([]rune(term)); {
+	case n >= 8:
+		allowed = 2
+	case n >= 4:
+		allowed = 1
+	}
+	if allowed > 0 && abs(len(term)-len(word)) <= allowed && editDistance(term, word) <= allowed {
+		return 1
+	}
+	return 0
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// search returns the questions matching every term of the query, best
+// first
+func (idx *searchIndex) search(query string) []searchResult {
+	var terms []string
+	filters := make(map[string][]string)
+	for _, part := range strings.Fields(query) {
+		if name, value, ok := strings.Cut(part, ":"); ok && searchFilters[strings.ToLower(name)] != nil {
+			filters[strings.ToLower(name)] = append(filters[strings.ToLower(name)], value)
+			continue
+		}
+		terms = append(terms, searchWords(part)...)
+	}
+	if len(terms) == 0 && len(filters) == 0 {
+		return nil
+	}
+
+	// Score every question against each term; a question missing any
+	// term drops out
+	scores := make(map[int]int)
+	matched := make(map[int]map[int]bool)
+	for i := range idx.questions {
+		if passesFilters(idx.questions[i], filters) {
+			scores[i] = 0
+		}
+	}
+	for _, term := range terms {
+		best := make(map[int]int)
+		for word, hits := range idx.words {
+			s := termScore(term, word)
+			if s == 0 {
+				continue
+			}
+			for _, h := range hits {
+				if _, ok := scores[h.question]; !ok {
+					continue
+				}
+				if v := s * searchFields[h.field].weight; v > best[h.question] {
+					best[h.question] = v
+				}
+				if matched[h.question] == nil {
+					matched[h.question] = make(map[int]bool)
+				}
+				matched[h.question][h.field] = true
+			}
+		}
+		for i := range scores {
+			if best[i] == 0 {
+				delete(scores, i)
+			} else {
+				scores[i] += best[i]
+			}
+		}
+	}
+
+	var results []searchResult
+	for i, score := range scores {
+		r := searchResult{index: i, score: score}
+		for field, f := range searchFields {
+			if matched[i][field] {
+				r.matched = append(r.matched, f.name)
+			}
+		}
+		results = append(results, r)
+	}
+	sort.Slice(results, func(a, b int) bool {
+		if results[a].score != results[b].score {
+			return results[a].score > results[b].score
+		}
+		return results[a].index < results[b].index
+	})
+	return results
+}
+
+// passesFilters reports whether a question meets every field:value filter;
+// several values for one field match any of them
+func passesFilters(q Question, filters map[string][]string) bool {
+	for name, values := range filters {
+		if !anyOf(searchFilters[name](q), values) {
+			return false
+		}
+	}
+	return true
+}
+
+// questionMeta describes a question's metadata in one line
+func questionMeta(q Question) string {
+	var parts []string
+	if q.Difficulty != "" {
+		parts = append(parts, q.Difficulty)
+	}
+	if len(q.Tags) > 0 {
+		parts = append(parts, "#"+strings.Join(q.Tags, " #"))
+	}
+	if q.Author != "" {
+		parts = append(parts, "by "+q.Author)
+	}
+	if q.Source != "" {
+		parts = append(parts, "from "+q.Source)
+	}
+	if q.UpdatedAt != nil {
+		parts = append(parts, "updated "+formatDate(*q.UpdatedAt))
+	} else if q.CreatedAt != nil {
+		parts = append(parts, "added "+formatDate(*q.CreatedAt))
+	}
+	return strings.Join(parts, " · ")
+}
+
+// searchResultItems turns results into list items, best match first
+func searchResultItems(results []searchResult) []listItem {
+	var items []listItem
+	for _, r := range results {
+		q := quizData.Questions[r.index]
+		detail := fmt.Sprintf("[%s - %s] %s", q.Category, q.Module, q.ID)
+		if meta := questionMeta(q); meta != "" {
+			detail += " · " + meta
+		}
+		if len(r.matched) > 0 {
+			detail += " (" + strings.Join(r.matched, ", ") + ")"
+		}
+		items = append(items, listItem{label: stemSummary(q.Question), detail: detail})
+	}
+	return items
+}
+
+// searchQuestions lets the admin search the bank and edit a result
+func searchQuestions() {
+	clearScreen()
+	printBoxHeader("Search Questions", ColorBlue)
+	fmt.Println()
+	printColor(ColorCyan, "Words match the question, options, explanation, tags and module, allowing for typos.\n")
+	printColor(ColorCyan, "Filter with tag:, difficulty:, category:, module:, author:, source: or id:\n\n")
+
+	printColor(ColorYellow, "Search: ")
+	query := readInput()
+	if query == "" {
+		return
+	}
+
+	for {
+		results := newSearchIndex(quizData.Questions).search(query)
+		if len(results) == 0 {
+			printColor(ColorRed, fmt.Sprintf("\nNo questions match %q.\n", query))
+			printColor(ColorYellow, tr("Press Enter to continue..."))
+			readInput()
+			return
+		}
+
+		idx := pickItem(fmt.Sprintf("Search: %s (%d found)", query, len(results)), ColorBlue, searchResultItems(results))
+		if idx < 0 {
+			return
+		}
+		editQuestionAt(results[idx].index)
+	}
+}
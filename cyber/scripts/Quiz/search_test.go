@@ -0,0 +1,92 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"nmap", "nmap", 0},
+		{"nmpa", "nmap", 1}, // swapped letters count once
+		{"nap", "nmap", 1},
+		{"nmaps", "nmap", 1},
+		{"kitten", "sitting", 3},
+		{"", "dns", 3},
+		{"café", "cafe", 1},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestTermScore(t *testing.T) {
+	tests := []struct {
+		term, word string
+		want       int
+	}{
+		{"nmap", "nmap", 3},
+		{"nm", "nmap", 2},
+		{"n", "nmap", 0}, // one letter is too short for a prefix
+		{"nmpa", "nmap", 1},
+		{"ssh", "ssl", 0}, // short terms must match exactly
+		{"firewal", "firewall", 2},
+		{"firewlal", "firewall", 1},
+		{"enumeration", "enumaretion", 1},
+		{"enumeration", "numerations", 1}, // long terms allow two edits
+		{"enumeration", "exfiltration", 0},
+	}
+	for _, tt := range tests {
+		if got := termScore(tt.term, tt.word); got != tt.want {
+			t.Errorf("termScore(%q, %q) = %d, want %d", tt.term, tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestSearchRanking(t *testing.T) {
+	questions := []Question{
+		{ID: "option", Question: "Which tool maps a network?", Options: []string{"nmap", "dig"}, Category: "PenTest+", Module: "Recon"},
+		{ID: "stem", Question: "What does nmap -sV do?", Options: []string{"a", "b"}, Category: "PenTest+", Module: "Recon", Difficulty: "easy"},
+		{ID: "tag", Question: "Which scan type is stealthiest?", Options: []string{"a", "b"}, Tags: []string{"nmap"}, Category: "PenTest+", Module: "Scanning", Difficulty: "hard"},
+		{ID: "dns", Question: "Which record holds a mail server?", Options: []string{"MX", "A"}, Explanation: "dig shows it", Category: "Networking", Module: "DNS", Author: "Ada"},
+		{ID: "es", Question: "Port of SSH?", Options: []string{"22", "23"}, Category: "Networking", Module: "Ports",
+			Translations: map[string]QuestionText{"es": {Question: "¿Qué puerto usa SSH?"}}},
+	}
+	idx := newSearchIndex(questions)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"nmap", []string{"tag", "stem", "option"}}, // tags weigh most, then the stem
+		{"nmpa", []string{"tag", "stem", "option"}},
+		{"NMAP scan", []string{"tag"}}, // every term must match
+		{"nmap difficulty:easy", []string{"stem"}},
+		{"difficulty:easy difficulty:hard", []string{"stem", "tag"}},
+		{"module:recon", []string{"option", "stem"}},
+		{"author:ada", []string{"dns"}},
+		{"dig", []string{"option", "dns"}},
+		{"pentest+", []string{"option", "stem", "tag"}},
+		{"puerto", []string{"es"}}, // translations are searched too
+		{"telnet", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, r := range idx.search(tt.query) {
+			got = append(got, questions[r.index].ID)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("search(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+
+	if r := idx.search("nmap"); !reflect.DeepEqual(r[0].matched, []string{"tags"}) || !reflect.DeepEqual(r[2].matched, []string{"options"}) {
+		t.Errorf("matched fields %v and %v", r[0].matched, r[2].matched)
+	}
+}
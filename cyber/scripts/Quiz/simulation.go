@@ -0,0 +1,524 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"net"
+	"regexp"
+	"strings"
+)
+
+// Command-line questions. Like the performance-based items of PenTest+ and
+// CCNA, a question with steps puts the learner at a simulated prompt and
+// grades each command they type against the accepted patterns of that
+// step. Each step earns its share of the question's credit.
+//
+//	"shell": "ios",
+//	"steps": [
+//	  {"task": "Enter global configuration mode", "prompt": "Router#",
+//	   "accept": ["conf[igure] t[erminal]"], "output": "Enter configuration commands, one per line."},
+//	  {"task": "Select GigabitEthernet0/1", "prompt": "Router(config)#",
+//	   "accept": ["int[erface] g[igabitethernet]0/1"]}
+//	]
+//
+// A pattern is a command written with these additions:
+//
+//	conf[igure]     an abbreviation: conf, confi, ... configure
+//	-sS|--syn       either spelling
+//	-Pn?            an optional option or word
+//	<ip> <cidr> <host> <num> <ports> <any>
+//	                an IP address, a network such as 10.0.0.0/24, an IP or
+//	                host name, a number, a port list such as 22,80 or
+//	                1-1000, or any single word
+//
+// In a Linux shell the options after the command may come in any order,
+// --name=value is the same as --name value and a leading sudo is ignored. An option's value follows
+// it in the pattern; words before the first option are arguments that can
+// go anywhere, e.g. "nmap <ip> -sS --top-ports 1000". On Cisco IOS words
+// must come in order and case does not matter. Equivalent commands that
+// do not fit one pattern are listed as further patterns.
+
+// SimStep is one command of a command-line question
+type SimStep struct {
+	Task   string   `json:"task,omitempty"`   // what to do, shown above the prompt
+	Prompt string   `json:"prompt,omitempty"` // defaults to the previous step's prompt
+	Accept []string `json:"accept"`           // accepted command patterns
+	Output string   `json:"output,omitempty"` // shown after the command, as the device would
+}
+
+// shells are the kinds of prompt a command-line question can simulate
+var shells = []string{"bash", "ios"}
+
+// stepPrompt returns the prompt of each step
+func stepPrompts(q Question) []string {
+	prompts := make([]string, len(q.Steps))
+	prompt := "$"
+	if q.Shell == "ios" {
+		prompt = "Router>"
+	}
+	for i, s := range q.Steps {
+		if s.Prompt != "" {
+			prompt = s.Prompt
+		}
+		prompts[i] = prompt
+	}
+	return prompts
+}
+
+// patternToken is a word of a pattern with its spellings
+type patternToken struct {
+	alternatives []tokenSpelling
+	optional     bool
+}
+
+// tokenSpelling is one spelling of a pattern word: a placeholder, or text
+// of which the abbreviable part may be cut short
+type tokenSpelling struct {
+	placeholder       string
+	pre, abbrev, post string
+}
+
+// patternUnit is a group of words matched together: an option and its
+// value, or a single word
+type patternUnit []patternToken
+
+type commandPattern struct {
+	units   []patternUnit
+	kinds   []int // units written the same share a kind
+	ordered bool
+	fold    bool // ignore case
+}
+
+var (
+	spellingPattern = regexp.MustCompile(`^([^\[\]]*)(?:\[([^\[\]]*)\])?([^\[\]]*)$`)
+	placeholders    = map[string]func(string) bool{
+		"ip":    func(s string) bool { return net.ParseIP(s) != nil },
+		"cidr":  func(s string) bool { _, _, err := net.ParseCIDR(s); return err == nil },
+		"host":  regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9.-]*[A-Za-z0-9])?$|^[0-9a-fA-F:]+$`).MatchString,
+		"num":   regexp.MustCompile(`^[0-9]+$`).MatchString,
+		"ports": regexp.MustCompile(`^[0-9]+(-[0-9]+)?(,[0-9]+(-[0-9]+)?)*$`).MatchString,
+		"any":   func(string) bool { return true },
+	}
+)
+
+// splitCommand splits a typed command into words, keeping quoted text
+// together and splitting --name=value
+func splitCommand(command string) []string {
+	var words []string
+	var word strings.Builder
+	quote := rune(0)
+	inWord := false
+	for _, r := range command {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			word.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	var split []string
+	for _, w := range words {
+		if name, value, ok := strings.Cut(w, "="); ok && strings.HasPrefix(w, "--") {
+			split = append(split, name, value)
+			continue
+		}
+		split = append(split, w)
+	}
+	return split
+}
+
+// compilePattern parses an accepted command pattern for a shell
+func compilePattern(pattern, shell string) (commandPattern, error) {
+	p := commandPattern{ordered: shell == "ios", fold: shell == "ios"}
+	words := strings.Fields(pattern)
+	if len(words) == 0 {
+		return p, fmt.Errorf("empty pattern")
+	}
+	var expanded []string
+	for _, w := range words {
+		if name, value, ok := strings.Cut(w, "="); ok && strings.HasPrefix(w, "--") {
+			expanded = append(expanded, name, value)
+			continue
+		}
+		expanded = append(expanded, w)
+	}
+
+	var unit patternUnit
+	flush := func() {
+		if len(unit) > 0 {
+			p.units = append(p.units, unit)
+			unit = nil
+		}
+	}
+	for i, w := range expanded {
+		tok, option, err := parseToken(w)
+		if err != nil {
+			return p, err
+		}
+		switch {
+		case p.ordered || i == 0:
+			flush()
+			p.units = append(p.units, patternUnit{tok})
+		case option:
+			// An option takes the words after it as its value
+			flush()
+			unit = patternUnit{tok}
+		case len(unit) > 0:
+			unit = append(unit, tok)
+		default:
+			// An argument before the first option stands alone
+			p.units = append(p.units, patternUnit{tok})
+		}
+	}
+	flush()
+
+	first := make(map[string]int)
+	for i, u := range p.units {
+		key := fmt.Sprint(u)
+		if _, seen := first[key]; !seen {
+			first[key] = i
+		}
+		p.kinds = append(p.kinds, first[key])
+	}
+	return p, nil
+}
+
+// parseToken reads a pattern word, reporting whether it is an option
+func parseToken(w string) (patternToken, bool, error) {
+	tok := patternToken{}
+	if len(w) > 1 && strings.HasSuffix(w, "?") {
+		tok.optional = true
+		w = strings.TrimSuffix(w, "?")
+	}
+	alternatives := []string{w}
+	if w != "|" && w != "||" {
+		alternatives = strings.Split(w, "|")
+	}
+	option := false
+	for _, alt := range alternatives {
+		if strings.HasPrefix(alt, "<") && strings.HasSuffix(alt, ">") {
+			name := strings.Trim(alt, "<>")
+			if placeholders[name] == nil {
+				return tok, false, fmt.Errorf("unknown placeholder %s", alt)
+			}
+			tok.alternatives = append(tok.alternatives, tokenSpelling{placeholder: name})
+			continue
+		}
+		m := spellingPattern.FindStringSubmatch(alt)
+		if m == nil || alt == "" {
+			return tok, false, fmt.Errorf("cannot read %q", w)
+		}
+		tok.alternatives = append(tok.alternatives, tokenSpelling{pre: m[1], abbrev: m[2], post: m[3]})
+		option = option || strings.HasPrefix(m[1], "-")
+	}
+	return tok, option, nil
+}
+
+// matches reports whether a typed word is one of the token's spellings
+func (t patternToken) matches(word string, fold bool) bool {
+	for _, s := range t.alternatives {
+		if s.placeholder != "" {
+			if placeholders[s.placeholder](word) {
+				return true
+			}
+			continue
+		}
+		w, pre, abbrev, post := word, s.pre, s.abbrev, s.post
+		if fold {
+			w, pre, abbrev, post = strings.ToLower(w), strings.ToLower(pre), strings.ToLower(abbrev), strings.ToLower(post)
+		}
+		if !strings.HasPrefix(w, pre) || !strings.HasSuffix(w[len(pre):], post) {
+			continue
+		}
+		if middle := w[len(pre) : len(w)-len(post)]; strings.HasPrefix(abbrev, middle) {
+			return true
+		}
+	}
+	return false
+}
+
+// optional reports whether a unit may be left out
+func (u patternUnit) optional() bool {
+	return u[0].optional
+}
+
+// matchAt matches the unit's words at the start of words, returning how
+// many it used or -1
+func (u patternUnit) matchAt(words []string, fold bool) int {
+	used := 0
+	for _, tok := range u {
+		if used < len(words) && tok.matches(words[used], fold) {
+			used++
+			continue
+		}
+		if !tok.optional {
+			return -1
+		}
+	}
+	return used
+}
+
+// match reports whether a typed command fits the pattern
+func (p commandPattern) match(command string) bool {
+	words := splitCommand(command)
+	if len(words) == 0 {
+		return false
+	}
+	if p.ordered {
+		return p.matchOrdered(words, 0)
+	}
+	if words[0] == "sudo" && len(words) > 1 && !p.units[0][0].matches("sudo", false) {
+		words = words[1:]
+	}
+	n := p.units[0].matchAt(words, p.fold)
+	if n < 0 {
+		return false
+	}
+	return p.matchAnyOrder(words[n:], make([]bool, len(p.units)), 1)
+}
+
+func (p commandPattern) matchOrdered(words []string, unit int) bool {
+	if unit == len(p.units) {
+		return len(words) == 0
+	}
+	if n := p.units[unit].matchAt(words, p.fold); n > 0 && p.matchOrdered(words[n:], unit+1) {
+		return true
+	}
+	return p.units[unit].optional() && p.matchOrdered(words, unit+1)
+}
+
+// matchAnyOrder matches the remaining words against the units not yet
+// used, trying every order. Only the first unused unit of each kind is
+// tried, as several <any> arguments would otherwise take factorial time.
+func (p commandPattern) matchAnyOrder(words []string, used []bool, from int) bool {
+	if len(words) == 0 {
+		for i := from; i < len(p.units); i++ {
+			if !used[i] && !p.units[i].optional() {
+				return false
+			}
+		}
+		return true
+	}
+	tried := make(map[int]bool)
+	for i := from; i < len(p.units); i++ {
+		if used[i] || tried[p.kinds[i]] {
+			continue
+		}
+		tried[p.kinds[i]] = true
+		if n := p.units[i].matchAt(words, p.fold); n > 0 {
+			used[i] = true
+			if p.matchAnyOrder(words[n:], used, from) {
+				return true
+			}
+			used[i] = false
+		}
+	}
+	return false
+}
+
+// example writes a pattern as a command that fits it, for feedback
+func examplePattern(pattern string) string {
+	var words []string
+	for _, w := range strings.Fields(pattern) {
+		if len(w) > 1 && strings.HasSuffix(w, "?") {
+			continue
+		}
+		if w != "|" && w != "||" {
+			w, _, _ = strings.Cut(w, "|")
+		}
+		words = append(words, strings.NewReplacer("[", "", "]", "").Replace(w))
+	}
+	return strings.Join(words, " ")
+}
+
+// stepAccepts reports whether a command is accepted for a step
+func stepAccepts(step SimStep, shell, command string) bool {
+	for _, pattern := range step.Accept {
+		p, err := compilePattern(pattern, shell)
+		if err == nil && p.match(command) {
+			return true
+		}
+	}
+	return false
+}
+
+// stepProblems checks the steps of a command-line question
+func stepProblems(q Question) []string {
+	var problems []string
+	if q.Shell != "" && !anyOf([]string{q.Shell}, shells) {
+		problems = append(problems, fmt.Sprintf("question %s: shell must be one of %s", q.ID, strings.Join(shells, ", ")))
+	}
+	for i, s := range q.Steps {
+		if len(s.Accept) == 0 {
+			problems = append(problems, fmt.Sprintf("question %s: step %d accepts no commands", q.ID, i+1))
+		}
+		for _, pattern := range s.Accept {
+			if _, err := compilePattern(pattern, q.Shell); err != nil {
+				problems = append(problems, fmt.Sprintf("question %s: step %d: %v", q.ID, i+1, err))
+			}
+		}
+	}
+	return problems
+}
+
+// stepTasks returns the task text of each step or lab check, for
+// searching
+func stepTasks(q Question) []string {
+	var tasks []string
+	for _, s := range q.Steps {
+		tasks = append(tasks, s.Task)
+	}
+	if q.Lab != nil {
+		for _, c := range q.Lab.Checks {
+			tasks = append(tasks, c.Task)
+		}
+	}
+	return tasks
+}
+
+// promptSteps asks for the shell and steps of a new command-line question
+// and reports whether at least one step was entered
+func promptSteps(q *Question) bool {
+	for {
+		printColor(ColorYellow, fmt.Sprintf("Shell, one of %s (Enter for bash): ", strings.Join(shells, ", ")))
+		q.Shell = strings.ToLower(readInput())
+		if q.Shell == "" || anyOf([]string{q.Shell}, shells) {
+			break
+		}
+	}
+	if q.Shell == "bash" {
+		q.Shell = ""
+	}
+
+	for {
+		printColor(ColorCyan, fmt.Sprintf("\nStep %d\n", len(q.Steps)+1))
+		printColor(ColorYellow, "Task, e.g. Enter global configuration mode (Enter to finish): ")
+		task := readInput()
+		if task == "" {
+			return len(q.Steps) > 0
+		}
+		printColor(ColorYellow, "Prompt, e.g. Router# (Enter to keep the previous one): ")
+		step := SimStep{Task: task, Prompt: readInput()}
+
+		printColor(ColorCyan, "Accepted commands, one pattern per line, then a line with only a dot.\n")
+		printColor(ColorCyan, "Use conf[igure] for abbreviations, a|b for alternatives, a trailing ? for\n")
+		printColor(ColorCyan, "optional words and <ip>, <cidr>, <host>, <num>, <ports> or <any> for values:\n")
+		for _, line := range strings.Split(readBlock(), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				step.Accept = append(step.Accept, line)
+			}
+		}
+		printColor(ColorYellow, "Output to show once the step is done, then a line with only a dot:\n")
+		step.Output = strings.TrimRight(readBlock(), "\n")
+
+		check := Question{ID: q.ID, Shell: q.Shell, Steps: []SimStep{step}}
+		if problems := stepProblems(check); len(problems) > 0 {
+			printColor(ColorRed, problems[0]+"\n")
+			continue
+		}
+		q.Steps = append(q.Steps, step)
+	}
+}
+
+// askCommands runs a command-line question and grades each step. A step
+// allows config.CommandTries commands before the accepted one is shown.
+func askCommands(q Question) AnswerRecord {
+	prompts := stepPrompts(q)
+	typed := make([]string, len(q.Steps))
+	passed := 0
+
+	for i, step := range q.Steps {
+		if step.Task != "" {
+			printColor(ColorYellow, "\n"+trf("Step %d of %d: %s", i+1, len(q.Steps), step.Task)+"\n")
+		}
+		ok := false
+		for try := 1; try <= config.CommandTries && !ok; try++ {
+			printColor(ColorGreen+ColorBold, prompts[i]+" ")
+			typed[i] = readInput()
+			ok = stepAccepts(step, q.Shell, typed[i])
+			if !ok && try < config.CommandTries {
+				printColor(ColorRed, tr("% Invalid or incomplete command, try again.")+"\n")
+			}
+		}
+
+		if ok {
+			passed++
+			printColor(ColorGreen, tr("✓ Accepted")+"\n")
+		} else {
+			printColor(ColorRed, "✗ "+trf("Expected: %s", examplePattern(step.Accept[0]))+"\n")
+		}
+		if step.Output != "" {
+			printColor(ColorWhite, step.Output+"\n")
+		}
+	}
+	return newPartialAnswer(q, typed, passed)
+}
+
+// gradedParts returns how many steps or lab checks a question is graded on
+func gradedParts(q Question) int {
+	if q.Lab != nil {
+		return len(q.Lab.Checks)
+	}
+	return len(q.Steps)
+}
+
+// newPartialAnswer records a command-line or lab answer with its share of
+// credit
+func newPartialAnswer(q Question, typed []string, passed int) AnswerRecord {
+	parts := gradedParts(q)
+	a := AnswerRecord{QuestionID: q.ID, Chosen: -1, Typed: typed, Correct: parts > 0 && passed == parts}
+	if !a.Correct && passed > 0 {
+		a.Credit = float64(passed) / float64(parts)
+	}
+	return a
+}
+
+// commandReviewLines shows each step with what was typed
+func commandReviewLines(q Question, a AnswerRecord) []pageLine {
+	var lines []pageLine
+	prompts := stepPrompts(q)
+	for i, step := range q.Steps {
+		typed := ""
+		if i < len(a.Typed) {
+			typed = a.Typed[i]
+		}
+		if stepAccepts(step, q.Shell, typed) {
+			lines = append(lines, pageLine{ColorGreen, fmt.Sprintf("   ✓ %s %s", prompts[i], typed)})
+			continue
+		}
+		lines = append(lines, pageLine{ColorRed, fmt.Sprintf("   ✗ %s %s", prompts[i], typed)})
+		lines = append(lines, pageLine{ColorGreen, "     " + trf("Expected: %s", examplePattern(step.Accept[0]))})
+	}
+	return lines
+}
+
+// commandHTML shows the steps of a command-line question on a web page
+func commandHTML(q Question, showAnswer bool) string {
+	var b strings.Builder
+	b.WriteString("<ol class=\"steps\">\n")
+	prompts := stepPrompts(q)
+	for i, step := range q.Steps {
+		fmt.Fprintf(&b, "<li>%s<pre class=\"prompt\">%s ", inlineHTML(step.Task), html.EscapeString(prompts[i]))
+		if showAnswer {
+			b.WriteString(html.EscapeString(examplePattern(step.Accept[0])))
+		}
+		b.WriteString("</pre></li>\n")
+	}
+	b.WriteString("</ol>\n")
+	return b.String()
+}
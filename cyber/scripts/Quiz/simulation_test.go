@@ -0,0 +1,153 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"  ls\t-la  /tmp ", []string{"ls", "-la", "/tmp"}},
+		{`grep "two words" 'it''s' file`, []string{"grep", "two words", "its", "file"}},
+		{`echo "" x`, []string{"echo", "", "x"}},
+		{"nmap --top-ports=100 --open", []string{"nmap", "--top-ports", "100", "--open"}},
+		{"dd if=/dev/zero", []string{"dd", "if=/dev/zero"}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := splitCommand(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitCommand(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCommandPatterns(t *testing.T) {
+	tests := []struct {
+		pattern, shell, command string
+		want                    bool
+	}{
+		// Options in any order, with values, spellings and optional words
+		{"nmap <ip> -sS --top-ports <num>", "bash", "nmap -sS --top-ports 100 10.0.0.5", true},
+		{"nmap <ip> -sS --top-ports <num>", "bash", "nmap --top-ports=100 10.0.0.5 -sS", true},
+		{"nmap <ip> -sS --top-ports <num>", "bash", "sudo nmap 10.0.0.5 --top-ports 100 -sS", true},
+		{"nmap <ip> -sS --top-ports <num>", "bash", "nmap 10.0.0.5 -sS --top-ports", false},
+		{"nmap <ip> -sS --top-ports <num>", "bash", "nmap 10.0.0.5 -sS --top-ports many", false},
+		{"nmap <ip> -sS --top-ports <num>", "bash", "nmap 10.0.0.5 --top-ports 100", false},
+		{"nmap <ip> -sS --top-ports <num>", "bash", "nmap 10.0.0.5 -sS -sS --top-ports 100", false},
+		{"nmap <ip> -sS --top-ports <num>", "bash", "nmap 10.0.0.999 -sS --top-ports 100", false},
+		{"nmap <ip> -sS --top-ports <num>", "bash", "NMAP 10.0.0.5 -sS --top-ports 100", false},
+		{"nmap <cidr> -sS|--syn -Pn?", "bash", "nmap --syn 10.0.0.0/24", true},
+		{"nmap <cidr> -sS|--syn -Pn?", "bash", "nmap -Pn -sS 10.0.0.0/24", true},
+		{"nmap <cidr> -sS|--syn -Pn?", "bash", "nmap -sS 10.0.0.5", false},
+		{"nmap <host> -p <ports>", "bash", "nmap -p 22,80,8000-8100 scanme.example.org", true},
+		{"nmap <host> -p <ports>", "bash", "nmap -p 22, scanme.example.org", false},
+		{"nmap <host> -p <ports>", "bash", "nmap -p 22 fe80::1", true},
+		{"grep -r <any> <any>", "bash", `grep -r "pass word" /etc`, true},
+		{"sudo -l", "bash", "sudo -l", true},
+		{"ls", "bash", "sudo", false},
+		{"ls", "bash", "", false},
+		{"cp" + strings.Repeat(" <any>", 14), "bash", "cp" + strings.Repeat(" f", 14), true},
+		{"cp" + strings.Repeat(" <any>", 14), "bash", "cp" + strings.Repeat(" f", 15), false},
+		{"cp <num> <any> <num> <any> -r", "bash", "cp -r a 1 b 2", true},
+
+		// IOS: in order, abbreviable and case-insensitive
+		{"conf[igure] t[erminal]", "ios", "conf t", true},
+		{"conf[igure] t[erminal]", "ios", "CONFIGURE TERMINAL", true},
+		{"conf[igure] t[erminal]", "ios", "con t", false},
+		{"conf[igure] t[erminal]", "ios", "conf termx", false},
+		{"conf[igure] t[erminal]", "ios", "t conf", false},
+		{"int[erface] g[igabitethernet]0/1", "ios", "int g0/1", true},
+		{"int[erface] g[igabitethernet]0/1", "ios", "interface GigabitEthernet0/1", true},
+		{"int[erface] g[igabitethernet]0/1", "ios", "int g0/2", false},
+		{"sh[ow] ip int[erface] br[ief]?", "ios", "sh ip int", true},
+		{"sh[ow] ip int[erface] br[ief]?", "ios", "show ip interface brief", true},
+		{"sh[ow] ip int[erface] br[ief]?", "ios", "sh ip int br extra", false},
+		{"ip add[ress] <ip> <ip>", "ios", "ip add 10.1.1.1 255.255.255.0", true},
+		{"no? sh[utdown]", "ios", "no shut", true},
+		{"no? sh[utdown]", "ios", "shut", true},
+	}
+	for _, tt := range tests {
+		p, err := compilePattern(tt.pattern, tt.shell)
+		if err != nil {
+			t.Errorf("compilePattern(%q): %v", tt.pattern, err)
+			continue
+		}
+		if got := p.match(tt.command); got != tt.want {
+			t.Errorf("%s pattern %q matching %q = %v, want %v", tt.shell, tt.pattern, tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestCompilePatternErrors(t *testing.T) {
+	tests := []struct{ pattern, want string }{
+		{"", "empty pattern"},
+		{"   ", "empty pattern"},
+		{"nmap <address>", "unknown placeholder <address>"},
+		{"conf[ig[ure]]", "cannot read"},
+		{"ls -a|", "cannot read"},
+	}
+	for _, tt := range tests {
+		_, err := compilePattern(tt.pattern, "bash")
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("compilePattern(%q): got %v, want %q", tt.pattern, err, tt.want)
+		}
+	}
+}
+
+func TestExamplePattern(t *testing.T) {
+	tests := []struct{ pattern, want string }{
+		{"conf[igure] t[erminal]", "configure terminal"},
+		{"nmap <ip> -sS|--syn -Pn? --top-ports=<num>", "nmap <ip> -sS --top-ports=<num>"},
+		{"cat file | grep x", "cat file | grep x"},
+	}
+	for _, tt := range tests {
+		if got := examplePattern(tt.pattern); got != tt.want {
+			t.Errorf("examplePattern(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestStepQuestions(t *testing.T) {
+	q := Question{ID: "s1", Shell: "ios", Steps: []SimStep{
+		{Task: "Enable", Accept: []string{"en[able]"}},
+		{Task: "Configure", Prompt: "Router#", Accept: []string{"conf[igure] t[erminal]"}},
+		{Task: "Hostname", Prompt: "Router(config)#", Accept: []string{"hostname <any>"}},
+		{Task: "Save"},
+	}}
+	if got, want := stepPrompts(q), []string{"Router>", "Router#", "Router(config)#", "Router(config)#"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("prompts %q, want %q", got, want)
+	}
+	if !stepAccepts(q.Steps[1], q.Shell, "CONF T") || stepAccepts(q.Steps[1], q.Shell, "enable") {
+		t.Error("step 2 accepts the wrong commands")
+	}
+
+	q.Shell = "zsh"
+	q.Steps[0].Accept = append(q.Steps[0].Accept, "en[a[ble]]")
+	problems := strings.Join(stepProblems(q), "\n")
+	for _, want := range []string{"shell must be one of bash, ios", "step 1: cannot read", "step 4 accepts no commands"} {
+		if !strings.Contains(problems, want) {
+			t.Errorf("problems:\n%s\nwant one containing %q", problems, want)
+		}
+	}
+	if got := stepPrompts(Question{Steps: []SimStep{{}}}); got[0] != "$" {
+		t.Errorf("bash prompt %q", got[0])
+	}
+}
+
+func FuzzCommandPattern(f *testing.F) {
+	f.Add("nmap <ip> -sS|--syn -Pn? --top-ports <num>", "sudo nmap --top-ports=10 -sS 10.0.0.1")
+	f.Add("conf[igure] t[erminal]", "CONF T")
+	f.Add("no? sh[utdown]", `"no" 'shut'`)
+	f.Fuzz(func(t *testing.T, pattern, command string) {
+		for _, shell := range shells {
+			if p, err := compilePattern(pattern, shell); err == nil {
+				p.match(command)
+			}
+		}
+		examplePattern(pattern)
+	})
+}
@@ -0,0 +1,420 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Progress sync between installations. Every installation has a device ID
+// and every attempt a unique ID and the device it was recorded on. A sync
+// bundle carries a device's verified users and attempts, signed with its
+// Ed25519 key; importing merges the attempt logs as grow-only sets (see
+// mergeUser), so devices converge whatever order bundles are exchanged in.
+//
+// The attempts in a bundle carry MACs made with the exporting device's
+// integrity key, which the importer does not have. The bundle therefore
+// also carries a head per user, hashed over that user's attempt chain and
+// scores, and a user is only merged if the chain is unbroken and matches
+// its head. The device key is sealed with the admin password, so a bundle
+// can only be signed by a session that was given it.
+//
+// A sync directory, such as a shared folder, holds one bundle per device.
+// On startup the quiz imports the other devices' bundles from it and
+// writes its own.
+
+const (
+	syncFormat    = "cyber-quiz-sync/v1"
+	syncExtension = ".cqsync"
+)
+
+// SyncPeer is a device whose bundles are accepted
+type SyncPeer struct {
+	Device    string `json:"device"`
+	Name      string `json:"name"`
+	PublicKey string `json:"public_key"`
+}
+
+type syncPayload struct {
+	Format     string            `json:"format"`
+	Device     string            `json:"device"`
+	DeviceName string            `json:"device_name"`
+	ExportedAt time.Time         `json:"exported_at"`
+	Users      []User            `json:"users"`
+	ChainHeads map[string]string `json:"chain_heads"` // by user ID, see syncChainHead
+}
+
+// syncBundle keeps the payload as raw bytes so the signature is checked
+// over exactly what was signed
+type syncBundle struct {
+	Payload   json.RawMessage `json:"payload"`
+	PublicKey string          `json:"public_key"`
+	Signature string          `json:"signature"`
+}
+
+// deviceKey returns the key this device signs its sync bundles with,
+// creating it on first use. It is sealed with the admin password, which
+// sessions that save results have been given. A key older versions left
+// in plain text is used as it is until the password is known, then sealed.
+func deviceKey() (ed25519.PrivateKey, error) {
+	var seed []byte
+	switch {
+	case adminConfig.SealedDeviceKey != nil:
+		if adminPassword == "" {
+			return nil, errors.New("the device key is sealed with the admin password")
+		}
+		var err error
+		if seed, err = unseal(adminPassword, adminConfig.SealedDeviceKey); err != nil {
+			return nil, fmt.Errorf("cannot unseal the device key: %w", err)
+		}
+	case adminConfig.DeviceKey != "":
+		seed, _ = base64.StdEncoding.DecodeString(adminConfig.DeviceKey)
+	}
+	valid := len(seed) == ed25519.SeedSize
+
+	if adminPassword == "" {
+		if !valid {
+			return nil, errors.New("the device key is created with the admin password")
+		}
+		return ed25519.NewKeyFromSeed(seed), nil
+	}
+	if !valid {
+		seed = randomBytes(ed25519.SeedSize)
+	}
+	key := ed25519.NewKeyFromSeed(seed)
+	if adminConfig.SealedDeviceKey == nil || !valid {
+		sealed, err := seal(adminPassword, seed)
+		if err != nil {
+			return nil, err
+		}
+		adminConfig.SealedDeviceKey, adminConfig.DeviceKey = sealed, ""
+		adminConfig.DevicePublicKey = base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey))
+		saveAdminConfig()
+	}
+	return key, nil
+}
+
+// devicePublicKey returns the public half of the device key without
+// unsealing it, or nil before the key exists
+func devicePublicKey() ed25519.PublicKey {
+	pub, err := base64.StdEncoding.DecodeString(adminConfig.DevicePublicKey)
+	if err == nil && len(pub) == ed25519.PublicKeySize {
+		return pub
+	}
+	if key, err := deviceKey(); err == nil {
+		return key.Public().(ed25519.PublicKey)
+	}
+	return nil
+}
+
+func ensureDeviceID() {
+	if adminConfig.DeviceID != "" {
+		return
+	}
+	adminConfig.DeviceID = fmt.Sprintf("%x", randomBytes(8))
+	adminConfig.DeviceName, _ = os.Hostname()
+	saveAdminConfig()
+}
+
+// attemptID returns an attempt's ID. Attempts recorded before IDs existed
+// get one derived from their contents, so copies of the same users.json
+// on different machines agree on it.
+func attemptID(a Attempt) string {
+	if a.ID != "" {
+		return a.ID
+	}
+	answers := make([]string, len(a.Answers))
+	for i, ans := range a.Answers {
+		answers[i] = fmt.Sprintf("%s:%d", ans.QuestionID, ans.Chosen)
+	}
+	sum := sha256.Sum256([]byte(strings.Join([]string{a.Category, a.Module, a.AssignmentID,
+		canonicalTime(a.StartedAt), canonicalTime(a.FinishedAt), strings.Join(answers, ",")}, "\n")))
+	return fmt.Sprintf("legacy-%x", sum[:16])
+}
+
+// syncChainHead hashes a user's exported attempts, in chain order, and
+// scores
+func syncChainHead(u User) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "sync-head/v1\n%s\n", u.ID)
+	for _, a := range u.Attempts {
+		fmt.Fprintf(h, "attempt\x1e%s\x1e%s\n", strings.Join(attemptParts(u.ID, a.Prev, a), "\x1f"), a.MAC)
+	}
+	categories := make([]string, 0, len(u.Scores))
+	for category := range u.Scores {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	for _, category := range categories {
+		modules := make([]string, 0, len(u.Scores[category]))
+		for module := range u.Scores[category] {
+			modules = append(modules, module)
+		}
+		sort.Strings(modules)
+		for _, module := range modules {
+			s := u.Scores[category][module]
+			fmt.Fprintf(h, "score\x1e%s\x1e%s\x1e%d/%d %.4f\x1e%s\x1e%s\n",
+				category, module, s.Correct, s.Total, s.Points, canonicalTime(s.LastTaken), s.MAC)
+		}
+	}
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// checkSyncChain checks that a user's attempts in a bundle form one
+// unbroken chain and match the head the device exported with them
+func checkSyncChain(u User, head string) error {
+	if head == "" {
+		return errors.New("no chain head in the bundle")
+	}
+	prev := ""
+	for i, a := range u.Attempts {
+		if a.MAC == "" {
+			return fmt.Errorf("attempt %d is unsigned", i+1)
+		}
+		if a.Prev != prev {
+			return fmt.Errorf("chain broken at attempt %d", i+1)
+		}
+		prev = a.MAC
+	}
+	if head != syncChainHead(u) {
+		return errors.New("records do not match the chain head")
+	}
+	return nil
+}
+
+func deviceLabel(device, name string) string {
+	if name == "" {
+		return device
+	}
+	return fmt.Sprintf("%s (%s)", name, device)
+}
+
+// exportSyncBundle writes this device's verified progress to path
+func exportSyncBundle(path string) (int, error) {
+	key, err := deviceKey()
+	if err != nil {
+		return 0, err
+	}
+	payload := syncPayload{
+		Format:     syncFormat,
+		Device:     adminConfig.DeviceID,
+		DeviceName: adminConfig.DeviceName,
+		ExportedAt: time.Now().UTC(),
+		ChainHeads: make(map[string]string),
+	}
+
+	unsigned := 0
+	for _, u := range loadAllUsers() {
+		if len(u.integrityIssues) > 0 {
+			continue
+		}
+		out := User{ID: u.ID, Name: u.Name, CreatedAt: u.CreatedAt, Groups: u.Groups,
+			Scores: make(map[string]map[string]Score)}
+		for _, a := range u.Attempts {
+			if a.MAC == "" {
+				unsigned++
+				continue
+			}
+			out.Attempts = append(out.Attempts, a)
+		}
+		for category, modules := range u.Scores {
+			for module, s := range modules {
+				if s.MAC == "" {
+					unsigned++
+					continue
+				}
+				if out.Scores[category] == nil {
+					out.Scores[category] = make(map[string]Score)
+				}
+				out.Scores[category][module] = s
+			}
+		}
+		payload.Users = append(payload.Users, out)
+		payload.ChainHeads[out.ID] = syncChainHead(out)
+	}
+
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return unsigned, err
+	}
+	data, _ := json.Marshal(syncBundle{
+		Payload:   raw,
+		PublicKey: base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)),
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key, raw)),
+	})
+
+	// Write then rename, so readers of a shared folder never see half a file
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return unsigned, err
+	}
+	return unsigned, os.Rename(tmp, path)
+}
+
+// readSyncBundle checks a bundle's signature and returns its payload and
+// signing key
+func readSyncBundle(path string) (syncPayload, ed25519.PublicKey, error) {
+	var payload syncPayload
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return payload, nil, err
+	}
+
+	var bundle syncBundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return payload, nil, fmt.Errorf("not a sync bundle: %w", err)
+	}
+	pub, err1 := base64.StdEncoding.DecodeString(bundle.PublicKey)
+	sig, err2 := base64.StdEncoding.DecodeString(bundle.Signature)
+	if err1 != nil || err2 != nil || len(pub) != ed25519.PublicKeySize {
+		return payload, nil, errors.New("bundle signature is malformed")
+	}
+	if !ed25519.Verify(pub, bundle.Payload, sig) {
+		return payload, nil, errors.New("bundle signature does not match its contents")
+	}
+
+	if err := json.Unmarshal(bundle.Payload, &payload); err != nil {
+		return payload, nil, err
+	}
+	if payload.Format != syncFormat {
+		return payload, nil, fmt.Errorf("unsupported sync format %q", payload.Format)
+	}
+	return payload, pub, nil
+}
+
+// checkSyncPeer decides whether a bundle's device and key are accepted,
+// adding the device as a peer when trust is set
+func checkSyncPeer(payload syncPayload, pub ed25519.PublicKey, trust bool) error {
+	encoded := base64.StdEncoding.EncodeToString(pub)
+	for _, p := range adminConfig.SyncPeers {
+		if p.Device != payload.Device {
+			continue
+		}
+		if p.PublicKey != encoded {
+			return fmt.Errorf("device %s is signed by a different key than before (fingerprint %s)",
+				deviceLabel(payload.Device, payload.DeviceName), keyFingerprint(pub))
+		}
+		return nil
+	}
+
+	if !trust {
+		return fmt.Errorf("device %s is not a known peer (key fingerprint %s); import with -trust after checking the fingerprint",
+			deviceLabel(payload.Device, payload.DeviceName), keyFingerprint(pub))
+	}
+	adminConfig.SyncPeers = append(adminConfig.SyncPeers, SyncPeer{
+		Device:    payload.Device,
+		Name:      payload.DeviceName,
+		PublicKey: encoded,
+	})
+	saveAdminConfig()
+	return nil
+}
+
+// importSyncBundle merges a trusted bundle into the local users
+func importSyncBundle(path string, trust bool) (syncPayload, mergeReport, error) {
+	var report mergeReport
+
+	payload, pub, err := readSyncBundle(path)
+	if err != nil {
+		return payload, report, err
+	}
+	if payload.Device == adminConfig.DeviceID {
+		return payload, report, errors.New("this bundle was exported by this device")
+	}
+	if err := checkSyncPeer(payload, pub, trust); err != nil {
+		return payload, report, err
+	}
+
+	var incoming []User
+	for _, u := range payload.Users {
+		if err := checkSyncChain(u, payload.ChainHeads[u.ID]); err != nil {
+			report.usersRejected = append(report.usersRejected, fmt.Sprintf("%s (%v)", u.ID, err))
+			continue
+		}
+		incoming = append(incoming, u)
+	}
+	users := mergeUsers(loadAllUsers(), incoming, &report)
+	saveAllUsers(users)
+	return payload, report, nil
+}
+
+// syncWithDir imports every other device's bundle from dir, then writes
+// this device's bundle there. It returns a line per bundle for display.
+func syncWithDir(dir string) []pageLine {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return []pageLine{{ColorRed, fmt.Sprintf("⚠ Sync folder unavailable: %v", err)}}
+	}
+
+	var lines []pageLine
+	own := adminConfig.DeviceID + syncExtension
+	files, _ := filepath.Glob(filepath.Join(dir, "*"+syncExtension))
+	for _, file := range files {
+		if filepath.Base(file) == own {
+			continue
+		}
+
+		payload, report, err := importSyncBundle(file, false)
+		if err != nil {
+			lines = append(lines, pageLine{ColorYellow, fmt.Sprintf("⚠ Skipped %s: %v", filepath.Base(file), err)})
+			continue
+		}
+		color := ColorWhite
+		if report.attemptsAdded > 0 || report.usersAdded > 0 {
+			color = ColorGreen
+		}
+		lines = append(lines, pageLine{color, fmt.Sprintf("✓ %s: %d attempts and %d new users merged",
+			deviceLabel(payload.Device, payload.DeviceName), report.attemptsAdded, report.usersAdded)})
+		if len(report.usersSkipped) > 0 {
+			lines = append(lines, pageLine{ColorRed, fmt.Sprintf("  not merged, local records failed the integrity check: %s",
+				strings.Join(report.usersSkipped, ", "))})
+		}
+		if len(report.usersRejected) > 0 {
+			lines = append(lines, pageLine{ColorRed, fmt.Sprintf("  not merged, records from the bundle failed the check: %s",
+				strings.Join(report.usersRejected, ", "))})
+		}
+	}
+
+	if _, err := exportSyncBundle(filepath.Join(dir, own)); err != nil {
+		lines = append(lines, pageLine{ColorRed, fmt.Sprintf("⚠ Could not write this device's bundle: %v", err)})
+	}
+	return lines
+}
+
+// syncOnStartup reconciles with the sync directory, if one is set
+func syncOnStartup() {
+	if adminConfig.SyncDir == "" {
+		return
+	}
+	if recordsLocked() {
+		printColor(ColorYellow, "⚠ Sync skipped: merged records cannot be signed in practice mode\n")
+		return
+	}
+
+	printColor(ColorCyan, fmt.Sprintf("🔄 Syncing with %s\n", adminConfig.SyncDir))
+	lines := syncWithDir(adminConfig.SyncDir)
+	for _, line := range lines {
+		printColor(line.color, "   "+line.text+"\n")
+	}
+	if len(lines) > 0 {
+		pause(1 * time.Second)
+	}
+}
+
+// exportToSyncDir refreshes this device's bundle after progress changes
+func exportToSyncDir() {
+	if adminConfig.SyncDir == "" {
+		return
+	}
+	path := filepath.Join(adminConfig.SyncDir, adminConfig.DeviceID+syncExtension)
+	if _, err := exportSyncBundle(path); err != nil {
+		printColor(ColorRed, fmt.Sprintf("⚠ Could not update the sync folder: %v\n", err))
+	}
+}
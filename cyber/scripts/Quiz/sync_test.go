@@ -0,0 +1,306 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var syncEpoch = time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+
+// recordAttempt appends a signed attempt finished minutes after syncEpoch
+func recordAttempt(u *User, id string, minutes int) {
+	finished := syncEpoch.Add(time.Duration(minutes) * time.Minute)
+	a := Attempt{ID: id, Origin: "test", Category: "Networking", Module: "Subnetting",
+		StartedAt: finished.Add(-time.Minute), FinishedAt: finished,
+		Answers: []AnswerRecord{{QuestionID: "q1", Chosen: minutes % 2, Correct: minutes%2 == 0}}}
+	signAttempt(u, &a)
+	u.Attempts = append(u.Attempts, a)
+	sealChainHead(u)
+}
+
+func setScore(u *User, correct, minutes int) {
+	s := Score{Correct: correct, Total: 4, LastTaken: syncEpoch.Add(time.Duration(minutes) * time.Minute)}
+	s.MAC = scoreMAC(u.ID, "Networking", "Subnetting", s)
+	if u.Scores == nil {
+		u.Scores = make(map[string]map[string]Score)
+	}
+	u.Scores["Networking"] = map[string]Score{"Subnetting": s}
+}
+
+func attemptIDs(u User) []string {
+	var ids []string
+	for _, a := range u.Attempts {
+		ids = append(ids, attemptID(a))
+	}
+	return ids
+}
+
+// copyUser deep-copies the parts of a user a merge changes
+func copyUser(u User) User {
+	data, _ := json.Marshal(u)
+	var c User
+	json.Unmarshal(data, &c)
+	return c
+}
+
+func TestMergeUserConverges(t *testing.T) {
+	useDataDir(t)
+	useIntegrityKey(t)
+
+	// Both devices started from the same history, then diverged
+	base := User{ID: "01HSYNC0000000000000000000", Name: "Ada"}
+	recordAttempt(&base, "shared", 0)
+	a, b := copyUser(base), copyUser(base)
+	recordAttempt(&a, "a1", 10)
+	recordAttempt(&b, "b1", 5)
+	recordAttempt(&a, "a2", 20)
+	recordAttempt(&b, "b2", 20) // same time as a2, ordered by ID
+	setScore(&a, 2, 20)
+	setScore(&b, 3, 21)
+
+	ab, ba := copyUser(a), copyUser(b)
+	var r1, r2 mergeReport
+	mergeUser(&ab, b, &r1)
+	mergeUser(&ba, a, &r2)
+
+	want := []string{"shared", "b1", "a1", "a2", "b2"}
+	if got := attemptIDs(ab); !reflect.DeepEqual(got, want) {
+		t.Errorf("a after merging b: %v, want %v", got, want)
+	}
+	if got := attemptIDs(ba); !reflect.DeepEqual(got, want) {
+		t.Errorf("b after merging a: %v, want %v", got, want)
+	}
+	if r1.attemptsAdded != 2 || r2.attemptsAdded != 2 {
+		t.Errorf("attempts added %d and %d, want 2 each", r1.attemptsAdded, r2.attemptsAdded)
+	}
+	for _, u := range []User{ab, ba} {
+		if s := u.Scores["Networking"]["Subnetting"]; s.Correct != 3 {
+			t.Errorf("score %d/4, want the newer 3/4", s.Correct)
+		}
+		verifyUserRecords(&u)
+		if len(u.integrityIssues) > 0 {
+			t.Errorf("merged records do not verify: %v", u.integrityIssues)
+		}
+	}
+
+	// Merging again changes nothing
+	var r3 mergeReport
+	again := copyUser(ab)
+	mergeUser(&again, b, &r3)
+	if r3.attemptsAdded != 0 || r3.scoresUpdated != 0 || !reflect.DeepEqual(attemptIDs(again), want) {
+		t.Errorf("second merge added %d attempts and %d scores", r3.attemptsAdded, r3.scoresUpdated)
+	}
+}
+
+func TestMergeUserSkipsUntrustedRecords(t *testing.T) {
+	useDataDir(t)
+	useIntegrityKey(t)
+
+	ours := User{ID: "01HSYNC0000000000000000000", Attempts: []Attempt{{Category: "Legacy", FinishedAt: syncEpoch}}}
+	theirs := User{ID: ours.ID}
+	recordAttempt(&theirs, "good", 1)
+	recordAttempt(&theirs, "forged", 2)
+	theirs.Attempts[1].tampered = true
+	theirs.Attempts = append(theirs.Attempts, Attempt{ID: "unsigned", FinishedAt: syncEpoch})
+	setScore(&theirs, 4, 3)
+	s := theirs.Scores["Networking"]["Subnetting"]
+	s.tampered = true
+	theirs.Scores["Networking"]["Subnetting"] = s
+
+	var report mergeReport
+	mergeUser(&ours, theirs, &report)
+	if report.attemptsAdded != 1 || report.skipped != 3 {
+		t.Errorf("added %d, skipped %d; want 1 and 3", report.attemptsAdded, report.skipped)
+	}
+	if len(ours.Attempts) != 2 || ours.Attempts[0].MAC != "" || ours.Attempts[1].ID != "good" {
+		t.Errorf("attempts %+v: want the legacy attempt first and unsigned, then the good one", ours.Attempts)
+	}
+	if _, ok := ours.Scores["Networking"]["Subnetting"]; ok {
+		t.Error("a tampered score was merged")
+	}
+
+	// Users whose local records fail the check are not merged into
+	tampered := User{ID: ours.ID, integrityIssues: []string{"contents were modified"}}
+	users := mergeUsers([]User{tampered}, []User{theirs}, &report)
+	if len(users[0].Attempts) != 0 || len(report.usersSkipped) != 1 {
+		t.Errorf("merged into a tampered user: %+v", report)
+	}
+}
+
+func TestAttemptIDLegacy(t *testing.T) {
+	a := Attempt{Category: "Networking", Module: "Subnetting", FinishedAt: syncEpoch,
+		Answers: []AnswerRecord{{QuestionID: "q1", Chosen: 2}}}
+	id := attemptID(a)
+	if !strings.HasPrefix(id, "legacy-") || attemptID(a) != id {
+		t.Fatalf("legacy ID %q is not stable", id)
+	}
+	a.Answers[0].Chosen = 1
+	if attemptID(a) == id {
+		t.Error("different attempts share a legacy ID")
+	}
+	a.ID = "01HID"
+	if attemptID(a) != "01HID" {
+		t.Error("a recorded ID was not used")
+	}
+}
+
+func TestSyncBundles(t *testing.T) {
+	// Device A records an attempt and exports it
+	useDataDir(t)
+	useIntegrityKey(t)
+	ensureDeviceID()
+	u := User{ID: "01HSYNC0000000000000000000", Name: "Ada"}
+	recordAttempt(&u, "from-a", 1)
+	saveAllUsers([]User{u})
+	bundle := filepath.Join(t.TempDir(), "a"+syncExtension)
+	if _, err := exportSyncBundle(bundle); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := importSyncBundle(bundle, true); err == nil {
+		t.Error("a device imported its own bundle")
+	}
+	keyA, err := deviceKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if adminConfig.DeviceKey != "" || strings.Contains(mustRead(t, adminFile), base64.StdEncoding.EncodeToString(keyA.Seed())) {
+		t.Error("admin.json holds the device key in plain text")
+	}
+	adminPassword = ""
+	if _, err := exportSyncBundle(bundle); err == nil {
+		t.Error("a bundle was signed without the admin password")
+	}
+
+	// Device B only takes it once the peer is trusted
+	useDataDir(t)
+	useIntegrityKey(t)
+	ensureDeviceID()
+	if _, _, err := importSyncBundle(bundle, false); err == nil || !strings.Contains(err.Error(), "not a known peer") {
+		t.Fatalf("untrusted import: %v", err)
+	}
+	_, report, err := importSyncBundle(bundle, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.usersAdded != 1 || report.attemptsAdded != 1 {
+		t.Errorf("report %+v, want one user and attempt added", report)
+	}
+	users := loadAllUsers()
+	if len(users) != 1 || len(users[0].integrityIssues) > 0 || attemptIDs(users[0])[0] != "from-a" {
+		t.Fatalf("imported users %+v", users)
+	}
+	if _, _, err := importSyncBundle(bundle, false); err != nil {
+		t.Errorf("importing from a trusted peer again: %v", err)
+	}
+
+	// A bundle edited after signing is refused
+	data, _ := os.ReadFile(bundle)
+	edited := filepath.Join(t.TempDir(), "edited"+syncExtension)
+	os.WriteFile(edited, []byte(strings.Replace(string(data), `"chosen":1`, `"chosen":0`, 1)), 0644)
+	if string(data) == mustRead(t, edited) {
+		t.Fatal("the test did not change the bundle")
+	}
+	if _, _, err := importSyncBundle(edited, false); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("edited bundle: %v", err)
+	}
+
+	// Records the device did not export are refused even when the bundle
+	// is signed with its key
+	forgeries := []struct {
+		name   string
+		change func(p *syncPayload)
+		want   string
+	}{
+		{"answer changed", func(p *syncPayload) { p.Users[0].Attempts[0].Answers[0].Correct = true }, "do not match the chain head"},
+		{"attempt added", func(p *syncPayload) {
+			p.Users[0].Attempts = append(p.Users[0].Attempts, Attempt{ID: "forged", Prev: p.Users[0].Attempts[0].MAC, MAC: "x"})
+		}, "do not match the chain head"},
+		{"chain broken", func(p *syncPayload) { p.Users[0].Attempts[0].Prev = "x" }, "chain broken at attempt 1"},
+		{"no chain head", func(p *syncPayload) { p.ChainHeads = nil }, "no chain head"},
+	}
+	for _, f := range forgeries {
+		forged := resignBundle(t, bundle, keyA, f.change)
+		_, report, err := importSyncBundle(forged, false)
+		if err != nil || len(report.usersRejected) != 1 || !strings.Contains(report.usersRejected[0], f.want) || report.attemptsAdded != 0 {
+			t.Errorf("%s: %+v, %v; want the user rejected with %q", f.name, report, err, f.want)
+		}
+	}
+
+	// So is one signed by another key claiming to be device A
+	peers := adminConfig.SyncPeers
+	useDataDir(t)
+	useIntegrityKey(t)
+	adminConfig.DeviceID = peers[0].Device
+	saveAllUsers([]User{u})
+	impostor := filepath.Join(t.TempDir(), "impostor"+syncExtension)
+	if _, err := exportSyncBundle(impostor); err != nil {
+		t.Fatal(err)
+	}
+	if devicePublicKey().Equal(keyA.Public()) {
+		t.Fatal("the impostor has device A's key")
+	}
+	adminConfig.DeviceID, adminConfig.SyncPeers = "device-b", peers
+	if _, _, err := importSyncBundle(impostor, true); err == nil || !strings.Contains(err.Error(), "different key") {
+		t.Errorf("impostor bundle: %v", err)
+	}
+}
+
+// resignBundle copies a bundle with its payload changed and signed again
+// with key
+func resignBundle(t *testing.T, path string, key ed25519.PrivateKey, change func(p *syncPayload)) string {
+	t.Helper()
+	var bundle syncBundle
+	var payload syncPayload
+	json.Unmarshal([]byte(mustRead(t, path)), &bundle)
+	json.Unmarshal(bundle.Payload, &payload)
+	change(&payload)
+	bundle.Payload, _ = json.Marshal(payload)
+	bundle.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, bundle.Payload))
+	data, _ := json.Marshal(bundle)
+	out := filepath.Join(t.TempDir(), "forged"+syncExtension)
+	os.WriteFile(out, data, 0644)
+	return out
+}
+
+func TestLegacyDeviceKey(t *testing.T) {
+	useDataDir(t)
+	seed := randomBytes(32)
+	adminConfig.DeviceKey = base64.StdEncoding.EncodeToString(seed)
+
+	// Used as it is until the admin password is known
+	key, err := deviceKey()
+	if err != nil || !key.Equal(ed25519.NewKeyFromSeed(seed)) {
+		t.Fatalf("legacy key: %v", err)
+	}
+	adminPassword = "admin-password"
+	if key, err = deviceKey(); err != nil || !key.Equal(ed25519.NewKeyFromSeed(seed)) {
+		t.Fatalf("after the password: %v", err)
+	}
+	if adminConfig.DeviceKey != "" || adminConfig.SealedDeviceKey == nil || !devicePublicKey().Equal(key.Public()) {
+		t.Error("the legacy key was not sealed")
+	}
+
+	adminPassword = ""
+	if _, err := deviceKey(); err == nil {
+		t.Error("the sealed key was used without the password")
+	}
+	if !devicePublicKey().Equal(key.Public()) {
+		t.Error("the public key is not shown while the key is sealed")
+	}
+}
+
+func mustRead(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -0,0 +1,32 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// termState is unused on platforms without raw mode support
+type termState struct{}
+
+var errNoRawMode = errors.New("raw terminal mode not supported on this platform")
+
+// isTerminal reports false so the line-based interface is used
+func isTerminal(fd int) bool {
+	return false
+}
+
+func makeRaw(fd int) (*termState, error) {
+	return nil, errNoRawMode
+}
+
+func restoreTerm(fd int, state *termState) error {
+	return errNoRawMode
+}
+
+func getTermSize(fd int) (width, height int, err error) {
+	return 0, 0, errNoRawMode
+}
+
+func notifyResize(ch chan<- os.Signal) {}
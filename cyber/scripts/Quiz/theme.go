@@ -0,0 +1,150 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Colour themes and accessible output. A theme maps the colour names used
+// throughout the interface to escape sequences. The auto theme picks
+// default, or none when NO_COLOR is set, TERM is dumb or the output is not
+// a terminal, such as a log file.
+//
+// ASCII mode replaces box drawing and symbols with plain characters and
+// drops emoji. Screen-reader mode implies ASCII mode and no colour, never
+// clears the screen and states progress and results in sentences.
+
+var themes = map[string]map[string]string{
+	"default": {
+		"red": "\033[31m", "green": "\033[32m", "yellow": "\033[33m", "blue": "\033[34m",
+		"magenta": "\033[35m", "cyan": "\033[36m", "white": "\033[37m",
+	},
+	"bright": {
+		"red": "\033[91m", "green": "\033[92m", "yellow": "\033[93m", "blue": "\033[94m",
+		"magenta": "\033[95m", "cyan": "\033[96m", "white": "\033[97m",
+	},
+	// Red-green safe (deuteranopia and protanopia), after the Okabe-Ito
+	// palette: right answers are blue and wrong ones orange
+	"colorblind": {
+		"red": "\033[38;5;208m", "green": "\033[38;5;33m", "yellow": "\033[38;5;220m", "blue": "\033[38;5;75m",
+		"magenta": "\033[38;5;175m", "cyan": "\033[38;5;117m", "white": "\033[37m",
+	},
+	// Blue-yellow safe (tritanopia): warnings are magenta rather than yellow
+	"tritanopia": {
+		"red": "\033[38;5;160m", "green": "\033[38;5;30m", "yellow": "\033[38;5;163m", "blue": "\033[38;5;30m",
+		"magenta": "\033[38;5;163m", "cyan": "\033[38;5;37m", "white": "\033[37m",
+	},
+	"mono": {"bold": "\033[1m"},
+	"none": {},
+	"auto": nil,
+}
+
+var (
+	// activeTheme is the theme in use after resolving auto
+	activeTheme string
+
+	// themeReason explains why auto chose the theme it did
+	themeReason string
+
+	asciiOnly    bool
+	screenReader bool
+
+	// dumbTerminal is set when cursor control is unavailable, so the screen
+	// is never cleared
+	dumbTerminal bool
+)
+
+func themeNames() []string {
+	var names []string
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// stdoutIsTerminal reports whether output goes to a terminal rather than
+// a file or pipe
+func stdoutIsTerminal() bool {
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// applyOutputSettings resolves the theme and accessibility settings
+func applyOutputSettings() {
+	screenReader = config.ScreenReader
+	asciiOnly = config.ASCII || screenReader
+	dumbTerminal = os.Getenv("TERM") == "dumb" || !stdoutIsTerminal()
+
+	activeTheme, themeReason = config.Theme, ""
+	if activeTheme == "auto" {
+		switch {
+		case screenReader:
+			activeTheme, themeReason = "none", "screen-reader mode"
+		case os.Getenv("NO_COLOR") != "":
+			activeTheme, themeReason = "none", "NO_COLOR is set"
+		case os.Getenv("TERM") == "dumb":
+			activeTheme, themeReason = "none", "TERM is dumb"
+		case !stdoutIsTerminal():
+			activeTheme, themeReason = "none", "output is not a terminal"
+		default:
+			activeTheme = "default"
+		}
+	}
+
+	t := themes[activeTheme]
+	ColorRed, ColorGreen, ColorYellow, ColorBlue = t["red"], t["green"], t["yellow"], t["blue"]
+	ColorMagenta, ColorCyan, ColorWhite = t["magenta"], t["cyan"], t["white"]
+	ColorBold, ColorReset = "\033[1m", "\033[0m"
+	if activeTheme == "none" {
+		ColorBold, ColorReset = "", ""
+	}
+}
+
+// asciiReplacer maps box drawing and symbols to plain characters
+var asciiReplacer = strings.NewReplacer(
+	"═", "=", "║", "|", "─", "-", "│", "|", "┌", "+", "┐", "+", "└", "+", "┘", "+", "╔", "+", "╗", "+", "╚", "+", "╝", "+",
+	"█", "#", "░", ".", "▁", "_", "▂", ".", "▃", "-", "▄", "-", "▅", "=", "▆", "=", "▇", "#",
+	"✓", "[+]", "✗", "[-]", "❌", "[-]", "⚠", "[!]",
+	"·", "-", "•", "*", "…", "...", "›", ">", "→", "->", "⬅", "<-", "↑", "^", "↓", "v",
+	"‘", "'", "’", "'", "“", `"`, "”", `"`, "–", "-", "—", "-",
+)
+
+// readerReplacer words status symbols for screen readers, which would
+// otherwise read the ASCII replacements as punctuation
+var readerReplacer = strings.NewReplacer(
+	"✓ ", "", "✗ ", "", "❌ ", "", "⚠ ", "Warning: ",
+)
+
+// isSymbol reports whether r is an emoji or pictograph
+func isSymbol(r rune) bool {
+	return r >= 0x2190 && r <= 0x2bff || r >= 0x1f000 || r == 0xfe0f || r == 0x200d
+}
+
+// outputText prepares text for the terminal in ASCII mode
+func outputText(s string) string {
+	if !asciiOnly {
+		return s
+	}
+	if screenReader {
+		s = readerReplacer.Replace(s)
+	}
+	s = asciiReplacer.Replace(s)
+
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		if !isSymbol(r) {
+			b.WriteRune(r)
+			continue
+		}
+		// Drop the emoji and the space that followed it
+		if i < len(s) && s[i] == ' ' {
+			i++
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,74 @@
+package main
+
+import "testing"
+
+func TestOutputText(t *testing.T) {
+	useConfig(t)
+	tests := []struct {
+		in                   string
+		plain, ascii, reader string
+	}{
+		{"✓ Correct!", "✓ Correct!", "[+] Correct!", "Correct!"},
+		{"✗ Wrong", "✗ Wrong", "[-] Wrong", "Wrong"},
+		{"⚠ Sync skipped", "⚠ Sync skipped", "[!] Sync skipped", "Warning: Sync skipped"},
+		{"🔄 Syncing with /mnt", "🔄 Syncing with /mnt", "Syncing with /mnt", "Syncing with /mnt"},
+		{"📊 Results ▶️ next", "📊 Results ▶️ next", "Results next", "Results next"},
+		{"╔══╗ █░ …", "╔══╗ █░ …", "+==+ #. ...", "+==+ #. ..."},
+		{"“quoted” — dash", "“quoted” — dash", `"quoted" - dash`, `"quoted" - dash`},
+		{"¿Qué puerto? ñ", "¿Qué puerto? ñ", "¿Qué puerto? ñ", "¿Qué puerto? ñ"},
+	}
+	for _, tt := range tests {
+		for _, mode := range []struct {
+			name          string
+			ascii, reader bool
+			want          string
+		}{
+			{"default", false, false, tt.plain},
+			{"ascii", true, false, tt.ascii},
+			{"screen reader", false, true, tt.reader},
+		} {
+			config.ASCII, config.ScreenReader = mode.ascii, mode.reader
+			applyOutputSettings()
+			if got := outputText(tt.in); got != mode.want {
+				t.Errorf("%s: outputText(%q) = %q, want %q", mode.name, tt.in, got, mode.want)
+			}
+		}
+	}
+}
+
+func TestApplyOutputSettings(t *testing.T) {
+	useConfig(t)
+	tests := []struct {
+		name          string
+		theme         string
+		reader        bool
+		noColor, term string
+		want, reason  string
+	}{
+		{"screen reader", "auto", true, "", "xterm", "none", "screen-reader mode"},
+		{"NO_COLOR", "auto", false, "1", "xterm", "none", "NO_COLOR is set"},
+		{"dumb terminal", "auto", false, "", "dumb", "none", "TERM is dumb"},
+		{"piped output", "auto", false, "", "xterm", "none", "output is not a terminal"},
+		{"chosen theme", "colorblind", false, "1", "dumb", "colorblind", ""},
+	}
+	for _, tt := range tests {
+		t.Setenv("NO_COLOR", tt.noColor)
+		t.Setenv("TERM", tt.term)
+		config.Theme, config.ScreenReader, config.ASCII = tt.theme, tt.reader, false
+		applyOutputSettings()
+		if activeTheme != tt.want || themeReason != tt.reason {
+			t.Errorf("%s: theme %q (%s), want %q (%s)", tt.name, activeTheme, themeReason, tt.want, tt.reason)
+		}
+		if asciiOnly != tt.reader {
+			t.Errorf("%s: ASCII mode %v", tt.name, asciiOnly)
+		}
+		if activeTheme == "none" && ColorRed+ColorBold+ColorReset != "" {
+			t.Errorf("%s: colour codes are still set", tt.name)
+		}
+	}
+
+	// Right answers are blue and wrong ones orange in the colour-blind theme
+	if ColorGreen != "\033[38;5;33m" || ColorRed != "\033[38;5;208m" {
+		t.Errorf("colorblind theme: green %q, red %q", ColorGreen, ColorRed)
+	}
+}
@@ -0,0 +1,907 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Full-screen terminal UI. When stdin and stdout are both terminals the
+// menus, quiz screens and admin lists are drawn full-screen and driven by
+// arrow or vim keys. Otherwise everything falls back to the numbered,
+// line-based prompts.
+
+var (
+	tuiEnabled bool
+	rawState   *termState
+	resized    atomic.Bool
+)
+
+type keyCode int
+
+const (
+	keyNone keyCode = iota
+	keyRune
+	keyUp
+	keyDown
+	keyLeft
+	keyRight
+	keyHome
+	keyEnd
+	keyPgUp
+	keyPgDn
+	keyEnter
+	keyEscape
+	keyBackspace
+	keyDelete
+	keyTab
+	keySave
+	keyResize
+)
+
+type keyEvent struct {
+	code keyCode
+	r    rune
+}
+
+func (k keyEvent) is(r rune) bool {
+	return k.code == keyRune && k.r == r
+}
+
+// initTerminal switches to the full-screen interface when possible
+func initTerminal(plain bool) {
+	if plain || !isTerminal(int(os.Stdin.Fd())) || !isTerminal(int(os.Stdout.Fd())) {
+		return
+	}
+	tuiEnabled = true
+
+	winch := make(chan os.Signal, 1)
+	notifyResize(winch)
+	go func() {
+		for range winch {
+			resized.Store(true)
+		}
+	}()
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		shutdownTerminal()
+		os.Exit(130)
+	}()
+
+	// Use the alternate screen so the user's scrollback is left untouched
+	fmt.Print("\033[?1049h")
+}
+
+// shutdownTerminal restores the terminal before the program exits
+func shutdownTerminal() {
+	if !tuiEnabled {
+		return
+	}
+	leaveRaw()
+	fmt.Print("\033[?25h\033[?1049l")
+}
+
+func enterRaw() {
+	if rawState != nil {
+		return
+	}
+	state, err := makeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return
+	}
+	rawState = state
+	fmt.Print("\033[?25l")
+}
+
+func leaveRaw() {
+	if rawState == nil {
+		return
+	}
+	restoreTerm(int(os.Stdin.Fd()), rawState)
+	rawState = nil
+	fmt.Print("\033[?25h")
+}
+
+// readKey waits for a key press in raw mode. A window resize is reported
+// as keyResize so the caller can redraw.
+func readKey() keyEvent {
+	for {
+		if resized.Swap(false) {
+			return keyEvent{code: keyResize}
+		}
+
+		r, _, err := reader.ReadRune()
+		if err != nil {
+			// Raw reads time out periodically so resizes are noticed
+			continue
+		}
+
+		switch r {
+		case 3: // Ctrl-C
+			shutdownTerminal()
+			os.Exit(130)
+		case 19: // Ctrl-S
+			return keyEvent{code: keySave}
+		case '\r', '\n':
+			return keyEvent{code: keyEnter}
+		case 127, 8:
+			return keyEvent{code: keyBackspace}
+		case '\t':
+			return keyEvent{code: keyTab}
+		case 27:
+			return readEscape()
+		default:
+			return keyEvent{code: keyRune, r: r}
+		}
+	}
+}
+
+// readEscape decodes the CSI/SS3 sequences sent for arrows and paging keys
+func readEscape() keyEvent {
+	r, _, err := reader.ReadRune()
+	if err != nil || (r != '[' && r != 'O') {
+		return keyEvent{code: keyEscape}
+	}
+
+	var params strings.Builder
+	for {
+		c, _, err := reader.ReadRune()
+		if err != nil {
+			return keyEvent{code: keyEscape}
+		}
+		if c >= 0x40 && c <= 0x7e {
+			switch c {
+			case 'A':
+				return keyEvent{code: keyUp}
+			case 'B':
+				return keyEvent{code: keyDown}
+			case 'C':
+				return keyEvent{code: keyRight}
+			case 'D':
+				return keyEvent{code: keyLeft}
+			case 'H':
+				return keyEvent{code: keyHome}
+			case 'F':
+				return keyEvent{code: keyEnd}
+			case '~':
+				switch params.String() {
+				case "1", "7":
+					return keyEvent{code: keyHome}
+				case "4", "8":
+					return keyEvent{code: keyEnd}
+				case "3":
+					return keyEvent{code: keyDelete}
+				case "5":
+					return keyEvent{code: keyPgUp}
+				case "6":
+					return keyEvent{code: keyPgDn}
+				}
+			}
+			return keyEvent{code: keyNone}
+		}
+		params.WriteRune(c)
+	}
+}
+
+func termSize() (int, int) {
+	w, h, err := getTermSize(int(os.Stdout.Fd()))
+	if err != nil || w <= 0 || h <= 0 {
+		return 80, 24
+	}
+	return w, h
+}
+
+// drawScreen replaces the whole screen with the given lines
+func drawScreen(lines []string) {
+	var b strings.Builder
+	b.WriteString("\033[H\033[2J")
+	for i, line := range lines {
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString(line)
+	}
+	fmt.Print(b.String())
+}
+
+// runeWidth approximates the number of terminal cells a rune occupies
+func runeWidth(r rune) int {
+	switch {
+	case r == 0xfe0f || unicode.Is(unicode.Mn, r):
+		return 0
+	case r >= 0x1f300 && r <= 0x1faff, r >= 0x2600 && r <= 0x27bf, r >= 0x1100 && r <= 0x115f,
+		r >= 0x2e80 && r <= 0xa4cf, r >= 0xac00 && r <= 0xd7a3, r >= 0xf900 && r <= 0xfaff,
+		r >= 0xff00 && r <= 0xff60:
+		return 2
+	}
+	return 1
+}
+
+// escapeLen is the length of the colour escape sequence at the start of s,
+// or 0. Escape sequences take no space on screen.
+func escapeLen(s string) int {
+	if len(s) < 2 || s[0] != '\033' || s[1] != '[' {
+		return 0
+	}
+	for i := 2; i < len(s); i++ {
+		if s[i] >= 0x40 && s[i] <= 0x7e {
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+func displayWidth(s string) int {
+	w := 0
+	for i := 0; i < len(s); {
+		if l := escapeLen(s[i:]); l > 0 {
+			i += l
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		w += runeWidth(r)
+		i += size
+	}
+	return w
+}
+
+// fit truncates text to the given width, marking the cut with an ellipsis.
+// Colour escapes are kept.
+func fit(s string, width int) string {
+	if displayWidth(s) <= width {
+		return s
+	}
+	if width <= 1 {
+		return strings.Repeat(".", width)
+	}
+	var b strings.Builder
+	w := 0
+	for i := 0; i < len(s); {
+		if l := escapeLen(s[i:]); l > 0 {
+			b.WriteString(s[i : i+l])
+			i += l
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		rw := runeWidth(r)
+		if w+rw > width-1 {
+			break
+		}
+		b.WriteRune(r)
+		w += rw
+		i += size
+	}
+	b.WriteString("…")
+	return b.String()
+}
+
+func padRight(s string, width int) string {
+	s = fit(s, width)
+	if pad := width - displayWidth(s); pad > 0 {
+		s += strings.Repeat(" ", pad)
+	}
+	return s
+}
+
+// wrapText word-wraps plain text to the given width
+func wrapText(text string, width int) []string {
+	if width < 10 {
+		width = 10
+	}
+
+	var lines []string
+	for _, para := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(para) {
+			for displayWidth(word) > width {
+				if line != "" {
+					lines = append(lines, line)
+					line = ""
+				}
+				runes := []rune(word)
+				cut := 0
+				for w := 0; cut < len(runes) && w+runeWidth(runes[cut]) <= width; cut++ {
+					w += runeWidth(runes[cut])
+				}
+				lines = append(lines, string(runes[:cut]))
+				word = string(runes[cut:])
+			}
+			switch {
+			case line == "":
+				line = word
+			case displayWidth(line)+1+displayWidth(word) <= width:
+				line += " " + word
+			default:
+				lines = append(lines, line)
+				line = word
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// progressBar renders a bar such as [██████░░░░░░] 5/10
+func progressBar(done, total, width int) string {
+	if total <= 0 {
+		total = 1
+	}
+	filled := done * width / total
+	if filled > width {
+		filled = width
+	}
+	return fmt.Sprintf("[%s%s] %d/%d", strings.Repeat("█", filled), strings.Repeat("░", width-filled), done, total)
+}
+
+func tuiHeader(title, color string, width int) string {
+	return color + ColorBold + "\033[7m" + padRight(" "+title, width) + ColorReset
+}
+
+func tuiFooter(help string, width int) string {
+	return "\033[2m" + fit(help, width) + ColorReset
+}
+
+// listItem is one selectable row of a listView
+type listItem struct {
+	label  string
+	detail string
+	group  string // heading shown above the first item of each group
+}
+
+// listView is a scrollable, keyboard-driven selection list
+type listView struct {
+	title    string
+	color    string
+	subtitle string
+	items    []listItem
+	help     string
+	cursor   int
+	offset   int
+}
+
+type listRow struct {
+	text string
+	item int // -1 for group headings
+}
+
+// run shows the list until the user picks an item or backs out. It returns
+// the selected index and '\r' for Enter, the index and the key for one of
+// the extra action keys, or -1 when the list was cancelled.
+func (l *listView) run(actions string) (int, rune) {
+	enterRaw()
+	defer leaveRaw()
+
+	for {
+		page := l.draw()
+		k := readKey()
+
+		switch {
+		case k.code == keyUp || k.is('k'):
+			l.move(-1)
+		case k.code == keyDown || k.is('j'):
+			l.move(1)
+		case k.code == keyPgUp:
+			l.move(-page)
+		case k.code == keyPgDn:
+			l.move(page)
+		case k.code == keyHome || k.is('g'):
+			l.cursor = 0
+		case k.code == keyEnd || k.is('G'):
+			l.cursor = len(l.items) - 1
+		case k.code == keyEnter || k.code == keyRight || k.is('l'):
+			if len(l.items) > 0 {
+				return l.cursor, '\r'
+			}
+		case k.code == keyEscape || k.code == keyLeft || k.is('q') || k.is('h'):
+			return -1, 0
+		case k.code == keyRune && k.r >= '1' && k.r <= '9' && len(l.items) <= 9:
+			if n := int(k.r - '1'); n < len(l.items) {
+				return n, '\r'
+			}
+		case k.code == keyRune && strings.ContainsRune(actions, k.r):
+			if len(l.items) > 0 {
+				return l.cursor, k.r
+			}
+		}
+	}
+}
+
+func (l *listView) move(delta int) {
+	l.cursor += delta
+	if l.cursor >= len(l.items) {
+		l.cursor = len(l.items) - 1
+	}
+	if l.cursor < 0 {
+		l.cursor = 0
+	}
+}
+
+// draw renders the list and returns the number of visible rows
+func (l *listView) draw() int {
+	w, h := termSize()
+
+	lines := []string{tuiHeader(l.title, l.color, w)}
+	if l.subtitle != "" {
+		lines = append(lines, ColorBold+ColorYellow+fit(l.subtitle, w)+ColorReset)
+	}
+	lines = append(lines, "")
+
+	var rows []listRow
+	cursorRow, lastGroup := 0, ""
+	for i, item := range l.items {
+		if item.group != "" && item.group != lastGroup {
+			rows = append(rows, listRow{text: item.group, item: -1})
+			lastGroup = item.group
+		}
+		if i == l.cursor {
+			cursorRow = len(rows)
+		}
+		rows = append(rows, listRow{item: i})
+	}
+
+	visible := h - len(lines) - 2
+	if visible < 1 {
+		visible = 1
+	}
+
+	// Keep the cursor on screen, along with its group heading if possible
+	top := cursorRow
+	if top > 0 && rows[top-1].item < 0 {
+		top--
+	}
+	if top < l.offset {
+		l.offset = top
+	}
+	if cursorRow >= l.offset+visible {
+		l.offset = cursorRow - visible + 1
+	}
+	if maxOffset := len(rows) - visible; l.offset > maxOffset {
+		l.offset = maxOffset
+	}
+	if l.offset < 0 {
+		l.offset = 0
+	}
+
+	if len(rows) == 0 {
+		lines = append(lines, ColorYellow+"  (empty)"+ColorReset)
+	}
+	for r := l.offset; r < len(rows) && r < l.offset+visible; r++ {
+		row := rows[r]
+		if row.item < 0 {
+			lines = append(lines, ColorCyan+ColorBold+fit(row.text, w)+ColorReset)
+			continue
+		}
+
+		item := l.items[row.item]
+		indent := "  "
+		if item.group == "" {
+			indent = ""
+		}
+		text := indent + " " + item.label
+		if item.detail != "" {
+			text += "  " + item.detail
+		}
+
+		if row.item == l.cursor {
+			lines = append(lines, ColorBold+"\033[7m"+padRight(indent+"›"+text[len(indent)+1:], w)+ColorReset)
+		} else {
+			label := fit(indent+" "+item.label, w)
+			detail := ""
+			if item.detail != "" && displayWidth(label)+2 < w {
+				detail = ColorYellow + fit("  "+item.detail, w-displayWidth(label)) + ColorReset
+			}
+			lines = append(lines, label+detail)
+		}
+	}
+
+	for len(lines) < h-1 {
+		lines = append(lines, "")
+	}
+
+	help := l.help
+	if help == "" {
+		help = "↑/↓ j/k move · Enter select · Esc/q back"
+	}
+	if len(l.items) > 0 {
+		help += fmt.Sprintf("  (%d/%d)", l.cursor+1, len(l.items))
+	}
+	lines = append(lines, tuiFooter(help, w))
+
+	drawScreen(lines)
+	return visible
+}
+
+// menu describes a numbered menu. In full-screen mode it is a navigable
+// list; otherwise it is printed with a numbered "Enter choice" prompt.
+type menu struct {
+	title    string
+	color    string
+	subtitle string
+	items    []string
+	back     int // 1-based item chosen by Esc/q, 0 for none
+}
+
+// runMenu returns the user's choice as the 1-based number they would have
+// typed, so callers can switch on it the same way in both modes
+func runMenu(m menu) string {
+	if tuiEnabled {
+		l := listView{title: m.title, color: m.color, subtitle: m.subtitle}
+		for _, item := range m.items {
+			l.items = append(l.items, listItem{label: item})
+		}
+		idx, _ := l.run("")
+		if idx < 0 {
+			if m.back == 0 {
+				return ""
+			}
+			return strconv.Itoa(m.back)
+		}
+		return strconv.Itoa(idx + 1)
+	}
+
+	clearScreen()
+	printBoxHeader(m.title, m.color)
+	if m.subtitle != "" {
+		printColor(ColorBold+ColorYellow, m.subtitle+"\n")
+	}
+	fmt.Println()
+
+	for i, item := range m.items {
+		fmt.Printf("%d. %s\n", i+1, outputText(item))
+	}
+	printColor(ColorYellow, "\n"+trf("Enter choice (1-%d): ", len(m.items)))
+
+	return readInput()
+}
+
+// pickItem lets the user choose one of the items and returns its index,
+// or -1 if nothing was chosen
+func pickItem(title, color string, items []listItem) int {
+	if tuiEnabled {
+		l := listView{title: title, color: color, items: items}
+		idx, _ := l.run("")
+		return idx
+	}
+
+	clearScreen()
+	printBoxHeader(title, color)
+
+	lastGroup := ""
+	for i, item := range items {
+		if item.group != "" && item.group != lastGroup {
+			printColor(ColorCyan+ColorBold, fmt.Sprintf("\n%s:\n", item.group))
+			lastGroup = item.group
+		}
+		indent := ""
+		if item.group != "" {
+			indent = "  "
+		}
+		if i == 0 && item.group == "" {
+			fmt.Println()
+		}
+		printColor(ColorCyan, fmt.Sprintf("%s%d. ", indent, i+1))
+		printColor(ColorWhite, item.label)
+		if item.detail != "" {
+			printColor(ColorYellow, " "+item.detail)
+		}
+		fmt.Println()
+	}
+
+	printColor(ColorYellow, "\n"+trf("Enter choice (1-%d) or 0 to cancel: ", len(items)))
+	var choice int
+	fmt.Sscanf(readInput(), "%d", &choice)
+	if choice < 1 || choice > len(items) {
+		return -1
+	}
+	return choice - 1
+}
+
+// formField is one editable line of a form
+type formField struct {
+	label string
+	value string
+}
+
+// form edits a set of text fields in place
+type form struct {
+	title   string
+	color   string
+	fields  []formField
+	message string
+}
+
+// run lets the user edit the fields. It returns false if the form was
+// cancelled with Esc.
+func (f *form) run() bool {
+	enterRaw()
+	defer leaveRaw()
+
+	active := 0
+	pos := len([]rune(f.fields[active].value))
+
+	for {
+		f.draw(active, pos)
+		k := readKey()
+		value := []rune(f.fields[active].value)
+
+		switch k.code {
+		case keyEscape:
+			return false
+		case keySave:
+			return true
+		case keyEnter:
+			if active == len(f.fields)-1 {
+				return true
+			}
+			active++
+			pos = len([]rune(f.fields[active].value))
+		case keyDown, keyTab:
+			active = (active + 1) % len(f.fields)
+			pos = len([]rune(f.fields[active].value))
+		case keyUp:
+			active = (active + len(f.fields) - 1) % len(f.fields)
+			pos = len([]rune(f.fields[active].value))
+		case keyLeft:
+			if pos > 0 {
+				pos--
+			}
+		case keyRight:
+			if pos < len(value) {
+				pos++
+			}
+		case keyHome:
+			pos = 0
+		case keyEnd:
+			pos = len(value)
+		case keyBackspace:
+			if pos > 0 {
+				value = append(value[:pos-1], value[pos:]...)
+				f.fields[active].value = string(value)
+				pos--
+			}
+		case keyDelete:
+			if pos < len(value) {
+				value = append(value[:pos], value[pos+1:]...)
+				f.fields[active].value = string(value)
+			}
+		case keyRune:
+			if unicode.IsPrint(k.r) {
+				value = append(value[:pos], append([]rune{k.r}, value[pos:]...)...)
+				f.fields[active].value = string(value)
+				pos++
+			}
+		}
+	}
+}
+
+func (f *form) draw(active, pos int) {
+	w, h := termSize()
+	lines := []string{tuiHeader(f.title, f.color, w), ""}
+
+	labelWidth := 0
+	for _, field := range f.fields {
+		if lw := displayWidth(field.label); lw > labelWidth {
+			labelWidth = lw
+		}
+	}
+	valueWidth := w - labelWidth - 4
+	if valueWidth < 5 {
+		valueWidth = 5
+	}
+
+	cursorCol := 0
+	for i, field := range f.fields {
+		runes := []rune(field.value)
+		start := 0
+		if i == active && pos >= valueWidth {
+			start = pos - valueWidth + 1
+		}
+		end := start + valueWidth
+		if end > len(runes) {
+			end = len(runes)
+		}
+		shown := string(runes[start:end])
+
+		label := padRight(field.label, labelWidth)
+		if i == active {
+			lines = append(lines, ColorBold+ColorCyan+label+ColorReset+" : "+"\033[4m"+padRight(shown, valueWidth)+ColorReset)
+			cursorCol = labelWidth + 4 + displayWidth(string(runes[start:pos]))
+		} else {
+			lines = append(lines, label+" : "+shown)
+		}
+	}
+
+	lines = append(lines, "")
+	if f.message != "" {
+		lines = append(lines, ColorRed+fit(f.message, w)+ColorReset)
+	}
+	for len(lines) < h-1 {
+		lines = append(lines, "")
+	}
+	lines = append(lines, tuiFooter("↑/↓/Tab field · Enter next/save · Ctrl-S save · Esc cancel", w))
+
+	drawScreen(lines)
+	// Place the visible cursor inside the active field
+	fmt.Printf("\033[%d;%dH\033[?25h", active+3, cursorCol)
+}
+
+// tuiQuestion shows a quiz question full-screen and returns the index of
+// the chosen option
+func tuiQuestion(heading string, index, total int, q Question) int {
+	enterRaw()
+	defer leaveRaw()
+
+	cursor := 0
+	for {
+		drawQuestion(heading, index, total, q, cursor, -1)
+		k := readKey()
+
+		switch {
+		case k.code == keyUp || k.is('k'):
+			if cursor > 0 {
+				cursor--
+			}
+		case k.code == keyDown || k.is('j'):
+			if cursor < len(q.Options)-1 {
+				cursor++
+			}
+		case k.code == keyEnter || k.code == keyRight || k.is('l'):
+			return cursor
+		case k.code == keyRune && k.r >= '1' && k.r <= '9':
+			if n := int(k.r - '1'); n < len(q.Options) {
+				return n
+			}
+		}
+	}
+}
+
+// tuiAnswerFeedback reveals the correct answer and waits for a key press
+func tuiAnswerFeedback(heading string, index, total int, q Question, chosen int) {
+	enterRaw()
+	defer leaveRaw()
+
+	for {
+		drawQuestion(heading, index, total, q, chosen, chosen)
+		if k := readKey(); k.code != keyResize {
+			return
+		}
+	}
+}
+
+// drawQuestion renders a question screen. When reveal is not -1 the
+// correct answer and the learner's choice are marked.
+func drawQuestion(heading string, index, total int, q Question, cursor, reveal int) {
+	w, h := termSize()
+
+	barWidth := w - 20
+	if barWidth > 40 {
+		barWidth = 40
+	}
+	if barWidth < 10 {
+		barWidth = 10
+	}
+
+	lines := []string{
+		tuiHeader(heading, ColorCyan, w),
+		ColorYellow + trf("Question %d of %d", index+1, total) + "  " + ColorReset +
+			ColorGreen + progressBar(index, total, barWidth) + ColorReset,
+		"",
+	}
+	for _, line := range questionBody(q, w, ColorWhite+ColorBold) {
+		lines = append(lines, line.color+line.text+ColorReset)
+	}
+	lines = append(lines, "")
+
+	for i, opt := range q.Options {
+		text := fit(fmt.Sprintf(" %d. %s", i+1, opt), w-3)
+		switch {
+		case reveal >= 0 && i == q.Answer:
+			lines = append(lines, ColorGreen+ColorBold+"✓ "+text+ColorReset)
+		case reveal >= 0 && i == reveal:
+			lines = append(lines, ColorRed+ColorBold+"✗ "+text+ColorReset)
+		case reveal < 0 && i == cursor:
+			lines = append(lines, ColorBold+"\033[7m"+padRight("›"+text, w)+ColorReset)
+		default:
+			lines = append(lines, "  "+text)
+		}
+	}
+
+	help := tr("↑/↓ j/k move · Enter or 1-9 answer")
+	if reveal >= 0 {
+		lines = append(lines, "")
+		if reveal == q.Answer {
+			lines = append(lines, ColorGreen+ColorBold+tr("✓ Correct!")+ColorReset)
+		} else {
+			lines = append(lines, ColorRed+ColorBold+tr("✗ Incorrect.")+ColorReset)
+		}
+		if q.Explanation != "" {
+			lines = append(lines, "")
+			for _, line := range renderMarkdown(q.Explanation, w, ColorCyan) {
+				lines = append(lines, line.color+line.text+ColorReset)
+			}
+		}
+		help = tr("Press any key to continue")
+	}
+
+	for len(lines) < h-1 {
+		lines = append(lines, "")
+	}
+	lines = append(lines, tuiFooter(help, w))
+	drawScreen(lines)
+}
+
+// pageLine is one line of a report shown by showPager
+type pageLine struct {
+	color string
+	text  string
+}
+
+// showPager displays a report. Full-screen mode scrolls it with the
+// keyboard; line mode prints it and waits for Enter.
+func showPager(title, color string, lines []pageLine) {
+	if !tuiEnabled {
+		clearScreen()
+		printBoxHeader(title, color)
+		fmt.Println()
+		for _, line := range lines {
+			printColor(line.color, line.text+"\n")
+		}
+		printColor(ColorYellow, "\n"+tr("Press Enter to continue..."))
+		readInput()
+		return
+	}
+
+	enterRaw()
+	defer leaveRaw()
+
+	offset := 0
+	for {
+		w, h := termSize()
+		visible := h - 3
+		if visible < 1 {
+			visible = 1
+		}
+		if maxOffset := len(lines) - visible; offset > maxOffset {
+			offset = maxOffset
+		}
+		if offset < 0 {
+			offset = 0
+		}
+
+		screen := []string{tuiHeader(title, color, w), ""}
+		for i := offset; i < len(lines) && i < offset+visible; i++ {
+			screen = append(screen, lines[i].color+fit(lines[i].text, w)+ColorReset)
+		}
+		for len(screen) < h-1 {
+			screen = append(screen, "")
+		}
+		screen = append(screen, tuiFooter(fmt.Sprintf("↑/↓ j/k PgUp/PgDn scroll · Esc/q back  (%d/%d)", offset+1, len(lines)), w))
+		drawScreen(screen)
+
+		k := readKey()
+		switch {
+		case k.code == keyUp || k.is('k'):
+			offset--
+		case k.code == keyDown || k.is('j'):
+			offset++
+		case k.code == keyPgUp:
+			offset -= visible
+		case k.code == keyPgDn || k.is(' '):
+			offset += visible
+		case k.code == keyHome || k.is('g'):
+			offset = 0
+		case k.code == keyEnd || k.is('G'):
+			offset = len(lines)
+		case k.code == keyEscape || k.code == keyEnter || k.is('q'):
+			return
+		}
+	}
+}
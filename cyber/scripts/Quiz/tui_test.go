@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func TestDisplayWidth(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"plain", 5},
+		{ColorGreen + "ok" + ColorReset, 2},
+		{"✓ done", 7},
+		{"🔄 sync", 7},
+		{"ñandú", 5},
+		{"é", 1},
+		{"日本", 4},
+	}
+	for _, tt := range tests {
+		if got := displayWidth(tt.in); got != tt.want {
+			t.Errorf("displayWidth(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFitAndPad(t *testing.T) {
+	tests := []struct {
+		in         string
+		width      int
+		fit, right string
+	}{
+		{"short", 8, "short", "short   "},
+		{"exactly8", 8, "exactly8", "exactly8"},
+		{"much too long", 8, "much to…", "much to…"},
+		{"日本語テキスト", 6, "日本…", "日本… "},
+		{ColorRed + "colour" + ColorReset, 4, ColorRed + "col…", ColorRed + "col…"},
+		{"abc", 1, ".", "."},
+	}
+	for _, tt := range tests {
+		if got := fit(tt.in, tt.width); got != tt.fit {
+			t.Errorf("fit(%q, %d) = %q, want %q", tt.in, tt.width, got, tt.fit)
+		}
+		if got := padRight(tt.in, tt.width); got != tt.right {
+			t.Errorf("padRight(%q, %d) = %q, want %q", tt.in, tt.width, got, tt.right)
+		}
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	tests := []struct {
+		in    string
+		width int
+		want  []string
+	}{
+		{"the quick brown fox jumps", 10, []string{"the quick", "brown fox", "jumps"}},
+		{"first\n\nthird", 10, []string{"first", "", "third"}},
+		{"abcdefghijklmnopqrstuvwxyz", 10, []string{"abcdefghij", "klmnopqrst", "uvwxyz"}},
+		{"a b", 3, []string{"a b"}}, // widths below 10 are raised to 10
+	}
+	for _, tt := range tests {
+		got := wrapText(tt.in, tt.width)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("wrapText(%q, %d) = %q, want %q", tt.in, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestProgressBar(t *testing.T) {
+	tests := []struct {
+		done, total int
+		want        string
+	}{
+		{0, 4, "[░░░░] 0/4"},
+		{2, 4, "[██░░] 2/4"},
+		{4, 4, "[████] 4/4"},
+		{5, 4, "[████] 5/4"},
+		{0, 0, "[░░░░] 0/1"}, // an empty quiz counts as one question
+	}
+	for _, tt := range tests {
+		if got := progressBar(tt.done, tt.total, 4); got != tt.want {
+			t.Errorf("progressBar(%d, %d) = %q, want %q", tt.done, tt.total, got, tt.want)
+		}
+	}
+}
+
+func TestReadKey(t *testing.T) {
+	saved := reader
+	t.Cleanup(func() { reader = saved })
+
+	tests := []struct {
+		in   string
+		want keyEvent
+	}{
+		{"\033[A", keyEvent{code: keyUp}},
+		{"\033OB", keyEvent{code: keyDown}},
+		{"\033[5~", keyEvent{code: keyPgUp}},
+		{"\033[6~", keyEvent{code: keyPgDn}},
+		{"\033[1~", keyEvent{code: keyHome}},
+		{"\033[F", keyEvent{code: keyEnd}},
+		{"\033[3~", keyEvent{code: keyDelete}},
+		{"\033[1;5C", keyEvent{code: keyRight}},
+		{"\033", keyEvent{code: keyEscape}},
+		{"\r", keyEvent{code: keyEnter}},
+		{"\x7f", keyEvent{code: keyBackspace}},
+		{"\x13", keyEvent{code: keySave}},
+		{"j", keyEvent{code: keyRune, r: 'j'}},
+		{"é", keyEvent{code: keyRune, r: 'é'}},
+	}
+	for _, tt := range tests {
+		reader = bufio.NewReader(strings.NewReader(tt.in))
+		if got := readKey(); got != tt.want {
+			t.Errorf("readKey(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestListViewMove(t *testing.T) {
+	l := listView{items: make([]listItem, 5)}
+	for _, tt := range []struct{ delta, want int }{{1, 1}, {10, 4}, {-2, 2}, {-10, 0}} {
+		l.move(tt.delta)
+		if l.cursor != tt.want {
+			t.Errorf("move(%d): cursor %d, want %d", tt.delta, l.cursor, tt.want)
+		}
+	}
+}
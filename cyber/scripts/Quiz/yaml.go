@@ -0,0 +1,699 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// A small subset of YAML for question files, converted to and from the
+// JSON encoding of the same values so that the struct tags stay the only
+// schema. Supported: block mappings and sequences, plain, single- and
+// double-quoted scalars on one line, | and > block scalars, flow
+// collections on one line, and # comments. Anchors, tags, multi-document
+// streams and multi-line quoted scalars are not.
+
+// yamlMap keeps the keys of a mapping in order when writing
+type yamlMap struct {
+	keys   []string
+	values []any
+}
+
+// marshalYAML encodes v as YAML, with mapping keys in struct field order
+func marshalYAML(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	value, err := readOrderedJSON(dec)
+	if err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	switch value := value.(type) {
+	case *yamlMap:
+		writeYAMLMap(&b, value, 0)
+	case []any:
+		writeYAMLSeq(&b, value, 0)
+	default:
+		b.WriteString(yamlScalar(value) + "\n")
+	}
+	return []byte(b.String()), nil
+}
+
+// readOrderedJSON reads one JSON value, keeping object keys in order
+func readOrderedJSON(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		m := &yamlMap{}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := readOrderedJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			m.keys = append(m.keys, key.(string))
+			m.values = append(m.values, value)
+		}
+		_, err := dec.Token()
+		return m, err
+	case json.Delim('['):
+		items := []any{}
+		for dec.More() {
+			item, err := readOrderedJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		_, err := dec.Token()
+		return items, err
+	}
+	return tok, nil
+}
+
+func writeYAMLMap(b *strings.Builder, m *yamlMap, indent int) {
+	pad := strings.Repeat(" ", indent)
+	for i, key := range m.keys {
+		b.WriteString(pad + yamlScalar(key) + ":")
+		writeYAMLValue(b, m.values[i], indent)
+	}
+}
+
+func writeYAMLSeq(b *strings.Builder, items []any, indent int) {
+	pad := strings.Repeat(" ", indent)
+	for _, item := range items {
+		b.WriteString(pad + "-")
+		switch item := item.(type) {
+		case *yamlMap, []any:
+			// Write the nested block one level in and pull its first
+			// line up after the dash
+			if isEmptyYAML(item) {
+				writeYAMLValue(b, item, indent)
+				continue
+			}
+			var nested strings.Builder
+			if m, ok := item.(*yamlMap); ok {
+				writeYAMLMap(&nested, m, indent+2)
+			} else {
+				writeYAMLSeq(&nested, item.([]any), indent+2)
+			}
+			b.WriteString(" " + strings.TrimPrefix(nested.String(), pad+"  "))
+		default:
+			writeYAMLValue(b, item, indent)
+		}
+	}
+}
+
+// writeYAMLValue writes the value after a key or dash, starting on the
+// same line
+func writeYAMLValue(b *strings.Builder, v any, indent int) {
+	switch v := v.(type) {
+	case *yamlMap:
+		if len(v.keys) == 0 {
+			b.WriteString(" {}\n")
+			return
+		}
+		b.WriteString("\n")
+		writeYAMLMap(b, v, indent+2)
+	case []any:
+		if len(v) == 0 {
+			b.WriteString(" []\n")
+			return
+		}
+		b.WriteString("\n")
+		writeYAMLSeq(b, v, indent+2)
+	case string:
+		if header, ok := yamlLiteralHeader(v); ok {
+			b.WriteString(" " + header + "\n")
+			pad := strings.Repeat(" ", indent+2)
+			for _, line := range strings.Split(strings.TrimSuffix(v, "\n"), "\n") {
+				if line != "" {
+					line = pad + line
+				}
+				b.WriteString(line + "\n")
+			}
+			return
+		}
+		b.WriteString(" " + yamlScalar(v) + "\n")
+	default:
+		b.WriteString(" " + yamlScalar(v) + "\n")
+	}
+}
+
+func isEmptyYAML(v any) bool {
+	switch v := v.(type) {
+	case *yamlMap:
+		return len(v.keys) == 0
+	case []any:
+		return len(v) == 0
+	}
+	return false
+}
+
+// yamlLiteralHeader reports whether a multi-line string can be written as
+// a | block scalar, and with which chomping indicator
+func yamlLiteralHeader(s string) (string, bool) {
+	if !strings.Contains(s, "\n") || strings.HasSuffix(s, "\n\n") || !utf8.ValidString(s) {
+		return "", false
+	}
+	for _, r := range s {
+		if r < ' ' && r != '\n' && r != '\t' || r == 0x7f || r == 0xfeff {
+			return "", false
+		}
+	}
+	// The block's indentation is taken from its first non-empty line, and
+	// lines of only spaces would read back empty
+	first := true
+	for _, line := range strings.Split(strings.TrimSuffix(s, "\n"), "\n") {
+		if line != "" && strings.TrimSpace(line) == "" {
+			return "", false
+		}
+		if first && line != "" {
+			if line[0] == ' ' || line[0] == '\t' {
+				return "", false
+			}
+			first = false
+		}
+	}
+	if strings.HasSuffix(s, "\n") {
+		return "|", true
+	}
+	return "|-", true
+}
+
+var (
+	yamlIntPattern   = regexp.MustCompile(`^[-+]?[0-9]+$`)
+	yamlFloatPattern = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+)
+
+// yamlScalar formats a scalar, quoting strings that would otherwise read
+// back as something else
+func yamlScalar(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(v)
+	case json.Number:
+		return v.String()
+	case string:
+		if yamlPlainSafe(v) {
+			return v
+		}
+		return strconv.Quote(v)
+	}
+	return fmt.Sprint(v)
+}
+
+func yamlPlainSafe(s string) bool {
+	// Strings starting with a digit or dot are quoted too, as other YAML
+	// readers take some of them for numbers or dates, and so are the
+	// YAML 1.1 booleans
+	if s == "" || s != strings.TrimSpace(s) || strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@`.+0123456789") {
+		return false
+	}
+	if _, ok := yamlPlainValue(s).(string); !ok {
+		return false
+	}
+	switch strings.ToLower(s) {
+	case "y", "n", "yes", "no", "on", "off":
+		return false
+	}
+	if strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") {
+		return false
+	}
+	for _, r := range s {
+		if r < ' ' || r == 0x7f || r == 0xfeff {
+			return false
+		}
+	}
+	return true
+}
+
+// yamlPlainValue resolves a plain scalar to null, a boolean, a number or a
+// string
+func yamlPlainValue(s string) any {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	}
+	if yamlIntPattern.MatchString(s) {
+		return json.Number(strings.TrimPrefix(s, "+"))
+	}
+	if yamlFloatPattern.MatchString(s) {
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return json.Number(strconv.FormatFloat(f, 'g', -1, 64))
+		}
+	}
+	return s
+}
+
+// unmarshalYAML decodes YAML into v through its JSON encoding
+func unmarshalYAML(data []byte, v any) error {
+	p := &yamlParser{lines: strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")}
+	value, err := p.parseDocument()
+	if err != nil {
+		return err
+	}
+	plain, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(plain, v)
+}
+
+type yamlParser struct {
+	lines []string
+	pos   int
+}
+
+func (p *yamlParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("line %d: %s", p.pos+1, fmt.Sprintf(format, args...))
+}
+
+// peek skips blank and comment lines and returns the indentation and text
+// of the next line
+func (p *yamlParser) peek() (indent int, text string, ok bool) {
+	for ; p.pos < len(p.lines); p.pos++ {
+		line := p.lines[p.pos]
+		text = strings.TrimLeft(line, " ")
+		if trimmed := strings.TrimSpace(text); trimmed == "" || trimmed[0] == '#' || p.pos == 0 && trimmed == "---" {
+			continue
+		}
+		if strings.HasPrefix(text, "\t") {
+			return 0, "", false
+		}
+		return len(line) - len(text), strings.TrimRight(text, " \t"), true
+	}
+	return 0, "", false
+}
+
+func (p *yamlParser) parseDocument() (any, error) {
+	indent, _, ok := p.peek()
+	if !ok {
+		if p.pos < len(p.lines) {
+			return nil, p.errorf("tabs can't be used for indentation")
+		}
+		return nil, nil
+	}
+	value, err := p.parseBlock(indent)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, ok := p.peek(); ok || p.pos < len(p.lines) {
+		return nil, p.errorf("unexpected line after the end of the document's top level")
+	}
+	return value, nil
+}
+
+// parseBlock parses the mapping or sequence starting at the next line
+func (p *yamlParser) parseBlock(indent int) (any, error) {
+	_, text, _ := p.peek()
+	if isYAMLSeqItem(text) {
+		return p.parseSeq(indent)
+	}
+	return p.parseMap(indent)
+}
+
+func isYAMLSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func (p *yamlParser) parseMap(indent int) (any, error) {
+	m := make(map[string]any)
+	for {
+		ind, text, ok := p.peek()
+		if !ok || ind < indent || ind == indent && isYAMLSeqItem(text) {
+			return m, nil
+		}
+		if ind > indent {
+			return nil, p.errorf("unexpected indentation")
+		}
+		key, rest, ok := splitYAMLKey(text)
+		if !ok {
+			return nil, p.errorf("expected key: value")
+		}
+		if _, dup := m[key]; dup {
+			return nil, p.errorf("duplicate key %q", key)
+		}
+		p.pos++
+		value, err := p.parseValue(rest, indent, true)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = value
+	}
+}
+
+func (p *yamlParser) parseSeq(indent int) (any, error) {
+	items := []any{}
+	for {
+		ind, text, ok := p.peek()
+		if !ok || ind < indent || ind == indent && !isYAMLSeqItem(text) {
+			return items, nil
+		}
+		if ind > indent {
+			return nil, p.errorf("expected - item")
+		}
+		rest := strings.TrimLeft(text[1:], " ")
+		column := ind + len(text) - len(rest)
+
+		if _, _, isMap := splitYAMLKey(rest); isMap && rest[0] != '[' && rest[0] != '{' || isYAMLSeqItem(rest) {
+			// A nested block starting on the dash's line: parse the rest
+			// as if it were on a line of its own
+			p.lines[p.pos] = strings.Repeat(" ", column) + rest
+			item, err := p.parseBlock(column)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			continue
+		}
+
+		p.pos++
+		item, err := p.parseValue(rest, indent, false)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+}
+
+// parseValue parses what follows a key or dash. A mapping's value may be a
+// sequence at the key's own indentation.
+func (p *yamlParser) parseValue(rest string, indent int, inMap bool) (any, error) {
+	switch {
+	case rest == "" || rest[0] == '#':
+		ind, text, ok := p.peek()
+		if ok && (ind > indent || inMap && ind == indent && isYAMLSeqItem(text)) {
+			return p.parseBlock(ind)
+		}
+		return nil, nil
+	case rest[0] == '|' || rest[0] == '>':
+		return p.parseBlockScalar(rest, indent)
+	case rest[0] == '[' || rest[0] == '{':
+		f := &yamlFlow{s: rest}
+		value, err := f.parse()
+		if err == nil {
+			f.skipSpace()
+			if f.i < len(f.s) && f.s[f.i] != '#' {
+				err = errors.New("unexpected text after the closing bracket")
+			}
+		}
+		if err != nil {
+			p.pos--
+			return nil, p.errorf("%v", err)
+		}
+		return value, nil
+	}
+	value, after, err := parseYAMLScalar(rest)
+	if err == nil && after != "" && after[0] != '#' {
+		err = errors.New("unexpected text after the closing quote")
+	}
+	if err != nil {
+		p.pos--
+		return nil, p.errorf("%v", err)
+	}
+	return value, nil
+}
+
+// parseBlockScalar reads the lines of a | or > block scalar
+func (p *yamlParser) parseBlockScalar(header string, indent int) (any, error) {
+	header = strings.TrimSpace(stripYAMLComment(header))
+	folded := header[0] == '>'
+	chomp := byte(0)
+	blockIndent := 0
+	for _, c := range []byte(header[1:]) {
+		switch {
+		case c == '-' || c == '+':
+			chomp = c
+		case c >= '1' && c <= '9':
+			blockIndent = indent + int(c-'0')
+		default:
+			p.pos--
+			return nil, p.errorf("bad block scalar header %q", header)
+		}
+	}
+
+	var lines []string
+	for ; p.pos < len(p.lines); p.pos++ {
+		line := p.lines[p.pos]
+		content := strings.TrimLeft(line, " ")
+		if content == "" {
+			lines = append(lines, "")
+			continue
+		}
+		ind := len(line) - len(content)
+		if blockIndent == 0 {
+			if ind <= indent {
+				break
+			}
+			blockIndent = ind
+		}
+		if ind < blockIndent {
+			break
+		}
+		lines = append(lines, line[blockIndent:])
+	}
+
+	// Trailing blank lines belong to the block only for chomping
+	trailing := 0
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+		trailing++
+	}
+	if len(lines) == 0 {
+		return "", nil
+	}
+
+	var b strings.Builder
+	for i, line := range lines {
+		if i > 0 {
+			prev := lines[i-1]
+			if folded && line != "" && prev != "" && line[0] != ' ' && prev[0] != ' ' {
+				b.WriteByte(' ')
+			} else if !folded || prev != "" || line == "" || line[0] == ' ' {
+				b.WriteByte('\n')
+			}
+		}
+		b.WriteString(line)
+	}
+	switch chomp {
+	case 0:
+		b.WriteByte('\n')
+	case '+':
+		b.WriteString(strings.Repeat("\n", trailing+1))
+	}
+	return b.String(), nil
+}
+
+// splitYAMLKey splits "key: value", reporting false if text is not a
+// mapping entry
+func splitYAMLKey(text string) (key, rest string, ok bool) {
+	if text != "" && (text[0] == '"' || text[0] == '\'') {
+		value, after, err := parseYAMLScalar(text)
+		s, isString := value.(string)
+		if err != nil || !isString || !strings.HasPrefix(after, ":") {
+			return "", "", false
+		}
+		return s, strings.TrimSpace(after[1:]), true
+	}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '#' && i > 0 && text[i-1] == ' ' {
+			return "", "", false
+		}
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') {
+			key = strings.TrimSpace(text[:i])
+			return key, strings.TrimSpace(text[i+1:]), key != ""
+		}
+	}
+	return "", "", false
+}
+
+// parseYAMLScalar parses a quoted or plain scalar at the start of s and
+// returns what follows a quoted one. Plain scalars run to a comment.
+func parseYAMLScalar(s string) (any, string, error) {
+	switch s[0] {
+	case '\'':
+		var b strings.Builder
+		for i := 1; i < len(s); i++ {
+			if s[i] != '\'' {
+				b.WriteByte(s[i])
+				continue
+			}
+			if i+1 < len(s) && s[i+1] == '\'' {
+				b.WriteByte('\'')
+				i++
+				continue
+			}
+			return b.String(), strings.TrimLeft(s[i+1:], " "), nil
+		}
+		return nil, "", errors.New("unterminated quoted string")
+	case '"':
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				i++
+			case '"':
+				value, err := unquoteYAML(s[1:i])
+				return value, strings.TrimLeft(s[i+1:], " "), err
+			}
+		}
+		return nil, "", errors.New("unterminated quoted string")
+	}
+	return yamlPlainValue(strings.TrimSpace(stripYAMLComment(s))), "", nil
+}
+
+var yamlEscapes = map[byte]string{
+	'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n", 'v': "\v", 'f': "\f",
+	'r': "\r", 'e': "\x1b", ' ': " ", '"': `"`, '/': "/", '\\': `\`,
+	'N': "\u0085", '_': "\u00a0", 'L': "\u2028", 'P': "\u2029",
+}
+
+// unquoteYAML resolves the escapes of a double-quoted scalar
+func unquoteYAML(s string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		if i == len(s) {
+			return "", errors.New("bad escape at the end of a string")
+		}
+		if r, ok := yamlEscapes[s[i]]; ok {
+			b.WriteString(r)
+			continue
+		}
+		digits := map[byte]int{'x': 2, 'u': 4, 'U': 8}[s[i]]
+		if digits == 0 || i+digits >= len(s) {
+			return "", fmt.Errorf(`bad escape \%c`, s[i])
+		}
+		code, err := strconv.ParseUint(s[i+1:i+1+digits], 16, 32)
+		if err != nil {
+			return "", fmt.Errorf(`bad escape \%s`, s[i:i+1+digits])
+		}
+		b.WriteRune(rune(code))
+		i += digits
+	}
+	return b.String(), nil
+}
+
+// stripYAMLComment removes a " #" comment from a plain scalar
+func stripYAMLComment(s string) string {
+	if i := strings.Index(s, " #"); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+// yamlFlow parses a flow collection like [a, "b"] or {key: value}
+type yamlFlow struct {
+	s string
+	i int
+}
+
+func (f *yamlFlow) skipSpace() {
+	for f.i < len(f.s) && f.s[f.i] == ' ' {
+		f.i++
+	}
+}
+
+func (f *yamlFlow) parse() (any, error) {
+	f.skipSpace()
+	if f.i == len(f.s) {
+		return nil, errors.New("unterminated flow collection")
+	}
+	switch f.s[f.i] {
+	case '[':
+		f.i++
+		items := []any{}
+		for {
+			f.skipSpace()
+			if f.i < len(f.s) && f.s[f.i] == ']' {
+				f.i++
+				return items, nil
+			}
+			item, err := f.parse()
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			if err := f.separator(']'); err != nil {
+				return nil, err
+			}
+		}
+	case '{':
+		f.i++
+		m := make(map[string]any)
+		for {
+			f.skipSpace()
+			if f.i < len(f.s) && f.s[f.i] == '}' {
+				f.i++
+				return m, nil
+			}
+			key, err := f.parse()
+			if err != nil {
+				return nil, err
+			}
+			f.skipSpace()
+			if f.i == len(f.s) || f.s[f.i] != ':' {
+				return nil, errors.New("expected : in flow mapping")
+			}
+			f.i++
+			value, err := f.parse()
+			if err != nil {
+				return nil, err
+			}
+			m[fmt.Sprint(key)] = value
+			if err := f.separator('}'); err != nil {
+				return nil, err
+			}
+		}
+	case '"', '\'':
+		value, after, err := parseYAMLScalar(f.s[f.i:])
+		f.i = len(f.s) - len(after)
+		return value, err
+	}
+	start := f.i
+	for f.i < len(f.s) && !strings.ContainsRune(",]}", rune(f.s[f.i])) && !(f.s[f.i] == ':' && (f.i+1 == len(f.s) || f.s[f.i+1] == ' ')) {
+		f.i++
+	}
+	return yamlPlainValue(strings.TrimSpace(f.s[start:f.i])), nil
+}
+
+// separator consumes a comma, leaving the closing bracket for the caller
+func (f *yamlFlow) separator(closing byte) error {
+	f.skipSpace()
+	switch {
+	case f.i < len(f.s) && f.s[f.i] == ',':
+		f.i++
+		return nil
+	case f.i < len(f.s) && f.s[f.i] == closing:
+		return nil
+	case f.i == len(f.s):
+		return errors.New("unterminated flow collection")
+	}
+	return fmt.Errorf("expected , or %c in flow collection", closing)
+}
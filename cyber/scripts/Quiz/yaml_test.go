@@ -0,0 +1,133 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestYAMLRoundTrip(t *testing.T) {
+	q := Question{
+		ID:         "q1",
+		Question:   "Which port does SSH use?\n\n```\n$ ssh host\n```\n",
+		Options:    []string{"22", "yes", "- dash", "key: value", "a #hash", "", " padded", "tab\there", "ünïcode", "null"},
+		Answer:     0,
+		Category:   "Networking",
+		Module:     "1.0",
+		Tags:       []string{},
+		Difficulty: "easy",
+		Steps:      []SimStep{{Prompt: "R1#", Accept: []string{"show ip route"}}},
+		Lab: &Lab{
+			Device: "router",
+			Checks: []LabCheck{{Task: "Set the hostname", Line: "hostname R1"}},
+		},
+		Explanation: "no trailing newline\n  indented second line",
+		Translations: map[string]QuestionText{
+			"es": {Question: "¿Qué puerto usa SSH?", Explanation: "  leading spaces\nsecond"},
+		},
+	}
+
+	data, err := marshalYAML(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Question
+	if err := unmarshalYAML(data, &got); err != nil {
+		t.Fatalf("%v\n%s", err, data)
+	}
+	q.Tags = nil // omitted when empty
+	if !reflect.DeepEqual(got, q) {
+		t.Errorf("round trip changed the question:\n got %#v\nwant %#v\n%s", got, q, data)
+	}
+}
+
+func TestYAMLScalarStrings(t *testing.T) {
+	for _, s := range []string{
+		"", "plain", "true", "False", "~", "null", "12", "1.5", "-3", "0x1F", "2024-01-01", "on", "No",
+		"a: b", "ends:", "# comment", "'quoted'", `"quoted"`, "[list]", "{map}", "*alias", "&anchor",
+		"trailing ", "line\nbreak", "line\nbreak\n", "two\n\n", "\ttab", "back\\slash", "\x1b[0m", "|", ">",
+	} {
+		data, err := marshalYAML(map[string]string{"k": s})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got map[string]string
+		if err := unmarshalYAML(data, &got); err != nil {
+			t.Errorf("%q: %v\n%s", s, err, data)
+			continue
+		}
+		if got["k"] != s {
+			t.Errorf("%q read back as %q from\n%s", s, got["k"], data)
+		}
+	}
+}
+
+func TestUnmarshalYAML(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want any
+	}{
+		{"scalars", "a: 1\nb: true\nc: ~\nd: text here\ne: 'it''s'\nf: \"tab\\there\"\n",
+			map[string]any{"a": 1.0, "b": true, "c": nil, "d": "text here", "e": "it's", "f": "tab\there"}},
+		{"comments", "# head\na: x # trailing\n\n  # indented comment\nb: \"#not\" # yes\n",
+			map[string]any{"a": "x", "b": "#not"}},
+		{"document marker", "---\na: 1\n", map[string]any{"a": 1.0}},
+		{"nested", "a:\n  b:\n    c: 1\n  d: 2\n", map[string]any{"a": map[string]any{"b": map[string]any{"c": 1.0}, "d": 2.0}}},
+		{"sequence at key indent", "a:\n- x\n- y\nb: 1\n", map[string]any{"a": []any{"x", "y"}, "b": 1.0}},
+		{"compact mappings in sequence", "- a: 1\n  b: 2\n- c: 3\n",
+			[]any{map[string]any{"a": 1.0, "b": 2.0}, map[string]any{"c": 3.0}}},
+		{"nested sequences", "- - 1\n  - 2\n- 3\n", []any{[]any{1.0, 2.0}, 3.0}},
+		{"url item", "- http://example.com\n", []any{"http://example.com"}},
+		{"flow", "a: [1, \"two\", three]\nb: {x: 1, 'y': [ ]}\nc: []\n",
+			map[string]any{"a": []any{1.0, "two", "three"}, "b": map[string]any{"x": 1.0, "y": []any{}}, "c": []any{}}},
+		{"literal", "a: |\n  one\n    two\n\n  three\nb: 1\n", map[string]any{"a": "one\n  two\n\nthree\n", "b": 1.0}},
+		{"literal strip", "a: |-\n  one\n  two\n", map[string]any{"a": "one\ntwo"}},
+		{"literal keep", "a: |+\n  one\n\n\nb: 1\n", map[string]any{"a": "one\n\n\n", "b": 1.0}},
+		{"literal indicator", "a: |2\n    indented\n  less\n", map[string]any{"a": "  indented\nless\n"}},
+		{"folded", "a: >\n  one\n  two\n\n  three\n", map[string]any{"a": "one two\nthree\n"}},
+		{"sequence literal", "- |\n  x\n  y\n- z\n", []any{"x\ny\n", "z"}},
+		{"crlf", "a: 1\r\nb: 2\r\n", map[string]any{"a": 1.0, "b": 2.0}},
+		{"empty", "# nothing\n", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got any
+			if err := unmarshalYAML([]byte(tt.in), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalYAMLErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"duplicate key", "a: 1\na: 2\n", "line 2: duplicate key"},
+		{"bad indentation", "a: 1\n  b: 2\n", "line 2: unexpected indentation"},
+		{"not a mapping", "a: 1\njust text\n", "line 2: expected key: value"},
+		{"unterminated quote", "a: \"open\n", "line 1: unterminated quoted string"},
+		{"text after quote", "a: \"x\" y\n", "line 1: unexpected text after the closing quote"},
+		{"unterminated flow", "a: [1, 2\n", "line 1: unterminated flow collection"},
+		{"bad escape", "a: \"\\q\"\n", `line 1: bad escape \q`},
+		{"short escape", "a: \"\\u12\"\n", `line 1: bad escape \u`},
+		{"mixed sequence", "- a\nb: 1\n", "line 2: unexpected line"},
+		{"deeper item", "- a\n  - b\n", "line 2: expected - item"},
+		{"tab indentation", "a:\n\tb: 1\n", "line 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got any
+			err := unmarshalYAML([]byte(tt.in), &got)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want %q", err, tt.want)
+			}
+		})
+	}
+}